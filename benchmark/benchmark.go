@@ -2,6 +2,8 @@ package benchmark
 
 import (
 	"fmt"
+	"math"
+	"sort"
 	"sync"
 	"time"
 
@@ -18,6 +20,14 @@ var (
 	RecordMode      bool
 	records         []map[string]float64
 	currentFrameRaw = make(map[string]float64)
+
+	// counterRecords holds the counts of each recorded frame.
+	counterRecords  []map[string]float64
+	currentCounters = make(map[string]float64)
+
+	// positions holds the position along a camera path of each record, if the frames were positioned.
+	positions       []float64
+	currentPosition = -1.0
 )
 
 type rollingAverage struct {
@@ -88,6 +98,108 @@ func RecordFrame() {
 	}
 	records = append(records, newRecord)
 	currentFrameRaw = make(map[string]float64)
+	counterRecords = append(counterRecords, currentCounters)
+	currentCounters = make(map[string]float64)
+	if currentPosition >= 0 {
+		positions = append(positions, currentPosition)
+		currentPosition = -1
+	}
+}
+
+// Count adds n to the named counter of the frame being recorded.
+func Count(name string, n int) {
+	if !RecordMode {
+		return
+	}
+	metricsMu.Lock()
+	defer metricsMu.Unlock()
+	currentCounters[name] += float64(n)
+}
+
+// SetPosition sets the position along a camera path, in seconds from its start, of the frame being recorded.
+func SetPosition(seconds float64) {
+	metricsMu.Lock()
+	defer metricsMu.Unlock()
+	currentPosition = seconds
+}
+
+// pathSegment is the frames recorded over a stretch of a camera path.
+type pathSegment struct {
+	start, end float64
+	phases     map[string][]float64
+}
+
+func (s *pathSegment) average(phase string) float64 {
+	times := s.phases[phase]
+	if len(times) == 0 {
+		return 0
+	}
+	sum := 0.0
+	for _, t := range times {
+		sum += t
+	}
+	return sum / float64(len(times))
+}
+
+// slowestPhase returns the phase other than the whole frame that took longest on average over the segment.
+func (s *pathSegment) slowestPhase() string {
+	slowest := ""
+	for name := range s.phases {
+		if name != "Frame" && (slowest == "" || s.average(name) > s.average(slowest)) {
+			slowest = name
+		}
+	}
+	return slowest
+}
+
+// WritePathSummary prints the frame times over each of count equal stretches of the camera path the frames
+// were recorded along, slowest first.
+func WritePathSummary(count int) {
+	metricsMu.Lock()
+	defer metricsMu.Unlock()
+
+	if len(positions) == 0 || len(positions) != len(records) || count < 1 {
+		return
+	}
+	length := positions[len(positions)-1]
+	for _, p := range positions {
+		length = max(length, p)
+	}
+	segments := make([]*pathSegment, count)
+	for i := range segments {
+		segments[i] = &pathSegment{
+			start:  length * float64(i) / float64(count),
+			end:    length * float64(i+1) / float64(count),
+			phases: make(map[string][]float64),
+		}
+	}
+	for i, rec := range records {
+		s := count - 1
+		if length > 0 {
+			s = min(int(positions[i]/length*float64(count)), count-1)
+		}
+		for k, v := range rec {
+			segments[s].phases[k] = append(segments[s].phases[k], v*1000)
+		}
+	}
+	sort.SliceStable(segments, func(i, j int) bool { return segments[i].average("Frame") > segments[j].average("Frame") })
+
+	fmt.Printf("\n--- Path Segments (%.1f seconds, slowest first) ---\n", length)
+	fmt.Printf("%-17s | %-6s | %-10s | %-10s | %-25s\n", "Path (s)", "Frames", "Avg (ms)", "Max (ms)", "Slowest Phase")
+	fmt.Println("--------------------------------------------------------------------------------")
+	for _, s := range segments {
+		frames := s.phases["Frame"]
+		if len(frames) == 0 {
+			continue
+		}
+		worst := 0.0
+		for _, t := range frames {
+			worst = max(worst, t)
+		}
+		phase := s.slowestPhase()
+		fmt.Printf("%7.2f - %-7.2f | %-6d | %-10.4f | %-10.4f | %s (%.4f ms)\n", s.start, s.end, len(frames), s.average("Frame"), worst, phase, s.average(phase))
+	}
+	fmt.Println("--------------------------------------------------------------------------------")
 }
 
 // WriteSummary prints a statistical summary of all recorded frames and saves the raw data to a CSV.
@@ -127,6 +239,38 @@ func WriteSummary() {
 		fmt.Printf("%-25s | %-10.4f | %-10.4f | %-10.4f\n", name, avg, min, max)
 	}
 	fmt.Println("----------------------------------------------------------------------")
+
+	counters := make(map[string][]float64)
+	for _, rec := range counterRecords {
+		for k, v := range rec {
+			counters[k] = append(counters[k], v)
+		}
+	}
+	if len(counters) == 0 {
+		return
+	}
+	names := make([]string, 0, len(counters))
+	for name := range counters {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	fmt.Printf("%-25s | %-10s | %-10s | %-10s\n", "Counter", "Avg", "Min", "Max")
+	fmt.Println("----------------------------------------------------------------------")
+	for _, name := range names {
+		counts := counters[name]
+		// Frames which didn't count anything count zero.
+		sum, min, max := 0.0, 0.0, 0.0
+		if len(counts) == len(counterRecords) {
+			min = counts[0]
+		}
+		for _, c := range counts {
+			sum += c
+			min = math.Min(min, c)
+			max = math.Max(max, c)
+		}
+		fmt.Printf("%-25s | %-10.1f | %-10.0f | %-10.0f\n", name, sum/float64(len(counterRecords)), min, max)
+	}
+	fmt.Println("----------------------------------------------------------------------")
 }
 
 func init() {
@@ -8,6 +8,7 @@ document.addEventListener("DOMContentLoaded", function() {
     var cameraPositionElement = document.getElementsByClassName('camera_position')[0];
     var cameraForwardElement = document.getElementsByClassName('camera_forward')[0];
     var cameraAngleElement = document.getElementsByClassName('camera_angle')[0];
+    var terrainTrianglesElement = document.getElementsByClassName('terrain_triangles')[0];
     var connectionStatusElement = document.getElementsByClassName('connection_status_img')[0];
     var cascade1Element = document.getElementsByClassName('cascade_1')[0];
     var cascade2Element = document.getElementsByClassName('cascade_2')[0];
@@ -58,6 +59,10 @@ document.addEventListener("DOMContentLoaded", function() {
                 cameraAngleElement.innerHTML = data.value;
             }
 
+            if (data.type == "terrain_triangles") {
+                terrainTrianglesElement.innerHTML = data.value;
+            }
+
             if (data.type == "timer_fps") {
                 line.append(new Date().getTime(), data.value);
             }
@@ -0,0 +1,469 @@
+package gfx
+
+import (
+	"math"
+	"sort"
+
+	"github.com/go-gl/mathgl/mgl32"
+)
+
+// nullNode marks the absence of a node in an AABBTree.
+const nullNode = -1
+
+// AABBTree is a dynamic bounding volume hierarchy of renderables, for finding those within a volume without
+// testing every one. Each renderable's leaf keeps a box a little larger than its bounds, so renderables which
+// move only a little don't restructure the tree.
+type AABBTree struct {
+	// Margin is how far past a renderable's bounds the box of its leaf extends.
+	Margin float32
+
+	nodes  []treeNode
+	root   int32
+	free   int32
+	leaves map[Renderable]int32
+	// generation is the count of Syncs, for telling which leaves the last one didn't see.
+	generation uint32
+}
+
+// treeNode is a node of an AABBTree. Leaves hold a renderable and have no children.
+type treeNode struct {
+	// min and max bound every leaf below the node, and are a margin larger than a leaf's renderable.
+	min, max mgl32.Vec3
+	// parent links free nodes together while they are unused.
+	parent, left, right int32
+	height              int32
+
+	renderable Renderable
+	// boundsMin and boundsMax are the renderable's bounds when it was last inserted or updated.
+	boundsMin, boundsMax mgl32.Vec3
+	generation           uint32
+}
+
+func (n *treeNode) isLeaf() bool {
+	return n.left == nullNode
+}
+
+// RayHit is a renderable whose bounds a ray passes through, at the distance along the ray it enters them.
+type RayHit struct {
+	Renderable Renderable
+	Distance   float32
+}
+
+// NewAABBTree returns an empty tree whose leaves extend margin past their renderables' bounds.
+func NewAABBTree(margin float32) *AABBTree {
+	return &AABBTree{Margin: margin, root: nullNode, free: nullNode, leaves: make(map[Renderable]int32)}
+}
+
+// Len returns the number of renderables in the tree.
+func (t *AABBTree) Len() int {
+	return len(t.leaves)
+}
+
+// Contains reports whether r is in the tree.
+func (t *AABBTree) Contains(r Renderable) bool {
+	_, ok := t.leaves[r]
+	return ok
+}
+
+// Insert adds r to the tree, or updates it if it is already in it.
+func (t *AABBTree) Insert(r Renderable) {
+	if _, ok := t.leaves[r]; ok {
+		t.Update(r)
+		return
+	}
+	leaf := t.allocate()
+	n := &t.nodes[leaf]
+	n.renderable = r
+	n.boundsMin, n.boundsMax = r.GetBounds()
+	n.min, n.max = t.fatten(n.boundsMin, n.boundsMax)
+	n.generation = t.generation
+	t.leaves[r] = leaf
+	t.insertLeaf(leaf)
+}
+
+// Remove takes r out of the tree, and reports whether it was in it.
+func (t *AABBTree) Remove(r Renderable) bool {
+	leaf, ok := t.leaves[r]
+	if !ok {
+		return false
+	}
+	delete(t.leaves, r)
+	t.removeLeaf(leaf)
+	t.release(leaf)
+	return true
+}
+
+// Update refits the tree to the current bounds of r. It reports whether r had moved out of its leaf's box,
+// and so had to be reinserted.
+func (t *AABBTree) Update(r Renderable) bool {
+	leaf, ok := t.leaves[r]
+	if !ok {
+		return false
+	}
+	n := &t.nodes[leaf]
+	n.generation = t.generation
+	min, max := r.GetBounds()
+	if min == n.boundsMin && max == n.boundsMax {
+		return false
+	}
+	n.boundsMin, n.boundsMax = min, max
+	if contains(n.min, n.max, min, max) {
+		return false
+	}
+	t.removeLeaf(leaf)
+	n = &t.nodes[leaf]
+	n.min, n.max = t.fatten(min, max)
+	t.insertLeaf(leaf)
+	return true
+}
+
+// Refit updates every renderable in the tree to its current bounds.
+func (t *AABBTree) Refit() {
+	for r := range t.leaves {
+		t.Update(r)
+	}
+}
+
+// Sync makes the tree hold exactly renderables, inserting those it doesn't hold yet, updating those it does
+// and removing the rest.
+func (t *AABBTree) Sync(renderables []Renderable) {
+	t.generation++
+	for _, r := range renderables {
+		t.Insert(r)
+	}
+	for r, leaf := range t.leaves {
+		if t.nodes[leaf].generation != t.generation {
+			t.Remove(r)
+		}
+	}
+}
+
+// QueryFrustum appends the renderables whose bounds are at least partly within f to results.
+func (t *AABBTree) QueryFrustum(f *Frustum, results []Renderable) []Renderable {
+	if t.root == nullNode {
+		return results
+	}
+	stack := make([]int32, 1, 64)
+	stack[0] = t.root
+	for len(stack) > 0 {
+		i := stack[len(stack)-1]
+		stack = stack[:len(stack)-1]
+		n := &t.nodes[i]
+		switch {
+		case n.isLeaf():
+			if f.IsBoxIn(n.boundsMin, n.boundsMax) {
+				results = append(results, n.renderable)
+			}
+		case f.IsBoxInside(n.min, n.max):
+			// Everything below a node wholly within the frustum is too.
+			results = t.appendLeaves(i, results)
+		case f.IsBoxIn(n.min, n.max):
+			stack = append(stack, n.left, n.right)
+		}
+	}
+	return results
+}
+
+// appendLeaves appends the renderable of every leaf below node i to results.
+func (t *AABBTree) appendLeaves(i int32, results []Renderable) []Renderable {
+	n := &t.nodes[i]
+	if n.isLeaf() {
+		return append(results, n.renderable)
+	}
+	return t.appendLeaves(n.right, t.appendLeaves(n.left, results))
+}
+
+// QueryBox appends the renderables whose bounds overlap the box from min to max to results.
+func (t *AABBTree) QueryBox(min, max mgl32.Vec3, results []Renderable) []Renderable {
+	return t.query(results, func(n *treeNode, leaf bool) bool {
+		if leaf {
+			return overlaps(n.boundsMin, n.boundsMax, min, max)
+		}
+		return overlaps(n.min, n.max, min, max)
+	})
+}
+
+// QuerySphere appends the renderables whose bounds overlap the sphere to results.
+func (t *AABBTree) QuerySphere(center mgl32.Vec3, radius float32, results []Renderable) []Renderable {
+	return t.query(results, func(n *treeNode, leaf bool) bool {
+		if leaf {
+			return boxDistanceSqr(n.boundsMin, n.boundsMax, center) <= radius*radius
+		}
+		return boxDistanceSqr(n.min, n.max, center) <= radius*radius
+	})
+}
+
+// QueryRay appends the renderables whose bounds the ray from origin along direction passes through within
+// maxDistance to results, nearest first. Distances are in lengths of direction.
+func (t *AABBTree) QueryRay(origin, direction mgl32.Vec3, maxDistance float32, results []RayHit) []RayHit {
+	first := len(results)
+	inverse := mgl32.Vec3{1 / direction.X(), 1 / direction.Y(), 1 / direction.Z()}
+	t.query(nil, func(n *treeNode, leaf bool) bool {
+		if !leaf {
+			_, hit := rayBox(origin, inverse, n.min, n.max, maxDistance)
+			return hit
+		}
+		if distance, hit := rayBox(origin, inverse, n.boundsMin, n.boundsMax, maxDistance); hit {
+			results = append(results, RayHit{n.renderable, distance})
+		}
+		return false
+	})
+	hits := results[first:]
+	sort.Slice(hits, func(i, j int) bool { return hits[i].Distance < hits[j].Distance })
+	return results
+}
+
+// query appends the renderable of every leaf within to results, descending only into nodes within. within is
+// told whether the node is a leaf, whose renderable's own bounds should be tested.
+func (t *AABBTree) query(results []Renderable, within func(n *treeNode, leaf bool) bool) []Renderable {
+	if t.root == nullNode {
+		return results
+	}
+	stack := make([]int32, 1, 64)
+	stack[0] = t.root
+	for len(stack) > 0 {
+		i := stack[len(stack)-1]
+		stack = stack[:len(stack)-1]
+		n := &t.nodes[i]
+		if n.isLeaf() {
+			if within(n, true) {
+				results = append(results, n.renderable)
+			}
+			continue
+		}
+		if within(n, false) {
+			stack = append(stack, n.left, n.right)
+		}
+	}
+	return results
+}
+
+// fatten returns the box from min to max grown by the tree's margin.
+func (t *AABBTree) fatten(min, max mgl32.Vec3) (mgl32.Vec3, mgl32.Vec3) {
+	margin := mgl32.Vec3{t.Margin, t.Margin, t.Margin}
+	return min.Sub(margin), max.Add(margin)
+}
+
+// allocate returns an unused node, reset to a leaf.
+func (t *AABBTree) allocate() int32 {
+	if t.free == nullNode {
+		t.nodes = append(t.nodes, treeNode{})
+		t.free = int32(len(t.nodes) - 1)
+		t.nodes[t.free].parent = nullNode
+	}
+	i := t.free
+	t.free = t.nodes[i].parent
+	t.nodes[i] = treeNode{parent: nullNode, left: nullNode, right: nullNode}
+	return i
+}
+
+// release returns node i to the free list.
+func (t *AABBTree) release(i int32) {
+	t.nodes[i] = treeNode{parent: t.free, left: nullNode, right: nullNode}
+	t.free = i
+}
+
+// insertLeaf links leaf into the tree beside the node it grows the tree's surface area least to pair it with.
+func (t *AABBTree) insertLeaf(leaf int32) {
+	if t.root == nullNode {
+		t.root = leaf
+		t.nodes[leaf].parent = nullNode
+		return
+	}
+
+	min, max := t.nodes[leaf].min, t.nodes[leaf].max
+	sibling := t.root
+	for !t.nodes[sibling].isLeaf() {
+		n := &t.nodes[sibling]
+		area := surfaceArea(n.min, n.max)
+		combined := surfaceArea(union(n.min, n.max, min, max))
+		// Pairing the leaf with this node makes a new parent, and grows every ancestor.
+		cost := 2 * combined
+		inherited := 2 * (combined - area)
+		left := t.descentCost(n.left, min, max) + inherited
+		right := t.descentCost(n.right, min, max) + inherited
+		if cost < left && cost < right {
+			break
+		}
+		if left < right {
+			sibling = n.left
+		} else {
+			sibling = n.right
+		}
+	}
+
+	oldParent := t.nodes[sibling].parent
+	parent := t.allocate()
+	p := &t.nodes[parent]
+	p.parent = oldParent
+	p.min, p.max = union(min, max, t.nodes[sibling].min, t.nodes[sibling].max)
+	p.height = t.nodes[sibling].height + 1
+	p.left, p.right = sibling, leaf
+	t.nodes[sibling].parent = parent
+	t.nodes[leaf].parent = parent
+	t.replaceChild(oldParent, sibling, parent)
+
+	t.refitUpFrom(t.nodes[leaf].parent)
+}
+
+// descentCost returns the cost of pairing the box from min to max with node i, or with a node below it.
+func (t *AABBTree) descentCost(i int32, min, max mgl32.Vec3) float32 {
+	n := &t.nodes[i]
+	combined := surfaceArea(union(n.min, n.max, min, max))
+	if n.isLeaf() {
+		return combined
+	}
+	return combined - surfaceArea(n.min, n.max)
+}
+
+// removeLeaf unlinks leaf from the tree, replacing its parent with its sibling.
+func (t *AABBTree) removeLeaf(leaf int32) {
+	if leaf == t.root {
+		t.root = nullNode
+		return
+	}
+	parent := t.nodes[leaf].parent
+	grandParent := t.nodes[parent].parent
+	sibling := t.nodes[parent].left
+	if sibling == leaf {
+		sibling = t.nodes[parent].right
+	}
+	t.nodes[sibling].parent = grandParent
+	t.replaceChild(grandParent, parent, sibling)
+	t.release(parent)
+	t.refitUpFrom(grandParent)
+}
+
+// replaceChild points parent, or the root if parent is null, at child instead of old.
+func (t *AABBTree) replaceChild(parent, old, child int32) {
+	switch {
+	case parent == nullNode:
+		t.root = child
+	case t.nodes[parent].left == old:
+		t.nodes[parent].left = child
+	default:
+		t.nodes[parent].right = child
+	}
+}
+
+// refitUpFrom rebalances node i and every ancestor of it, and refits their boxes and heights.
+func (t *AABBTree) refitUpFrom(i int32) {
+	for i != nullNode {
+		i = t.balance(i)
+		n := &t.nodes[i]
+		left, right := &t.nodes[n.left], &t.nodes[n.right]
+		n.height = 1 + max(left.height, right.height)
+		n.min, n.max = union(left.min, left.max, right.min, right.max)
+		i = n.parent
+	}
+}
+
+// balance rotates the taller child of node a above it if its children's heights differ by more than one, and
+// returns the node now in a's place.
+func (t *AABBTree) balance(a int32) int32 {
+	A := &t.nodes[a]
+	if A.isLeaf() || A.height < 2 {
+		return a
+	}
+	b, c := A.left, A.right
+	switch difference := t.nodes[c].height - t.nodes[b].height; {
+	case difference > 1:
+		return t.rotate(a, c, false)
+	case difference < -1:
+		return t.rotate(a, b, true)
+	}
+	return a
+}
+
+// rotate lifts child up above a, which takes the shorter of child's children in its place. left tells whether
+// child is a's left child.
+func (t *AABBTree) rotate(a, child int32, left bool) int32 {
+	A, C := &t.nodes[a], &t.nodes[child]
+	f, g := C.left, C.right
+	C.left = a
+	C.parent = A.parent
+	A.parent = child
+	t.replaceChild(C.parent, a, child)
+
+	// The taller of child's children stays below it.
+	keep, move := f, g
+	if t.nodes[f].height <= t.nodes[g].height {
+		keep, move = g, f
+	}
+	C.right = keep
+	if left {
+		A.left = move
+	} else {
+		A.right = move
+	}
+	t.nodes[move].parent = a
+
+	l, r := &t.nodes[A.left], &t.nodes[A.right]
+	A.min, A.max = union(l.min, l.max, r.min, r.max)
+	A.height = 1 + max(l.height, r.height)
+	K := &t.nodes[keep]
+	C.min, C.max = union(A.min, A.max, K.min, K.max)
+	C.height = 1 + max(A.height, K.height)
+	return child
+}
+
+// union returns the box bounding both boxes.
+func union(aMin, aMax, bMin, bMax mgl32.Vec3) (mgl32.Vec3, mgl32.Vec3) {
+	return mgl32.Vec3{min(aMin.X(), bMin.X()), min(aMin.Y(), bMin.Y()), min(aMin.Z(), bMin.Z())},
+		mgl32.Vec3{max(aMax.X(), bMax.X()), max(aMax.Y(), bMax.Y()), max(aMax.Z(), bMax.Z())}
+}
+
+// surfaceArea returns the surface area of the box, the cost of testing it in a query.
+func surfaceArea(min, max mgl32.Vec3) float32 {
+	d := max.Sub(min)
+	return 2 * (d.X()*d.Y() + d.Y()*d.Z() + d.Z()*d.X())
+}
+
+// contains reports whether the outer box wholly contains the inner one.
+func contains(outerMin, outerMax, innerMin, innerMax mgl32.Vec3) bool {
+	return outerMin.X() <= innerMin.X() && outerMin.Y() <= innerMin.Y() && outerMin.Z() <= innerMin.Z() &&
+		innerMax.X() <= outerMax.X() && innerMax.Y() <= outerMax.Y() && innerMax.Z() <= outerMax.Z()
+}
+
+// overlaps reports whether the boxes overlap.
+func overlaps(aMin, aMax, bMin, bMax mgl32.Vec3) bool {
+	return aMin.X() <= bMax.X() && bMin.X() <= aMax.X() &&
+		aMin.Y() <= bMax.Y() && bMin.Y() <= aMax.Y() &&
+		aMin.Z() <= bMax.Z() && bMin.Z() <= aMax.Z()
+}
+
+// boxDistanceSqr returns the squared distance from p to the nearest point of the box.
+func boxDistanceSqr(min, max, p mgl32.Vec3) float32 {
+	var d float32
+	for i := 0; i < 3; i++ {
+		if p[i] < min[i] {
+			d += (min[i] - p[i]) * (min[i] - p[i])
+		} else if p[i] > max[i] {
+			d += (p[i] - max[i]) * (p[i] - max[i])
+		}
+	}
+	return d
+}
+
+// rayBox returns the distance along the ray from origin, with the reciprocal of its direction inverse, at
+// which it enters the box, and whether it does so within maxDistance. A ray starting inside enters at zero.
+func rayBox(origin, inverse, boxMin, boxMax mgl32.Vec3, maxDistance float32) (float32, bool) {
+	near, far := float32(0), maxDistance
+	for i := 0; i < 3; i++ {
+		t0, t1 := (boxMin[i]-origin[i])*inverse[i], (boxMax[i]-origin[i])*inverse[i]
+		if t0 > t1 {
+			t0, t1 = t1, t0
+		}
+		// A ray parallel to a slab, starting on its edge, makes NaN; it counts as inside.
+		if !math.IsNaN(float64(t0)) {
+			near = max(near, t0)
+		}
+		if !math.IsNaN(float64(t1)) {
+			far = min(far, t1)
+		}
+		if near > far {
+			return 0, false
+		}
+	}
+	return near, true
+}
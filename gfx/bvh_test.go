@@ -0,0 +1,262 @@
+package gfx
+
+import (
+	"math/rand"
+	"sort"
+	"testing"
+
+	"github.com/brandonnelson3/GoRender/gfx/shaders"
+
+	"github.com/go-gl/mathgl/mgl32"
+	"github.com/stretchr/testify/assert"
+)
+
+// boxRenderable is a Renderable which is only a box, for testing without GL.
+type boxRenderable struct {
+	id       int
+	min, max mgl32.Vec3
+}
+
+func (b *boxRenderable) Render(*shaders.ColorShader, *Frustum)                           {}
+func (b *boxRenderable) RenderDepth(*shaders.DepthShader, *Frustum)                      {}
+func (b *boxRenderable) RenderPointLightDepth(*shaders.PointLightShadowShader, *Frustum) {}
+func (b *boxRenderable) GetBounds() (mgl32.Vec3, mgl32.Vec3)                             { return b.min, b.max }
+
+func randomBoxes(r *rand.Rand, n int) []Renderable {
+	boxes := make([]Renderable, n)
+	for i := range boxes {
+		min := mgl32.Vec3{r.Float32()*1000 - 500, r.Float32()*100 - 50, r.Float32()*1000 - 500}
+		size := mgl32.Vec3{r.Float32()*10 + .1, r.Float32()*10 + .1, r.Float32()*10 + .1}
+		boxes[i] = &boxRenderable{id: i, min: min, max: min.Add(size)}
+	}
+	return boxes
+}
+
+func ids(renderables []Renderable) []int {
+	ids := make([]int, len(renderables))
+	for i, r := range renderables {
+		ids[i] = r.(*boxRenderable).id
+	}
+	sort.Ints(ids)
+	return ids
+}
+
+// linearScan returns the renderables within, testing every one.
+func linearScan(renderables []Renderable, within func(min, max mgl32.Vec3) bool) []Renderable {
+	var results []Renderable
+	for _, r := range renderables {
+		if within(r.GetBounds()) {
+			results = append(results, r)
+		}
+	}
+	return results
+}
+
+// validate checks every node of the tree is linked to its parent, bounds its children and has the right height.
+func validate(t *testing.T, tree *AABBTree) {
+	t.Helper()
+	if tree.root == nullNode {
+		assert.Zero(t, tree.Len())
+		return
+	}
+	assert.Equal(t, int32(nullNode), tree.nodes[tree.root].parent)
+	leaves := 0
+	var walk func(i int32) int32
+	walk = func(i int32) int32 {
+		n := &tree.nodes[i]
+		if n.isLeaf() {
+			leaves++
+			assert.Equal(t, i, tree.leaves[n.renderable])
+			assert.True(t, contains(n.min, n.max, n.boundsMin, n.boundsMax))
+			return 0
+		}
+		for _, child := range []int32{n.left, n.right} {
+			assert.Equal(t, i, tree.nodes[child].parent)
+			assert.True(t, contains(n.min, n.max, tree.nodes[child].min, tree.nodes[child].max))
+		}
+		height := 1 + max(walk(n.left), walk(n.right))
+		assert.Equal(t, height, n.height)
+		return height
+	}
+	walk(tree.root)
+	assert.Equal(t, tree.Len(), leaves)
+}
+
+func testFrustum() *Frustum {
+	projection := mgl32.Perspective(mgl32.DegToRad(45), 16.0/9, .1, 400)
+	view := mgl32.LookAtV(mgl32.Vec3{0, 20, 0}, mgl32.Vec3{100, 0, 100}, mgl32.Vec3{0, 1, 0})
+	return NewFrustumFromMatrix(projection.Mul4(view))
+}
+
+func TestAABBTreeQueriesMatchLinearScan(t *testing.T) {
+	r := rand.New(rand.NewSource(1))
+	boxes := randomBoxes(r, 2000)
+	tree := NewAABBTree(1)
+	for _, b := range boxes {
+		tree.Insert(b)
+	}
+	validate(t, tree)
+	assert.Equal(t, len(boxes), tree.Len())
+
+	f := testFrustum()
+	assert.Equal(t, ids(linearScan(boxes, f.IsBoxIn)), ids(tree.QueryFrustum(f, nil)))
+
+	queryMin, queryMax := mgl32.Vec3{-100, -10, -100}, mgl32.Vec3{50, 10, 80}
+	assert.Equal(t, ids(linearScan(boxes, func(min, max mgl32.Vec3) bool {
+		return overlaps(min, max, queryMin, queryMax)
+	})), ids(tree.QueryBox(queryMin, queryMax, nil)))
+
+	center, radius := mgl32.Vec3{30, 0, -60}, float32(75)
+	assert.Equal(t, ids(linearScan(boxes, func(min, max mgl32.Vec3) bool {
+		return boxDistanceSqr(min, max, center) <= radius*radius
+	})), ids(tree.QuerySphere(center, radius, nil)))
+}
+
+func TestAABBTreeQueryRay(t *testing.T) {
+	near := &boxRenderable{id: 0, min: mgl32.Vec3{9, -1, -1}, max: mgl32.Vec3{11, 1, 1}}
+	far := &boxRenderable{id: 1, min: mgl32.Vec3{29, -1, -1}, max: mgl32.Vec3{31, 1, 1}}
+	beside := &boxRenderable{id: 2, min: mgl32.Vec3{19, 5, -1}, max: mgl32.Vec3{21, 7, 1}}
+	behind := &boxRenderable{id: 3, min: mgl32.Vec3{-11, -1, -1}, max: mgl32.Vec3{-9, 1, 1}}
+	tree := NewAABBTree(0)
+	for _, b := range []Renderable{far, beside, behind, near} {
+		tree.Insert(b)
+	}
+
+	hits := tree.QueryRay(mgl32.Vec3{}, mgl32.Vec3{1, 0, 0}, 100, nil)
+	if assert.Len(t, hits, 2) {
+		assert.Equal(t, Renderable(near), hits[0].Renderable)
+		assert.InDelta(t, 9, hits[0].Distance, 1e-5)
+		assert.Equal(t, Renderable(far), hits[1].Renderable)
+		assert.InDelta(t, 29, hits[1].Distance, 1e-5)
+	}
+	assert.Len(t, tree.QueryRay(mgl32.Vec3{}, mgl32.Vec3{1, 0, 0}, 20, nil), 1)
+
+	// A ray starting inside a box hits it at once.
+	hits = tree.QueryRay(mgl32.Vec3{10, 0, 0}, mgl32.Vec3{0, 1, 0}, 100, nil)
+	if assert.Len(t, hits, 1) {
+		assert.Equal(t, Renderable(near), hits[0].Renderable)
+		assert.Zero(t, hits[0].Distance)
+	}
+}
+
+func TestAABBTreeUpdate(t *testing.T) {
+	r := rand.New(rand.NewSource(2))
+	boxes := randomBoxes(r, 500)
+	tree := NewAABBTree(2)
+	for _, b := range boxes {
+		tree.Insert(b)
+	}
+
+	// Moving within the margin keeps the leaf where it is.
+	b := boxes[0].(*boxRenderable)
+	b.min, b.max = b.min.Add(mgl32.Vec3{1, 0, 0}), b.max.Add(mgl32.Vec3{1, 0, 0})
+	assert.False(t, tree.Update(b))
+	b.min, b.max = b.min.Add(mgl32.Vec3{50, 0, 0}), b.max.Add(mgl32.Vec3{50, 0, 0})
+	assert.True(t, tree.Update(b))
+
+	for _, b := range boxes {
+		b := b.(*boxRenderable)
+		move := mgl32.Vec3{r.Float32()*20 - 10, 0, r.Float32()*20 - 10}
+		b.min, b.max = b.min.Add(move), b.max.Add(move)
+	}
+	tree.Refit()
+	validate(t, tree)
+	f := testFrustum()
+	assert.Equal(t, ids(linearScan(boxes, f.IsBoxIn)), ids(tree.QueryFrustum(f, nil)))
+}
+
+func TestAABBTreeSync(t *testing.T) {
+	r := rand.New(rand.NewSource(3))
+	boxes := randomBoxes(r, 300)
+	tree := NewAABBTree(1)
+	tree.Sync(boxes)
+	assert.Equal(t, 300, tree.Len())
+
+	kept := boxes[100:]
+	tree.Sync(kept)
+	validate(t, tree)
+	assert.Equal(t, 200, tree.Len())
+	assert.False(t, tree.Remove(boxes[0]))
+	f := testFrustum()
+	assert.Equal(t, ids(linearScan(kept, f.IsBoxIn)), ids(tree.QueryFrustum(f, nil)))
+
+	for _, b := range kept {
+		assert.True(t, tree.Remove(b))
+	}
+	validate(t, tree)
+	assert.Empty(t, tree.QueryFrustum(f, nil))
+}
+
+func BenchmarkAABBTreeQueryFrustum(b *testing.B) {
+	tree := NewAABBTree(1)
+	tree.Sync(randomBoxes(rand.New(rand.NewSource(4)), 10000))
+	f := testFrustum()
+	var results []Renderable
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		results = tree.QueryFrustum(f, results[:0])
+	}
+}
+
+func BenchmarkLinearQueryFrustum(b *testing.B) {
+	boxes := randomBoxes(rand.New(rand.NewSource(4)), 10000)
+	f := testFrustum()
+	var results []Renderable
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		results = results[:0]
+		for _, r := range boxes {
+			if f.IsBoxIn(r.GetBounds()) {
+				results = append(results, r)
+			}
+		}
+	}
+}
+
+func BenchmarkAABBTreeQuerySphere(b *testing.B) {
+	tree := NewAABBTree(1)
+	tree.Sync(randomBoxes(rand.New(rand.NewSource(4)), 10000))
+	var results []Renderable
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		results = tree.QuerySphere(mgl32.Vec3{}, 30, results[:0])
+	}
+}
+
+func BenchmarkAABBTreeQueryRay(b *testing.B) {
+	tree := NewAABBTree(1)
+	tree.Sync(randomBoxes(rand.New(rand.NewSource(4)), 10000))
+	var hits []RayHit
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		hits = tree.QueryRay(mgl32.Vec3{0, 0, 0}, mgl32.Vec3{1, 0, 1}.Normalize(), 1000, hits[:0])
+	}
+}
+
+func BenchmarkAABBTreeSyncMoving(b *testing.B) {
+	r := rand.New(rand.NewSource(5))
+	boxes := randomBoxes(r, 10000)
+	tree := NewAABBTree(1)
+	tree.Sync(boxes)
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		// A tenth of the renderables move every frame.
+		for j := 0; j < len(boxes)/10; j++ {
+			box := boxes[r.Intn(len(boxes))].(*boxRenderable)
+			move := mgl32.Vec3{r.Float32()*4 - 2, 0, r.Float32()*4 - 2}
+			box.min, box.max = box.min.Add(move), box.max.Add(move)
+		}
+		tree.Sync(boxes)
+	}
+}
+
+func BenchmarkAABBTreeInsert(b *testing.B) {
+	boxes := randomBoxes(rand.New(rand.NewSource(6)), 10000)
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		tree := NewAABBTree(1)
+		for _, r := range boxes {
+			tree.Insert(r)
+		}
+	}
+}
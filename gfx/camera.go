@@ -20,15 +20,19 @@ const (
 )
 
 var (
-	// FirstPerson is the "main" camera in the scene.
-	FirstPerson *camera
-	// ThirdPerson is a "secondary" camera in the scene, mainly for observing the world around the FirstPerson camera.
-	ThirdPerson *camera
-	// ActiveCamera is either FirstPerson or ThirdPerson, depending on which is currently being used for rendering.
-	ActiveCamera *camera
-
-	// FirstPersonCameraRenderable is an optional model rendered at the FirstPerson
-	// camera's position whenever the ThirdPerson view (and thus the frustum) is
+	// FirstPerson is the "main" camera in the scene, registered as "first_person".
+	FirstPerson *Camera
+	// ThirdPerson is a "secondary" camera in the scene, mainly for observing the world around the FirstPerson camera,
+	// registered as "third_person".
+	ThirdPerson *Camera
+	// ActiveCamera is the camera currently being used for rendering.
+	ActiveCamera *Camera
+	// CullingCamera is the camera shadow cascades are fit to and terrain detail follows. Its frustum can be
+	// drawn while viewing it from another camera.
+	CullingCamera *Camera
+
+	// FirstPersonCameraRenderable is an optional model rendered at the CullingCamera's
+	// position whenever another camera's view (and thus the frustum) is
 	// active. Set this once after InitCameras() to enable it.
 	FirstPersonCameraRenderable *VAORenderable
 
@@ -41,17 +45,51 @@ var (
 	cascadeColors = []mgl32.Vec3{redColor, greenColor, blueColor, yellowColor, cyanColor}
 
 	whiteColor = mgl32.Vec3{1, 1, 1}
+
+	// heldButtons holds whether each mouse button is held.
+	heldButtons [glfw.MouseButtonLast + 1]bool
+
+	// frustumShellTexture is the texture the culling camera's frustum is drawn with.
+	frustumShellTexture uint32
 )
 
-type camera struct {
+// Viewer is anything the scene can be viewed from.
+type Viewer interface {
+	GetView() mgl32.Mat4
+	GetProjection() mgl32.Mat4
+	GetPosition() mgl32.Vec3
+	GetForward() mgl32.Vec3
+}
+
+// Projection is how a camera projects the scene onto the screen.
+type Projection struct {
+	// FieldOfView is the vertical field of view of a perspective projection in degrees.
+	FieldOfView float32
+	Near, Far   float32
+	// Orthographic projects the scene without perspective, fitting OrthographicHeight units to the height of the screen.
+	Orthographic       bool
+	OrthographicHeight float32
+}
+
+// Camera is a point of view the scene can be rendered from.
+type Camera struct {
+	name       string
+	projection Projection
+	// viewAspect is the aspect ratio of the viewport the camera was last rendered into, or zero if it was
+	// rendered into the whole window.
+	viewAspect      float32
 	position        mgl32.Vec3
-	direction       mgl32.Vec3
 	horizontalAngle float32
 	verticalAngle   float32
-	sensitivity     float32
-	speed           float32
+	roll            float32
 	shadowMatrices  [NumberOfCascades]mgl32.Mat4
 
+	// controllers are the controllers the camera can be switched between, and controller the one moving it.
+	controllers []CameraController
+	controller  int
+	// input is the input gathered for the camera since it was last updated.
+	input CameraInput
+
 	// Frustum rendering done internally without Renderable.
 	vao, vbo                       uint32
 	shellVAO, shellVBO             uint32
@@ -69,8 +107,8 @@ type camera struct {
 	renderCascadeShadowFrustumEyes bool
 }
 
-// InitCameras instantiates new cameras into the package first and third person package variables.
-func InitCameras() {
+// NewCamera returns a camera named name at pose, projecting with projection. Must be called after InitRenderer.
+func NewCamera(name string, pose CameraPose, projection Projection) *Camera {
 	var vao uint32
 	gl.GenVertexArrays(1, &vao)
 	gl.BindVertexArray(vao)
@@ -89,107 +127,90 @@ func InitCameras() {
 
 	gl.BindVertexArray(0)
 
-	shellTexture, err := LoadTexture("assets/crosshatch.png")
-	if err != nil {
-		fmt.Printf("Warning: failed to load frustum crosshatch texture: %v\n", err)
+	c := &Camera{
+		name:         name,
+		projection:   projection,
+		controllers:  newCameraControllers(),
+		vao:          vao,
+		vbo:          vbo,
+		shellVAO:     shellVAO,
+		shellVBO:     shellVBO,
+		shellTexture: frustumShellTexture,
 	}
+	c.setPose(c.Controller().Attach(pose))
+	return c
+}
 
-	FirstPerson = &camera{
-		position:                       mgl32.Vec3{0, 40, 0},
-		horizontalAngle:                0,
-		verticalAngle:                  0,
-		sensitivity:                    0.001,
-		speed:                          20,
-		vao:                            vao,
-		vbo:                            vbo,
-		shellVAO:                       shellVAO,
-		shellVBO:                       shellVBO,
-		shellTexture:                   shellTexture,
-		renderCascade1:                 false,
-		renderCascade2:                 false,
-		renderCascade3:                 false,
-		renderCascade4:                 false,
-		renderCascadeCenters:           false,
-		renderFrustum:                  false,
-		renderCascade1ShadowFrustum:    false,
-		renderCascade2ShadowFrustum:    false,
-		renderCascade3ShadowFrustum:    false,
-		renderCascade4ShadowFrustum:    false,
-		renderCascadeShadowFrustumEyes: false,
+// InitCameras instantiates and registers the first and third person cameras.
+func InitCameras() {
+	var err error
+	if frustumShellTexture, err = LoadTexture("assets/crosshatch.png"); err != nil {
+		fmt.Printf("Warning: failed to load frustum crosshatch texture: %v\n", err)
 	}
 
-	ThirdPerson = &camera{
-		position:        mgl32.Vec3{-10, 60, -10},
-		horizontalAngle: -pi4,
-		verticalAngle:   -pi4,
-		sensitivity:     0.001,
-		speed:           20,
+	FirstPerson = NewCamera("first_person", CameraPose{Position: mgl32.Vec3{0, 40, 0}}, Window.DefaultProjection())
+	ThirdPerson = NewCamera("third_person", CameraPose{Position: mgl32.Vec3{-10, 60, -10}, HorizontalAngle: -pi4, VerticalAngle: -pi4}, Window.DefaultProjection())
+	for _, c := range []*Camera{FirstPerson, ThirdPerson} {
+		if err := RegisterCamera(c); err != nil {
+			panic(err)
+		}
 	}
 	ActiveCamera = FirstPerson
-	messagebus.RegisterType("key", func(m *messagebus.Message) {
-		direction := mgl32.Vec3{0, 0, 0}
-		pressedKeys := m.Data1.([]glfw.Key)
-		for _, key := range pressedKeys {
-			switch key {
-			case glfw.KeyW:
-				direction = direction.Add(ActiveCamera.GetForward())
-			case glfw.KeyS:
-				direction = direction.Sub(ActiveCamera.GetForward())
-			case glfw.KeyD:
-				direction = direction.Add(ActiveCamera.GetRight())
-			case glfw.KeyA:
-				direction = direction.Sub(ActiveCamera.GetRight())
-			}
-		}
-		ActiveCamera.direction = direction
+	CullingCamera = FirstPerson
+	messagebus.RegisterType("action", func(m *messagebus.Message) {
+		in := &ActiveCamera.input
+		// Gamepad sticks push the camera part of the way.
+		in.Move = in.Move.Add(mgl32.Vec3{
+			input.Value(input.MoveRight) - input.Value(input.MoveLeft),
+			input.Value(input.MoveUp) - input.Value(input.MoveDown),
+			input.Value(input.MoveForward) - input.Value(input.MoveBack),
+		})
+		in.Roll += input.Value(input.RollRight) - input.Value(input.RollLeft)
+		in.Sprint = in.Sprint || input.Held(input.Sprint)
+		in.LookRate = in.LookRate.Add(mgl32.Vec2{
+			input.Value(input.LookRight) - input.Value(input.LookLeft),
+			input.Value(input.LookDown) - input.Value(input.LookUp),
+		}.Mul(gamepadLookSpeed))
 	})
-	messagebus.RegisterType("key", func(m *messagebus.Message) {
-		pressedKeysThisFrame := m.Data2.([]glfw.Key)
-		for _, key := range pressedKeysThisFrame {
-			switch key {
-			case glfw.KeyC:
-				if ActiveCamera == FirstPerson {
-					ActiveCamera = ThirdPerson
-				} else {
-					ActiveCamera = FirstPerson
-				}
-			case glfw.KeyKP0:
-				FirstPerson.renderFrustum = !FirstPerson.renderFrustum
-			case glfw.KeyKP1:
-				FirstPerson.renderCascade1 = !FirstPerson.renderCascade1
-			case glfw.KeyKP2:
-				FirstPerson.renderCascade2 = !FirstPerson.renderCascade2
-			case glfw.KeyKP3:
-				FirstPerson.renderCascade3 = !FirstPerson.renderCascade3
-			case glfw.KeyKPDecimal:
-				FirstPerson.renderCascadeCenters = !FirstPerson.renderCascadeCenters
-			case glfw.KeyKP4:
-				FirstPerson.renderCascade1ShadowFrustum = !FirstPerson.renderCascade1ShadowFrustum
-			case glfw.KeyKP5:
-				FirstPerson.renderCascade2ShadowFrustum = !FirstPerson.renderCascade2ShadowFrustum
-			case glfw.KeyKP6:
-				FirstPerson.renderCascade3ShadowFrustum = !FirstPerson.renderCascade3ShadowFrustum
-			case glfw.KeyKPAdd:
-				FirstPerson.renderCascadeShadowFrustumEyes = !FirstPerson.renderCascadeShadowFrustumEyes
+	messagebus.RegisterType("action", func(m *messagebus.Message) {
+		for _, action := range m.Data2.([]input.Action) {
+			switch action {
+			case input.NextCamera:
+				ActiveCamera = nextCamera(ActiveCamera)
+			case input.NextController:
+				ActiveCamera.SetController((ActiveCamera.controller + 1) % len(ActiveCamera.controllers))
+			case input.ToggleFrustum:
+				CullingCamera.renderFrustum = !CullingCamera.renderFrustum
+			case input.ToggleCascade1:
+				CullingCamera.renderCascade1 = !CullingCamera.renderCascade1
+			case input.ToggleCascade2:
+				CullingCamera.renderCascade2 = !CullingCamera.renderCascade2
+			case input.ToggleCascade3:
+				CullingCamera.renderCascade3 = !CullingCamera.renderCascade3
+			case input.ToggleCascadeCenters:
+				CullingCamera.renderCascadeCenters = !CullingCamera.renderCascadeCenters
+			case input.ToggleCascade1ShadowFrustum:
+				CullingCamera.renderCascade1ShadowFrustum = !CullingCamera.renderCascade1ShadowFrustum
+			case input.ToggleCascade2ShadowFrustum:
+				CullingCamera.renderCascade2ShadowFrustum = !CullingCamera.renderCascade2ShadowFrustum
+			case input.ToggleCascade3ShadowFrustum:
+				CullingCamera.renderCascade3ShadowFrustum = !CullingCamera.renderCascade3ShadowFrustum
+			case input.ToggleShadowFrustumEyes:
+				CullingCamera.renderCascadeShadowFrustumEyes = !CullingCamera.renderCascadeShadowFrustumEyes
 			}
 		}
 	})
+	// The cursor is recentered every frame, so its latest position is how far it moved this frame.
 	messagebus.RegisterType("mouse", func(m *messagebus.Message) {
 		mouseInput := m.Data1.(input.MouseInput)
-		ActiveCamera.verticalAngle -= ActiveCamera.sensitivity * float32(mouseInput.Y-float64(Window.Height)/2)
-		if ActiveCamera.verticalAngle < -pi2 {
-			ActiveCamera.verticalAngle = float32(-pi2 + 0.0001)
-		}
-		if ActiveCamera.verticalAngle > pi2 {
-			ActiveCamera.verticalAngle = float32(pi2 - 0.0001)
-		}
-		ActiveCamera.horizontalAngle -= ActiveCamera.sensitivity * float32(mouseInput.X-float64(Window.Width)/2)
-		for ActiveCamera.horizontalAngle < 0 {
-			ActiveCamera.horizontalAngle += float32(2 * math.Pi)
-		}
-		for ActiveCamera.horizontalAngle > float32(2*math.Pi) {
-			ActiveCamera.horizontalAngle -= float32(2 * math.Pi)
-		}
+		ActiveCamera.input.Look = mgl32.Vec2{float32(mouseInput.X - float64(Window.Width)/2), float32(mouseInput.Y - float64(Window.Height)/2)}
+	})
+	messagebus.RegisterType("mousebutton", func(m *messagebus.Message) {
+		buttonInput := m.Data1.(input.MouseButtonInput)
+		heldButtons[buttonInput.Button] = buttonInput.Pressed
+	})
+	messagebus.RegisterType("scroll", func(m *messagebus.Message) {
+		ActiveCamera.input.Zoom += float32(m.Data1.(input.ScrollInput).Y)
 	})
 
 	go updateConsoleOnTimer()
@@ -208,8 +229,29 @@ func updateConsoleOnTimer() {
 		cameraForwardValue := fmt.Sprintf("[%.2f, %.2f, %.2f]", cameraForward.X(), cameraForward.Y(), cameraForward.Z())
 		messagebus.SendAsync(&messagebus.Message{Type: "console", Data1: "camera_forward", Data2: cameraForwardValue})
 
-		cameraAngleValue := fmt.Sprintf("[H: %.2f, V:%.2f]", ActiveCamera.horizontalAngle, ActiveCamera.verticalAngle)
+		cameraAngleValue := fmt.Sprintf("[H: %.2f, V:%.2f, R:%.2f]", ActiveCamera.horizontalAngle, ActiveCamera.verticalAngle, ActiveCamera.roll)
 		messagebus.SendAsync(&messagebus.Message{Type: "console", Data1: "camera_angle", Data2: cameraAngleValue})
+
+		messagebus.SendAsync(&messagebus.Message{Type: "console", Data1: "camera_controller", Data2: ActiveCamera.Controller().Name()})
+		messagebus.SendAsync(&messagebus.Message{Type: "console", Data1: "camera", Data2: ActiveCamera.Name()})
+	}
+}
+
+// newCameraControllers returns the controllers a camera can be switched between, the first of which moves it
+// initially.
+func newCameraControllers() []CameraController {
+	s := DefaultControllerSettings()
+	return []CameraController{NewFlyController(s), NewFPSController(s, nil), NewOrbitController(s), NewPanZoomController(s)}
+}
+
+// SetCameraGround sets the ground the registered cameras' FPS controllers walk over.
+func SetCameraGround(ground func(x, z float32) float32) {
+	for _, c := range cameras {
+		for _, controller := range c.controllers {
+			if fps, ok := controller.(*FPSController); ok {
+				fps.Ground = ground
+			}
+		}
 	}
 }
 
@@ -224,13 +266,15 @@ func transformTransposed(p mgl32.Vec3, m mgl32.Mat4) mgl32.Vec3 {
 }
 
 // Update is called every frame to execute this frame's movement.
-func (c *camera) Update(d float64) {
-	if c.direction.X() != 0 || c.direction.Y() != 0 || c.direction.Z() != 0 {
-		delta := c.direction.Normalize().Mul(float32(d) * c.speed)
-		c.position = c.position.Add(delta)
-		c.direction = mgl32.Vec3{0, 0, 0}
+func (c *Camera) Update(d float64) {
+	in := c.input
+	c.input = CameraInput{}
+	if c == ActiveCamera {
+		in.Buttons = heldButtons
 	}
-	if c == FirstPerson {
+	in.Look = in.Look.Add(in.LookRate.Mul(float32(d)))
+	c.setPose(c.Controller().Update(c.GetPose(), in, d))
+	if c == CullingCamera {
 		cornerVertices := []mgl32.Vec3{
 			{-1, 1, -1},
 			{1, 1, -1},
@@ -253,7 +297,7 @@ func (c *camera) Update(d float64) {
 
 		vertices := []LineVertex{}
 		for j := range NumberOfCascades {
-			lightViewProjection := Window.GetShadowCascadePerspectiveProjection(j).Mul4(c.GetView()).Transpose().Inv()
+			lightViewProjection := c.GetShadowCascadeProjection(j).Mul4(c.GetView()).Transpose().Inv()
 
 			cascadeCornerVertices := [8]mgl32.Vec3{}
 			cascadeCenter := mgl32.Vec3{}
@@ -313,7 +357,7 @@ func (c *camera) Update(d float64) {
 
 		// Calculate corners for the main view frustum
 		// Using the same Transpose().Inv() logic as cascades for consistency
-		projViewInvT := Window.GetProjection().Mul4(c.GetView()).Transpose().Inv()
+		projViewInvT := c.GetProjection().Mul4(c.GetView()).Transpose().Inv()
 		mainFrustumCorners := [8]mgl32.Vec3{}
 		for i, v := range cornerVertices {
 			mainFrustumCorners[i] = transformTransposed(v, projViewInvT)
@@ -363,61 +407,141 @@ func (c *camera) Update(d float64) {
 	}
 }
 
+// Name returns the name the camera is registered under.
+func (c *Camera) Name() string {
+	return c.name
+}
+
+// GetProjectionSettings returns how the camera projects the scene.
+func (c *Camera) GetProjectionSettings() Projection {
+	return c.projection
+}
+
+// SetProjection sets how the camera projects the scene.
+func (c *Camera) SetProjection(projection Projection) {
+	c.projection = projection
+}
+
+// aspect returns the ratio of the width of the camera's view to its height.
+func (c *Camera) aspect() float32 {
+	if v := Renderer.view; v != nil && v.camera == c {
+		return float32(v.width) / float32(v.height)
+	}
+	if c.viewAspect != 0 {
+		return c.viewAspect
+	}
+	return float32(Window.Width) / float32(Window.Height)
+}
+
+// GetProjection returns the camera's projection matrix.
+func (c *Camera) GetProjection() mgl32.Mat4 {
+	return c.projectionBetween(c.projection.Near, c.projection.Far)
+}
+
+// GetShadowCascadeProjection returns the projection matrix of the part of the camera's view the i-th shadow cascade covers.
+func (c *Camera) GetShadowCascadeProjection(i int) mgl32.Mat4 {
+	return c.projectionBetween(ShadowSplits[i], ShadowSplits[i+1])
+}
+
+// projectionBetween returns the camera's projection matrix clipped to the given near and far planes.
+func (c *Camera) projectionBetween(near, far float32) mgl32.Mat4 {
+	if c.projection.Orthographic {
+		h := c.projection.OrthographicHeight / 2
+		w := h * c.aspect()
+		return mgl32.Ortho(-w, w, -h, h, near, far)
+	}
+	return mgl32.Perspective(mgl32.DegToRad(c.projection.FieldOfView), c.aspect(), near, far)
+}
+
+// GetPose returns the camera's pose.
+func (c *Camera) GetPose() CameraPose {
+	return CameraPose{c.position, c.horizontalAngle, c.verticalAngle, c.roll}
+}
+
+// setPose moves the camera to pose.
+func (c *Camera) setPose(pose CameraPose) {
+	c.position, c.horizontalAngle, c.verticalAngle, c.roll = pose.Position, pose.HorizontalAngle, pose.VerticalAngle, pose.Roll
+}
+
+// Controller returns the controller moving the camera.
+func (c *Camera) Controller() CameraController {
+	return c.controllers[c.controller]
+}
+
+// AddController adds controller to those the camera can be switched between, and returns its index.
+func (c *Camera) AddController(controller CameraController) int {
+	c.controllers = append(c.controllers, controller)
+	return len(c.controllers) - 1
+}
+
+// SetController switches the camera to be moved by its controller at index i.
+func (c *Camera) SetController(i int) {
+	c.controller = i
+	c.setPose(c.Controller().Attach(c.GetPose()))
+}
+
 // GetPosition returns the position of this camera.
-func (c *camera) GetPosition() mgl32.Vec3 {
+func (c *Camera) GetPosition() mgl32.Vec3 {
 	return c.position
 }
 
 // SetPose sets the camera's position and angles directly.
 // horizontalAngle rotates around Y (yaw), verticalAngle tilts up/down (pitch).
 // This is intended for deterministic setup in render-test mode.
-func (c *camera) SetPose(position mgl32.Vec3, horizontalAngle, verticalAngle float32) {
+func (c *Camera) SetPose(position mgl32.Vec3, horizontalAngle, verticalAngle float32) {
 	c.position = position
 	c.horizontalAngle = horizontalAngle
 	c.verticalAngle = verticalAngle
+	c.roll = 0
+	c.setPose(c.Controller().Attach(c.GetPose()))
 }
 
 // SetFrustumRendering enables or disables the full-frustum wireframe overlay
-// drawn from the perspective of the ThirdPerson camera. This is intended for
+// drawn from the perspective of the other cameras. This is intended for
 // deterministic setup in render-test mode.
-func (c *camera) SetFrustumRendering(enabled bool) {
+func (c *Camera) SetFrustumRendering(enabled bool) {
 	c.renderFrustum = enabled
 }
 
 // IsFrustumRenderingEnabled returns whether the frustum is currently enabled.
-func (c *camera) IsFrustumRenderingEnabled() bool {
+func (c *Camera) IsFrustumRenderingEnabled() bool {
 	return c.renderFrustum
 }
 
 // GetHorizontalAngle returns the camera's current yaw (horizontal rotation).
 // This is used by the renderer to orient FirstPersonCameraRenderable each frame.
-func (c *camera) GetHorizontalAngle() float32 {
+func (c *Camera) GetHorizontalAngle() float32 {
 	return c.horizontalAngle
 }
 
 // GetVerticalAngle returns the camera's current pitch (vertical tilt).
 // This is used by the renderer to orient FirstPersonCameraRenderable each frame.
-func (c *camera) GetVerticalAngle() float32 {
+func (c *Camera) GetVerticalAngle() float32 {
 	return c.verticalAngle
 }
 
 // GetForward returns the forward unit vector for this camera.
-func (c *camera) GetForward() mgl32.Vec3 {
-	return mgl32.Rotate3DY(c.horizontalAngle).Mul3x1(mgl32.Rotate3DZ(c.verticalAngle).Mul3x1((mgl32.Vec3{1, 0, 0})))
+func (c *Camera) GetForward() mgl32.Vec3 {
+	return c.GetPose().Forward()
 }
 
 // GetRight returns the right unit vector for this camera.
-func (c *camera) GetRight() mgl32.Vec3 {
+func (c *Camera) GetRight() mgl32.Vec3 {
 	return mgl32.Rotate3DY(c.horizontalAngle).Mul3x1(mgl32.Vec3{0, 0, 1})
 }
 
+// GetUp returns the unit vector which is up on screen for this camera.
+func (c *Camera) GetUp() mgl32.Vec3 {
+	return c.GetPose().Up()
+}
+
 // GetView returns the current view matrix for this camera.
-func (c *camera) GetView() mgl32.Mat4 {
-	return mgl32.LookAtV(c.position, c.position.Add(c.GetForward()), mgl32.Vec3{0, 1, 0})
+func (c *Camera) GetView() mgl32.Mat4 {
+	return mgl32.LookAtV(c.position, c.position.Add(c.GetForward()), c.GetUp())
 }
 
 // RenderFrustum renders the frustum for this camera.
-func (c *camera) RenderFrustum() {
+func (c *Camera) RenderFrustum() {
 	gl.BindVertexArray(c.vao)
 	if c.renderCascade1 {
 		gl.DrawArrays(gl.LINES, 0, 24)
@@ -478,8 +602,8 @@ func (c *camera) RenderFrustum() {
 		gl.DrawArrays(gl.LINES, int32(NumberOfCascades*50), 16)
 
 		Renderer.frustumShader.Use()
-		Renderer.frustumShader.View.Set(ThirdPerson.GetView())
-		Renderer.frustumShader.Projection.Set(Window.GetProjection())
+		Renderer.frustumShader.View.Set(ActiveCamera.GetView())
+		Renderer.frustumShader.Projection.Set(ActiveCamera.GetProjection())
 		Renderer.frustumShader.Color.Set(mgl32.Vec3{0.4, 0.7, 1.0}) // Light blue tint
 		Renderer.frustumShader.Alpha.Set(0.6)                      // More visible
 
@@ -0,0 +1,335 @@
+package gfx
+
+import (
+	"math"
+
+	"github.com/go-gl/glfw/v3.1/glfw"
+	"github.com/go-gl/mathgl/mgl32"
+)
+
+// CameraPose is where a camera is and which way it faces.
+type CameraPose struct {
+	Position mgl32.Vec3
+	// HorizontalAngle turns the camera around the Y axis, VerticalAngle tilts it up and down, and Roll turns it
+	// around the direction it faces.
+	HorizontalAngle, VerticalAngle, Roll float32
+}
+
+// Forward returns the unit vector the pose faces along.
+func (p CameraPose) Forward() mgl32.Vec3 {
+	return mgl32.Rotate3DY(p.HorizontalAngle).Mul3x1(mgl32.Rotate3DZ(p.VerticalAngle).Mul3x1((mgl32.Vec3{1, 0, 0})))
+}
+
+// Up returns the unit vector which is up on screen, which is the Y axis until the pose is rolled.
+func (p CameraPose) Up() mgl32.Vec3 {
+	return mgl32.HomogRotate3D(p.Roll, p.Forward()).Mul4x1(mgl32.Vec4{0, 1, 0, 0}).Vec3()
+}
+
+// Right returns the unit vector which is right on screen.
+func (p CameraPose) Right() mgl32.Vec3 {
+	return p.Forward().Cross(p.Up()).Normalize()
+}
+
+// turned returns the pose turned by the given angles, keeping it from tilting past straight up or down.
+func (p CameraPose) turned(horizontal, vertical float32) CameraPose {
+	p.VerticalAngle = mgl32.Clamp(p.VerticalAngle+vertical, float32(-pi2+0.0001), float32(pi2-0.0001))
+	p.HorizontalAngle += horizontal
+	for p.HorizontalAngle < 0 {
+		p.HorizontalAngle += float32(2 * math.Pi)
+	}
+	for p.HorizontalAngle > float32(2*math.Pi) {
+		p.HorizontalAngle -= float32(2 * math.Pi)
+	}
+	return p
+}
+
+// flatForward returns the unit vector the pose faces along, levelled onto the ground.
+func (p CameraPose) flatForward() mgl32.Vec3 {
+	return mgl32.Rotate3DY(p.HorizontalAngle).Mul3x1(mgl32.Vec3{1, 0, 0})
+}
+
+// flatRight returns the unit vector right of the pose, levelled onto the ground.
+func (p CameraPose) flatRight() mgl32.Vec3 {
+	return mgl32.Rotate3DY(p.HorizontalAngle).Mul3x1(mgl32.Vec3{0, 0, 1})
+}
+
+// gamepadLookSpeed is how many pixels a second a gamepad stick pushed all the way turns the camera, as if the
+// cursor moved.
+const gamepadLookSpeed = 600
+
+// CameraInput is the input a camera is moved with over one frame.
+type CameraInput struct {
+	// Move is the direction the movement keys push the camera, with X to its right, Y up and Z forward.
+	Move mgl32.Vec3
+	// Roll is 1 while the camera is rolled right, and -1 while it is rolled left.
+	Roll   float32
+	Sprint bool
+	// Look is how many pixels the cursor moved, and Zoom how many steps the mouse wheel turned away from the user.
+	Look mgl32.Vec2
+	Zoom float32
+	// LookRate is how many pixels a second a gamepad stick turns the camera, as if the cursor moved. It is
+	// added to Look before the controller is updated.
+	LookRate mgl32.Vec2
+	// Buttons holds whether each mouse button is held.
+	Buttons [glfw.MouseButtonLast + 1]bool
+}
+
+// dragging reports whether any mouse button is held.
+func (in CameraInput) dragging() bool {
+	for _, b := range in.Buttons {
+		if b {
+			return true
+		}
+	}
+	return false
+}
+
+// CameraController moves a camera by its input every frame.
+type CameraController interface {
+	// Name returns what the controller is called on the console.
+	Name() string
+	// Attach is called with the camera's pose when the controller starts moving it, and returns the pose the
+	// camera starts from.
+	Attach(pose CameraPose) CameraPose
+	// Update returns the camera's pose after being moved by in for d seconds.
+	Update(pose CameraPose, in CameraInput, d float64) CameraPose
+}
+
+// ControllerSettings are how a camera controller responds to input.
+type ControllerSettings struct {
+	// Speed is how fast the camera moves in units per second, multiplied by SprintMultiplier while sprinting.
+	Speed            float32
+	SprintMultiplier float32
+	// Acceleration is how quickly the camera reaches its speed, as the rate the difference closes per second.
+	// Zero reaches it immediately.
+	Acceleration float32
+	// Sensitivity is the radians the camera turns per pixel the cursor moves, and RollSpeed the radians per second
+	// it rolls.
+	Sensitivity float32
+	RollSpeed   float32
+	// Smoothing is how many seconds cursor and mouse wheel movement is spread over. Zero applies it immediately.
+	Smoothing float32
+	// ZoomSpeed is the fraction of the way to its target a mouse wheel step zooms the camera.
+	ZoomSpeed float32
+}
+
+// DefaultControllerSettings returns the settings cameras are controlled with by default.
+func DefaultControllerSettings() ControllerSettings {
+	return ControllerSettings{
+		Speed:            20,
+		SprintMultiplier: 4,
+		Acceleration:     10,
+		Sensitivity:      0.001,
+		RollSpeed:        1,
+		Smoothing:        0.03,
+		ZoomSpeed:        0.1,
+	}
+}
+
+// motion is the velocity and smoothed input a controller carries from frame to frame.
+type motion struct {
+	velocity mgl32.Vec3
+	// look and zoom are the cursor and mouse wheel movement not applied yet.
+	look mgl32.Vec2
+	zoom float32
+}
+
+// move accelerates toward moving along direction, and returns how far the camera moves over d seconds.
+func (m *motion) move(s *ControllerSettings, direction mgl32.Vec3, sprint bool, d float64) mgl32.Vec3 {
+	var target mgl32.Vec3
+	if direction.Len() > 0 {
+		speed := s.Speed
+		if sprint {
+			speed *= s.SprintMultiplier
+		}
+		target = direction.Normalize().Mul(speed)
+	}
+	if s.Acceleration > 0 {
+		m.velocity = m.velocity.Add(target.Sub(m.velocity).Mul(1 - float32(math.Exp(-float64(s.Acceleration)*d))))
+	} else {
+		m.velocity = target
+	}
+	return m.velocity.Mul(float32(d))
+}
+
+// smooth adds in's cursor and mouse wheel movement to what is still to be applied, and returns the part of it
+// applied over d seconds.
+func (m *motion) smooth(s *ControllerSettings, in CameraInput, d float64) (mgl32.Vec2, float32) {
+	m.look = m.look.Add(in.Look)
+	m.zoom += in.Zoom
+	f := float32(1)
+	if s.Smoothing > 0 {
+		f = 1 - float32(math.Exp(-d/float64(s.Smoothing)))
+	}
+	look, zoom := m.look.Mul(f), m.zoom*f
+	m.look, m.zoom = m.look.Sub(look), m.zoom-zoom
+	return look, zoom
+}
+
+// turn returns pose turned by the cursor movement look.
+func (m *motion) turn(s *ControllerSettings, pose CameraPose, look mgl32.Vec2) CameraPose {
+	if look == (mgl32.Vec2{}) {
+		return pose
+	}
+	return pose.turned(-s.Sensitivity*look.X(), -s.Sensitivity*look.Y())
+}
+
+// FlyController flies the camera freely along the direction it faces, up and down, and rolls it.
+type FlyController struct {
+	ControllerSettings
+	motion
+}
+
+// NewFlyController returns a FlyController with settings s.
+func NewFlyController(s ControllerSettings) *FlyController {
+	return &FlyController{ControllerSettings: s}
+}
+
+// Name implements CameraController.
+func (c *FlyController) Name() string {
+	return "fly"
+}
+
+// Attach implements CameraController.
+func (c *FlyController) Attach(pose CameraPose) CameraPose {
+	c.motion = motion{}
+	return pose
+}
+
+// Update implements CameraController.
+func (c *FlyController) Update(pose CameraPose, in CameraInput, d float64) CameraPose {
+	look, _ := c.smooth(&c.ControllerSettings, in, d)
+	pose = c.turn(&c.ControllerSettings, pose, look)
+	pose.Roll += in.Roll * c.RollSpeed * float32(d)
+	direction := pose.Right().Mul(in.Move.X()).Add(mgl32.Vec3{0, in.Move.Y(), 0}).Add(pose.Forward().Mul(in.Move.Z()))
+	pose.Position = pose.Position.Add(c.move(&c.ControllerSettings, direction, in.Sprint, d))
+	return pose
+}
+
+// FPSController walks the camera over the ground, keeping it EyeHeight above it.
+type FPSController struct {
+	ControllerSettings
+	motion
+
+	// Ground returns the height of the ground at (x, z). The camera keeps its height while it is nil.
+	Ground    func(x, z float32) float32
+	EyeHeight float32
+}
+
+// NewFPSController returns an FPSController with settings s, walking over ground.
+func NewFPSController(s ControllerSettings, ground func(x, z float32) float32) *FPSController {
+	return &FPSController{ControllerSettings: s, Ground: ground, EyeHeight: 2}
+}
+
+// Name implements CameraController.
+func (c *FPSController) Name() string {
+	return "fps"
+}
+
+// Attach implements CameraController.
+func (c *FPSController) Attach(pose CameraPose) CameraPose {
+	c.motion = motion{}
+	pose.Roll = 0
+	return c.clamp(pose)
+}
+
+// Update implements CameraController.
+func (c *FPSController) Update(pose CameraPose, in CameraInput, d float64) CameraPose {
+	look, _ := c.smooth(&c.ControllerSettings, in, d)
+	pose = c.turn(&c.ControllerSettings, pose, look)
+	direction := pose.flatRight().Mul(in.Move.X()).Add(pose.flatForward().Mul(in.Move.Z()))
+	pose.Position = pose.Position.Add(c.move(&c.ControllerSettings, direction, in.Sprint, d))
+	return c.clamp(pose)
+}
+
+// clamp returns pose standing on the ground.
+func (c *FPSController) clamp(pose CameraPose) CameraPose {
+	if c.Ground != nil {
+		pose.Position[1] = c.Ground(pose.Position.X(), pose.Position.Z()) + c.EyeHeight
+	}
+	return pose
+}
+
+// OrbitController turns the camera around Target, looking at it from Distance away. The mouse wheel zooms
+// toward it, and the movement keys move it.
+type OrbitController struct {
+	ControllerSettings
+	motion
+
+	Target mgl32.Vec3
+	// Distance is kept within [MinDistance, MaxDistance].
+	Distance, MinDistance, MaxDistance float32
+}
+
+// NewOrbitController returns an OrbitController with settings s.
+func NewOrbitController(s ControllerSettings) *OrbitController {
+	return &OrbitController{ControllerSettings: s, Distance: 30, MinDistance: 1, MaxDistance: 1000}
+}
+
+// Name implements CameraController.
+func (c *OrbitController) Name() string {
+	return "orbit"
+}
+
+// Attach implements CameraController. The camera orbits the point Distance in front of it.
+func (c *OrbitController) Attach(pose CameraPose) CameraPose {
+	c.motion = motion{}
+	c.Target = pose.Position.Add(pose.Forward().Mul(c.Distance))
+	pose.Roll = 0
+	return pose
+}
+
+// Update implements CameraController.
+func (c *OrbitController) Update(pose CameraPose, in CameraInput, d float64) CameraPose {
+	look, zoom := c.smooth(&c.ControllerSettings, in, d)
+	pose = c.turn(&c.ControllerSettings, pose, look)
+	if zoom != 0 {
+		c.Distance *= float32(math.Pow(float64(1-c.ZoomSpeed), float64(zoom)))
+	}
+	c.Distance = mgl32.Clamp(c.Distance, c.MinDistance, c.MaxDistance)
+	direction := pose.flatRight().Mul(in.Move.X()).Add(mgl32.Vec3{0, in.Move.Y(), 0}).Add(pose.flatForward().Mul(in.Move.Z()))
+	c.Target = c.Target.Add(c.move(&c.ControllerSettings, direction, in.Sprint, d))
+	pose.Position = c.Target.Sub(pose.Forward().Mul(c.Distance))
+	return pose
+}
+
+// PanZoomController keeps the camera facing the same way, dragging it across the screen while a mouse button
+// is held and zooming it along the direction it faces with the mouse wheel.
+type PanZoomController struct {
+	ControllerSettings
+	motion
+
+	// PanScale is the units the camera pans per pixel the cursor is dragged.
+	PanScale float32
+	// Reach is the distance to the point the mouse wheel zooms toward.
+	Reach float32
+}
+
+// NewPanZoomController returns a PanZoomController with settings s.
+func NewPanZoomController(s ControllerSettings) *PanZoomController {
+	return &PanZoomController{ControllerSettings: s, PanScale: 0.05, Reach: 100}
+}
+
+// Name implements CameraController.
+func (c *PanZoomController) Name() string {
+	return "panzoom"
+}
+
+// Attach implements CameraController.
+func (c *PanZoomController) Attach(pose CameraPose) CameraPose {
+	c.motion = motion{}
+	return pose
+}
+
+// Update implements CameraController.
+func (c *PanZoomController) Update(pose CameraPose, in CameraInput, d float64) CameraPose {
+	if !in.dragging() {
+		in.Look = mgl32.Vec2{}
+	}
+	look, zoom := c.smooth(&c.ControllerSettings, in, d)
+	pan := pose.Right().Mul(-look.X() * c.PanScale).Add(pose.Up().Mul(look.Y() * c.PanScale))
+	pan = pan.Add(pose.Forward().Mul(zoom * c.ZoomSpeed * c.Reach))
+	direction := pose.Right().Mul(in.Move.X()).Add(mgl32.Vec3{0, in.Move.Y(), 0}).Add(pose.flatForward().Mul(in.Move.Z()))
+	pose.Position = pose.Position.Add(pan).Add(c.move(&c.ControllerSettings, direction, in.Sprint, d))
+	return pose
+}
@@ -0,0 +1,278 @@
+package gfx
+
+import (
+	"encoding/json"
+	"fmt"
+	"math"
+	"os"
+	"sort"
+
+	"github.com/go-gl/mathgl/mgl32"
+)
+
+// pathArcSamples is the number of samples per segment the length along a camera path is measured at.
+const pathArcSamples = 32
+
+// PathInterpolation selects the spline a camera path's positions follow between keyframes.
+type PathInterpolation int
+
+const (
+	// CatmullRom passes smoothly through every keyframe's position.
+	CatmullRom PathInterpolation = iota
+	// Bezier leaves each keyframe toward its Out handle and arrives at the next from its In handle.
+	Bezier
+)
+
+// PathKeyframe is a pose a camera path passes through at a time.
+type PathKeyframe struct {
+	// Time is the seconds from the start of the path the keyframe is reached at.
+	Time float64
+	Pose CameraPose
+	// In and Out are the offsets from the keyframe's position of the Bezier control points before and after it.
+	In, Out mgl32.Vec3
+}
+
+// CameraPath is a path a camera flies along through keyframed poses. Orientation follows a Catmull-Rom spline
+// through the keyframes' angles, so the camera turns smoothly through them without stopping at each.
+type CameraPath struct {
+	// Keyframes are in increasing order of time. They must not be changed in place once the path has been
+	// sampled; use Add to extend it.
+	Keyframes     []PathKeyframe
+	Interpolation PathInterpolation
+	// ConstantSpeed moves along the path at the same speed throughout its duration, instead of reaching each
+	// keyframe at its time.
+	ConstantSpeed bool
+
+	// arcLengths holds the length of the path up to each of pathArcSamples samples per segment, measured for
+	// the first arcKeyframes keyframes.
+	arcLengths   []float32
+	arcKeyframes int
+}
+
+// LoadCameraPath reads a camera path Save wrote to file.
+func LoadCameraPath(file string) (*CameraPath, error) {
+	data, err := os.ReadFile(file)
+	if err != nil {
+		return nil, err
+	}
+	p := &CameraPath{}
+	if err := json.Unmarshal(data, p); err != nil {
+		return nil, fmt.Errorf("%v is not a camera path: %v", file, err)
+	}
+	for i := 1; i < len(p.Keyframes); i++ {
+		if p.Keyframes[i].Time <= p.Keyframes[i-1].Time {
+			return nil, fmt.Errorf("%v has keyframe %d at %vs, not after the one before it", file, i, p.Keyframes[i].Time)
+		}
+	}
+	return p, nil
+}
+
+// Save writes the path to file.
+func (p *CameraPath) Save(file string) error {
+	data, err := json.MarshalIndent(p, "", "\t")
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(file, data, 0644)
+}
+
+// Add appends a keyframe reaching pose at time, which must be later than the last keyframe.
+func (p *CameraPath) Add(time float64, pose CameraPose) error {
+	if n := len(p.Keyframes); n > 0 && time <= p.Keyframes[n-1].Time {
+		return fmt.Errorf("camera path keyframe at %vs is not after the last one at %vs", time, p.Keyframes[n-1].Time)
+	}
+	p.Keyframes = append(p.Keyframes, PathKeyframe{Time: time, Pose: pose})
+	return nil
+}
+
+// Duration returns the seconds the path takes.
+func (p *CameraPath) Duration() float64 {
+	if len(p.Keyframes) < 2 {
+		return 0
+	}
+	return p.Keyframes[len(p.Keyframes)-1].Time - p.Keyframes[0].Time
+}
+
+// Pose returns the pose along the path t seconds from its start.
+func (p *CameraPath) Pose(t float64) CameraPose {
+	switch len(p.Keyframes) {
+	case 0:
+		return CameraPose{}
+	case 1:
+		return p.Keyframes[0].Pose
+	}
+	i, u := p.segmentAt(t)
+	k := p.Keyframes
+	a0, a1, a2, a3 := k[max(i-1, 0)].Pose, k[i].Pose, k[i+1].Pose, k[min(i+2, len(k)-1)].Pose
+
+	// The horizontal angles are unwrapped from a1's, so the camera turns the short way between each pair.
+	h1 := a1.HorizontalAngle
+	h0 := h1 - turn(a0.HorizontalAngle, a1.HorizontalAngle)
+	h2 := h1 + turn(a1.HorizontalAngle, a2.HorizontalAngle)
+	h3 := h2 + turn(a2.HorizontalAngle, a3.HorizontalAngle)
+	return CameraPose{
+		Position:        p.position(i, u),
+		HorizontalAngle: catmullRom(h0, h1, h2, h3, u),
+		VerticalAngle:   catmullRom(a0.VerticalAngle, a1.VerticalAngle, a2.VerticalAngle, a3.VerticalAngle, u),
+		Roll:            catmullRom(a0.Roll, a1.Roll, a2.Roll, a3.Roll, u),
+	}
+}
+
+// turn returns the shortest angle from a to b, in [-π, π].
+func turn(a, b float32) float32 {
+	return float32(math.Remainder(float64(b-a), 2*math.Pi))
+}
+
+// catmullRom returns the value u of the way from p1 to p2 along the Catmull-Rom spline through p0, p1, p2 and p3.
+func catmullRom(p0, p1, p2, p3, u float32) float32 {
+	u2, u3 := u*u, u*u*u
+	return 0.5 * (2*p1 + (p2-p0)*u + (2*p0-5*p1+4*p2-p3)*u2 + (3*(p1-p2)+p3-p0)*u3)
+}
+
+// position returns the position u of the way along the spline between keyframes i and i+1.
+func (p *CameraPath) position(i int, u float32) mgl32.Vec3 {
+	k := p.Keyframes
+	p1, p2 := k[i].Pose.Position, k[i+1].Pose.Position
+	if p.Interpolation == Bezier {
+		c1, c2 := p1.Add(k[i].Out), p2.Add(k[i+1].In)
+		v := 1 - u
+		return p1.Mul(v * v * v).Add(c1.Mul(3 * v * v * u)).Add(c2.Mul(3 * v * u * u)).Add(p2.Mul(u * u * u))
+	}
+	p0, p3 := k[max(i-1, 0)].Pose.Position, k[min(i+2, len(k)-1)].Pose.Position
+	u2, u3 := u*u, u*u*u
+	return p1.Mul(2).
+		Add(p2.Sub(p0).Mul(u)).
+		Add(p0.Mul(2).Sub(p1.Mul(5)).Add(p2.Mul(4)).Sub(p3).Mul(u2)).
+		Add(p3.Sub(p0).Add(p1.Sub(p2).Mul(3)).Mul(u3)).
+		Mul(0.5)
+}
+
+// segmentAt returns the segment of the path t seconds from its start, and how far along it t is.
+func (p *CameraPath) segmentAt(t float64) (int, float32) {
+	k := p.Keyframes
+	duration := p.Duration()
+	t = max(min(t, duration), 0)
+	if !p.ConstantSpeed || duration == 0 {
+		at := k[0].Time + t
+		i := sort.Search(len(k)-1, func(i int) bool { return k[i+1].Time > at }) // The first segment ending after at.
+		i = min(i, len(k)-2)
+		return i, float32(min((at-k[i].Time)/(k[i+1].Time-k[i].Time), 1))
+	}
+
+	lengths := p.lengths()
+	s := lengths[len(lengths)-1] * float32(t/duration)
+	j := sort.Search(len(lengths), func(j int) bool { return lengths[j] >= s })
+	if j == 0 {
+		return 0, 0
+	}
+	sample := float32(j - 1)
+	if span := lengths[j] - lengths[j-1]; span > 0 {
+		sample += (s - lengths[j-1]) / span
+	}
+	i := min(int(sample)/pathArcSamples, len(k)-2)
+	return i, min((sample-float32(i*pathArcSamples))/pathArcSamples, 1)
+}
+
+// lengths returns the length of the path up to each of its samples, measuring it if it was extended since.
+func (p *CameraPath) lengths() []float32 {
+	if p.arcKeyframes == len(p.Keyframes) {
+		return p.arcLengths
+	}
+	segments := len(p.Keyframes) - 1
+	p.arcLengths = make([]float32, 1, segments*pathArcSamples+1)
+	previous := p.position(0, 0)
+	for i := range segments {
+		for j := 1; j <= pathArcSamples; j++ {
+			next := p.position(i, float32(j)/pathArcSamples)
+			p.arcLengths = append(p.arcLengths, p.arcLengths[len(p.arcLengths)-1]+next.Sub(previous).Len())
+			previous = next
+		}
+	}
+	p.arcKeyframes = len(p.Keyframes)
+	return p.arcLengths
+}
+
+// PathRecorder records a camera's poses into a path as it moves.
+type PathRecorder struct {
+	// Interval is the seconds between recorded poses. Zero only records keyframes added with AddKeyframe.
+	Interval float64
+
+	path      CameraPath
+	time      float64
+	sinceLast float64
+}
+
+// NewPathRecorder returns a PathRecorder recording a pose every interval seconds.
+func NewPathRecorder(interval float64) *PathRecorder {
+	return &PathRecorder{Interval: interval}
+}
+
+// Update advances the recording by d seconds, recording pose if the interval has passed.
+func (r *PathRecorder) Update(pose CameraPose, d float64) {
+	first := len(r.path.Keyframes) == 0
+	r.time += d
+	r.sinceLast += d
+	if r.Interval > 0 && (first || r.sinceLast >= r.Interval) {
+		r.AddKeyframe(pose)
+	}
+}
+
+// AddKeyframe records pose at the current time.
+func (r *PathRecorder) AddKeyframe(pose CameraPose) {
+	// Keyframes added in the same frame replace each other.
+	if n := len(r.path.Keyframes); n > 0 && r.path.Keyframes[n-1].Time >= r.time {
+		r.path.Keyframes[n-1].Pose = pose
+		return
+	}
+	r.path.Add(r.time, pose)
+	r.sinceLast = 0
+}
+
+// Path returns the path recorded so far.
+func (r *PathRecorder) Path() *CameraPath {
+	return &r.path
+}
+
+// PathController flies the camera along Path, ignoring its input.
+type PathController struct {
+	Path *CameraPath
+	// Loop starts the path over from the beginning once it ends.
+	Loop bool
+
+	time float64
+}
+
+// NewPathController returns a PathController flying along path.
+func NewPathController(path *CameraPath) *PathController {
+	return &PathController{Path: path}
+}
+
+// Name implements CameraController.
+func (c *PathController) Name() string {
+	return "path"
+}
+
+// Attach implements CameraController. The camera starts over from the beginning of the path.
+func (c *PathController) Attach(pose CameraPose) CameraPose {
+	c.time = 0
+	return c.Path.Pose(0)
+}
+
+// Update implements CameraController.
+func (c *PathController) Update(pose CameraPose, in CameraInput, d float64) CameraPose {
+	c.time += d
+	if duration := c.Path.Duration(); c.Loop && duration > 0 {
+		c.time = math.Mod(c.time, duration)
+	}
+	return c.Path.Pose(c.time)
+}
+
+// GetTime returns the seconds along its path the camera is.
+func (c *PathController) GetTime() float64 {
+	return c.time
+}
+
+// Done reports whether the camera has reached the end of a path which doesn't loop.
+func (c *PathController) Done() bool {
+	return !c.Loop && c.time >= c.Path.Duration()
+}
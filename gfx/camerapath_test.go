@@ -0,0 +1,110 @@
+package gfx
+
+import (
+	"math"
+	"path/filepath"
+	"testing"
+
+	"github.com/go-gl/mathgl/mgl32"
+	"github.com/stretchr/testify/assert"
+)
+
+// testPath returns a path through four keyframes, spaced unevenly in both time and distance.
+func testPath() *CameraPath {
+	p := &CameraPath{}
+	p.Add(0, CameraPose{Position: mgl32.Vec3{0, 0, 0}, HorizontalAngle: 0, VerticalAngle: 0})
+	p.Add(2, CameraPose{Position: mgl32.Vec3{10, 2, 0}, HorizontalAngle: 1, VerticalAngle: .2, Roll: .1})
+	p.Add(3, CameraPose{Position: mgl32.Vec3{12, 2, 8}, HorizontalAngle: 2, VerticalAngle: -.3})
+	p.Add(7, CameraPose{Position: mgl32.Vec3{0, 5, 20}, HorizontalAngle: 2.5, VerticalAngle: 0})
+	return p
+}
+
+func TestCameraPathPassesThroughKeyframes(t *testing.T) {
+	p := testPath()
+	for _, k := range p.Keyframes {
+		pose := p.Pose(k.Time - p.Keyframes[0].Time)
+		assert.InDelta(t, 0, pose.Position.Sub(k.Pose.Position).Len(), 1e-4, "at %vs", k.Time)
+		assert.InDelta(t, k.Pose.HorizontalAngle, pose.HorizontalAngle, 1e-5, "at %vs", k.Time)
+		assert.InDelta(t, k.Pose.VerticalAngle, pose.VerticalAngle, 1e-5, "at %vs", k.Time)
+		assert.InDelta(t, k.Pose.Roll, pose.Roll, 1e-5, "at %vs", k.Time)
+	}
+	// Before and after the path, the camera waits at its ends.
+	assert.Equal(t, p.Pose(0), p.Pose(-1))
+	assert.Equal(t, p.Pose(p.Duration()), p.Pose(p.Duration()+1))
+}
+
+func TestCameraPathConstantSpeed(t *testing.T) {
+	p := testPath()
+	p.ConstantSpeed = true
+	const steps = 200
+	step := p.Duration() / steps
+	var distances []float32
+	previous := p.Pose(0).Position
+	for i := 1; i <= steps; i++ {
+		next := p.Pose(float64(i) * step).Position
+		distances = append(distances, next.Sub(previous).Len())
+		previous = next
+	}
+	// The length is measured at pathArcSamples points per segment, so steps near tight bends, like the end of
+	// the last segment, are off by a few percent. Without ConstantSpeed they differ several times over.
+	mean := p.lengths()[len(p.lengths())-1] / steps
+	for i, d := range distances {
+		assert.InDelta(t, mean, d, float64(mean)*.05, "step %d", i)
+	}
+}
+
+func TestCameraPathOrientationIsSmooth(t *testing.T) {
+	// The camera keeps turning through the middle keyframes, instead of stopping at each.
+	p := &CameraPath{}
+	p.Add(0, CameraPose{Position: mgl32.Vec3{0, 0, 0}, HorizontalAngle: 0})
+	p.Add(1, CameraPose{Position: mgl32.Vec3{1, 0, 0}, HorizontalAngle: 1})
+	p.Add(2, CameraPose{Position: mgl32.Vec3{2, 0, 0}, HorizontalAngle: 2})
+	p.Add(3, CameraPose{Position: mgl32.Vec3{3, 0, 0}, HorizontalAngle: 3})
+	const h = 1e-3
+	for _, at := range []float64{1, 2} {
+		before := (p.Pose(at).HorizontalAngle - p.Pose(at-h).HorizontalAngle) / h
+		after := (p.Pose(at+h).HorizontalAngle - p.Pose(at).HorizontalAngle) / h
+		assert.InDelta(t, 1, before, .01, "at %vs", at)
+		assert.InDelta(t, 1, after, .01, "at %vs", at)
+	}
+}
+
+func TestCameraPathWrapsHorizontalAngle(t *testing.T) {
+	p := &CameraPath{}
+	p.Add(0, CameraPose{HorizontalAngle: mgl32.DegToRad(350)})
+	p.Add(1, CameraPose{HorizontalAngle: mgl32.DegToRad(10)})
+	p.Add(2, CameraPose{HorizontalAngle: mgl32.DegToRad(30)})
+	// Turning from 350° to 10° goes through 0°, not back around through 180°.
+	for u := 0.0; u <= 1; u += .125 {
+		angle := float64(p.Pose(u).HorizontalAngle)
+		assert.InDelta(t, 0, math.Remainder(angle, 2*math.Pi), float64(mgl32.DegToRad(11)), "at %vs", u)
+	}
+	assert.InDelta(t, 0, math.Remainder(float64(p.Pose(.5).HorizontalAngle), 2*math.Pi), float64(mgl32.DegToRad(2)))
+}
+
+func TestCameraPathSaveLoad(t *testing.T) {
+	p := testPath()
+	p.Interpolation = Bezier
+	p.ConstantSpeed = true
+	p.Keyframes[1].In, p.Keyframes[1].Out = mgl32.Vec3{-1, 0, 0}, mgl32.Vec3{1, 0, 0}
+	file := filepath.Join(t.TempDir(), "path.json")
+	assert.NoError(t, p.Save(file))
+
+	loaded, err := LoadCameraPath(file)
+	assert.NoError(t, err)
+	assert.Equal(t, p.Keyframes, loaded.Keyframes)
+	assert.Equal(t, p.Interpolation, loaded.Interpolation)
+	assert.Equal(t, p.ConstantSpeed, loaded.ConstantSpeed)
+	for _, at := range []float64{0, 1.5, 4, 7} {
+		assert.Equal(t, p.Pose(at), loaded.Pose(at), "at %vs", at)
+	}
+}
+
+func TestLoadCameraPathRejectsUnorderedKeyframes(t *testing.T) {
+	p := testPath()
+	p.Keyframes[2].Time = p.Keyframes[1].Time
+	file := filepath.Join(t.TempDir(), "path.json")
+	assert.NoError(t, p.Save(file))
+	_, err := LoadCameraPath(file)
+	assert.Error(t, err)
+}
@@ -0,0 +1,84 @@
+package gfx
+
+import (
+	"fmt"
+	"sort"
+)
+
+// cameras holds the registered cameras by name.
+var cameras = make(map[string]*Camera)
+
+// RegisterCamera registers c under its name, so it can be switched to and is updated by UpdateCameras.
+func RegisterCamera(c *Camera) error {
+	if _, ok := cameras[c.name]; ok {
+		return fmt.Errorf("a camera named %q is already registered", c.name)
+	}
+	cameras[c.name] = c
+	return nil
+}
+
+// UnregisterCamera removes the camera registered under name. The active and culling cameras can't be removed.
+func UnregisterCamera(name string) error {
+	c, ok := cameras[name]
+	if !ok {
+		return fmt.Errorf("no camera named %q is registered", name)
+	}
+	if c == ActiveCamera || c == CullingCamera {
+		return fmt.Errorf("camera %q is in use and can't be unregistered", name)
+	}
+	delete(cameras, name)
+	return nil
+}
+
+// GetCamera returns the camera registered under name, or nil if there is none.
+func GetCamera(name string) *Camera {
+	return cameras[name]
+}
+
+// CameraNames returns the names of the registered cameras in order.
+func CameraNames() []string {
+	names := make([]string, 0, len(cameras))
+	for name := range cameras {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// SetActiveCamera renders the scene from the camera registered under name.
+func SetActiveCamera(name string) error {
+	c, ok := cameras[name]
+	if !ok {
+		return fmt.Errorf("no camera named %q is registered", name)
+	}
+	ActiveCamera = c
+	return nil
+}
+
+// SetCullingCamera fits shadows to the camera registered under name, and makes terrain detail follow it.
+func SetCullingCamera(name string) error {
+	c, ok := cameras[name]
+	if !ok {
+		return fmt.Errorf("no camera named %q is registered", name)
+	}
+	CullingCamera = c
+	return nil
+}
+
+// UpdateCameras moves every registered camera by d seconds.
+func UpdateCameras(d float64) {
+	for _, c := range cameras {
+		c.Update(d)
+	}
+}
+
+// nextCamera returns the registered camera after c in order, wrapping around to the first.
+func nextCamera(c *Camera) *Camera {
+	names := CameraNames()
+	for i, name := range names {
+		if name == c.name {
+			return cameras[names[(i+1)%len(names)]]
+		}
+	}
+	return cameras[names[0]]
+}
@@ -0,0 +1,142 @@
+package gfx
+
+import (
+	"math"
+
+	"github.com/go-gl/mathgl/mgl32"
+)
+
+// CloudSettings describe the cloud layer drawn by the Atmosphere.
+type CloudSettings struct {
+	// Coverage is the fraction of the sky covered by clouds, in [0, 1]. Zero disables the clouds.
+	Coverage float32
+	// Density is how opaque the clouds are, in [0, 1].
+	Density float32
+	// WindSpeed is how fast the clouds drift, in meters per second at the layer's altitude.
+	WindSpeed float32
+	// WindDirection is the heading the clouds drift toward, in radians around the vertical axis.
+	WindDirection float32
+}
+
+// lerpCloudSettings interpolates every setting from a to b by t in [0, 1]. The wind direction turns the
+// shortest way around.
+func lerpCloudSettings(a, b CloudSettings, t float32) CloudSettings {
+	lerp := func(x, y float32) float32 {
+		return x + (y-x)*t
+	}
+	turn := math.Remainder(float64(b.WindDirection-a.WindDirection), 2*math.Pi)
+	return CloudSettings{
+		Coverage:      lerp(a.Coverage, b.Coverage),
+		Density:       lerp(a.Density, b.Density),
+		WindSpeed:     lerp(a.WindSpeed, b.WindSpeed),
+		WindDirection: a.WindDirection + float32(turn)*t,
+	}
+}
+
+// windVelocity returns how fast, in meters per second along x and z, the wind of c moves the clouds.
+func (c CloudSettings) windVelocity() (float64, float64) {
+	return float64(c.WindSpeed) * math.Cos(float64(c.WindDirection)), float64(c.WindSpeed) * math.Sin(float64(c.WindDirection))
+}
+
+// coverageDiffers reports whether the coverage or density of c and o differ by more than threshold.
+func (c CloudSettings) coverageDiffers(o CloudSettings, threshold float32) bool {
+	return math.Abs(float64(c.Coverage-o.Coverage)) > float64(threshold) ||
+		math.Abs(float64(c.Density-o.Density)) > float64(threshold)
+}
+
+// cloudLayer animates CloudSettings over time. The settings and wind offset at any time are derived from that
+// time and the last call to set or tween alone, so they don't depend on how often setTime was called.
+type cloudLayer struct {
+	settings CloudSettings
+
+	tweenFrom, tweenTo        CloudSettings
+	tweenStart, tweenDuration float64
+	tweening                  bool
+
+	time float64
+	// segmentStart is when the settings were last set or tweened, and segmentOffset how far the wind had moved
+	// the clouds by then.
+	segmentStart  float64
+	segmentOffset mgl32.Vec2
+	// offset is how far the wind has moved the clouds by time.
+	offset mgl32.Vec2
+}
+
+// windSteps is the number of steps the wind is integrated over a tween in.
+const windSteps = 64
+
+// set replaces the cloud settings immediately, cancelling any tween in progress.
+func (c *cloudLayer) set(settings CloudSettings) {
+	c.startSegment()
+	c.settings = settings
+	c.tweening = false
+}
+
+// tween eases from the current settings to target over duration seconds, starting at the current time.
+func (c *cloudLayer) tween(target CloudSettings, duration float64) {
+	if duration <= 0 {
+		c.set(target)
+		return
+	}
+	c.startSegment()
+	c.tweenFrom = c.settings
+	c.tweenTo = target
+	c.tweenStart = c.time
+	c.tweenDuration = duration
+	c.tweening = true
+}
+
+// startSegment starts integrating the wind afresh from the current time.
+func (c *cloudLayer) startSegment() {
+	c.segmentOffset = c.offsetAt(c.time)
+	c.segmentStart = c.time
+}
+
+// setTime advances the tween and the wind to the given time in seconds.
+func (c *cloudLayer) setTime(t float64) {
+	c.time = t
+	if c.tweening {
+		c.settings = c.tweenSettings(t)
+	}
+	c.offset = c.offsetAt(t)
+}
+
+// tweenSettings returns the settings part way through the tween at time t, holding the tween's ends before and
+// after it.
+func (c *cloudLayer) tweenSettings(t float64) CloudSettings {
+	progress := min(max((t-c.tweenStart)/c.tweenDuration, 0), 1)
+	eased := float32(progress * progress * (3 - 2*progress))
+	return lerpCloudSettings(c.tweenFrom, c.tweenTo, eased)
+}
+
+// offsetAt returns how far the wind has moved the clouds by time t.
+func (c *cloudLayer) offsetAt(t float64) mgl32.Vec2 {
+	if !c.tweening {
+		x, z := c.settings.windVelocity()
+		elapsed := t - c.segmentStart
+		return c.segmentOffset.Add(mgl32.Vec2{float32(x * elapsed), float32(z * elapsed)})
+	}
+
+	// The eased part of the tween is integrated with Simpson's rule, and the wind is steady after it.
+	end := min(t, c.tweenStart+c.tweenDuration)
+	h := (end - c.segmentStart) / windSteps
+	var x, z float64
+	for i := 0; i <= windSteps; i++ {
+		weight := 2.0
+		if i == 0 || i == windSteps {
+			weight = 1
+		} else if i%2 == 1 {
+			weight = 4
+		}
+		vx, vz := c.tweenSettings(c.segmentStart + float64(i)*h).windVelocity()
+		x += weight * vx
+		z += weight * vz
+	}
+	x, z = x*h/3, z*h/3
+	if t > end {
+		vx, vz := c.tweenTo.windVelocity()
+		x += vx * (t - end)
+		z += vz * (t - end)
+	}
+	return c.segmentOffset.Add(mgl32.Vec2{float32(x), float32(z)})
+}
@@ -0,0 +1,51 @@
+package gfx
+
+import (
+	"math"
+	"testing"
+
+	"github.com/go-gl/mathgl/mgl32"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCloudWindOffset(t *testing.T) {
+	var c cloudLayer
+	c.set(CloudSettings{WindSpeed: 2, WindDirection: math.Pi / 2})
+	c.setTime(3)
+	assert.InDelta(t, 0, c.offset.Sub(mgl32.Vec2{0, 6}).Len(), 1e-5)
+
+	// Speeding up from 0 to 10 over 2 seconds with smoothstep covers half the distance the full speed would.
+	c.set(CloudSettings{WindSpeed: 0})
+	c.tween(CloudSettings{WindSpeed: 10}, 2)
+	c.setTime(5)
+	assert.InDelta(t, 0, c.offset.Sub(mgl32.Vec2{10, 6}).Len(), 1e-4)
+	c.setTime(7)
+	assert.InDelta(t, 0, c.offset.Sub(mgl32.Vec2{30, 6}).Len(), 1e-4)
+	assert.Equal(t, float32(10), c.settings.WindSpeed)
+}
+
+func TestCloudOffsetOnlyDependsOnTime(t *testing.T) {
+	// Tweening the wind's speed and direction moves the clouds as far whether the time is set once or every frame.
+	layers := []*cloudLayer{{}, {}}
+	for _, c := range layers {
+		c.set(CloudSettings{WindSpeed: 3, WindDirection: .5})
+		c.setTime(1)
+		c.tween(CloudSettings{WindSpeed: 12, WindDirection: 2.5}, 4)
+	}
+	for step := 1.0; step <= 6; step += 1.0 / 60 {
+		layers[0].setTime(step)
+	}
+	layers[0].setTime(6)
+	layers[1].setTime(2.5)
+	layers[1].setTime(6)
+	assert.InDelta(t, 0, layers[0].offset.Sub(layers[1].offset).Len(), 1e-4)
+	assert.Equal(t, layers[0].settings, layers[1].settings)
+}
+
+func TestLerpCloudSettingsTurnsTheShortestWay(t *testing.T) {
+	a := CloudSettings{WindDirection: mgl32.DegToRad(350)}
+	b := CloudSettings{WindDirection: mgl32.DegToRad(10)}
+	half := lerpCloudSettings(a, b, .5)
+	assert.InDelta(t, 0, math.Remainder(float64(half.WindDirection), 2*math.Pi), 1e-5)
+	assert.InDelta(t, 0, math.Remainder(float64(lerpCloudSettings(a, b, 1).WindDirection-b.WindDirection), 2*math.Pi), 1e-5)
+}
@@ -91,6 +91,28 @@ func (f *Frustum) IsBoxIn(min, max mgl32.Vec3) bool {
 	return true
 }
 
+// IsBoxInside returns true if the axis-aligned bounding box is fully inside the frustum.
+func (f *Frustum) IsBoxInside(min, max mgl32.Vec3) bool {
+	for i := 0; i < 6; i++ {
+		// If the most-negative vertex is in front of the plane, the whole box is.
+		p := max
+		if f.Planes[i].Normal.X() >= 0 {
+			p = mgl32.Vec3{min.X(), p.Y(), p.Z()}
+		}
+		if f.Planes[i].Normal.Y() >= 0 {
+			p = mgl32.Vec3{p.X(), min.Y(), p.Z()}
+		}
+		if f.Planes[i].Normal.Z() >= 0 {
+			p = mgl32.Vec3{p.X(), p.Y(), min.Z()}
+		}
+
+		if f.Planes[i].DistanceToPoint(p) < 0 {
+			return false
+		}
+	}
+	return true
+}
+
 // IsSphereIn returns true if the sphere is partially or fully inside the frustum.
 func (f *Frustum) IsSphereIn(center mgl32.Vec3, radius float32) bool {
 	for i := 0; i < 6; i++ {
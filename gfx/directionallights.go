@@ -11,6 +11,10 @@ var (
 	directionalLight DirectionalLight
 
 	directionalLightBuffer uint32
+
+	// directionalLightVersion is incremented every time the directional light changes, so that
+	// anything derived from it (such as the sky's image based lighting) knows to rebuild.
+	directionalLightVersion uint64
 )
 
 // DirectionalLight represents all of the data about the DirectionaLight in the scene.
@@ -42,6 +46,7 @@ func InitDirectionalLights() {
 // UpdateDirectionalLight updates the global directional light by calling the provided function and applying the result of the function.
 func UpdateDirectionalLight(f func(dL DirectionalLight) DirectionalLight) {
 	directionalLight = f(directionalLight)
+	directionalLightVersion++
 
 	// Bind light buffer
 	gl.BindBuffer(gl.SHADER_STORAGE_BUFFER, directionalLightBuffer)
@@ -0,0 +1,176 @@
+package gfx
+
+import (
+	"github.com/brandonnelson3/GoRender/gfx/shaders"
+
+	"github.com/go-gl/gl/v4.5-core/gl"
+	"github.com/go-gl/mathgl/mgl32"
+)
+
+const (
+	// environmentMapSize is the resolution of each face of the captured sky cubemap.
+	environmentMapSize = 128
+	// irradianceSampleMip is the mip of the captured sky cubemap read back for the SH projection.
+	irradianceSampleMip = 3
+	// prefilteredMapSize is the resolution of mip 0 of the prefiltered specular cubemap.
+	prefilteredMapSize = 64
+	// PrefilteredMipLevels is the number of roughness levels stored in the prefiltered specular cubemap.
+	PrefilteredMipLevels = 5
+)
+
+// environmentCaptureProjection is the 90 degree projection used to render each face of a cubemap.
+var environmentCaptureProjection = mgl32.Perspective(mgl32.DegToRad(90), 1, 0.1, 10)
+
+// environment holds the image based lighting derived from the sky: diffuse irradiance as spherical
+// harmonics, and a specular cubemap whose mips are prefiltered for increasing roughness.
+type environment struct {
+	fbo uint32
+
+	// captureVAO draws the capture quad with the sky's shader, prefilterVAO with the prefilter shader.
+	captureVAO, prefilterVAO, vbo uint32
+
+	environmentMap, prefilteredMap uint32
+
+	prefilterShader *shaders.PrefilterShader
+
+	irradianceSH [numSHCoefficients]mgl32.Vec3
+}
+
+// newEnvironment creates an environment which is captured by drawing with the given shader program.
+func newEnvironment(captureProgram uint32) (*environment, error) {
+	prefilterShader, err := shaders.NewPrefilterShader()
+	if err != nil {
+		return nil, err
+	}
+
+	var vbo uint32
+	gl.GenBuffers(1, &vbo)
+
+	var captureVAO uint32
+	gl.GenVertexArrays(1, &captureVAO)
+	gl.BindVertexArray(captureVAO)
+	gl.BindBuffer(gl.ARRAY_BUFFER, vbo)
+	BindSkyVertexAttributes(captureProgram)
+
+	var prefilterVAO uint32
+	gl.GenVertexArrays(1, &prefilterVAO)
+	gl.BindVertexArray(prefilterVAO)
+	gl.BindBuffer(gl.ARRAY_BUFFER, vbo)
+	BindSkyVertexAttributes(prefilterShader.Program())
+	gl.BindVertexArray(0)
+
+	var fbo uint32
+	gl.GenFramebuffers(1, &fbo)
+
+	return &environment{
+		fbo:             fbo,
+		captureVAO:      captureVAO,
+		prefilterVAO:    prefilterVAO,
+		vbo:             vbo,
+		environmentMap:  newEnvironmentCubemap(environmentMapSize, 0),
+		prefilteredMap:  newEnvironmentCubemap(prefilteredMapSize, PrefilteredMipLevels-1),
+		prefilterShader: prefilterShader,
+	}, nil
+}
+
+// newEnvironmentCubemap allocates a mipmapped RGBA16F cubemap. A non-zero maxLevel limits sampling
+// to the mips that will actually be rendered into.
+func newEnvironmentCubemap(size int32, maxLevel int32) uint32 {
+	var texture uint32
+	gl.GenTextures(1, &texture)
+	gl.BindTexture(gl.TEXTURE_CUBE_MAP, texture)
+	for face := uint32(0); face < 6; face++ {
+		gl.TexImage2D(gl.TEXTURE_CUBE_MAP_POSITIVE_X+face, 0, gl.RGBA16F, size, size, 0, gl.RGBA, gl.FLOAT, nil)
+	}
+	gl.GenerateMipmap(gl.TEXTURE_CUBE_MAP)
+	if maxLevel > 0 {
+		gl.TexParameteri(gl.TEXTURE_CUBE_MAP, gl.TEXTURE_MAX_LEVEL, maxLevel)
+	}
+	gl.TexParameteri(gl.TEXTURE_CUBE_MAP, gl.TEXTURE_MIN_FILTER, gl.LINEAR_MIPMAP_LINEAR)
+	gl.TexParameteri(gl.TEXTURE_CUBE_MAP, gl.TEXTURE_MAG_FILTER, gl.LINEAR)
+	gl.TexParameteri(gl.TEXTURE_CUBE_MAP, gl.TEXTURE_WRAP_S, gl.CLAMP_TO_EDGE)
+	gl.TexParameteri(gl.TEXTURE_CUBE_MAP, gl.TEXTURE_WRAP_T, gl.CLAMP_TO_EDGE)
+	gl.TexParameteri(gl.TEXTURE_CUBE_MAP, gl.TEXTURE_WRAP_R, gl.CLAMP_TO_EDGE)
+	gl.BindTexture(gl.TEXTURE_CUBE_MAP, 0)
+	return texture
+}
+
+// cubemapFaceViews returns the 6 view matrices looking out of each cubemap face from center,
+// in gl.TEXTURE_CUBE_MAP_POSITIVE_X + face order.
+func cubemapFaceViews(center mgl32.Vec3) [6]mgl32.Mat4 {
+	return [6]mgl32.Mat4{
+		mgl32.LookAtV(center, center.Add(mgl32.Vec3{1, 0, 0}), mgl32.Vec3{0, -1, 0}),
+		mgl32.LookAtV(center, center.Add(mgl32.Vec3{-1, 0, 0}), mgl32.Vec3{0, -1, 0}),
+		mgl32.LookAtV(center, center.Add(mgl32.Vec3{0, 1, 0}), mgl32.Vec3{0, 0, 1}),
+		mgl32.LookAtV(center, center.Add(mgl32.Vec3{0, -1, 0}), mgl32.Vec3{0, 0, -1}),
+		mgl32.LookAtV(center, center.Add(mgl32.Vec3{0, 0, 1}), mgl32.Vec3{0, -1, 0}),
+		mgl32.LookAtV(center, center.Add(mgl32.Vec3{0, 0, -1}), mgl32.Vec3{0, -1, 0}),
+	}
+}
+
+// capture renders the sky into the environment cubemap and rebuilds the lighting derived from it.
+// The sky's shader must already be in use with environmentCaptureProjection, and setView must
+// update that shader's view matrix.
+func (e *environment) capture(setView func(view mgl32.Mat4)) {
+	var cullingEnabled bool
+	gl.GetBooleanv(gl.CULL_FACE, &cullingEnabled)
+	gl.Disable(gl.CULL_FACE)
+	gl.Disable(gl.DEPTH_TEST)
+	gl.BindFramebuffer(gl.FRAMEBUFFER, e.fbo)
+
+	projection := environmentCaptureProjection
+	views := cubemapFaceViews(mgl32.Vec3{})
+
+	// Step 1: Capture the sky into every face of the environment cubemap.
+	gl.Viewport(0, 0, environmentMapSize, environmentMapSize)
+	for face, view := range views {
+		gl.FramebufferTexture2D(gl.FRAMEBUFFER, gl.COLOR_ATTACHMENT0, gl.TEXTURE_CUBE_MAP_POSITIVE_X+uint32(face), e.environmentMap, 0)
+		setView(view)
+		e.drawQuad(e.captureVAO, view, projection)
+	}
+	gl.BindTexture(gl.TEXTURE_CUBE_MAP, e.environmentMap)
+	gl.GenerateMipmap(gl.TEXTURE_CUBE_MAP)
+
+	// Step 2: Project a low resolution mip onto spherical harmonics for diffuse irradiance.
+	const sampleSize = environmentMapSize >> irradianceSampleMip
+	var faces [6][]float32
+	for face := range faces {
+		faces[face] = make([]float32, sampleSize*sampleSize*3)
+		gl.GetTexImage(gl.TEXTURE_CUBE_MAP_POSITIVE_X+uint32(face), irradianceSampleMip, gl.RGB, gl.FLOAT, gl.Ptr(faces[face]))
+	}
+	gl.BindTexture(gl.TEXTURE_CUBE_MAP, 0)
+	e.irradianceSH = projectIrradianceSH(faces, sampleSize)
+
+	// Step 3: Convolve the environment with increasingly rough GGX lobes, one roughness per mip.
+	e.prefilterShader.Use()
+	e.prefilterShader.Projection.Set(projection)
+	e.prefilterShader.EnvironmentMap.Set(gl.TEXTURE0, 0, e.environmentMap)
+	e.prefilterShader.EnvironmentMapSize.Set(environmentMapSize)
+	for mip := int32(0); mip < PrefilteredMipLevels; mip++ {
+		size := int32(prefilteredMapSize) >> mip
+		gl.Viewport(0, 0, size, size)
+		e.prefilterShader.Roughness.Set(float32(mip) / float32(PrefilteredMipLevels-1))
+		for face, view := range views {
+			gl.FramebufferTexture2D(gl.FRAMEBUFFER, gl.COLOR_ATTACHMENT0, gl.TEXTURE_CUBE_MAP_POSITIVE_X+uint32(face), e.prefilteredMap, mip)
+			e.prefilterShader.View.Set(view)
+			e.drawQuad(e.prefilterVAO, view, projection)
+		}
+	}
+
+	gl.UseProgram(0)
+	gl.BindFramebuffer(gl.FRAMEBUFFER, 0)
+	gl.Enable(gl.DEPTH_TEST)
+	if cullingEnabled {
+		gl.Enable(gl.CULL_FACE)
+	}
+}
+
+// drawQuad draws a quad covering the viewport of the given face view using vao.
+func (e *environment) drawQuad(vao uint32, view, projection mgl32.Mat4) {
+	vertices := skyQuadVertices(view, projection)
+	gl.BindVertexArray(vao)
+	gl.BindBuffer(gl.ARRAY_BUFFER, e.vbo)
+	gl.BufferData(gl.ARRAY_BUFFER, len(vertices)*3*4, gl.Ptr(vertices), gl.DYNAMIC_DRAW)
+	gl.DrawArrays(gl.TRIANGLES, 0, 2*3)
+	gl.BindVertexArray(0)
+}
@@ -7,7 +7,7 @@ const firstPersonCameraModelScale = float32(0.027)
 // InitFirstPersonCameraModel loads the OBJ at path, builds a VAORenderable
 // scaled to a realistic camera size (~0.56 world units wide), and stores it in
 // FirstPersonCameraRenderable. The renderer will automatically draw it at
-// FirstPerson's position whenever ThirdPerson is the active camera.
+// CullingCamera's position whenever another camera is active.
 //
 // Scale rationale: the Blender model is ~21 units wide (X: -10.5 to +10.5).
 // A real 35mm film camera body is ~14 cm wide. At 1 world unit ≈ 0.25 m,
@@ -0,0 +1,129 @@
+package gfx
+
+import (
+	"github.com/go-gl/gl/v4.5-core/gl"
+	"github.com/go-gl/mathgl/mgl32"
+)
+
+const (
+	// fogVolumeWidth, fogVolumeHeight and fogVolumeDepth are the number of froxels the view frustum
+	// is divided into for volumetric lighting. Depth slices are distributed exponentially.
+	fogVolumeWidth  = 160
+	fogVolumeHeight = 90
+	fogVolumeDepth  = 64
+	// fogVolumeGroupSize is the local work group size in x and y of the volumetric compute shaders.
+	fogVolumeGroupSize = 8
+)
+
+// FogSettings controls the height fog and the volumetric light scattered by it.
+type FogSettings struct {
+	// Density is the fog extinction per unit at BaseHeight. Zero disables fog entirely.
+	Density float32
+	// HeightFalloff is how quickly the density decays above BaseHeight.
+	HeightFalloff float32
+	BaseHeight    float32
+	// Anisotropy is the Henyey-Greenstein g term, positive values scatter light forward into light shafts.
+	Anisotropy float32
+	// VolumetricNear and VolumetricFar bound the froxel volume, beyond it fog is evaluated analytically without shadows.
+	VolumetricNear, VolumetricFar float32
+}
+
+var (
+	// Fog is the global fog configuration.
+	Fog = FogSettings{
+		Density:        0.004,
+		HeightFalloff:  0.05,
+		BaseHeight:     0,
+		Anisotropy:     0.6,
+		VolumetricNear: 0.5,
+		VolumetricFar:  200,
+	}
+
+	// scatteringVolume holds per froxel in-scattering and extinction, integratedVolume holds
+	// the accumulated in-scattering and transmittance from the camera to each froxel.
+	scatteringVolume, integratedVolume uint32
+)
+
+// initVolumetricFog allocates the froxel volumes.
+func initVolumetricFog() {
+	scatteringVolume = newFogVolume()
+	integratedVolume = newFogVolume()
+}
+
+func newFogVolume() uint32 {
+	var texture uint32
+	gl.GenTextures(1, &texture)
+	gl.BindTexture(gl.TEXTURE_3D, texture)
+	gl.TexStorage3D(gl.TEXTURE_3D, 1, gl.RGBA16F, fogVolumeWidth, fogVolumeHeight, fogVolumeDepth)
+	gl.TexParameteri(gl.TEXTURE_3D, gl.TEXTURE_MIN_FILTER, gl.LINEAR)
+	gl.TexParameteri(gl.TEXTURE_3D, gl.TEXTURE_MAG_FILTER, gl.LINEAR)
+	gl.TexParameteri(gl.TEXTURE_3D, gl.TEXTURE_WRAP_S, gl.CLAMP_TO_EDGE)
+	gl.TexParameteri(gl.TEXTURE_3D, gl.TEXTURE_WRAP_T, gl.CLAMP_TO_EDGE)
+	gl.TexParameteri(gl.TEXTURE_3D, gl.TEXTURE_WRAP_R, gl.CLAMP_TO_EDGE)
+	gl.BindTexture(gl.TEXTURE_3D, 0)
+	return texture
+}
+
+// renderVolumetricFog injects the light scattered in every froxel, shadowed by the cascades and the
+// shadowed point lights, and then integrates it along each view ray.
+func (renderer *r) renderVolumetricFog(sky Sky, numShadowLights int) {
+	if Fog.Density <= 0 {
+		return
+	}
+
+	inverseView := ActiveCamera.GetView().Inv()
+	inverseProjection := ActiveCamera.GetProjection().Inv()
+
+	var pointShadowColors [MaxPointLightShadows]mgl32.Vec3
+	var pointShadowRadii [MaxPointLightShadows]float32
+	for slot, lightIdx := range GetShadowLightIndices() {
+		if lightIdx < 0 {
+			continue
+		}
+		pointShadowColors[slot] = PointLights[lightIdx].Color.Mul(PointLights[lightIdx].Intensity)
+		pointShadowRadii[slot] = PointLights[lightIdx].Radius
+	}
+
+	ss := renderer.volumetricScatteringShader
+	ss.Use()
+	ss.ScatteringVolume.Set(0, scatteringVolume, gl.WRITE_ONLY, gl.RGBA16F)
+	ss.InverseView.Set(inverseView)
+	ss.InverseProjection.Set(inverseProjection)
+	ss.VolumetricNear.Set(Fog.VolumetricNear)
+	ss.VolumetricFar.Set(Fog.VolumetricFar)
+	ss.FogDensity.Set(Fog.Density)
+	ss.FogHeightFalloff.Set(Fog.HeightFalloff)
+	ss.FogBaseHeight.Set(Fog.BaseHeight)
+	ss.FogAnisotropy.Set(Fog.Anisotropy)
+	ss.SkyAmbient.Set(sky.getEnvironment().irradianceSH[0].Mul(0.282095))
+	ss.SkyLightIntensity.Set(SkyLightIntensity * sky.skyLightScale())
+	ss.LightViewProjs.Set(&CullingCamera.shadowMatrices[0][0], NumberOfCascades)
+	ss.CascadeDepthLimits.Set(&ShadowSplits[0], NumberOfCascades+1)
+	ss.FirstPersonPosition.Set(CullingCamera.GetPosition())
+	ss.FirstPersonForward.Set(CullingCamera.GetForward())
+	ss.ShadowMap1.Set(gl.TEXTURE1, 1, renderer.csmDepthMaps[0])
+	ss.ShadowMap2.Set(gl.TEXTURE2, 2, renderer.csmDepthMaps[1])
+	ss.ShadowMap3.Set(gl.TEXTURE3, 3, renderer.csmDepthMaps[2])
+	ss.ShadowMap4.Set(gl.TEXTURE4, 4, renderer.csmDepthMaps[3])
+	ss.ShadowMap5.Set(gl.TEXTURE6, 6, renderer.csmDepthMaps[4])
+	ss.PointShadowMaps.Set(gl.TEXTURE7, 7, GetPointShadowArray())
+	ss.NumPointShadowLights.Set(int32(numShadowLights))
+	ss.PointShadowLightPositions.Set(&GetPointShadowLightPositions()[0], MaxPointLightShadows)
+	ss.PointShadowLightColors.Set(&pointShadowColors[0][0], MaxPointLightShadows)
+	ss.PointShadowLightRadii.Set(&pointShadowRadii[0], MaxPointLightShadows)
+	ss.PointShadowFarPlane.Set(PointShadowFarPlane)
+	ss.DirectionalLightBuffer.Set(GetDirectionalLightBuffer())
+	gl.DispatchCompute(fogVolumeWidth/fogVolumeGroupSize, (fogVolumeHeight+fogVolumeGroupSize-1)/fogVolumeGroupSize, fogVolumeDepth)
+	gl.MemoryBarrier(gl.SHADER_IMAGE_ACCESS_BARRIER_BIT)
+
+	is := renderer.volumetricIntegrationShader
+	is.Use()
+	is.ScatteringVolume.Set(0, scatteringVolume, gl.READ_ONLY, gl.RGBA16F)
+	is.IntegratedVolume.Set(1, integratedVolume, gl.WRITE_ONLY, gl.RGBA16F)
+	is.InverseProjection.Set(inverseProjection)
+	is.VolumetricNear.Set(Fog.VolumetricNear)
+	is.VolumetricFar.Set(Fog.VolumetricFar)
+	gl.DispatchCompute(fogVolumeWidth/fogVolumeGroupSize, (fogVolumeHeight+fogVolumeGroupSize-1)/fogVolumeGroupSize, 1)
+	gl.MemoryBarrier(gl.TEXTURE_FETCH_BARRIER_BIT)
+	gl.UseProgram(0)
+}
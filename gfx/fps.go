@@ -5,6 +5,7 @@ import (
 	"strconv"
 	"strings"
 
+	"github.com/brandonnelson3/GoRender/input"
 	"github.com/brandonnelson3/GoRender/messagebus"
 	"github.com/go-gl/gl/v4.5-core/gl"
 	"github.com/go-gl/glfw/v3.1/glfw"
@@ -54,12 +55,12 @@ void main() {
 
 // init registers the keyboard listener early during package initialization.
 func init() {
-	messagebus.RegisterType("key", func(m *messagebus.Message) {
-		pressedThisFrame := m.Data2.([]glfw.Key)
-		for _, key := range pressedThisFrame {
-			if key == glfw.KeyF {
+	messagebus.RegisterType("action", func(m *messagebus.Message) {
+		pressedThisFrame := m.Data2.([]input.Action)
+		for _, action := range pressedThisFrame {
+			if action == input.ToggleFPS {
 				fpsEnabled = !fpsEnabled
-				log.Printf("[HUD Overlay] Toggled FPS counter via F key. Active: %v\n", fpsEnabled)
+				log.Printf("[HUD Overlay] Toggled FPS counter. Active: %v\n", fpsEnabled)
 			}
 		}
 	})
@@ -0,0 +1,197 @@
+package gfx
+
+import (
+	"bufio"
+	"fmt"
+	"image"
+	"image/draw"
+	"io"
+	"math"
+	"strings"
+
+	"github.com/brandonnelson3/GoRender/loader"
+	"github.com/go-gl/mathgl/mgl32"
+)
+
+const hdrExt = ".hdr"
+
+// maxHDRSize limits the width and height of a Radiance .hdr image, so a corrupt header can't ask for a huge allocation.
+const maxHDRSize = 1 << 15
+
+// hdrImage is a linear RGB image with 3 float32 values per pixel, stored row by row from the top.
+type hdrImage struct {
+	width, height int
+	pix           []float32
+}
+
+// at returns the color of the pixel at (x, y).
+func (img *hdrImage) at(x, y int) mgl32.Vec3 {
+	i := (y*img.width + x) * 3
+	return mgl32.Vec3{img.pix[i], img.pix[i+1], img.pix[i+2]}
+}
+
+// sampleBilinear returns the bilinearly filtered color at the normalized coordinates (u, v).
+// u wraps around horizontally and v is clamped vertically, as suits an equirectangular map.
+func (img *hdrImage) sampleBilinear(u, v float32) mgl32.Vec3 {
+	x := u*float32(img.width) - 0.5
+	y := mgl32.Clamp(v*float32(img.height)-0.5, 0, float32(img.height-1))
+	x0, y0 := int(math.Floor(float64(x))), int(y)
+	fx, fy := x-float32(x0), y-float32(y0)
+
+	wrap := func(x int) int {
+		return ((x % img.width) + img.width) % img.width
+	}
+	x1, y1 := wrap(x0+1), y0+1
+	x0 = wrap(x0)
+	if y1 >= img.height {
+		y1 = img.height - 1
+	}
+
+	top := img.at(x0, y0).Mul(1 - fx).Add(img.at(x1, y0).Mul(fx))
+	bottom := img.at(x0, y1).Mul(1 - fx).Add(img.at(x1, y1).Mul(fx))
+	return top.Mul(1 - fy).Add(bottom.Mul(fy))
+}
+
+// loadHDRImage loads a Radiance .hdr file as linear radiance, or a .png file as colors in [0, 1].
+func loadHDRImage(file string) (*hdrImage, error) {
+	r, err := loader.Load(file)
+	if err != nil {
+		return nil, err
+	}
+	switch {
+	case strings.HasSuffix(file, hdrExt):
+		return decodeRadianceHDR(r)
+	case strings.HasSuffix(file, pngExt):
+		img, _, err := image.Decode(r)
+		if err != nil {
+			return nil, err
+		}
+		rgba := image.NewRGBA(img.Bounds())
+		draw.Draw(rgba, rgba.Bounds(), img, img.Bounds().Min, draw.Src)
+		size := rgba.Rect.Size()
+		result := &hdrImage{width: size.X, height: size.Y, pix: make([]float32, size.X*size.Y*3)}
+		for y := 0; y < size.Y; y++ {
+			for x := 0; x < size.X; x++ {
+				src := y*rgba.Stride + x*4
+				dst := (y*size.X + x) * 3
+				for c := 0; c < 3; c++ {
+					result.pix[dst+c] = float32(rgba.Pix[src+c]) / 255
+				}
+			}
+		}
+		return result, nil
+	}
+	return nil, fmt.Errorf("Attempted to load environment image from unsupported file type: %v", file)
+}
+
+// decodeRadianceHDR decodes a Radiance RGBE image in the standard -Y +X orientation,
+// with either flat or run length encoded scanlines.
+func decodeRadianceHDR(r io.Reader) (*hdrImage, error) {
+	br := bufio.NewReader(r)
+
+	magic, err := br.ReadString('\n')
+	if err != nil || !strings.HasPrefix(magic, "#?") {
+		return nil, fmt.Errorf("not a radiance hdr file")
+	}
+	for {
+		line, err := br.ReadString('\n')
+		if err != nil {
+			return nil, fmt.Errorf("reading hdr header: %v", err)
+		}
+		line = strings.TrimSpace(line)
+		if line == "" {
+			break
+		}
+		if strings.HasPrefix(line, "FORMAT=") && line != "FORMAT=32-bit_rle_rgbe" {
+			return nil, fmt.Errorf("unsupported hdr pixel format %q", line)
+		}
+	}
+
+	var width, height int
+	resolution, err := br.ReadString('\n')
+	if err != nil {
+		return nil, fmt.Errorf("reading hdr resolution: %v", err)
+	}
+	if _, err := fmt.Sscanf(resolution, "-Y %d +X %d", &height, &width); err != nil {
+		return nil, fmt.Errorf("unsupported hdr resolution %q", strings.TrimSpace(resolution))
+	}
+	if width <= 0 || height <= 0 || width > maxHDRSize || height > maxHDRSize {
+		return nil, fmt.Errorf("invalid hdr size %dx%d", width, height)
+	}
+
+	img := &hdrImage{width: width, height: height, pix: make([]float32, width*height*3)}
+	scanline := make([]byte, width*4)
+	for y := 0; y < height; y++ {
+		if err := readRGBEScanline(br, scanline); err != nil {
+			return nil, fmt.Errorf("reading hdr scanline %d: %v", y, err)
+		}
+		for x := 0; x < width; x++ {
+			e := scanline[x*4+3]
+			if e == 0 {
+				continue
+			}
+			f := float32(math.Ldexp(1, int(e)-(128+8)))
+			i := (y*width + x) * 3
+			img.pix[i] = float32(scanline[x*4]) * f
+			img.pix[i+1] = float32(scanline[x*4+1]) * f
+			img.pix[i+2] = float32(scanline[x*4+2]) * f
+		}
+	}
+	return img, nil
+}
+
+// readRGBEScanline reads one scanline of interleaved RGBE pixels into scanline.
+func readRGBEScanline(br *bufio.Reader, scanline []byte) error {
+	width := len(scanline) / 4
+	header, err := br.Peek(4)
+	if err != nil {
+		return err
+	}
+
+	// Scanlines outside this width range, or without the marker, are stored flat.
+	if width < 8 || width > 0x7fff || header[0] != 2 || header[1] != 2 || header[2]&0x80 != 0 {
+		_, err := io.ReadFull(br, scanline)
+		return err
+	}
+	if int(header[2])<<8|int(header[3]) != width {
+		return fmt.Errorf("scanline width mismatch")
+	}
+	br.Discard(4)
+
+	// Each channel is run length encoded separately.
+	for c := 0; c < 4; c++ {
+		for x := 0; x < width; {
+			count, err := br.ReadByte()
+			if err != nil {
+				return err
+			}
+			if count > 128 {
+				run := int(count - 128)
+				value, err := br.ReadByte()
+				if err != nil {
+					return err
+				}
+				if x+run > width {
+					return fmt.Errorf("run overflows scanline")
+				}
+				for ; run > 0; run-- {
+					scanline[x*4+c] = value
+					x++
+				}
+			} else {
+				if count == 0 || x+int(count) > width {
+					return fmt.Errorf("invalid literal run")
+				}
+				for ; count > 0; count-- {
+					value, err := br.ReadByte()
+					if err != nil {
+						return err
+					}
+					scanline[x*4+c] = value
+					x++
+				}
+			}
+		}
+	}
+	return nil
+}
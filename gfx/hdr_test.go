@@ -0,0 +1,95 @@
+package gfx
+
+import (
+	"bytes"
+	"fmt"
+	"math"
+	"testing"
+
+	"github.com/go-gl/mathgl/mgl32"
+	"github.com/stretchr/testify/assert"
+)
+
+// hdrFile returns a Radiance .hdr file with the given size and raw scanline data.
+func hdrFile(width, height int, scanlines []byte) []byte {
+	var b bytes.Buffer
+	fmt.Fprintf(&b, "#?RADIANCE\nFORMAT=32-bit_rle_rgbe\n\n-Y %d +X %d\n", height, width)
+	b.Write(scanlines)
+	return b.Bytes()
+}
+
+func TestDecodeRadianceHDRFlat(t *testing.T) {
+	// Pixels narrower than 8 are always flat; an exponent of 129 scales the mantissa by 2/256, and 0 is black.
+	img, err := decodeRadianceHDR(bytes.NewReader(hdrFile(2, 2, []byte{
+		128, 64, 32, 129, 0, 0, 0, 0,
+		255, 255, 255, 0, 128, 128, 128, 130,
+	})))
+	assert.NoError(t, err)
+	assert.Equal(t, 2, img.width)
+	assert.Equal(t, 2, img.height)
+	assert.Equal(t, mgl32.Vec3{1, .5, .25}, img.at(0, 0))
+	assert.Equal(t, mgl32.Vec3{}, img.at(1, 0))
+	assert.Equal(t, mgl32.Vec3{}, img.at(0, 1))
+	assert.Equal(t, mgl32.Vec3{2, 2, 2}, img.at(1, 1))
+}
+
+func TestDecodeRadianceHDRRunLength(t *testing.T) {
+	// An 8 pixel scanline with each channel encoded separately: red as one run, green and blue as
+	// a run followed by literals, and the exponent as one run.
+	const width = 8
+	scanline := []byte{2, 2, 0, width}
+	scanline = append(scanline, 128+8, 128)
+	scanline = append(scanline, 128+4, 64, 4, 0, 1, 2, 3)
+	scanline = append(scanline, 128+6, 32, 2, 255, 0)
+	scanline = append(scanline, 128+8, 129)
+	img, err := decodeRadianceHDR(bytes.NewReader(hdrFile(width, 1, scanline)))
+	assert.NoError(t, err)
+	for x, want := range []mgl32.Vec3{
+		{1, .5, .25}, {1, .5, .25}, {1, .5, .25}, {1, .5, .25},
+		{1, 0, .25}, {1, 1.0 / 128, .25}, {1, 2.0 / 128, 255.0 / 128}, {1, 3.0 / 128, 0},
+	} {
+		assert.Equal(t, want, img.at(x, 0), "pixel %d", x)
+	}
+}
+
+func TestDecodeRadianceHDRErrors(t *testing.T) {
+	for name, file := range map[string][]byte{
+		"empty size":    hdrFile(0, 0, nil),
+		"negative size": hdrFile(-4, 2, nil),
+		"huge size":     hdrFile(maxHDRSize+1, 1, nil),
+		"not hdr":       []byte("P6\n2 2\n255\n"),
+		"truncated":     hdrFile(2, 2, []byte{1, 2, 3}),
+		"overlong run":  hdrFile(8, 1, []byte{2, 2, 0, 8, 128 + 9, 1}),
+	} {
+		_, err := decodeRadianceHDR(bytes.NewReader(file))
+		assert.Error(t, err, name)
+	}
+}
+
+func TestEquirectangularToCubemap(t *testing.T) {
+	// An image whose color is its direction maps each cubemap texel back to its own direction.
+	const width, height, size = 256, 128, 8
+	img := &hdrImage{width: width, height: height, pix: make([]float32, width*height*3)}
+	for y := 0; y < height; y++ {
+		theta := math.Pi * (float64(y) + .5) / height
+		for x := 0; x < width; x++ {
+			phi := 2*math.Pi*(float64(x)+.5)/width - math.Pi
+			d := mgl32.Vec3{float32(math.Sin(theta) * math.Sin(phi)), float32(math.Cos(theta)), float32(-math.Sin(theta) * math.Cos(phi))}
+			copy(img.pix[(y*width+x)*3:], d[:])
+		}
+	}
+
+	faces := equirectangularToCubemap(img, size)
+	for face := range faces {
+		for y := 0; y < size; y++ {
+			for x := 0; x < size; x++ {
+				i := (y*size + x) * 3
+				got := mgl32.Vec3{faces[face][i], faces[face][i+1], faces[face][i+2]}
+				want := cubemapTexelDirection(face, x, y, size)
+				assert.InDelta(t, 0, got.Sub(want).Len(), .05, "face %d texel %d,%d", face, x, y)
+			}
+		}
+	}
+	// The center of the image faces -Z.
+	assert.InDelta(t, 0, img.sampleBilinear(.5, .5).Sub(mgl32.Vec3{0, 0, -1}).Len(), .05)
+}
@@ -4,9 +4,9 @@ import (
 	"log"
 
 	"github.com/brandonnelson3/GoRender/gfx/shaders"
+	"github.com/brandonnelson3/GoRender/input"
 	"github.com/brandonnelson3/GoRender/messagebus"
 	"github.com/go-gl/gl/v4.5-core/gl"
-	"github.com/go-gl/glfw/v3.1/glfw"
 	"github.com/go-gl/mathgl/mgl32"
 )
 
@@ -67,11 +67,11 @@ func InitHUD() {
 	BindPipVertexAttributes(hudShader.Program())
 	gl.BindVertexArray(0)
 
-	// Register key callback to toggle the HUD using the 'H' key
-	messagebus.RegisterType("key", func(m *messagebus.Message) {
-		pressedThisFrame := m.Data2.([]glfw.Key)
-		for _, key := range pressedThisFrame {
-			if key == glfw.KeyH {
+	// Register action callback to toggle the HUD
+	messagebus.RegisterType("action", func(m *messagebus.Message) {
+		pressedThisFrame := m.Data2.([]input.Action)
+		for _, action := range pressedThisFrame {
+			if action == input.ToggleHUD {
 				hudEnabled = !hudEnabled
 				log.Printf("[HUD Overlay] Toggled Google Slides HUD. Active: %v\n", hudEnabled)
 			}
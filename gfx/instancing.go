@@ -0,0 +1,218 @@
+package gfx
+
+import (
+	"time"
+	"unsafe"
+
+	"github.com/go-gl/gl/v4.5-core/gl"
+	"github.com/go-gl/mathgl/mgl32"
+)
+
+// drawArraysIndirectCommand is the layout glMultiDrawArraysIndirect reads each draw from.
+type drawArraysIndirectCommand struct {
+	count, instanceCount, first, baseInstance uint32
+}
+
+// instanceBinding is the vertex buffer binding the instance transforms are read through. The vertex
+// attributes set up by BindVertexAttributes use the bindings matching their locations.
+const instanceBinding = 3
+
+// instanceBuffers are the GL buffers an instanced renderable is culled and drawn from.
+type instanceBuffers struct {
+	// vao draws the renderable's vertices with the transforms of whichever buffer is bound to
+	// instanceBinding. Every copy of a renderable has its own, so copies never draw each other's instances.
+	vao uint32
+
+	// transforms holds every instance's transform, and is mapped for as long as it exists so instances can
+	// be moved without uploading them again. stale is set once the renderable's transforms are replaced, until
+	// they're copied into mapped.
+	transforms uint32
+	mapped     []mgl32.Mat4
+	stale      bool
+	// fence is signalled once the GPU has finished the last commands reading transforms, or 0 if there are
+	// none in flight. mapped isn't written until then.
+	fence uintptr
+
+	// visible holds the transforms of the instances left by the last cull, and commands the draws of every
+	// portion of the renderable for them.
+	visible  uint32
+	commands uint32
+	draws    []drawArraysIndirectCommand
+}
+
+// newInstanceBuffers creates the buffers for transforms, drawing each of portions of the vertices in vbo.
+func newInstanceBuffers(vbo uint32, transforms []mgl32.Mat4, portions []RenderablePortion) *instanceBuffers {
+	b := &instanceBuffers{}
+	size := len(transforms) * int(unsafe.Sizeof(mgl32.Mat4{}))
+
+	const mapping = gl.MAP_WRITE_BIT | gl.MAP_PERSISTENT_BIT | gl.MAP_COHERENT_BIT
+	gl.CreateBuffers(1, &b.transforms)
+	gl.NamedBufferStorage(b.transforms, size, gl.Ptr(transforms), mapping)
+	mapped := gl.MapNamedBufferRange(b.transforms, 0, size, mapping)
+	b.mapped = unsafe.Slice((*mgl32.Mat4)(mapped), len(transforms))
+
+	gl.CreateBuffers(1, &b.visible)
+	gl.NamedBufferStorage(b.visible, size, nil, 0)
+
+	b.draws = make([]drawArraysIndirectCommand, len(portions))
+	for i, p := range portions {
+		b.draws[i] = drawArraysIndirectCommand{count: uint32(p.numIndex), first: uint32(p.startIndex)}
+	}
+	gl.CreateBuffers(1, &b.commands)
+	gl.NamedBufferStorage(b.commands, len(b.draws)*int(unsafe.Sizeof(drawArraysIndirectCommand{})), gl.Ptr(b.draws), gl.DYNAMIC_STORAGE_BIT)
+
+	gl.GenVertexArrays(1, &b.vao)
+	gl.BindVertexArray(b.vao)
+	gl.BindBuffer(gl.ARRAY_BUFFER, vbo)
+	BindVertexAttributes(Renderer.colorShader.Program())
+	gl.BindBuffer(gl.ARRAY_BUFFER, 0)
+	gl.BindVertexArray(0)
+	// The instance transform takes the four attribute locations from 3, a column each.
+	for i := uint32(0); i < 4; i++ {
+		loc := uint32(3) + i
+		gl.EnableVertexArrayAttrib(b.vao, loc)
+		gl.VertexArrayAttribFormat(b.vao, loc, 4, gl.FLOAT, false, i*4*4)
+		gl.VertexArrayAttribBinding(b.vao, loc, instanceBinding)
+	}
+	gl.VertexArrayBindingDivisor(b.vao, instanceBinding, 1)
+	return b
+}
+
+// write waits until the GPU has finished reading the transforms, so they can be written through mapped.
+func (b *instanceBuffers) write() {
+	if b.fence == 0 {
+		return
+	}
+	for {
+		status := gl.ClientWaitSync(b.fence, gl.SYNC_FLUSH_COMMANDS_BIT, uint64(time.Second))
+		if status != gl.TIMEOUT_EXPIRED {
+			break
+		}
+	}
+	gl.DeleteSync(b.fence)
+	b.fence = 0
+}
+
+// read fences the commands issued so far, which may read the transforms.
+func (b *instanceBuffers) read() {
+	if b.fence != 0 {
+		gl.DeleteSync(b.fence)
+	}
+	b.fence = gl.FenceSync(gl.SYNC_GPU_COMMANDS_COMPLETE, 0)
+}
+
+// delete frees the buffers and the vao.
+func (b *instanceBuffers) delete() {
+	if b.fence != 0 {
+		gl.DeleteSync(b.fence)
+		b.fence = 0
+	}
+	gl.DeleteVertexArrays(1, &b.vao)
+	gl.UnmapNamedBuffer(b.transforms)
+	b.mapped = nil
+	buffers := []uint32{b.transforms, b.visible, b.commands}
+	gl.DeleteBuffers(int32(len(buffers)), &buffers[0])
+}
+
+// SetInstanceTransforms replaces the transforms of every instance of the renderable, from the next time it is
+// drawn. An empty slice draws the renderable once with its own transform. The renderable keeps transforms, so
+// they must only be changed through SetInstanceTransform after.
+func (r *VAORenderable) SetInstanceTransforms(transforms []mgl32.Mat4) {
+	r.instanceTransforms = transforms
+	if r.instances != nil {
+		r.instances.stale = true
+	}
+	r.bounds.valid = false
+}
+
+// SetInstanceTransform moves instance i of the renderable to transform m, from the next time it is drawn. It
+// waits for the GPU to finish any draws of the renderable still reading the instance's old transform.
+func (r *VAORenderable) SetInstanceTransform(i int, m mgl32.Mat4) {
+	r.instanceTransforms[i] = m
+	if r.instances != nil {
+		r.instances.write()
+		r.instances.mapped[i] = m
+	}
+	r.bounds.valid = false
+}
+
+// uploadInstances creates the instance buffers on first use, and again whenever instanceTransforms is
+// replaced by a slice of a different length. A replacement of the same length is copied into them.
+func (r *VAORenderable) uploadInstances() {
+	if r.instances != nil && len(r.instances.mapped) != len(r.instanceTransforms) {
+		r.ReleaseInstances()
+	}
+	if r.instances == nil {
+		r.instances = newInstanceBuffers(r.vbo, r.instanceTransforms, r.portions)
+	} else if r.instances.stale {
+		r.instances.write()
+		copy(r.instances.mapped, r.instanceTransforms)
+		r.instances.stale = false
+	}
+}
+
+// bindInstanceAttributes binds the renderable's instance vao, reading the instance transforms from buffer.
+func (r *VAORenderable) bindInstanceAttributes(buffer uint32) {
+	gl.VertexArrayVertexBuffer(r.instances.vao, instanceBinding, buffer, 0, int32(unsafe.Sizeof(mgl32.Mat4{})))
+	gl.BindVertexArray(r.instances.vao)
+}
+
+// cullInstances compacts the instances within frustum, and nearer the culling camera than
+// InstanceDrawDistance, into the visible buffer and counts them into the draw commands.
+func (r *VAORenderable) cullInstances(frustum *Frustum) {
+	var planes [6]mgl32.Vec4
+	for i, p := range frustum.Planes {
+		planes[i] = p.Normal.Vec4(p.Distance)
+	}
+
+	s := Renderer.instanceCullingShader
+	s.Use()
+	s.InstanceCount.Set(uint32(len(r.instanceTransforms)))
+	s.CommandCount.Set(uint32(len(r.instances.draws)))
+	s.FrustumPlanes.Set(&planes[0][0], int32(len(planes)))
+	s.LocalMin.Set(r.LocalMin)
+	s.LocalMax.Set(r.LocalMax)
+	s.CameraPosition.Set(CullingCamera.GetPosition())
+	s.DrawDistance.Set(r.InstanceDrawDistance)
+
+	// The draws start out drawing no instances, and the cull counts in those left.
+	gl.NamedBufferSubData(r.instances.commands, 0, len(r.instances.draws)*int(unsafe.Sizeof(drawArraysIndirectCommand{})), gl.Ptr(r.instances.draws))
+	s.InstanceBuffer.Set(r.instances.transforms)
+	s.VisibleInstanceBuffer.Set(r.instances.visible)
+	s.DrawCommandBuffer.Set(r.instances.commands)
+	gl.DispatchCompute(uint32(len(r.instanceTransforms)+63)/64, 1, 1)
+	gl.MemoryBarrier(gl.COMMAND_BARRIER_BIT | gl.VERTEX_ATTRIB_ARRAY_BARRIER_BIT)
+}
+
+// drawInstances draws the renderable's instances within frustum, or all of them if it is nil, with the
+// program use binds. setDiffuse binds the diffuse texture of each portion drawn.
+func (r *VAORenderable) drawInstances(frustum *Frustum, use func(), setDiffuse func(uint32)) {
+	r.uploadInstances()
+	if frustum == nil {
+		r.bindInstanceAttributes(r.instances.transforms)
+		for _, p := range r.portions {
+			setDiffuse(p.diffuse)
+			gl.DrawArraysInstanced(r.renderStyle, p.startIndex, p.numIndex, int32(len(r.instanceTransforms)))
+		}
+		r.instances.read()
+		return
+	}
+
+	r.cullInstances(frustum)
+	r.instances.read()
+	use()
+	r.bindInstanceAttributes(r.instances.visible)
+	gl.BindBuffer(gl.DRAW_INDIRECT_BUFFER, r.instances.commands)
+	// Portions sharing a texture are drawn together.
+	stride := int(unsafe.Sizeof(drawArraysIndirectCommand{}))
+	for first := 0; first < len(r.portions); {
+		last := first + 1
+		for last < len(r.portions) && r.portions[last].diffuse == r.portions[first].diffuse {
+			last++
+		}
+		setDiffuse(r.portions[first].diffuse)
+		gl.MultiDrawArraysIndirect(r.renderStyle, gl.PtrOffset(first*stride), int32(last-first), 0)
+		first = last
+	}
+	gl.BindBuffer(gl.DRAW_INDIRECT_BUFFER, 0)
+}
@@ -0,0 +1,258 @@
+package gfx
+
+import (
+	"fmt"
+	"math"
+	"sync/atomic"
+	"time"
+
+	"github.com/brandonnelson3/GoRender/benchmark"
+	"github.com/brandonnelson3/GoRender/gfx/shaders"
+	"github.com/brandonnelson3/GoRender/input"
+	"github.com/brandonnelson3/GoRender/messagebus"
+
+	"github.com/go-gl/gl/v4.5-core/gl"
+	"github.com/go-gl/mathgl/mgl32"
+)
+
+// hiZReadWidth is the widest level of a depth pyramid which is read back to cull against.
+const hiZReadWidth = 128
+
+var (
+	// OcclusionCulling skips drawing renderables hidden behind what was drawn the frame before. It is toggled
+	// with O.
+	OcclusionCulling = true
+
+	// occlusionTested and occlusionCulled count the renderables tested and culled during the last frame.
+	occlusionTested, occlusionCulled atomic.Int64
+)
+
+// hiZ is a pyramid of the farthest depth a view saw over ever larger areas of its depth map. A small level of
+// it is read back once it has been built, and the next frame culls what lies behind it.
+type hiZ struct {
+	texture       uint32
+	width, height int32
+	levels        int32
+	// readLevel is the level read back, of size readWidth by readHeight.
+	readLevel             int32
+	readWidth, readHeight int32
+
+	// pixels is the buffer the read level is copied into, and fence is signalled once it has been. camera
+	// and viewProjection are those the pending level was built from.
+	pixels         uint32
+	fence          uintptr
+	camera         *Camera
+	viewProjection mgl32.Mat4
+
+	// depth is the last read level, as seen by depthCamera through depthViewProjection.
+	depth               []float32
+	depthCamera         *Camera
+	depthViewProjection mgl32.Mat4
+}
+
+// allocate creates the pyramid for a depth map of the given size, if it isn't already.
+func (h *hiZ) allocate(width, height int32) {
+	// Level 0 is half the size of the depth map.
+	width, height = max((width+1)/2, 1), max((height+1)/2, 1)
+	if h.texture != 0 && h.width == width && h.height == height {
+		return
+	}
+	h.Delete()
+
+	h.width, h.height = width, height
+	h.levels = 1
+	for w, ht := width, height; w > 1 || ht > 1; w, ht = max((w+1)/2, 1), max((ht+1)/2, 1) {
+		if w > hiZReadWidth {
+			h.readLevel++
+		}
+		h.levels++
+	}
+	h.readWidth, h.readHeight = h.levelSize(h.readLevel)
+
+	gl.CreateTextures(gl.TEXTURE_2D, 1, &h.texture)
+	gl.TextureStorage2D(h.texture, h.levels, gl.R32F, width, height)
+	gl.TextureParameteri(h.texture, gl.TEXTURE_MIN_FILTER, gl.NEAREST_MIPMAP_NEAREST)
+	gl.TextureParameteri(h.texture, gl.TEXTURE_MAG_FILTER, gl.NEAREST)
+
+	gl.CreateBuffers(1, &h.pixels)
+	gl.NamedBufferStorage(h.pixels, int(h.readWidth*h.readHeight)*4, nil, gl.DYNAMIC_STORAGE_BIT)
+}
+
+// levelSize returns the size of the given level of the pyramid.
+func (h *hiZ) levelSize(level int32) (int32, int32) {
+	w, ht := h.width, h.height
+	for range level {
+		w, ht = max((w+1)/2, 1), max((ht+1)/2, 1)
+	}
+	return w, ht
+}
+
+// Delete frees the GL resources of the pyramid. It is allocated again when it is next built.
+func (h *hiZ) Delete() {
+	if h.texture == 0 {
+		return
+	}
+	h.reset()
+	gl.DeleteTextures(1, &h.texture)
+	gl.DeleteBuffers(1, &h.pixels)
+	h.texture, h.pixels = 0, 0
+	h.readLevel = 0
+}
+
+// build reduces depthMap, just rendered by camera through viewProjection, into the pyramid and starts reading
+// it back.
+func (h *hiZ) build(shader *shaders.HiZShader, depthMap uint32, width, height int32, camera *Camera, viewProjection mgl32.Mat4) {
+	h.allocate(width, height)
+
+	shader.Use()
+	for level := int32(0); level < h.levels; level++ {
+		if level == 0 {
+			shader.FromDepth.Set(1)
+			shader.DepthMap.Set(gl.TEXTURE5, 5, depthMap)
+		} else {
+			shader.FromDepth.Set(0)
+			shader.Source.Set(0, h.texture, level-1, gl.READ_ONLY, gl.R32F)
+		}
+		shader.Destination.Set(1, h.texture, level, gl.WRITE_ONLY, gl.R32F)
+		w, ht := h.levelSize(level)
+		gl.DispatchCompute(uint32(w+7)/8, uint32(ht+7)/8, 1)
+		gl.MemoryBarrier(gl.SHADER_IMAGE_ACCESS_BARRIER_BIT)
+	}
+	gl.UseProgram(0)
+	gl.MemoryBarrier(gl.TEXTURE_UPDATE_BARRIER_BIT | gl.PIXEL_BUFFER_BARRIER_BIT)
+
+	gl.BindBuffer(gl.PIXEL_PACK_BUFFER, h.pixels)
+	gl.GetTextureImage(h.texture, h.readLevel, gl.RED, gl.FLOAT, h.readWidth*h.readHeight*4, gl.PtrOffset(0))
+	gl.BindBuffer(gl.PIXEL_PACK_BUFFER, 0)
+	if h.fence != 0 {
+		gl.DeleteSync(h.fence)
+	}
+	h.fence = gl.FenceSync(gl.SYNC_GPU_COMMANDS_COMPLETE, 0)
+	h.camera, h.viewProjection = camera, viewProjection
+}
+
+// resolve takes the level read back since the pyramid was last built, if it has arrived, as the depth to cull
+// against.
+func (h *hiZ) resolve() {
+	if h.fence == 0 {
+		return
+	}
+	if status := gl.ClientWaitSync(h.fence, 0, 0); status != gl.ALREADY_SIGNALED && status != gl.CONDITION_SATISFIED {
+		return
+	}
+	gl.DeleteSync(h.fence)
+	h.fence = 0
+
+	if len(h.depth) != int(h.readWidth*h.readHeight) {
+		h.depth = make([]float32, h.readWidth*h.readHeight)
+	}
+	gl.GetNamedBufferSubData(h.pixels, 0, len(h.depth)*4, gl.Ptr(h.depth))
+	h.depthCamera, h.depthViewProjection = h.camera, h.viewProjection
+}
+
+// reset forgets the depth read back, so nothing is culled until the pyramid is built and read back again.
+func (h *hiZ) reset() {
+	if h.fence != 0 {
+		gl.DeleteSync(h.fence)
+		h.fence = 0
+	}
+	h.depth, h.depthCamera = nil, nil
+}
+
+// renderableOccluded reports whether r, with the given bounds, lies wholly behind the depth last read back for
+// camera. Instanced renderables are occluded once every one of their instances is.
+func (h *hiZ) renderableOccluded(camera *Camera, r Renderable, boxMin, boxMax mgl32.Vec3) bool {
+	if h.occluded(camera, boxMin, boxMax) {
+		return true
+	}
+	instanced, ok := r.(*VAORenderable)
+	if !ok || len(instanced.instanceTransforms) == 0 {
+		return false
+	}
+	for i := range instanced.instanceTransforms {
+		instanceMin, instanceMax := instanced.GetInstanceBounds(i)
+		if !h.occluded(camera, instanceMin, instanceMax) {
+			return false
+		}
+	}
+	return true
+}
+
+// occluded reports whether the box lies wholly behind the depth last read back for camera. Anything the depth
+// can't tell about, such as a box in front of the near plane or outside the view it was read from, isn't.
+func (h *hiZ) occluded(camera *Camera, boxMin, boxMax mgl32.Vec3) bool {
+	if h.depth == nil || h.depthCamera != camera {
+		return false
+	}
+	lower, upper := mgl32.Vec2{math.MaxFloat32, math.MaxFloat32}, mgl32.Vec2{-math.MaxFloat32, -math.MaxFloat32}
+	nearest := float32(1)
+	for i := 0; i < 8; i++ {
+		corner := boxMin.Vec4(1)
+		if i&1 != 0 {
+			corner[0] = boxMax.X()
+		}
+		if i&2 != 0 {
+			corner[1] = boxMax.Y()
+		}
+		if i&4 != 0 {
+			corner[2] = boxMax.Z()
+		}
+		clip := h.depthViewProjection.Mul4x1(corner)
+		if clip.W() <= 0 || clip.Z() < -clip.W() {
+			return false
+		}
+		ndc := clip.Vec3().Mul(1 / clip.W())
+		for a := 0; a < 2; a++ {
+			lower[a] = min(lower[a], ndc[a]*0.5+0.5)
+			upper[a] = max(upper[a], ndc[a]*0.5+0.5)
+		}
+		nearest = min(nearest, ndc.Z()*0.5+0.5)
+	}
+	if lower.X() > 1 || lower.Y() > 1 || upper.X() < 0 || upper.Y() < 0 {
+		return false
+	}
+
+	w, ht := int(h.readWidth), int(h.readHeight)
+	x0, x1 := readTexel(lower.X(), w), readTexel(upper.X(), w)
+	y0, y1 := readTexel(lower.Y(), ht), readTexel(upper.Y(), ht)
+	for y := y0; y <= y1; y++ {
+		for x := x0; x <= x1; x++ {
+			if h.depth[y*w+x] >= nearest {
+				return false
+			}
+		}
+	}
+	return true
+}
+
+// readTexel returns the texel of a read back level of the given size covering screen coordinate s.
+func readTexel(s float32, size int) int {
+	return min(int(mgl32.Clamp(s, 0, 1)*float32(size)), size-1)
+}
+
+// countOcclusion records the renderables tested and culled against the depth pyramids during a frame.
+func countOcclusion(tested, culled int) {
+	occlusionTested.Store(int64(tested))
+	occlusionCulled.Store(int64(culled))
+	benchmark.Count("Occlusion: Tested", tested)
+	benchmark.Count("Occlusion: Culled", culled)
+}
+
+func initOcclusion() {
+	messagebus.RegisterType("action", func(m *messagebus.Message) {
+		for _, action := range m.Data2.([]input.Action) {
+			if action == input.ToggleOcclusion {
+				OcclusionCulling = !OcclusionCulling
+			}
+		}
+	})
+	go func() {
+		for range time.Tick(time.Millisecond * 100) {
+			if benchmark.RecordMode {
+				continue
+			}
+			messagebus.SendAsync(&messagebus.Message{Type: "console", Data1: "occlusion_tested", Data2: fmt.Sprintf("%d", occlusionTested.Load())})
+			messagebus.SendAsync(&messagebus.Message{Type: "console", Data1: "occlusion_culled", Data2: fmt.Sprintf("%d", occlusionCulled.Load())})
+		}
+	}()
+}
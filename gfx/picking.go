@@ -0,0 +1,257 @@
+package gfx
+
+import (
+	"github.com/brandonnelson3/GoRender/input"
+	"github.com/brandonnelson3/GoRender/messagebus"
+
+	"github.com/go-gl/gl/v4.5-core/gl"
+	"github.com/go-gl/mathgl/mgl32"
+)
+
+var (
+	// PickDistance is how far from the camera renderables can be picked.
+	PickDistance = float32(1000)
+
+	// Selection is what was last picked with the Select action, and is outlined while it is in the scene.
+	// Its Renderable is nil if nothing is selected.
+	Selection Pick
+
+	// cursorX and cursorY are where the cursor last was in the window.
+	cursorX, cursorY float64
+
+	// selectionVAO and selectionVBO hold the outline of the selection.
+	selectionVAO, selectionVBO uint32
+	selectionColor             = mgl32.Vec3{1, .8, 0}
+)
+
+// Pick is where a ray hit a renderable. It is the Data1 of "pick" messages, which are sent whenever the Select
+// action is pressed, with a nil Renderable if the ray hit nothing.
+type Pick struct {
+	Renderable Renderable
+	// Instance is the instance of an instanced renderable which was hit, or -1.
+	Instance int
+	// Point is where the ray hit, Distance how far along the ray it is, and Normal the surface normal there,
+	// facing back along the ray.
+	Point, Normal mgl32.Vec3
+	Distance      float32
+}
+
+// RayIntersecter is a Renderable which is picked by its surface rather than its bounds.
+type RayIntersecter interface {
+	Renderable
+	// IntersectRay returns the distance along the ray from origin along the normalized direction to the
+	// nearest point of the surface within maxDistance, and the surface normal there.
+	IntersectRay(origin, direction mgl32.Vec3, maxDistance float32) (float32, mgl32.Vec3, bool)
+}
+
+// ScreenRay returns the ray from the camera through the point (x, y) of a view of the given size, with y
+// increasing downwards as the cursor's does.
+func (c *Camera) ScreenRay(x, y float64, width, height uint32) (mgl32.Vec3, mgl32.Vec3) {
+	return screenRay(c.GetProjection().Mul4(c.GetView()).Inv(), x, y, width, height)
+}
+
+// screenRay returns the ray through the point (x, y) of a view of the given size, unprojecting it onto the
+// near and far planes with inverseViewProjection.
+func screenRay(inverseViewProjection mgl32.Mat4, x, y float64, width, height uint32) (mgl32.Vec3, mgl32.Vec3) {
+	ndcX := float32(2*x/float64(width) - 1)
+	ndcY := float32(1 - 2*y/float64(height))
+	near := inverseViewProjection.Mul4x1(mgl32.Vec4{ndcX, ndcY, -1, 1})
+	far := inverseViewProjection.Mul4x1(mgl32.Vec4{ndcX, ndcY, 1, 1})
+	origin := near.Vec3().Mul(1 / near.W())
+	return origin, far.Vec3().Mul(1 / far.W()).Sub(origin).Normalize()
+}
+
+// PickRay returns the nearest renderable drawn last frame, besides water and the culling camera's model, that
+// the ray from origin along the normalized direction hits within maxDistance. Renderables which aren't
+// RayIntersecters are hit where the ray enters their bounds.
+func PickRay(origin, direction mgl32.Vec3, maxDistance float32) (Pick, bool) {
+	best := Pick{Instance: -1, Distance: maxDistance}
+	found := false
+	for _, hit := range Renderer.scene.QueryRay(origin, direction, maxDistance, nil) {
+		// Hits are sorted by where the ray enters their bounds, so nothing after this can be nearer.
+		if hit.Distance > best.Distance {
+			break
+		}
+		if hit.Renderable == Renderable(FirstPersonCameraRenderable) {
+			continue
+		}
+		p := Pick{Renderable: hit.Renderable, Instance: -1, Distance: hit.Distance, Normal: direction.Mul(-1)}
+		switch r := hit.Renderable.(type) {
+		case *VAORenderable:
+			distance, normal, instance, ok := r.intersectRay(origin, direction, best.Distance)
+			if !ok {
+				continue
+			}
+			p.Distance, p.Normal, p.Instance = distance, normal, instance
+		case RayIntersecter:
+			distance, normal, ok := r.IntersectRay(origin, direction, best.Distance)
+			if !ok {
+				continue
+			}
+			p.Distance, p.Normal = distance, normal
+		}
+		p.Point = origin.Add(direction.Mul(p.Distance))
+		best, found = p, true
+	}
+	if !found {
+		return Pick{Instance: -1}, false
+	}
+	return best, true
+}
+
+// PickCursor picks along the ray from the active camera through the cursor. The cursor is kept at the centre
+// of the window while looking around, so this is usually what is in the middle of the view.
+func PickCursor() (Pick, bool) {
+	origin, direction := ActiveCamera.ScreenRay(cursorX, cursorY, Window.Width, Window.Height)
+	return PickRay(origin, direction, PickDistance)
+}
+
+// IntersectRay returns the distance along the ray to the nearest of the renderable's triangles, or of any of
+// its instances', within maxDistance, and the triangle's normal facing back along the ray.
+func (r *VAORenderable) IntersectRay(origin, direction mgl32.Vec3, maxDistance float32) (float32, mgl32.Vec3, bool) {
+	distance, normal, _, ok := r.intersectRay(origin, direction, maxDistance)
+	return distance, normal, ok
+}
+
+// intersectRay is IntersectRay, also returning the instance hit, or -1 if the renderable isn't instanced.
+func (r *VAORenderable) intersectRay(origin, direction mgl32.Vec3, maxDistance float32) (float32, mgl32.Vec3, int, bool) {
+	if len(r.instanceTransforms) == 0 {
+		distance, normal, ok := r.intersectMesh(r.getModelMatrix(), origin, direction, maxDistance)
+		return distance, normal, -1, ok
+	}
+
+	inverse := mgl32.Vec3{1 / direction.X(), 1 / direction.Y(), 1 / direction.Z()}
+	var normal mgl32.Vec3
+	instance := -1
+	for i, m := range r.instanceTransforms {
+		instanceMin, instanceMax := r.GetInstanceBounds(i)
+		if _, ok := rayBox(origin, inverse, instanceMin, instanceMax, maxDistance); !ok {
+			continue
+		}
+		if d, n, ok := r.intersectMesh(m, origin, direction, maxDistance); ok {
+			maxDistance, normal, instance = d, n, i
+		}
+	}
+	return maxDistance, normal, instance, instance != -1
+}
+
+// intersectMesh intersects the ray with the renderable's triangles placed by model.
+func (r *VAORenderable) intersectMesh(model mgl32.Mat4, origin, direction mgl32.Vec3, maxDistance float32) (float32, mgl32.Vec3, bool) {
+	// The ray is taken into model space rather than every triangle out of it. Its direction isn't normalized
+	// again, so distances along it are the same in both.
+	toLocal := model.Inv()
+	localOrigin := toLocal.Mul4x1(origin.Vec4(1)).Vec3()
+	localDirection := toLocal.Mul4x1(direction.Vec4(0)).Vec3()
+
+	found := -1
+	for i := 0; i+2 < len(r.triangles); i += 3 {
+		if d, ok := IntersectTriangle(localOrigin, localDirection, r.triangles[i], r.triangles[i+1], r.triangles[i+2]); ok && d <= maxDistance {
+			maxDistance, found = d, i
+		}
+	}
+	if found == -1 {
+		return 0, mgl32.Vec3{}, false
+	}
+
+	a, b, c := r.triangles[found], r.triangles[found+1], r.triangles[found+2]
+	normal := toLocal.Transpose().Mul4x1(b.Sub(a).Cross(c.Sub(a)).Vec4(0)).Vec3().Normalize()
+	if normal.Dot(direction) > 0 {
+		normal = normal.Mul(-1)
+	}
+	return maxDistance, normal, true
+}
+
+// IntersectTriangle returns the distance along the ray from origin in direction dir to the triangle a, b, c
+// using the Möller-Trumbore algorithm, in lengths of dir. Both sides of the triangle are hit.
+func IntersectTriangle(origin, dir, a, b, c mgl32.Vec3) (float32, bool) {
+	const epsilon = 1e-9
+	// slack lets rays through a shared edge hit one of its triangles despite rounding.
+	const slack = 1e-5
+	e1 := b.Sub(a)
+	e2 := c.Sub(a)
+	p := dir.Cross(e2)
+	det := e1.Dot(p)
+	if det > -epsilon && det < epsilon {
+		return 0, false
+	}
+	inv := 1 / det
+	s := origin.Sub(a)
+	u := s.Dot(p) * inv
+	if u < -slack || u > 1+slack {
+		return 0, false
+	}
+	q := s.Cross(e1)
+	v := dir.Dot(q) * inv
+	if v < -slack || u+v > 1+slack {
+		return 0, false
+	}
+	t := e2.Dot(q) * inv
+	return t, t >= 0
+}
+
+// renderSelection outlines the bounds of the selection, or of the selected instance, if it is in the scene.
+// The line shader must be in use.
+func renderSelection() {
+	if Selection.Renderable == nil || !Renderer.scene.Contains(Selection.Renderable) {
+		return
+	}
+	boxMin, boxMax := Selection.Renderable.GetBounds()
+	if r, ok := Selection.Renderable.(*VAORenderable); ok && Selection.Instance >= 0 && Selection.Instance < len(r.instanceTransforms) {
+		boxMin, boxMax = r.GetInstanceBounds(Selection.Instance)
+	}
+
+	var corners [8]mgl32.Vec3
+	for i := range corners {
+		corners[i] = boxMin
+		if i&1 != 0 {
+			corners[i][0] = boxMax.X()
+		}
+		if i&2 != 0 {
+			corners[i][1] = boxMax.Y()
+		}
+		if i&4 != 0 {
+			corners[i][2] = boxMax.Z()
+		}
+	}
+	// Each edge joins two corners differing along one axis.
+	vertices := make([]LineVertex, 0, 24)
+	for i := range corners {
+		for _, axis := range []int{1, 2, 4} {
+			if i&axis == 0 {
+				vertices = append(vertices, LineVertex{corners[i], selectionColor}, LineVertex{corners[i|axis], selectionColor})
+			}
+		}
+	}
+
+	gl.BindVertexArray(selectionVAO)
+	gl.BindBuffer(gl.ARRAY_BUFFER, selectionVBO)
+	gl.BufferData(gl.ARRAY_BUFFER, len(vertices)*6*4, gl.Ptr(vertices), gl.DYNAMIC_DRAW)
+	// The outline shows through whatever is in front of the selection.
+	gl.Disable(gl.DEPTH_TEST)
+	gl.DrawArrays(gl.LINES, 0, int32(len(vertices)))
+	gl.Enable(gl.DEPTH_TEST)
+	gl.BindVertexArray(0)
+}
+
+func initPicking(lineShaderProgram uint32) {
+	gl.GenVertexArrays(1, &selectionVAO)
+	gl.BindVertexArray(selectionVAO)
+	gl.GenBuffers(1, &selectionVBO)
+	gl.BindBuffer(gl.ARRAY_BUFFER, selectionVBO)
+	BindLineVertexAttributes(lineShaderProgram)
+	gl.BindVertexArray(0)
+
+	messagebus.RegisterType("mouse", func(m *messagebus.Message) {
+		mouseInput := m.Data1.(input.MouseInput)
+		cursorX, cursorY = mouseInput.X, mouseInput.Y
+	})
+	messagebus.RegisterType("action", func(m *messagebus.Message) {
+		for _, action := range m.Data2.([]input.Action) {
+			if action == input.Select {
+				Selection, _ = PickCursor()
+				// Handlers can't send synchronously while a message is being delivered.
+				messagebus.SendAsync(&messagebus.Message{Type: "pick", Data1: Selection})
+			}
+		}
+	})
+}
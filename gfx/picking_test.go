@@ -0,0 +1,95 @@
+package gfx
+
+import (
+	"testing"
+
+	"github.com/go-gl/mathgl/mgl32"
+	"github.com/stretchr/testify/assert"
+)
+
+// quadRenderable returns a renderable of a unit square facing +z, centered on the origin in model space.
+func quadRenderable() *VAORenderable {
+	corners := []mgl32.Vec3{{-.5, -.5, 0}, {.5, -.5, 0}, {.5, .5, 0}, {-.5, -.5, 0}, {.5, .5, 0}, {-.5, .5, 0}}
+	return &VAORenderable{
+		Rotation:  mgl32.Ident4(),
+		Scale:     mgl32.Ident4(),
+		LocalMin:  mgl32.Vec3{-.5, -.5, 0},
+		LocalMax:  mgl32.Vec3{.5, .5, 0},
+		triangles: corners,
+	}
+}
+
+func TestScreenRay(t *testing.T) {
+	eye := mgl32.Vec3{1, 2, 3}
+	view := mgl32.LookAtV(eye, mgl32.Vec3{1, 2, -7}, mgl32.Vec3{0, 1, 0})
+	projection := mgl32.Perspective(mgl32.DegToRad(90), 2, .1, 100)
+	inverse := projection.Mul4(view).Inv()
+
+	origin, direction := screenRay(inverse, 400, 200, 800, 400)
+	assert.InDelta(t, 0, origin.Sub(eye.Add(mgl32.Vec3{0, 0, -.1})).Len(), 1e-4)
+	assert.InDelta(t, 0, direction.Sub(mgl32.Vec3{0, 0, -1}).Len(), 1e-4)
+
+	// The top right corner of the view is 45 degrees up, and twice as far across for the aspect ratio.
+	_, direction = screenRay(inverse, 800, 0, 800, 400)
+	assert.InDelta(t, 0, direction.Sub(mgl32.Vec3{2, 1, -1}.Normalize()).Len(), 1e-4)
+}
+
+func TestVAORenderableIntersectRay(t *testing.T) {
+	r := quadRenderable()
+	r.Position = mgl32.Vec3{0, 0, -10}
+	r.Scale = mgl32.Scale3D(4, 4, 4)
+
+	distance, normal, ok := r.IntersectRay(mgl32.Vec3{1.5, 0, 0}, mgl32.Vec3{0, 0, -1}, 100)
+	if assert.True(t, ok) {
+		assert.InDelta(t, 10, distance, 1e-4)
+		assert.InDelta(t, 0, normal.Sub(mgl32.Vec3{0, 0, 1}).Len(), 1e-4)
+	}
+	_, _, ok = r.IntersectRay(mgl32.Vec3{2.5, 0, 0}, mgl32.Vec3{0, 0, -1}, 100)
+	assert.False(t, ok)
+	_, _, ok = r.IntersectRay(mgl32.Vec3{1.5, 0, 0}, mgl32.Vec3{0, 0, -1}, 9)
+	assert.False(t, ok)
+
+	// From behind, the normal faces the other way.
+	_, normal, ok = r.IntersectRay(mgl32.Vec3{0, 0, -20}, mgl32.Vec3{0, 0, 1}, 100)
+	if assert.True(t, ok) {
+		assert.InDelta(t, 0, normal.Sub(mgl32.Vec3{0, 0, -1}).Len(), 1e-4)
+	}
+}
+
+func TestPickRay(t *testing.T) {
+	scene := Renderer.scene
+	defer func() { Renderer.scene = scene }()
+	Renderer.scene = NewAABBTree(0)
+
+	near, far := quadRenderable(), quadRenderable()
+	near.Position, far.Position = mgl32.Vec3{0, 0, -5}, mgl32.Vec3{0, 0, -10}
+	instanced := quadRenderable()
+	instanced.SetInstanceTransforms([]mgl32.Mat4{mgl32.Translate3D(5, 0, -20), mgl32.Translate3D(5, 0, -8)})
+	// A renderable which can't be intersected is hit at its bounds.
+	box := &boxRenderable{min: mgl32.Vec3{-11, -1, -31}, max: mgl32.Vec3{-9, 1, -29}}
+	Renderer.scene.Sync([]Renderable{near, far, instanced, box})
+
+	pick, ok := PickRay(mgl32.Vec3{}, mgl32.Vec3{0, 0, -1}, 100)
+	if assert.True(t, ok) {
+		assert.Equal(t, Renderable(near), pick.Renderable)
+		assert.Equal(t, -1, pick.Instance)
+		assert.InDelta(t, 5, pick.Distance, 1e-4)
+		assert.InDelta(t, 0, pick.Point.Sub(mgl32.Vec3{0, 0, -5}).Len(), 1e-4)
+	}
+
+	pick, ok = PickRay(mgl32.Vec3{5, 0, 0}, mgl32.Vec3{0, 0, -1}, 100)
+	if assert.True(t, ok) {
+		assert.Equal(t, Renderable(instanced), pick.Renderable)
+		assert.Equal(t, 1, pick.Instance)
+		assert.InDelta(t, 8, pick.Distance, 1e-4)
+	}
+
+	pick, ok = PickRay(mgl32.Vec3{-10, 0, 0}, mgl32.Vec3{0, 0, -1}, 100)
+	if assert.True(t, ok) {
+		assert.Equal(t, Renderable(box), pick.Renderable)
+		assert.InDelta(t, 29, pick.Distance, 1e-4)
+	}
+
+	_, ok = PickRay(mgl32.Vec3{20, 0, 0}, mgl32.Vec3{0, 0, -1}, 100)
+	assert.False(t, ok)
+}
@@ -2,10 +2,10 @@ package gfx
 
 import (
 	"github.com/brandonnelson3/GoRender/gfx/shaders"
+	"github.com/brandonnelson3/GoRender/input"
 	"github.com/brandonnelson3/GoRender/messagebus"
 
 	"github.com/go-gl/gl/v4.5-core/gl"
-	"github.com/go-gl/glfw/v3.1/glfw"
 	"github.com/go-gl/mathgl/mgl32"
 )
 
@@ -51,13 +51,13 @@ func InitPip() {
 	BindPipVertexAttributes(pipShader.Program())
 	gl.BindVertexArray(0)
 
-	messagebus.RegisterType("key", func(m *messagebus.Message) {
-		pressedKeys := m.Data1.([]glfw.Key)
-		for _, key := range pressedKeys {
-			switch key {
-			case glfw.KeyHome:
+	messagebus.RegisterType("action", func(m *messagebus.Message) {
+		heldActions := m.Data1.([]input.Action)
+		for _, action := range heldActions {
+			switch action {
+			case input.ShowPip:
 				enabled = true
-			case glfw.KeyEnd:
+			case input.HidePip:
 				enabled = false
 			}
 		}
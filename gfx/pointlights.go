@@ -264,14 +264,11 @@ func GetPointShadowLightPositions() *[MaxPointLightShadows * 3]float32 {
 // given light position, using PointShadowFarPlane as the far plane.
 func BuildPointLightCubemapMatrices(lightPos mgl32.Vec3) [6]mgl32.Mat4 {
 	proj := mgl32.Perspective(mgl32.DegToRad(90), 1.0, 0.05, PointShadowFarPlane)
-	return [6]mgl32.Mat4{
-		proj.Mul4(mgl32.LookAtV(lightPos, lightPos.Add(mgl32.Vec3{1, 0, 0}), mgl32.Vec3{0, -1, 0})),
-		proj.Mul4(mgl32.LookAtV(lightPos, lightPos.Add(mgl32.Vec3{-1, 0, 0}), mgl32.Vec3{0, -1, 0})),
-		proj.Mul4(mgl32.LookAtV(lightPos, lightPos.Add(mgl32.Vec3{0, 1, 0}), mgl32.Vec3{0, 0, 1})),
-		proj.Mul4(mgl32.LookAtV(lightPos, lightPos.Add(mgl32.Vec3{0, -1, 0}), mgl32.Vec3{0, 0, -1})),
-		proj.Mul4(mgl32.LookAtV(lightPos, lightPos.Add(mgl32.Vec3{0, 0, 1}), mgl32.Vec3{0, -1, 0})),
-		proj.Mul4(mgl32.LookAtV(lightPos, lightPos.Add(mgl32.Vec3{0, 0, -1}), mgl32.Vec3{0, -1, 0})),
+	var matrices [6]mgl32.Mat4
+	for i, view := range cubemapFaceViews(lightPos) {
+		matrices[i] = proj.Mul4(view)
 	}
+	return matrices
 }
 
 // IsShadowSlotDirty returns true if the shadow slot needs to be re-rendered.
@@ -290,9 +287,14 @@ func IsShadowSlotDirty(slot int, intersecting []Renderable) bool {
 		len(intersecting) != len(shadowSlotRenderedObjects[slot])
 
 	if !dirty {
-		for i, currentObj := range intersecting {
-			prevObj := shadowSlotRenderedObjects[slot][i]
-			if prevObj.Renderable != currentObj {
+		// The renderables may be gathered in a different order from frame to frame.
+		previous := make(map[Renderable]RenderedObjectState, len(intersecting))
+		for _, prevObj := range shadowSlotRenderedObjects[slot] {
+			previous[prevObj.Renderable] = prevObj
+		}
+		for _, currentObj := range intersecting {
+			prevObj, ok := previous[currentObj]
+			if !ok {
 				dirty = true
 				break
 			}
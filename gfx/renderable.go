@@ -1,6 +1,8 @@
 package gfx
 
 import (
+	"slices"
+
 	"github.com/brandonnelson3/GoRender/gfx/shaders"
 	"github.com/go-gl/gl/v4.5-core/gl"
 	"github.com/go-gl/mathgl/mgl32"
@@ -20,6 +22,26 @@ type Renderable interface {
 	GetBounds() (mgl32.Vec3, mgl32.Vec3) // World space Min, Max
 }
 
+// RenderableGroup is a Renderable made of parts which the renderer culls, sorts and shadows individually.
+type RenderableGroup interface {
+	Renderable
+	// Parts returns the renderables currently making up the group. It is called once per frame.
+	Parts() []Renderable
+}
+
+// expandGroups returns renderables with every RenderableGroup replaced by its parts.
+func expandGroups(renderables []Renderable) []Renderable {
+	expanded := make([]Renderable, 0, len(renderables))
+	for _, r := range renderables {
+		if group, ok := r.(RenderableGroup); ok {
+			expanded = append(expanded, group.Parts()...)
+		} else {
+			expanded = append(expanded, r)
+		}
+	}
+	return expanded
+}
+
 // VAORenderable is a object wrapping around something that is renderable on top of a vao.
 type VAORenderable struct {
 	vao, vbo uint32
@@ -32,10 +54,22 @@ type VAORenderable struct {
 
 	// LocalMin and LocalMax are the axis-aligned bounding box in model space.
 	LocalMin, LocalMax mgl32.Vec3
+	// triangles holds the corners of every triangle in model space, for picking.
+	triangles []mgl32.Vec3
 
 	// Instancing support
-	InstanceTransforms []mgl32.Mat4
-	instanceVBO        uint32
+	// instanceTransforms places each instance in world space. It only changes through SetinstanceTransforms and
+	// SetInstanceTransform, so the cached bounds and the instance buffers always match it.
+	instanceTransforms []mgl32.Mat4
+	// InstanceDrawDistance is how far from the culling camera instances are drawn, or zero to draw them at any
+	// distance.
+	InstanceDrawDistance float32
+	instances            *instanceBuffers
+	// bounds caches the world-space bounds of every instance, while valid.
+	bounds struct {
+		valid    bool
+		min, max mgl32.Vec3
+	}
 }
 
 // NewVAORenderable instantiates a Renderable for the given verticies of the normal Vertex Type.
@@ -65,7 +99,17 @@ func NewVAORenderable(verticies []Vertex, diffuse uint32) *VAORenderable {
 		portions:    []RenderablePortion{{0, int32(len(verticies)), diffuse}},
 		LocalMin:    min,
 		LocalMax:    max,
+		triangles:   vertexPositions(verticies),
+	}
+}
+
+// vertexPositions returns the position of each of verts.
+func vertexPositions(verts []Vertex) []mgl32.Vec3 {
+	positions := make([]mgl32.Vec3, len(verts))
+	for i, v := range verts {
+		positions[i] = v.Vert
 	}
+	return positions
 }
 
 func calculateBounds(verts []Vertex) (mgl32.Vec3, mgl32.Vec3) {
@@ -114,6 +158,7 @@ func NewChunkedRenderable(verticies []Vertex, portions []RenderablePortion) *VAO
 		portions:    portions,
 		LocalMin:    min,
 		LocalMax:    max,
+		triangles:   vertexPositions(verticies),
 	}
 }
 
@@ -122,24 +167,12 @@ func (r *VAORenderable) getModelMatrix() mgl32.Mat4 {
 	return mgl32.Translate3D(r.Position.X(), r.Position.Y(), r.Position.Z()).Mul4(r.Scale.Mul4(r.Rotation))
 }
 
-func (r *VAORenderable) setupInstancing() {
-	if len(r.InstanceTransforms) == 0 || r.instanceVBO != 0 {
-		return
-	}
-
-	gl.GenBuffers(1, &r.instanceVBO)
-	gl.BindBuffer(gl.ARRAY_BUFFER, r.instanceVBO)
-	gl.BufferData(gl.ARRAY_BUFFER, len(r.InstanceTransforms)*16*4, gl.Ptr(r.InstanceTransforms), gl.STATIC_DRAW)
-
-	gl.BindVertexArray(r.vao)
-	for i := uint32(0); i < 4; i++ {
-		loc := uint32(3) + i
-		gl.EnableVertexAttribArray(loc)
-		gl.VertexAttribPointer(loc, 4, gl.FLOAT, false, 16*4, gl.PtrOffset(int(i*4*4)))
-		gl.VertexAttribDivisor(loc, 1)
+// ReleaseInstances deletes the buffers holding the instance transforms, and the vao drawing them. They are recreated if the renderable is drawn again.
+func (r *VAORenderable) ReleaseInstances() {
+	if r.instances != nil {
+		r.instances.delete()
+		r.instances = nil
 	}
-	gl.BindVertexArray(0)
-	gl.BindBuffer(gl.ARRAY_BUFFER, 0)
 }
 
 // Render bind's this renderable's VAO and draws.
@@ -151,27 +184,21 @@ func (r *VAORenderable) Render(colorShader *shaders.ColorShader, frustum *Frustu
 		}
 	}
 
-	isInstanced := len(r.InstanceTransforms) > 0
-	if isInstanced {
-		r.setupInstancing()
+	if len(r.instanceTransforms) > 0 {
 		colorShader.IsInstanced.Set(1)
-	} else {
+		r.drawInstances(frustum, colorShader.Use, func(diffuse uint32) {
+			colorShader.Diffuse.Set(gl.TEXTURE0, 0, diffuse)
+		})
 		colorShader.IsInstanced.Set(0)
-		colorShader.Model.Set(r.getModelMatrix())
+		return
 	}
 
+	colorShader.IsInstanced.Set(0)
+	colorShader.Model.Set(r.getModelMatrix())
 	gl.BindVertexArray(r.vao)
 	for _, p := range r.portions {
 		colorShader.Diffuse.Set(gl.TEXTURE0, 0, p.diffuse)
-		if isInstanced {
-			gl.DrawArraysInstanced(r.renderStyle, p.startIndex, p.numIndex, int32(len(r.InstanceTransforms)))
-		} else {
-			gl.DrawArrays(r.renderStyle, p.startIndex, p.numIndex)
-		}
-	}
-
-	if isInstanced {
-		colorShader.IsInstanced.Set(0)
+		gl.DrawArrays(r.renderStyle, p.startIndex, p.numIndex)
 	}
 }
 
@@ -184,27 +211,21 @@ func (r *VAORenderable) RenderDepth(depthShader *shaders.DepthShader, frustum *F
 		}
 	}
 
-	isInstanced := len(r.InstanceTransforms) > 0
-	if isInstanced {
-		r.setupInstancing()
+	if len(r.instanceTransforms) > 0 {
 		depthShader.IsInstanced.Set(1)
-	} else {
+		r.drawInstances(frustum, depthShader.Use, func(diffuse uint32) {
+			depthShader.Diffuse.Set(gl.TEXTURE0, 0, diffuse)
+		})
 		depthShader.IsInstanced.Set(0)
-		depthShader.Model.Set(r.getModelMatrix())
+		return
 	}
 
+	depthShader.IsInstanced.Set(0)
+	depthShader.Model.Set(r.getModelMatrix())
 	gl.BindVertexArray(r.vao)
 	for _, p := range r.portions {
 		depthShader.Diffuse.Set(gl.TEXTURE0, 0, p.diffuse)
-		if isInstanced {
-			gl.DrawArraysInstanced(r.renderStyle, p.startIndex, p.numIndex, int32(len(r.InstanceTransforms)))
-		} else {
-			gl.DrawArrays(r.renderStyle, p.startIndex, p.numIndex)
-		}
-	}
-
-	if isInstanced {
-		depthShader.IsInstanced.Set(0)
+		gl.DrawArrays(r.renderStyle, p.startIndex, p.numIndex)
 	}
 }
 
@@ -217,33 +238,30 @@ func (r *VAORenderable) RenderPointLightDepth(shader *shaders.PointLightShadowSh
 		}
 	}
 
-	isInstanced := len(r.InstanceTransforms) > 0
-	if isInstanced {
-		r.setupInstancing()
+	if len(r.instanceTransforms) > 0 {
 		shader.IsInstanced.Set(1)
-	} else {
+		r.drawInstances(frustum, shader.Use, func(diffuse uint32) {
+			shader.Diffuse.Set(gl.TEXTURE0, 0, diffuse)
+		})
 		shader.IsInstanced.Set(0)
-		shader.Model.Set(r.getModelMatrix())
+		return
 	}
 
+	shader.IsInstanced.Set(0)
+	shader.Model.Set(r.getModelMatrix())
 	gl.BindVertexArray(r.vao)
 	for _, p := range r.portions {
 		shader.Diffuse.Set(gl.TEXTURE0, 0, p.diffuse)
-		if isInstanced {
-			gl.DrawArraysInstanced(r.renderStyle, p.startIndex, p.numIndex, int32(len(r.InstanceTransforms)))
-		} else {
-			gl.DrawArrays(r.renderStyle, p.startIndex, p.numIndex)
-		}
-	}
-
-	if isInstanced {
-		shader.IsInstanced.Set(0)
+		gl.DrawArrays(r.renderStyle, p.startIndex, p.numIndex)
 	}
 }
 
 // GetBounds returns the world-space axis-aligned bounding box.
 func (r *VAORenderable) GetBounds() (mgl32.Vec3, mgl32.Vec3) {
-	if len(r.InstanceTransforms) > 0 {
+	if len(r.instanceTransforms) > 0 {
+		if r.bounds.valid {
+			return r.bounds.min, r.bounds.max
+		}
 		corners := [8]mgl32.Vec3{
 			{r.LocalMin.X(), r.LocalMin.Y(), r.LocalMin.Z()},
 			{r.LocalMax.X(), r.LocalMin.Y(), r.LocalMin.Z()},
@@ -258,7 +276,7 @@ func (r *VAORenderable) GetBounds() (mgl32.Vec3, mgl32.Vec3) {
 		worldMin := mgl32.Vec3{1e9, 1e9, 1e9}
 		worldMax := mgl32.Vec3{-1e9, -1e9, -1e9}
 
-		for _, m := range r.InstanceTransforms {
+		for _, m := range r.instanceTransforms {
 			for _, c := range corners {
 				p := m.Mul4x1(c.Vec4(1))
 				for i := 0; i < 3; i++ {
@@ -271,6 +289,8 @@ func (r *VAORenderable) GetBounds() (mgl32.Vec3, mgl32.Vec3) {
 				}
 			}
 		}
+		r.bounds.valid = true
+		r.bounds.min, r.bounds.max = worldMin, worldMax
 		return worldMin, worldMax
 	}
 
@@ -304,8 +324,34 @@ func (r *VAORenderable) GetBounds() (mgl32.Vec3, mgl32.Vec3) {
 	return worldMin, worldMax
 }
 
+// GetInstanceBounds returns the world-space axis-aligned bounding box of instance i.
+func (r *VAORenderable) GetInstanceBounds(i int) (mgl32.Vec3, mgl32.Vec3) {
+	m := r.instanceTransforms[i]
+	worldMin := mgl32.Vec3{1e9, 1e9, 1e9}
+	worldMax := mgl32.Vec3{-1e9, -1e9, -1e9}
+	for c := 0; c < 8; c++ {
+		corner := r.LocalMin
+		if c&1 != 0 {
+			corner[0] = r.LocalMax.X()
+		}
+		if c&2 != 0 {
+			corner[1] = r.LocalMax.Y()
+		}
+		if c&4 != 0 {
+			corner[2] = r.LocalMax.Z()
+		}
+		p := m.Mul4x1(corner.Vec4(1))
+		for j := 0; j < 3; j++ {
+			worldMin[j] = min(worldMin[j], p[j])
+			worldMax[j] = max(worldMax[j], p[j])
+		}
+	}
+	return worldMin, worldMax
+}
+
 func (r *VAORenderable) Copy() *VAORenderable {
 	temp := *r
-	temp.instanceVBO = 0
+	temp.instanceTransforms = slices.Clone(r.instanceTransforms)
+	temp.instances = nil
 	return &temp
 }
@@ -8,10 +8,10 @@ import (
 	"github.com/brandonnelson3/GoRender/benchmark"
 	"github.com/brandonnelson3/GoRender/gfx/shaders"
 	"github.com/brandonnelson3/GoRender/gfx/uniforms"
+	"github.com/brandonnelson3/GoRender/input"
 	"github.com/brandonnelson3/GoRender/messagebus"
 
 	"github.com/go-gl/gl/v4.5-core/gl"
-	"github.com/go-gl/glfw/v3.1/glfw"
 	"github.com/go-gl/mathgl/mgl32"
 )
 
@@ -22,6 +22,8 @@ const (
 	shadowMapSize = 2048
 	// NumberOfCascades is the number of shadow cascades being used.
 	NumberOfCascades = 5
+	// sceneTreeMargin is how far renderables can move before the scene tree is restructured around them.
+	sceneTreeMargin = 1
 )
 
 // Renderer is the global instance of a Renderer.
@@ -30,6 +32,11 @@ var (
 
 	ambientLightColor = mgl32.Vec3{.2, .2, .2}
 
+	// SkyLightIntensity scales the diffuse and specular light contributed by the sky's image based lighting.
+	SkyLightIntensity = float32(1.0)
+	// EnvironmentRoughness is the roughness used to sample the prefiltered sky for specular reflections.
+	EnvironmentRoughness = float32(0.8)
+
 	// shadowSplits is the percents of the full view spectrum for each shadow cascade.
 	// The 0th cascade is effective shadowSplits[0] to shadowSplits[1], therefore there
 	// should be n+1 elements in this list where n is the number of cascades.
@@ -46,11 +53,30 @@ type r struct {
 	colorShader             *shaders.ColorShader
 	frustumShader           *shaders.FrustumShader
 	pointLightShadowShader  *shaders.PointLightShadowShader
+	volumetricScatteringShader  *shaders.VolumetricScatteringShader
+	volumetricIntegrationShader *shaders.VolumetricIntegrationShader
+	hiZShader                   *shaders.HiZShader
+	instanceCullingShader       *shaders.InstanceCullingShader
+
+	// scene holds the bounds of everything drawn this frame besides water, for culling and queries.
+	scene *AABBTree
 
 	csmDepthMapFBO uint32
 	csmDepthMaps   [NumberOfCascades]uint32
 
 	depthMapFBO, depthMap uint32
+	// occlusion is the depth pyramid of the whole window's view.
+	occlusion hiZ
+	// occlusionTested and occlusionCulled count the renderables tested and culled by occlusion this frame.
+	occlusionTested, occlusionCulled int
+
+	// view is the view being rendered, and nil outside of rendering.
+	view *view
+	// mainColorPass is set while the counted view draws its main color pass.
+	mainColorPass bool
+	// splitScreen holds the viewports Render renders every registered camera into side by side, and is nil
+	// while only the active camera is rendered.
+	splitScreen []*Viewport
 
 	// TargetFramebuffer is the framebuffer the color pass renders into.
 	// Zero (the default) means the window's default backbuffer.
@@ -70,6 +96,7 @@ func InitRenderer() {
 	gl.ClearColor(0.0, 0.0, 0.0, 1.0)
 	gl.CullFace(gl.BACK)
 	gl.PolygonOffset(2.5, 1.0)
+	gl.Enable(gl.TEXTURE_CUBE_MAP_SEAMLESS)
 
 	ls, err := shaders.NewLineShader()
 	if err != nil {
@@ -101,6 +128,30 @@ func InitRenderer() {
 		log.Fatalf("Failed to compile PointLightShadowShader: %v", err)
 	}
 
+	vss, err := shaders.NewVolumetricScatteringShader()
+	if err != nil {
+		log.Fatalf("Failed to compile VolumetricScatteringShader: %v", err)
+	}
+
+	vis, err := shaders.NewVolumetricIntegrationShader()
+	if err != nil {
+		log.Fatalf("Failed to compile VolumetricIntegrationShader: %v", err)
+	}
+
+	hzs, err := shaders.NewHiZShader()
+	if err != nil {
+		log.Fatalf("Failed to compile HiZShader: %v", err)
+	}
+
+	ics, err := shaders.NewInstanceCullingShader()
+	if err != nil {
+		log.Fatalf("Failed to compile InstanceCullingShader: %v", err)
+	}
+
+	initVolumetricFog()
+	initOcclusion()
+	initPicking(ls.Program())
+
 	var depthMapFBO uint32
 	gl.GenFramebuffers(1, &depthMapFBO)
 	var depthMap uint32
@@ -153,26 +204,33 @@ func InitRenderer() {
 		colorShader:            cs,
 		frustumShader:          fs,
 		pointLightShadowShader: pls,
+		volumetricScatteringShader:  vss,
+		volumetricIntegrationShader: vis,
+		hiZShader:                   hzs,
+		instanceCullingShader:       ics,
+		scene:                       NewAABBTree(sceneTreeMargin),
 		depthMapFBO:            depthMapFBO,
 		depthMap:               depthMap,
 		csmDepthMapFBO:         csmDepthMapFBO,
 		csmDepthMaps:           csmDepthMaps,
 	}
 
-	messagebus.RegisterType("key", func(m *messagebus.Message) {
-		pressedKeys := m.Data1.([]glfw.Key)
-		for _, key := range pressedKeys {
-			if key >= glfw.KeyF1 && key <= glfw.KeyF25 {
-				cs.RenderMode.Set(int32(key - glfw.KeyF1))
+	messagebus.RegisterType("action", func(m *messagebus.Message) {
+		heldActions := m.Data1.([]input.Action)
+		for _, action := range heldActions {
+			for mode := 0; mode < input.RenderModes; mode++ {
+				if action == input.RenderMode(mode) {
+					cs.RenderMode.Set(int32(mode))
+				}
 			}
-			switch key {
-			case glfw.KeyPageUp:
+			switch action {
+			case input.RotateSunLeft:
 				UpdateDirectionalLight(func(dL DirectionalLight) DirectionalLight {
 					m := mgl32.Rotate3DZ(.01)
 					dL.Direction = m.Mul3x1(dL.Direction)
 					return dL
 				})
-			case glfw.KeyPageDown:
+			case input.RotateSunRight:
 				UpdateDirectionalLight(func(dL DirectionalLight) DirectionalLight {
 					m := mgl32.Rotate3DZ(-.01)
 					dL.Direction = m.Mul3x1(dL.Direction)
@@ -180,36 +238,64 @@ func InitRenderer() {
 				})
 			}
 		}
-		pressedKeysThisFrame := m.Data2.([]glfw.Key)
-		for _, key := range pressedKeysThisFrame {
-			switch key {
-			case glfw.KeyKP7:
-				UpdatePip(&csmDepthMaps[0], Window.GetNearFar(0))
-			case glfw.KeyKP8:
-				UpdatePip(&csmDepthMaps[1], Window.GetNearFar(1))
-			case glfw.KeyKP9:
-				UpdatePip(&csmDepthMaps[2], Window.GetNearFar(2))
-			case glfw.KeyKPDivide:
-				UpdatePip(&csmDepthMaps[3], Window.GetNearFar(3))
-			case glfw.KeyKPMultiply:
-				UpdatePip(&csmDepthMaps[4], Window.GetNearFar(4))
-			case glfw.KeyPrintScreen:
+		pressedActionsThisFrame := m.Data2.([]input.Action)
+		for _, action := range pressedActionsThisFrame {
+			for i := range csmDepthMaps {
+				if action == input.PipCascade(i) {
+					UpdatePip(&csmDepthMaps[i], Window.GetNearFar(i))
+				}
+			}
+			switch action {
+			case input.Screenshot:
 				Screenshot()
-			case glfw.KeyL:
+			case input.AddPointLight:
 				AddPointLight(ActiveCamera.GetPosition().Add(ActiveCamera.GetForward().Mul(10)), whiteColor, 1.0, 30.0)
+			case input.ToggleSplitScreen:
+				Renderer.toggleSplitScreen()
 			}
 		}
 	})
 }
 
-// getNumTilesX returns back the number of tiles in each the X dimension that are needed for the current window size.
+// view is one camera's view of the scene, and the buffers it is rendered with.
+type view struct {
+	camera        *Camera
+	width, height int32
+	// framebuffer is what the view's color pass renders into, from its bottom left corner.
+	framebuffer           uint32
+	depthMapFBO, depthMap uint32
+	visibleLightIndices   uint32
+	// occlusion is the depth pyramid the view culls against and builds for the next frame.
+	occlusion *hiZ
+	// counted is set on the one view each frame whose main color pass draw statistics are counted from.
+	counted bool
+}
+
+// InMainColorPass reports whether the renderables being drawn are in the main color pass of the window's
+// view, or of the first viewport in split screen, rather than a shadow, water or other view's pass. Counts of
+// what is drawn each frame are taken from it.
+func InMainColorPass() bool {
+	return Renderer.mainColorPass
+}
+
+// viewSize returns the size of the view being rendered, or of the window outside of rendering.
+func viewSize() (uint32, uint32) {
+	if v := Renderer.view; v != nil {
+		return uint32(v.width), uint32(v.height)
+	}
+	return Window.Width, Window.Height
+}
+
+// getNumTilesX returns back the number of tiles in each the X dimension that are needed for the current view size.
 func getNumTilesX() uint32 {
-	return uint32((Window.Width + tileSize - 1) / tileSize)
+	width, _ := viewSize()
+	return (width + tileSize - 1) / tileSize
 }
 
-// getNumTilesY returns back the number of tiles in each the Y dimension that are needed for the current window size.
+// getNumTilesY returns back the number of tiles in each the Y dimension that are needed for the current view size.
 func getNumTilesY() uint32 {
-	return uint32((Window.Height + tileSize - 1) / tileSize)
+	_, height := viewSize()
+	return (height + tileSize - 1) / tileSize
 }
 
 // getTotalNumTiles returns back the total number of tiles required to cover the entire screen.
@@ -223,6 +309,11 @@ func (renderer *r) Update(updateables []Updateable) {
 	}
 }
 
+// ColorShader returns the shader the renderer draws the scene with, for uploading geometry outside of Update.
+func (renderer *r) ColorShader() *shaders.ColorShader {
+	return renderer.colorShader
+}
+
 // SetRenderMode sets the global render mode.
 func SetRenderMode(mode int32) {
 	Renderer.colorShader.Use()
@@ -230,10 +321,65 @@ func SetRenderMode(mode int32) {
 	gl.UseProgram(0)
 }
 
-func (renderer *r) Render(sky *Sky, renderables []Renderable) {
-	if ActiveCamera == ThirdPerson && FirstPersonCameraRenderable != nil && FirstPerson.IsFrustumRenderingEnabled() {
-		h := FirstPerson.GetHorizontalAngle()
-		v := FirstPerson.GetVerticalAngle()
+// toggleSplitScreen switches between rendering the active camera and every registered camera side by side.
+func (renderer *r) toggleSplitScreen() {
+	if renderer.splitScreen != nil {
+		for _, vp := range renderer.splitScreen {
+			vp.Delete()
+		}
+		renderer.splitScreen = nil
+		return
+	}
+	var split []*Camera
+	for _, name := range CameraNames() {
+		split = append(split, cameras[name])
+	}
+	renderer.splitScreen = SplitScreen(split...)
+}
+
+// Render renders the scene from the active camera into the whole window, or from every registered camera
+// side by side while split screen is on.
+func (renderer *r) Render(sky Sky, renderables []Renderable) {
+	if renderer.splitScreen != nil {
+		err := renderer.RenderViewports(sky, renderables, renderer.splitScreen)
+		if err == nil {
+			return
+		}
+		log.Printf("Failed to render split screen: %v", err)
+		renderer.toggleSplitScreen()
+	}
+	for _, c := range cameras {
+		c.viewAspect = 0
+	}
+	v := &view{
+		camera:              ActiveCamera,
+		width:               int32(Window.Width),
+		height:              int32(Window.Height),
+		framebuffer:         renderer.TargetFramebuffer,
+		depthMapFBO:         renderer.depthMapFBO,
+		depthMap:            renderer.depthMap,
+		visibleLightIndices: GetPointLightVisibleLightIndicesBuffer(),
+		occlusion:           &renderer.occlusion,
+		counted:             true,
+	}
+	waters := renderer.prepareScene(renderables, []*view{v})
+	renderer.renderCascades()
+	renderer.renderView(v, sky, waters)
+	countOcclusion(renderer.occlusionTested, renderer.occlusionCulled)
+	renderer.renderOverlays()
+}
+
+// prepareScene expands the renderables into the parts which are drawn, adding the culling camera's model if
+// any of views is seen from another camera, and separates out the water. The rest of the scene is synced
+// into the renderer's tree.
+func (renderer *r) prepareScene(renderables []Renderable, views []*view) []*Water {
+	observed := false
+	for _, v := range views {
+		observed = observed || v.camera != CullingCamera
+	}
+	if observed && FirstPersonCameraRenderable != nil && CullingCamera.IsFrustumRenderingEnabled() {
+		h := CullingCamera.GetHorizontalAngle()
+		v := CullingCamera.GetVerticalAngle()
 		baseCorrection := mgl32.HomogRotate3DY(-math.Pi / 2)
 		rotation := mgl32.HomogRotate3DY(h).Mul4(mgl32.HomogRotate3DZ(v).Mul4(baseCorrection))
 
@@ -241,29 +387,32 @@ func (renderer *r) Render(sky *Sky, renderables []Renderable) {
 		lensModelLocal := mgl32.Vec3{-2.5, 6.0, -10.5}.Mul(scale)
 		lensWorldOffset4 := rotation.Mul4x1(lensModelLocal.Vec4(0)) // w=0 because it's a vector
 		lensWorldOffset := lensWorldOffset4.Vec3()
-		FirstPersonCameraRenderable.Position = FirstPerson.GetPosition().Sub(lensWorldOffset)
+		FirstPersonCameraRenderable.Position = CullingCamera.GetPosition().Sub(lensWorldOffset)
 		FirstPersonCameraRenderable.Rotation = rotation
 		renderables = append(renderables, FirstPersonCameraRenderable)
 	}
 
-	mainFrustum := NewFrustumFromMatrix(Window.GetProjection().Mul4(ActiveCamera.GetView()))
-	type renderableDist struct {
-		r    Renderable
-		dist float32
-	}
-	visibleSorted := make([]renderableDist, 0, len(renderables))
-	camPos := ActiveCamera.GetPosition()
+	renderer.occlusionTested, renderer.occlusionCulled = 0, 0
+
+	// Groups such as terrain are culled, sorted and shadowed part by part.
+	renderables = expandGroups(renderables)
+
+	// Water is drawn last, over reflection and refraction textures rendered from the rest of the scene.
+	var waters []*Water
+	scene := make([]Renderable, 0, len(renderables))
 	for _, r := range renderables {
-		min, max := r.GetBounds()
-		if mainFrustum.IsBoxIn(min, max) {
-			center := min.Add(max).Mul(0.5)
-			visibleSorted = append(visibleSorted, renderableDist{r, center.Sub(camPos).LenSqr()})
+		if water, ok := r.(*Water); ok {
+			waters = append(waters, water)
+		} else {
+			scene = append(scene, r)
 		}
 	}
-	sort.Slice(visibleSorted, func(i, j int) bool {
-		return visibleSorted[i].dist < visibleSorted[j].dist
-	})
+	renderer.scene.Sync(scene)
+	return waters
+}
 
+// renderCascades renders the depth of the scene into each shadow cascade of the culling camera.
+func (renderer *r) renderCascades() {
 	gl.Enable(gl.CULL_FACE)
 	gl.CullFace(gl.FRONT)
 	gl.Enable(gl.POLYGON_OFFSET_FILL)
@@ -273,12 +422,14 @@ func (renderer *r) Render(sky *Sky, renderables []Renderable) {
 	gl.Viewport(0, 0, shadowMapSize, shadowMapSize)
 	renderer.depthShader.Use()
 	renderer.depthShader.View.Set(mgl32.Ident4())
+	var renderables []Renderable
 	for i, m := range renderer.csmDepthMaps {
 		gl.BindFramebuffer(gl.FRAMEBUFFER, renderer.csmDepthMapFBO)
 		gl.FramebufferTexture2D(gl.FRAMEBUFFER, gl.DEPTH_ATTACHMENT, gl.TEXTURE_2D, m, 0)
 		gl.Clear(gl.DEPTH_BUFFER_BIT)
-		renderer.depthShader.Projection.Set(FirstPerson.shadowMatrices[i])
-		csmFrustum := NewFrustumFromMatrix(FirstPerson.shadowMatrices[i])
+		renderer.depthShader.Projection.Set(CullingCamera.shadowMatrices[i])
+		csmFrustum := NewFrustumFromMatrix(CullingCamera.shadowMatrices[i])
+		renderables = renderer.scene.QueryFrustum(csmFrustum, renderables[:0])
 		for _, renderable := range renderables {
 			renderable.RenderDepth(renderer.depthShader, csmFrustum)
 		}
@@ -287,6 +438,51 @@ func (renderer *r) Render(sky *Sky, renderables []Renderable) {
 
 	gl.CullFace(gl.BACK)
 	gl.Disable(gl.POLYGON_OFFSET_FILL)
+}
+
+// renderView renders the scene into v from its camera, which is the active camera while it renders.
+func (renderer *r) renderView(v *view, sky Sky, waters []*Water) {
+	active := ActiveCamera
+	renderer.view, ActiveCamera = v, v.camera
+	defer func() {
+		renderer.view, ActiveCamera = nil, active
+	}()
+
+	viewProjection := ActiveCamera.GetProjection().Mul4(ActiveCamera.GetView())
+	mainFrustum := NewFrustumFromMatrix(viewProjection)
+	// Render tests draw each scene in a single frame, so there is no frame before to cull against.
+	occlusion := OcclusionCulling && !RenderTestMode
+	if occlusion {
+		v.occlusion.resolve()
+	} else {
+		v.occlusion.reset()
+	}
+	type renderableDist struct {
+		r    Renderable
+		dist float32
+	}
+	renderables := renderer.scene.QueryFrustum(mainFrustum, nil)
+	visibleSorted := make([]renderableDist, 0, len(renderables))
+	camPos := ActiveCamera.GetPosition()
+	for _, r := range renderables {
+		// The culling camera's model would block its own view.
+		if v.camera == CullingCamera && r == Renderable(FirstPersonCameraRenderable) {
+			continue
+		}
+		min, max := r.GetBounds()
+		if occlusion {
+			renderer.occlusionTested++
+			if v.occlusion.renderableOccluded(v.camera, r, min, max) {
+				renderer.occlusionCulled++
+				continue
+			}
+		}
+		center := min.Add(max).Mul(0.5)
+		visibleSorted = append(visibleSorted, renderableDist{r, center.Sub(camPos).LenSqr()})
+	}
+	sort.Slice(visibleSorted, func(i, j int) bool {
+		return visibleSorted[i].dist < visibleSorted[j].dist
+	})
 
 	// Step 1.5: Point light shadow pass — render up to 4 closest light cubemaps.
 	benchmark.Start("Render: Point Shadows")
@@ -323,16 +519,8 @@ func (renderer *r) Render(sky *Sky, renderables []Renderable) {
 			lightRange := float32(PointShadowFarPlane)
 
 			// Gather all renderables intersecting the light's volume.
-			var intersecting []Renderable
-			for _, renderable := range renderables {
-				min, max := renderable.GetBounds()
-				if min.X() > lightPos.X()+lightRange || max.X() < lightPos.X()-lightRange ||
-					min.Y() > lightPos.Y()+lightRange || max.Y() < lightPos.Y()-lightRange ||
-					min.Z() > lightPos.Z()+lightRange || max.Z() < lightPos.Z()-lightRange {
-					continue
-				}
-				intersecting = append(intersecting, renderable)
-			}
+			reach := mgl32.Vec3{lightRange, lightRange, lightRange}
+			intersecting := renderer.scene.QueryBox(lightPos.Sub(reach), lightPos.Add(reach), nil)
 
 			// ONLY render if the shadow map is dirty (light moved, slot reassigned, or an intersecting object moved/changed).
 			if !IsShadowSlotDirty(slot, intersecting) {
@@ -367,50 +555,106 @@ func (renderer *r) Render(sky *Sky, renderables []Renderable) {
 	benchmark.End("Render: Point Shadows")
 
 	benchmark.Start("Render: Depth Pre-pass")
-	gl.Viewport(0, 0, int32(Window.Width), int32(Window.Height))
-	gl.BindFramebuffer(gl.FRAMEBUFFER, renderer.depthMapFBO)
+	renderer.depthShader.Use()
+	gl.Viewport(0, 0, v.width, v.height)
+	gl.BindFramebuffer(gl.FRAMEBUFFER, v.depthMapFBO)
 	gl.Clear(gl.DEPTH_BUFFER_BIT)
 	renderer.depthShader.View.Set(ActiveCamera.GetView())
-	renderer.depthShader.Projection.Set(Window.GetProjection())
+	renderer.depthShader.Projection.Set(ActiveCamera.GetProjection())
 	for _, rd := range visibleSorted {
 		rd.r.RenderDepth(renderer.depthShader, mainFrustum)
 	}
 	benchmark.End("Render: Depth Pre-pass")
 
+	if occlusion {
+		benchmark.Start("Render: Hi-Z")
+		v.occlusion.build(renderer.hiZShader, v.depthMap, v.width, v.height, v.camera, viewProjection)
+		benchmark.End("Render: Hi-Z")
+	}
+
 	// Step 3: Light culling
 	benchmark.Start("Render: Light Culling")
 	renderer.lightCullingShader.Use()
 	renderer.lightCullingShader.View.Set(ActiveCamera.GetView())
-	renderer.lightCullingShader.Projection.Set(Window.GetProjection())
-	renderer.lightCullingShader.DepthMap.Set(gl.TEXTURE5, 5, renderer.depthMap)
-	renderer.lightCullingShader.ScreenSize.Set(uniforms.UIVec2{Window.Width, Window.Height})
+	renderer.lightCullingShader.Projection.Set(ActiveCamera.GetProjection())
+	renderer.lightCullingShader.DepthMap.Set(gl.TEXTURE5, 5, v.depthMap)
+	renderer.lightCullingShader.ScreenSize.Set(uniforms.UIVec2{uint32(v.width), uint32(v.height)})
 	renderer.lightCullingShader.LightCount.Set(GetNumPointLights())
 	renderer.lightCullingShader.LightBuffer.Set(GetPointLightBuffer())
-	renderer.lightCullingShader.VisibleLightIndicesBuffer.Set(GetPointLightVisibleLightIndicesBuffer())
+	renderer.lightCullingShader.VisibleLightIndicesBuffer.Set(v.visibleLightIndices)
 	gl.DispatchCompute(getNumTilesX(), getNumTilesY(), 1)
 	gl.UseProgram(0)
 	benchmark.End("Render: Light Culling")
 
+	benchmark.Start("Render: Volumetric Fog")
+	renderer.renderVolumetricFog(sky, numShadowLights)
+	benchmark.End("Render: Volumetric Fog")
+
+	benchmark.Start("Render: Water")
+	for _, water := range waters {
+		renderer.renderWaterTextures(water, sky, numShadowLights)
+	}
+	benchmark.End("Render: Water")
+
 	// Step 4: Normal pass
 	benchmark.Start("Render: Main Color")
-	gl.BindFramebuffer(gl.FRAMEBUFFER, renderer.TargetFramebuffer)
-	gl.Viewport(0, 0, int32(Window.Width), int32(Window.Height))
+	gl.BindFramebuffer(gl.FRAMEBUFFER, v.framebuffer)
+	gl.Viewport(0, 0, v.width, v.height)
 	gl.Clear(gl.COLOR_BUFFER_BIT | gl.DEPTH_BUFFER_BIT)
 	sky.Render()
-	renderer.colorShader.Use()
+	renderer.bindColorShader(sky, numShadowLights)
 	renderer.colorShader.View.Set(ActiveCamera.GetView())
-	renderer.colorShader.Projection.Set(Window.GetProjection())
-	renderer.colorShader.LightViewProjs.Set(&FirstPerson.shadowMatrices[0][0], NumberOfCascades)
+	renderer.colorShader.CameraPosition.Set(ActiveCamera.GetPosition())
+	renderer.colorShader.ScreenSize.Set(mgl32.Vec2{float32(v.width), float32(v.height)})
+
+	renderer.mainColorPass = v.counted
+	for _, rd := range visibleSorted {
+		rd.r.Render(renderer.colorShader, mainFrustum)
+	}
+	for _, water := range waters {
+		water.Render(renderer.colorShader, mainFrustum)
+	}
+	renderer.mainColorPass = false
+	benchmark.End("Render: Main Color")
+
+	renderer.lineShader.Use()
+	renderer.lineShader.View.Set(ActiveCamera.GetView())
+	renderer.lineShader.Projection.Set(ActiveCamera.GetProjection())
+	if v.camera != CullingCamera {
+		CullingCamera.RenderFrustum()
+	}
+	renderSelection()
+}
+
+// renderOverlays draws the debug overlays over the whole window.
+func (renderer *r) renderOverlays() {
+	benchmark.Start("Render: Debug Overlays")
+	RenderPip()
+	RenderHUD()
+	RenderFPS()
+	benchmark.End("Render: Debug Overlays")
+}
+
+// bindColorShader uses the color shader and binds the lighting, shadowing, sky and fog state shared by
+// every pass that shades the scene. The view, camera position and screen size are left to the caller,
+// and the per pass settings are reset to those of the main color pass.
+func (renderer *r) bindColorShader(sky Sky, numShadowLights int) {
+	renderer.colorShader.Use()
+	renderer.colorShader.Projection.Set(ActiveCamera.GetProjection())
+	renderer.colorShader.LightViewProjs.Set(&CullingCamera.shadowMatrices[0][0], NumberOfCascades)
 	renderer.colorShader.NumTilesX.Set(getNumTilesX())
+	renderer.colorShader.TileCoordScale.Set(1)
+	renderer.colorShader.TiledLightsEnabled.Set(1)
+	renderer.colorShader.ClipPlane.Set(mgl32.Vec4{})
 	renderer.colorShader.LightBuffer.Set(GetPointLightBuffer())
-	renderer.colorShader.ZNear.Set(Window.nearPlane)
-	renderer.colorShader.ZFar.Set(Window.farPlane)
+	renderer.colorShader.ZNear.Set(ActiveCamera.projection.Near)
+	renderer.colorShader.ZFar.Set(ActiveCamera.projection.Far)
 	renderer.colorShader.ShadowMapSize.Set(shadowMapSize)
 	renderer.colorShader.AmbientLightColor.Set(ambientLightColor)
 	renderer.colorShader.CascadeDepthLimits.Set(&ShadowSplits[0], NumberOfCascades+1)
-	renderer.colorShader.FirstPersonPosition.Set(FirstPerson.GetPosition())
-	renderer.colorShader.FirstPersonForward.Set(FirstPerson.GetForward())
-	renderer.colorShader.VisibleLightIndicesBuffer.Set(GetPointLightVisibleLightIndicesBuffer())
+	renderer.colorShader.FirstPersonPosition.Set(CullingCamera.GetPosition())
+	renderer.colorShader.FirstPersonForward.Set(CullingCamera.GetForward())
+	renderer.colorShader.VisibleLightIndicesBuffer.Set(renderer.view.visibleLightIndices)
 	renderer.colorShader.DirectionalLightBuffer.Set(GetDirectionalLightBuffer())
 	renderer.colorShader.ShadowMap1.Set(gl.TEXTURE1, 1, renderer.csmDepthMaps[0])
 	renderer.colorShader.ShadowMap2.Set(gl.TEXTURE2, 2, renderer.csmDepthMaps[1])
@@ -427,21 +671,26 @@ func (renderer *r) Render(sky *Sky, renderables []Renderable) {
 	)
 	renderer.colorShader.PointShadowFarPlane.Set(PointShadowFarPlane)
 
-	for _, rd := range visibleSorted {
-		rd.r.Render(renderer.colorShader, mainFrustum)
-	}
-	benchmark.End("Render: Main Color")
-
-	benchmark.Start("Render: Debug Overlays")
-	if ActiveCamera == ThirdPerson {
-		renderer.lineShader.Use()
-		renderer.lineShader.View.Set(ThirdPerson.GetView())
-		renderer.lineShader.Projection.Set(Window.GetProjection())
-		FirstPerson.RenderFrustum()
-	}
-
-	RenderPip()
-	RenderHUD()
-	RenderFPS()
-	benchmark.End("Render: Debug Overlays")
+	// Bind the sky's image based lighting, prefiltered specular cubemap on texture unit 9.
+	env := sky.getEnvironment()
+	renderer.colorShader.IrradianceSH.Set(&env.irradianceSH[0][0], numSHCoefficients)
+	renderer.colorShader.PrefilteredEnvironment.Set(gl.TEXTURE9, 9, env.prefilteredMap)
+	renderer.colorShader.PrefilteredMipLevels.Set(PrefilteredMipLevels)
+	renderer.colorShader.EnvironmentRoughness.Set(EnvironmentRoughness)
+	renderer.colorShader.SkyLightIntensity.Set(SkyLightIntensity * sky.skyLightScale())
+
+	// Bind the integrated fog volume on texture unit 10.
+	renderer.colorShader.VolumetricFog.Set(gl.TEXTURE10, 10, integratedVolume)
+	renderer.colorShader.VolumetricFogEnabled.Set(1)
+	renderer.colorShader.VolumetricNear.Set(Fog.VolumetricNear)
+	renderer.colorShader.VolumetricFar.Set(Fog.VolumetricFar)
+	renderer.colorShader.FogDensity.Set(Fog.Density)
+	renderer.colorShader.FogHeightFalloff.Set(Fog.HeightFalloff)
+	renderer.colorShader.FogBaseHeight.Set(Fog.BaseHeight)
+	renderer.colorShader.FogAnisotropy.Set(Fog.Anisotropy)
+
+	// Terrain binds its material to texture units 14 and 15 while it renders.
+	renderer.colorShader.TerrainMaterialEnabled.Set(0)
+	renderer.colorShader.TerrainLayers.Set(gl.TEXTURE14, 14, 0)
+	renderer.colorShader.TerrainSplatMap.Set(gl.TEXTURE15, 15, 0)
 }
@@ -3,6 +3,7 @@ package gfx
 import (
 	"image"
 	"image/jpeg"
+	"image/png"
 	"os"
 	"path/filepath"
 	"time"
@@ -48,3 +49,16 @@ func Screenshot() {
 	}
 	f.Close()
 }
+
+// SaveFrame writes the frame rendered into the window to file as a PNG.
+func SaveFrame(file string) error {
+	frame := image.NewNRGBA(image.Rect(0, 0, int(Window.Width), int(Window.Height)))
+	gl.ReadPixels(0, 0, int32(Window.Width), int32(Window.Height), gl.RGBA, gl.UNSIGNED_BYTE, gl.Ptr(frame.Pix))
+	flipNRGBAVertical(frame)
+	f, err := os.Create(file)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	return png.Encode(f, frame)
+}
@@ -22,28 +22,36 @@ uniform mat4 view;
 uniform mat4 model;
 uniform mat4 lightViewProjs[NUMBER_OF_CASCADES];
 uniform int isInstanced;
+// Geometry on the negative side of clipPlane is clipped when GL_CLIP_DISTANCE0 is enabled.
+uniform vec4 clipPlane;
 
 layout(location = 0) in vec3 vert;
 layout(location = 1) in vec3 norm;
 layout(location = 2) in vec2 uv;
 layout(location = 3) in mat4 instanceModel;
-
+` + lodMorphSrc + `
+// terrainPaint holds the weights of the terrain layers painted by brushes.
+layout(location = 8) in vec4 terrainPaint;
 out vec4 position;
 out vec3 worldPosition;
 out vec3 norm_out;
 out vec2 uv_out;	
+out vec4 terrainPaint_out;
 out vec4 lightPositions[NUMBER_OF_CASCADES];
 
 void main() {
 	mat4 modelMat = (isInstanced != 0) ? instanceModel : model;
-	gl_Position = projection * view * modelMat * vec4(vert, 1);
-	position = projection * view * modelMat * vec4(vert, 1);
-	worldPosition = vec3(modelMat * vec4(vert, 1));
+	vec3 v = morphVertex(modelMat, vert);
+	gl_Position = projection * view * modelMat * vec4(v, 1);
+	position = projection * view * modelMat * vec4(v, 1);
+	worldPosition = vec3(modelMat * vec4(v, 1));
+	gl_ClipDistance[0] = dot(vec4(worldPosition, 1), clipPlane);
 	norm_out = normalize(mat3(transpose(inverse(modelMat))) * norm);
 	uv_out = uv;
+	terrainPaint_out = terrainPaint;
 
 	for (int i=0;i < NUMBER_OF_CASCADES; i++) {
-		lightPositions[i] = lightViewProjs[i] * modelMat * vec4(vert, 1);
+		lightPositions[i] = lightViewProjs[i] * modelMat * vec4(v, 1);
 	}
 }` + "\x00"
 	colorShaderOriginalFragmentSourceFile = `colorshader.frag`
@@ -85,6 +93,10 @@ layout(std430, binding = 2) readonly buffer DirectionalLightBuffer {
 
 uniform int renderMode;
 uniform uint numTilesX;
+// tileCoordScale maps fragment coordinates of this pass to the light culling tiles, and
+// tiledLightsEnabled is zero for passes rendered from a different view than the tiles.
+uniform float tileCoordScale;
+uniform int tiledLightsEnabled;
 uniform float zNear;
 uniform float zFar;
 uniform float shadowMapSize;
@@ -92,6 +104,7 @@ uniform vec3 ambientLightColor;
 uniform float cascadeDepthLimits[NUMBER_OF_CASCADES + 1];
 uniform vec3 firstPersonPosition;
 uniform vec3 firstPersonForward;
+uniform vec3 cameraPosition;
 uniform sampler2D diffuse;
 uniform sampler2DShadow shadowMap1;
 uniform sampler2DShadow shadowMap2;
@@ -107,6 +120,27 @@ uniform vec3  pointShadowLightPositions[MAX_POINT_SHADOW_LIGHTS];
 uniform float pointShadowFarPlane;
 const float POINT_SHADOW_BIAS_EPSILON = 0.002; // Small bias offset in normalized [0,1] space to prevent light leaking/bleeding at contacting geometry boundaries.
 
+// Image based lighting from the sky
+uniform vec3 irradianceSH[9];
+uniform samplerCube prefilteredEnvironment;
+uniform float prefilteredMipLevels;
+uniform float environmentRoughness;
+uniform float skyLightIntensity;
+
+// Height fog, and the froxel volume holding in-scattering and transmittance near the camera.
+uniform mat4 view;
+uniform vec2 screenSize;
+uniform sampler3D volumetricFog;
+uniform float volumetricNear;
+uniform float volumetricFar;
+uniform float fogDensity;
+uniform float fogHeightFalloff;
+uniform float fogBaseHeight;
+uniform float fogAnisotropy;
+// volumetricFogEnabled is zero for passes rendered from a different view than the froxel volume,
+// which then use analytic height fog all the way from the camera.
+uniform int volumetricFogEnabled;
+` + terrainMaterialSrc + `
 in vec4 position;
 in vec3 worldPosition;
 in vec3 norm_out;
@@ -150,6 +184,83 @@ float getShadowFactor(int index, vec3 projCoords, int radius)
 	return shadowFactor / count;
 }
 
+// Evaluates the sky's diffuse irradiance (pre-divided by PI) for the given normal.
+vec3 getSkyIrradiance(vec3 n) {
+	vec3 result =
+		irradianceSH[0] * 0.282095 +
+		irradianceSH[1] * 0.488603 * n.y +
+		irradianceSH[2] * 0.488603 * n.z +
+		irradianceSH[3] * 0.488603 * n.x +
+		irradianceSH[4] * 1.092548 * n.x * n.y +
+		irradianceSH[5] * 1.092548 * n.y * n.z +
+		irradianceSH[6] * 0.315392 * (3.0 * n.z * n.z - 1.0) +
+		irradianceSH[7] * 1.092548 * n.x * n.z +
+		irradianceSH[8] * 0.546274 * (n.x * n.x - n.y * n.y);
+	return max(result, vec3(0.0));
+}
+
+// Returns the sky reflected toward the camera, weighted by a dielectric Schlick fresnel term.
+vec3 getSkySpecular(vec3 n) {
+	vec3 viewDir = normalize(cameraPosition - worldPosition);
+	vec3 r = reflect(-viewDir, n);
+	float fresnel = 0.04 + 0.96 * pow(1.0 - max(dot(n, viewDir), 0.0), 5.0);
+	float lod = environmentRoughness * (prefilteredMipLevels - 1.0);
+	return textureLod(prefilteredEnvironment, r, lod).rgb * fresnel;
+}
+
+#define PI 3.141592
+
+float henyeyGreenstein(float cosTheta, float g) {
+	float gg = g * g;
+	return (1.0 - gg) / (4.0 * PI * pow(1.0 + gg - 2.0 * g * cosTheta, 1.5));
+}
+
+// Returns the integral of the height fog extinction along the ray from start in direction dir for dist units.
+float getHeightFogOpticalDepth(vec3 start, vec3 dir, float dist) {
+	float density = fogDensity * exp(-fogHeightFalloff * (start.y - fogBaseHeight));
+	float falloff = fogHeightFalloff * dir.y;
+	if (abs(falloff) < 0.0001) {
+		return density * dist;
+	}
+	return density * (1.0 - exp(-falloff * dist)) / falloff;
+}
+
+// Returns the unshadowed light scattered toward the camera by the fog along dir, using the same
+// sun and sky terms as the volumetric pass so distant fog blends into the froxel volume.
+vec3 getFogInScattering(vec3 dir, DirectionalLight light, float skyLight) {
+	float phase = henyeyGreenstein(dot(dir, -light.direction), fogAnisotropy);
+	return light.color * light.brightness * phase + irradianceSH[0] * 0.282095 * skyLight;
+}
+
+// Applies analytic height fog beyond the froxel volume, then the froxel volume itself.
+vec3 applyFog(vec3 color, DirectionalLight light, float skyLight) {
+	if (fogDensity <= 0.0) {
+		return color;
+	}
+
+	vec3 toFragment = worldPosition - cameraPosition;
+	float dist = length(toFragment);
+	vec3 dir = toFragment / dist;
+	float viewDepth = -(view * vec4(worldPosition, 1.0)).z;
+
+	if (volumetricFogEnabled == 0) {
+		float transmittance = exp(-getHeightFogOpticalDepth(cameraPosition, dir, dist));
+		return mix(getFogInScattering(dir, light, skyLight), color, transmittance);
+	}
+
+	if (viewDepth > volumetricFar) {
+		float start = dist * volumetricFar / viewDepth;
+		float transmittance = exp(-getHeightFogOpticalDepth(cameraPosition + dir * start, dir, dist - start));
+		color = mix(getFogInScattering(dir, light, skyLight), color, transmittance);
+	}
+
+	// Each froxel stores the fog up to the far side of its slice, so shift back half a slice.
+	float slices = float(textureSize(volumetricFog, 0).z);
+	float w = log(max(viewDepth, volumetricNear) / volumetricNear) / log(volumetricFar / volumetricNear);
+	vec4 fog = texture(volumetricFog, vec3(gl_FragCoord.xy / screenSize, clamp(w - 0.5 / slices, 0.0, 1.0)));
+	return color * fog.a + fog.rgb;
+}
+
 // Poisson disk samples for softening point light shadows (12 samples).
 vec3 poissonDisk[12] = vec3[]
 (
@@ -192,7 +303,7 @@ float getPointShadowFactor(int slot, vec3 worldPos, vec3 normal) {
 
 
 void main() {
-	ivec2 location = ivec2(gl_FragCoord.xy);
+	ivec2 location = ivec2(gl_FragCoord.xy * tileCoordScale);
 	// TODO: Put this 16 somewhere constant.
 	ivec2 tileID = location / ivec2(16, 16);
 	uint index = tileID.y * numTilesX + tileID.x;
@@ -201,14 +312,14 @@ void main() {
 	uint offset = index * 1024;
 	
 	if (renderMode == 0 || renderMode == 5) {		
-		vec4 diffuseColor = texture(diffuse, uv_out);
+		vec4 diffuseColor = (terrainMaterialEnabled != 0) ? getTerrainColor(worldPosition, normalize(norm_out)) : texture(diffuse, uv_out);
 		if (diffuseColor.a < 0.5) {
 			discard;
 		} 
 		
 		vec3 pointLightColor = vec3(0, 0, 0);
 		uint i=0;
-		for (i=0; i < 1024 && visibleLightIndicesBuffer.data[offset + i].index != -1; i++) {
+		for (i=0; tiledLightsEnabled != 0 && i < 1024 && visibleLightIndicesBuffer.data[offset + i].index != -1; i++) {
 			uint lightIndex = visibleLightIndicesBuffer.data[offset + i].index;
 			PointLight light = lightBuffer.data[lightIndex];
 			vec3 lightVector = light.position - worldPosition;
@@ -235,11 +346,11 @@ void main() {
 		float depthTest = dot(worldPosition - firstPersonPosition, firstPersonForward);
 
 		vec3 shadowCoords[5] = vec3[](
-			lightPositions[0] * 0.5 + 0.5, 
-			lightPositions[1] * 0.5 + 0.5, 
-			lightPositions[2] * 0.5 + 0.5,
-			lightPositions[3] * 0.5 + 0.5,
-			lightPositions[4] * 0.5 + 0.5
+			lightPositions[0].xyz * 0.5 + 0.5, 
+			lightPositions[1].xyz * 0.5 + 0.5, 
+			lightPositions[2].xyz * 0.5 + 0.5,
+			lightPositions[3].xyz * 0.5 + 0.5,
+			lightPositions[4].xyz * 0.5 + 0.5
 		);
 
 		int shadowIndex = 5;
@@ -272,9 +383,12 @@ void main() {
 			shadowFactor = getShadowFactor(shadowIndex, shadowCoords[shadowIndex], radius);
 		}		
 		
-		vec3 ambientLight = directionalLight.color * directionalLight.brightness * 0.2f;
+		float skyLight = skyLightIntensity;
+		vec3 ambientLight = getSkyIrradiance(norm_out) * skyLight;
+		vec3 skySpecular = getSkySpecular(norm_out) * skyLight;
 
-		outputColor = diffuseColor * vec4(shadowIndexColor, 1.0) * vec4(directionalLightColor*shadowFactor + ambientLight, 1.0) + diffuseColor * vec4(shadowIndexColor, 1.0) * vec4(pointLightColor, 1.0);
+		outputColor = diffuseColor * vec4(shadowIndexColor, 1.0) * vec4(directionalLightColor*shadowFactor + ambientLight, 1.0) + diffuseColor * vec4(shadowIndexColor, 1.0) * vec4(pointLightColor, 1.0) + vec4(skySpecular, 0.0);
+		outputColor.rgb = applyFog(outputColor.rgb, directionalLight, skyLight);
 	} else if (renderMode == 1) {
 		uint i=0;
 		for (i; i < 1024 && visibleLightIndicesBuffer.data[offset + i].index != -1; i++) {}
@@ -284,7 +398,7 @@ void main() {
 	} else if (renderMode == 3) {
 		outputColor = vec4(uv_out, 0, 1.0);
 	} else if (renderMode == 4) {
-		vec4 diffuseColor = texture(diffuse, uv_out);
+		vec4 diffuseColor = (terrainMaterialEnabled != 0) ? getTerrainColor(worldPosition, normalize(norm_out)) : texture(diffuse, uv_out);
 		if (diffuseColor.a < 0.5) {
 			discard;
 		} 
@@ -310,8 +424,25 @@ type ColorShader struct {
 	CascadeDepthLimits *uniforms.FloatArray
 	FirstPersonPosition *uniforms.Vector3
 	FirstPersonForward  *uniforms.Vector3
+	CameraPosition     *uniforms.Vector3
 	Diffuse            *uniforms.Sampler2D
 	IsInstanced        *uniforms.Int
+	LodMorphEnabled    *uniforms.Int
+	LodCenter          *uniforms.Vector3
+	LodBaseDistance    *uniforms.Float
+	ClipPlane          *uniforms.Vector4
+	TileCoordScale     *uniforms.Float
+	TiledLightsEnabled *uniforms.Int
+
+	// Terrain material layers, see terrainMaterialSrc
+	TerrainMaterialEnabled                                     *uniforms.Int
+	TerrainLayers                                              *uniforms.Sampler2DArray
+	TerrainHeightMin, TerrainHeightMax                         *uniforms.Vector4
+	TerrainSlopeMin, TerrainSlopeMax                           *uniforms.Vector4
+	TerrainHeightBlend, TerrainSlopeBlend, TerrainTextureScale *uniforms.Float
+	TerrainSplatMapEnabled                                     *uniforms.Int
+	TerrainSplatMap                                            *uniforms.Sampler2D
+	TerrainSplatMapRect                                        *uniforms.Vector4
 
 	LightBuffer, VisibleLightIndicesBuffer, DirectionalLightBuffer *buffers.Binding
 
@@ -322,6 +453,23 @@ type ColorShader struct {
 	NumPointShadowLights      *uniforms.Int
 	PointShadowLightPositions *uniforms.Vector3Array
 	PointShadowFarPlane       *uniforms.Float
+
+	// Image based lighting from the sky
+	IrradianceSH           *uniforms.Vector3Array
+	PrefilteredEnvironment *uniforms.SamplerCube
+	PrefilteredMipLevels   *uniforms.Float
+	EnvironmentRoughness   *uniforms.Float
+	SkyLightIntensity      *uniforms.Float
+
+	// Height fog and volumetric lighting
+	ScreenSize                                                 *uniforms.Vector2
+	VolumetricFog                                              *uniforms.Sampler3D
+	VolumetricNear, VolumetricFar                              *uniforms.Float
+	FogDensity, FogHeightFalloff, FogBaseHeight, FogAnisotropy *uniforms.Float
+	VolumetricFogEnabled                                       *uniforms.Int
+
+	// Tessellated draws terrain subdivided on the GPU, with this shader's fragment stage.
+	Tessellated *TessellatedColorShader
 }
 
 // NewColorShader instantiates and initializes a shader object.
@@ -372,6 +520,23 @@ func NewColorShader() (*ColorShader, error) {
 		return nil, fmt.Errorf("failed to link %v: %v", colorShaderOriginalVertexSourceFile, log)
 	}
 
+	gl.DeleteShader(vertexShader)
+	gl.DeleteShader(fragmentShader)
+
+	gl.BindFragDataLocation(program, 0, gl.Str("outputColor\x00"))
+
+	colorShader := newColorShaderUniforms(program)
+
+	tessellated, err := newTessellatedColorShader(colorShader)
+	if err != nil {
+		return nil, err
+	}
+	colorShader.Tessellated = tessellated
+	return colorShader, nil
+}
+
+// newColorShaderUniforms looks up the uniforms of the color shader in program.
+func newColorShaderUniforms(program uint32) *ColorShader {
 	projectionLoc := gl.GetUniformLocation(program, gl.Str("projection\x00"))
 	viewLoc := gl.GetUniformLocation(program, gl.Str("view\x00"))
 	modelLoc := gl.GetUniformLocation(program, gl.Str("model\x00"))
@@ -385,8 +550,27 @@ func NewColorShader() (*ColorShader, error) {
 	cascadeDepthLimitsLoc := gl.GetUniformLocation(program, gl.Str("cascadeDepthLimits\x00"))
 	firstPersonPositionLoc := gl.GetUniformLocation(program, gl.Str("firstPersonPosition\x00"))
 	firstPersonForwardLoc := gl.GetUniformLocation(program, gl.Str("firstPersonForward\x00"))
+	cameraPositionLoc := gl.GetUniformLocation(program, gl.Str("cameraPosition\x00"))
 	diffuseLoc := gl.GetUniformLocation(program, gl.Str("diffuse\x00"))
 	isInstancedLoc := gl.GetUniformLocation(program, gl.Str("isInstanced\x00"))
+	lodMorphEnabledLoc := gl.GetUniformLocation(program, gl.Str("lodMorphEnabled\x00"))
+	lodCenterLoc := gl.GetUniformLocation(program, gl.Str("lodCenter\x00"))
+	lodBaseDistanceLoc := gl.GetUniformLocation(program, gl.Str("lodBaseDistance\x00"))
+	clipPlaneLoc := gl.GetUniformLocation(program, gl.Str("clipPlane\x00"))
+	tileCoordScaleLoc := gl.GetUniformLocation(program, gl.Str("tileCoordScale\x00"))
+	tiledLightsEnabledLoc := gl.GetUniformLocation(program, gl.Str("tiledLightsEnabled\x00"))
+	terrainMaterialEnabledLoc := gl.GetUniformLocation(program, gl.Str("terrainMaterialEnabled\x00"))
+	terrainLayersLoc := gl.GetUniformLocation(program, gl.Str("terrainLayers\x00"))
+	terrainHeightMinLoc := gl.GetUniformLocation(program, gl.Str("terrainHeightMin\x00"))
+	terrainHeightMaxLoc := gl.GetUniformLocation(program, gl.Str("terrainHeightMax\x00"))
+	terrainSlopeMinLoc := gl.GetUniformLocation(program, gl.Str("terrainSlopeMin\x00"))
+	terrainSlopeMaxLoc := gl.GetUniformLocation(program, gl.Str("terrainSlopeMax\x00"))
+	terrainHeightBlendLoc := gl.GetUniformLocation(program, gl.Str("terrainHeightBlend\x00"))
+	terrainSlopeBlendLoc := gl.GetUniformLocation(program, gl.Str("terrainSlopeBlend\x00"))
+	terrainTextureScaleLoc := gl.GetUniformLocation(program, gl.Str("terrainTextureScale\x00"))
+	terrainSplatMapEnabledLoc := gl.GetUniformLocation(program, gl.Str("terrainSplatMapEnabled\x00"))
+	terrainSplatMapLoc := gl.GetUniformLocation(program, gl.Str("terrainSplatMap\x00"))
+	terrainSplatMapRectLoc := gl.GetUniformLocation(program, gl.Str("terrainSplatMapRect\x00"))
 	shadowMap1Loc := gl.GetUniformLocation(program, gl.Str("shadowMap1\x00"))
 	shadowMap2Loc := gl.GetUniformLocation(program, gl.Str("shadowMap2\x00"))
 	shadowMap3Loc := gl.GetUniformLocation(program, gl.Str("shadowMap3\x00"))
@@ -396,11 +580,20 @@ func NewColorShader() (*ColorShader, error) {
 	numPointShadowLightsLoc := gl.GetUniformLocation(program, gl.Str("numPointShadowLights\x00"))
 	pointShadowLightPositionsLoc := gl.GetUniformLocation(program, gl.Str("pointShadowLightPositions\x00"))
 	pointShadowFarPlaneLoc := gl.GetUniformLocation(program, gl.Str("pointShadowFarPlane\x00"))
-
-	gl.DeleteShader(vertexShader)
-	gl.DeleteShader(fragmentShader)
-
-	gl.BindFragDataLocation(program, 0, gl.Str("outputColor\x00"))
+	irradianceSHLoc := gl.GetUniformLocation(program, gl.Str("irradianceSH\x00"))
+	prefilteredEnvironmentLoc := gl.GetUniformLocation(program, gl.Str("prefilteredEnvironment\x00"))
+	prefilteredMipLevelsLoc := gl.GetUniformLocation(program, gl.Str("prefilteredMipLevels\x00"))
+	environmentRoughnessLoc := gl.GetUniformLocation(program, gl.Str("environmentRoughness\x00"))
+	skyLightIntensityLoc := gl.GetUniformLocation(program, gl.Str("skyLightIntensity\x00"))
+	screenSizeLoc := gl.GetUniformLocation(program, gl.Str("screenSize\x00"))
+	volumetricFogLoc := gl.GetUniformLocation(program, gl.Str("volumetricFog\x00"))
+	volumetricNearLoc := gl.GetUniformLocation(program, gl.Str("volumetricNear\x00"))
+	volumetricFarLoc := gl.GetUniformLocation(program, gl.Str("volumetricFar\x00"))
+	fogDensityLoc := gl.GetUniformLocation(program, gl.Str("fogDensity\x00"))
+	fogHeightFalloffLoc := gl.GetUniformLocation(program, gl.Str("fogHeightFalloff\x00"))
+	fogBaseHeightLoc := gl.GetUniformLocation(program, gl.Str("fogBaseHeight\x00"))
+	fogAnisotropyLoc := gl.GetUniformLocation(program, gl.Str("fogAnisotropy\x00"))
+	volumetricFogEnabledLoc := gl.GetUniformLocation(program, gl.Str("volumetricFogEnabled\x00"))
 
 	return &ColorShader{
 		shader:                    shader{program},
@@ -417,8 +610,27 @@ func NewColorShader() (*ColorShader, error) {
 		CascadeDepthLimits:        uniforms.NewFloatArray(program, cascadeDepthLimitsLoc),
 		FirstPersonPosition:       uniforms.NewVector3(program, firstPersonPositionLoc),
 		FirstPersonForward:        uniforms.NewVector3(program, firstPersonForwardLoc),
+		CameraPosition:            uniforms.NewVector3(program, cameraPositionLoc),
 		Diffuse:                   uniforms.NewSampler2D(program, diffuseLoc),
 		IsInstanced:               uniforms.NewInt(program, isInstancedLoc),
+		LodMorphEnabled:           uniforms.NewInt(program, lodMorphEnabledLoc),
+		LodCenter:                 uniforms.NewVector3(program, lodCenterLoc),
+		LodBaseDistance:           uniforms.NewFloat(program, lodBaseDistanceLoc),
+		ClipPlane:                 uniforms.NewVector4(program, clipPlaneLoc),
+		TileCoordScale:            uniforms.NewFloat(program, tileCoordScaleLoc),
+		TiledLightsEnabled:        uniforms.NewInt(program, tiledLightsEnabledLoc),
+		TerrainMaterialEnabled:    uniforms.NewInt(program, terrainMaterialEnabledLoc),
+		TerrainLayers:             uniforms.NewSampler2DArray(program, terrainLayersLoc),
+		TerrainHeightMin:          uniforms.NewVector4(program, terrainHeightMinLoc),
+		TerrainHeightMax:          uniforms.NewVector4(program, terrainHeightMaxLoc),
+		TerrainSlopeMin:           uniforms.NewVector4(program, terrainSlopeMinLoc),
+		TerrainSlopeMax:           uniforms.NewVector4(program, terrainSlopeMaxLoc),
+		TerrainHeightBlend:        uniforms.NewFloat(program, terrainHeightBlendLoc),
+		TerrainSlopeBlend:         uniforms.NewFloat(program, terrainSlopeBlendLoc),
+		TerrainTextureScale:       uniforms.NewFloat(program, terrainTextureScaleLoc),
+		TerrainSplatMapEnabled:    uniforms.NewInt(program, terrainSplatMapEnabledLoc),
+		TerrainSplatMap:           uniforms.NewSampler2D(program, terrainSplatMapLoc),
+		TerrainSplatMapRect:       uniforms.NewVector4(program, terrainSplatMapRectLoc),
 		LightBuffer:               buffers.NewBinding(0),
 		VisibleLightIndicesBuffer: buffers.NewBinding(1),
 		DirectionalLightBuffer:    buffers.NewBinding(2),
@@ -431,5 +643,19 @@ func NewColorShader() (*ColorShader, error) {
 		NumPointShadowLights:      uniforms.NewInt(program, numPointShadowLightsLoc),
 		PointShadowLightPositions: uniforms.NewVector3Array(program, pointShadowLightPositionsLoc),
 		PointShadowFarPlane:       uniforms.NewFloat(program, pointShadowFarPlaneLoc),
-	}, nil
+		IrradianceSH:              uniforms.NewVector3Array(program, irradianceSHLoc),
+		PrefilteredEnvironment:    uniforms.NewSamplerCube(program, prefilteredEnvironmentLoc),
+		PrefilteredMipLevels:      uniforms.NewFloat(program, prefilteredMipLevelsLoc),
+		EnvironmentRoughness:      uniforms.NewFloat(program, environmentRoughnessLoc),
+		SkyLightIntensity:         uniforms.NewFloat(program, skyLightIntensityLoc),
+		ScreenSize:                uniforms.NewVector2(program, screenSizeLoc),
+		VolumetricFog:             uniforms.NewSampler3D(program, volumetricFogLoc),
+		VolumetricNear:            uniforms.NewFloat(program, volumetricNearLoc),
+		VolumetricFar:             uniforms.NewFloat(program, volumetricFarLoc),
+		FogDensity:                uniforms.NewFloat(program, fogDensityLoc),
+		FogHeightFalloff:          uniforms.NewFloat(program, fogHeightFalloffLoc),
+		FogBaseHeight:             uniforms.NewFloat(program, fogBaseHeightLoc),
+		FogAnisotropy:             uniforms.NewFloat(program, fogAnisotropyLoc),
+		VolumetricFogEnabled:      uniforms.NewInt(program, volumetricFogEnabledLoc),
+	}
 }
@@ -22,12 +22,12 @@ layout(location = 0) in vec3 vert;
 layout(location = 1) in vec3 norm;
 layout(location = 2) in vec2 uv;
 layout(location = 3) in mat4 instanceModel;
-
+` + lodMorphSrc + `
 out vec2 uv_out;
 
 void main() {
 	mat4 modelMat = (isInstanced != 0) ? instanceModel : model;
-	gl_Position = projection * view * modelMat * vec4(vert, 1);
+	gl_Position = projection * view * modelMat * vec4(morphVertex(modelMat, vert), 1);
 	uv_out = uv;
 }` + "\x00"
 	depthShaderOriginalFragmentSourceFile = `depthshader.frag`
@@ -53,7 +53,14 @@ type DepthShader struct {
 	Projection, View, Model *uniforms.Matrix4
 	IsInstanced             *uniforms.Int
 
+	LodMorphEnabled *uniforms.Int
+	LodCenter       *uniforms.Vector3
+	LodBaseDistance *uniforms.Float
+
 	Diffuse *uniforms.Sampler2D
+
+	// Tessellated draws terrain subdivided on the GPU, matching the tessellated color shader.
+	Tessellated *TessellatedDepthShader
 }
 
 // NewDepthShader instantiates and initializes a shader object.
@@ -104,21 +111,39 @@ func NewDepthShader() (*DepthShader, error) {
 		return nil, fmt.Errorf("failed to link %v: %v", depthShaderOriginalVertexSourceFile, log)
 	}
 
+	gl.DeleteShader(vertexShader)
+	gl.DeleteShader(fragmentShader)
+
+	depthShader := newDepthShaderUniforms(program)
+
+	tessellated, err := newTessellatedDepthShader(depthShader)
+	if err != nil {
+		return nil, err
+	}
+	depthShader.Tessellated = tessellated
+	return depthShader, nil
+}
+
+// newDepthShaderUniforms looks up the uniforms of the depth shader in program.
+func newDepthShaderUniforms(program uint32) *DepthShader {
 	projectionLoc := gl.GetUniformLocation(program, gl.Str("projection\x00"))
 	viewLoc := gl.GetUniformLocation(program, gl.Str("view\x00"))
 	modelLoc := gl.GetUniformLocation(program, gl.Str("model\x00"))
 	isInstancedLoc := gl.GetUniformLocation(program, gl.Str("isInstanced\x00"))
+	lodMorphEnabledLoc := gl.GetUniformLocation(program, gl.Str("lodMorphEnabled\x00"))
+	lodCenterLoc := gl.GetUniformLocation(program, gl.Str("lodCenter\x00"))
+	lodBaseDistanceLoc := gl.GetUniformLocation(program, gl.Str("lodBaseDistance\x00"))
 	diffuseLoc := gl.GetUniformLocation(program, gl.Str("diffuse\x00"))
 
-	gl.DeleteShader(vertexShader)
-	gl.DeleteShader(fragmentShader)
-
 	return &DepthShader{
 		shader:     shader{program},
 		Projection: uniforms.NewMatrix4(program, projectionLoc),
 		View:       uniforms.NewMatrix4(program, viewLoc),
 		Model:      uniforms.NewMatrix4(program, modelLoc),
 		IsInstanced: uniforms.NewInt(program, isInstancedLoc),
+		LodMorphEnabled: uniforms.NewInt(program, lodMorphEnabledLoc),
+		LodCenter:       uniforms.NewVector3(program, lodCenterLoc),
+		LodBaseDistance: uniforms.NewFloat(program, lodBaseDistanceLoc),
 		Diffuse:    uniforms.NewSampler2D(program, diffuseLoc),
-	}, nil
+	}
 }
@@ -0,0 +1,104 @@
+package shaders
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/brandonnelson3/GoRender/gfx/uniforms"
+
+	"github.com/go-gl/gl/v4.5-core/gl"
+)
+
+const (
+	hiZShaderOriginalComputeSourceFile = `hizshader.comp`
+	hiZShaderComputeSrc                = `
+#version 450
+
+// Level 0 of the pyramid is reduced from the depth map, every other level from the level before it.
+uniform bool fromDepth;
+uniform sampler2D depthMap;
+layout(r32f) uniform readonly image2D source;
+layout(r32f) uniform writeonly image2D destination;
+
+// Every texel holds the farthest depth of the source texels it covers. Sizes needn't halve exactly, so a
+// texel covers every source texel it overlaps at all.
+layout(local_size_x = 8, local_size_y = 8, local_size_z = 1) in;
+void main() {
+	ivec2 texel = ivec2(gl_GlobalInvocationID.xy);
+	ivec2 size = imageSize(destination);
+	if (any(greaterThanEqual(texel, size))) {
+		return;
+	}
+
+	ivec2 sourceSize = fromDepth ? textureSize(depthMap, 0) : imageSize(source);
+	ivec2 first = (texel * sourceSize) / size;
+	ivec2 last = min(((texel + 1) * sourceSize + size - 1) / size, sourceSize) - 1;
+
+	float farthest = 0.0;
+	for (int y = first.y; y <= last.y; y++) {
+		for (int x = first.x; x <= last.x; x++) {
+			float depth = fromDepth ? texelFetch(depthMap, ivec2(x, y), 0).r : imageLoad(source, ivec2(x, y)).r;
+			farthest = max(farthest, depth);
+		}
+	}
+	imageStore(destination, texel, vec4(farthest));
+}` + "\x00"
+)
+
+// HiZShader is a compute shader which reduces a depth map into a pyramid of the farthest depth over ever
+// larger areas, one level per dispatch.
+type HiZShader struct {
+	shader
+
+	FromDepth           *uniforms.Int
+	DepthMap            *uniforms.Sampler2D
+	Source, Destination *uniforms.Image2D
+}
+
+// NewHiZShader instantiates and initializes a HiZShader object.
+func NewHiZShader() (*HiZShader, error) {
+	program := gl.CreateProgram()
+
+	// ComputeShader
+	computeShader := gl.CreateShader(gl.COMPUTE_SHADER)
+	computeSrc, freeComputeSrc := gl.Strs(hiZShaderComputeSrc)
+	gl.ShaderSource(computeShader, 1, computeSrc, nil)
+	freeComputeSrc()
+	gl.CompileShader(computeShader)
+	var status int32
+	gl.GetShaderiv(computeShader, gl.COMPILE_STATUS, &status)
+	if status == gl.FALSE {
+		var logLength int32
+		gl.GetShaderiv(computeShader, gl.INFO_LOG_LENGTH, &logLength)
+		log := strings.Repeat("\x00", int(logLength+1))
+		gl.GetShaderInfoLog(computeShader, logLength, nil, gl.Str(log))
+		return nil, fmt.Errorf("failed to compile %v: %v", hiZShaderOriginalComputeSourceFile, log)
+	}
+	gl.AttachShader(program, computeShader)
+
+	// Linking
+	gl.LinkProgram(program)
+	gl.GetProgramiv(program, gl.LINK_STATUS, &status)
+	if status == gl.FALSE {
+		var logLength int32
+		gl.GetProgramiv(program, gl.INFO_LOG_LENGTH, &logLength)
+		log := strings.Repeat("\x00", int(logLength+1))
+		gl.GetProgramInfoLog(program, logLength, nil, gl.Str(log))
+		return nil, fmt.Errorf("failed to link %v: %v", hiZShaderOriginalComputeSourceFile, log)
+	}
+
+	fromDepthLoc := gl.GetUniformLocation(program, gl.Str("fromDepth\x00"))
+	depthMapLoc := gl.GetUniformLocation(program, gl.Str("depthMap\x00"))
+	sourceLoc := gl.GetUniformLocation(program, gl.Str("source\x00"))
+	destinationLoc := gl.GetUniformLocation(program, gl.Str("destination\x00"))
+
+	gl.DeleteShader(computeShader)
+
+	return &HiZShader{
+		shader:      shader{program},
+		FromDepth:   uniforms.NewInt(program, fromDepthLoc),
+		DepthMap:    uniforms.NewSampler2D(program, depthMapLoc),
+		Source:      uniforms.NewImage2D(program, sourceLoc),
+		Destination: uniforms.NewImage2D(program, destinationLoc),
+	}, nil
+}
@@ -0,0 +1,148 @@
+package shaders
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/brandonnelson3/GoRender/gfx/buffers"
+	"github.com/brandonnelson3/GoRender/gfx/uniforms"
+
+	"github.com/go-gl/gl/v4.5-core/gl"
+)
+
+const (
+	instanceCullingShaderOriginalComputeSourceFile = `instancecullingshader.comp`
+	instanceCullingShaderComputeSrc                = `
+#version 450
+
+struct DrawCommand {
+	uint count;
+	uint instanceCount;
+	uint first;
+	uint baseInstance;
+};
+
+layout(std430, binding = 0) readonly buffer InstanceBuffer {
+	mat4 data[];
+} instanceBuffer;
+
+layout(std430, binding = 1) writeonly buffer VisibleInstanceBuffer {
+	mat4 data[];
+} visibleInstanceBuffer;
+
+// Every command draws the visible instances, each with a different part of the mesh.
+layout(std430, binding = 2) buffer DrawCommandBuffer {
+	DrawCommand data[];
+} drawCommandBuffer;
+
+uniform uint instanceCount;
+uniform uint commandCount;
+uniform vec4 frustumPlanes[6];
+uniform vec3 localMin;
+uniform vec3 localMax;
+uniform vec3 cameraPosition;
+// drawDistance is how far from the camera instances are drawn, or zero to draw them at any distance.
+uniform float drawDistance;
+
+layout(local_size_x = 64, local_size_y = 1, local_size_z = 1) in;
+void main() {
+	uint index = gl_GlobalInvocationID.x;
+	if (index >= instanceCount) {
+		return;
+	}
+	mat4 model = instanceBuffer.data[index];
+
+	// The world space box around the transformed local bounds.
+	vec3 center = (model * vec4((localMin + localMax) * 0.5, 1.0)).xyz;
+	vec3 localExtent = (localMax - localMin) * 0.5;
+	vec3 extent = mat3(abs(model[0].xyz), abs(model[1].xyz), abs(model[2].xyz)) * localExtent;
+
+	if (drawDistance > 0.0 && distance(center, cameraPosition) - length(extent) > drawDistance) {
+		return;
+	}
+	for (int i = 0; i < 6; i++) {
+		vec4 plane = frustumPlanes[i];
+		if (dot(plane.xyz, center) + plane.w + dot(abs(plane.xyz), extent) < 0.0) {
+			return;
+		}
+	}
+
+	uint slot = atomicAdd(drawCommandBuffer.data[0].instanceCount, 1u);
+	for (uint i = 1u; i < commandCount; i++) {
+		atomicAdd(drawCommandBuffer.data[i].instanceCount, 1u);
+	}
+	visibleInstanceBuffer.data[slot] = model;
+}` + "\x00"
+)
+
+// InstanceCullingShader is a compute shader which culls the instances of a renderable against a frustum,
+// compacting those left into a buffer and counting them into indirect draw commands.
+type InstanceCullingShader struct {
+	shader
+
+	InstanceCount, CommandCount *uniforms.UInt
+	FrustumPlanes               *uniforms.Vector4Array
+	LocalMin, LocalMax          *uniforms.Vector3
+	CameraPosition              *uniforms.Vector3
+	DrawDistance                *uniforms.Float
+
+	InstanceBuffer, VisibleInstanceBuffer, DrawCommandBuffer *buffers.Binding
+}
+
+// NewInstanceCullingShader instantiates and initializes an InstanceCullingShader object.
+func NewInstanceCullingShader() (*InstanceCullingShader, error) {
+	program := gl.CreateProgram()
+
+	// ComputeShader
+	computeShader := gl.CreateShader(gl.COMPUTE_SHADER)
+	computeSrc, freeComputeSrc := gl.Strs(instanceCullingShaderComputeSrc)
+	gl.ShaderSource(computeShader, 1, computeSrc, nil)
+	freeComputeSrc()
+	gl.CompileShader(computeShader)
+	var status int32
+	gl.GetShaderiv(computeShader, gl.COMPILE_STATUS, &status)
+	if status == gl.FALSE {
+		var logLength int32
+		gl.GetShaderiv(computeShader, gl.INFO_LOG_LENGTH, &logLength)
+		log := strings.Repeat("\x00", int(logLength+1))
+		gl.GetShaderInfoLog(computeShader, logLength, nil, gl.Str(log))
+		return nil, fmt.Errorf("failed to compile %v: %v", instanceCullingShaderOriginalComputeSourceFile, log)
+	}
+	gl.AttachShader(program, computeShader)
+
+	// Linking
+	gl.LinkProgram(program)
+	gl.GetProgramiv(program, gl.LINK_STATUS, &status)
+	if status == gl.FALSE {
+		var logLength int32
+		gl.GetProgramiv(program, gl.INFO_LOG_LENGTH, &logLength)
+		log := strings.Repeat("\x00", int(logLength+1))
+		gl.GetProgramInfoLog(program, logLength, nil, gl.Str(log))
+		return nil, fmt.Errorf("failed to link %v: %v", instanceCullingShaderOriginalComputeSourceFile, log)
+	}
+
+	instanceCountLoc := gl.GetUniformLocation(program, gl.Str("instanceCount\x00"))
+	commandCountLoc := gl.GetUniformLocation(program, gl.Str("commandCount\x00"))
+	frustumPlanesLoc := gl.GetUniformLocation(program, gl.Str("frustumPlanes\x00"))
+	localMinLoc := gl.GetUniformLocation(program, gl.Str("localMin\x00"))
+	localMaxLoc := gl.GetUniformLocation(program, gl.Str("localMax\x00"))
+	cameraPositionLoc := gl.GetUniformLocation(program, gl.Str("cameraPosition\x00"))
+	drawDistanceLoc := gl.GetUniformLocation(program, gl.Str("drawDistance\x00"))
+
+	gl.DeleteShader(computeShader)
+
+	return &InstanceCullingShader{
+		shader:         shader{program},
+		InstanceCount:  uniforms.NewUInt(program, instanceCountLoc),
+		CommandCount:   uniforms.NewUInt(program, commandCountLoc),
+		FrustumPlanes:  uniforms.NewVector4Array(program, frustumPlanesLoc),
+		LocalMin:       uniforms.NewVector3(program, localMinLoc),
+		LocalMax:       uniforms.NewVector3(program, localMaxLoc),
+		CameraPosition: uniforms.NewVector3(program, cameraPositionLoc),
+		DrawDistance:   uniforms.NewFloat(program, drawDistanceLoc),
+
+		InstanceBuffer:        buffers.NewBinding(0),
+		VisibleInstanceBuffer: buffers.NewBinding(1),
+		DrawCommandBuffer:     buffers.NewBinding(2),
+	}, nil
+}
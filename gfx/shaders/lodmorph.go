@@ -0,0 +1,22 @@
+package shaders
+
+// lodMorphSrc is the vertex shader code shared by every shader that draws terrain. Each terrain vertex
+// carries the height it takes on the next coarser level of detail and the level it disappears after,
+// and slides toward that height as its distance from lodCenter approaches the next level.
+const lodMorphSrc = `
+uniform int lodMorphEnabled;
+uniform vec3 lodCenter;
+uniform float lodBaseDistance;
+
+layout(location = 7) in vec2 lodMorph;
+
+vec3 morphVertex(mat4 modelMat, vec3 v) {
+	if (lodMorphEnabled == 0) {
+		return v;
+	}
+	vec2 worldXZ = (modelMat * vec4(v, 1)).xz;
+	float lod = log2(max(distance(worldXZ, lodCenter.xz), 1.0) / lodBaseDistance);
+	float morph = clamp((lod - lodMorph.y) * 2.0 - 1.0, 0.0, 1.0);
+	return vec3(v.x, mix(v.y, lodMorph.x, morph), v.z);
+}
+`
@@ -24,13 +24,13 @@ layout(location = 0) in vec3 vert;
 layout(location = 1) in vec3 norm;
 layout(location = 2) in vec2 uv;
 layout(location = 3) in mat4 instanceModel;
-
+` + lodMorphSrc + `
 out vec2 uv_geom;
 out vec3 worldPos_geom;
 
 void main() {
 	mat4 modelMat = (isInstanced != 0) ? instanceModel : model;
-	vec4 worldPos = modelMat * vec4(vert, 1.0);
+	vec4 worldPos = modelMat * vec4(morphVertex(modelMat, vert), 1.0);
 	worldPos_geom = worldPos.xyz;
 	uv_geom = uv;
 	gl_Position = worldPos;
@@ -94,6 +94,9 @@ type PointLightShadowShader struct {
 
 	Model            *uniforms.Matrix4
 	IsInstanced      *uniforms.Int
+	LodMorphEnabled  *uniforms.Int
+	LodCenter        *uniforms.Vector3
+	LodBaseDistance  *uniforms.Float
 	ShadowMatrices   *uniforms.Matrix4Array
 	Diffuse          *uniforms.Sampler2D
 	LightPos         *uniforms.Vector3Array
@@ -171,6 +174,9 @@ func NewPointLightShadowShader() (*PointLightShadowShader, error) {
 
 	modelLoc := gl.GetUniformLocation(program, gl.Str("model\x00"))
 	isInstancedLoc := gl.GetUniformLocation(program, gl.Str("isInstanced\x00"))
+	lodMorphEnabledLoc := gl.GetUniformLocation(program, gl.Str("lodMorphEnabled\x00"))
+	lodCenterLoc := gl.GetUniformLocation(program, gl.Str("lodCenter\x00"))
+	lodBaseDistanceLoc := gl.GetUniformLocation(program, gl.Str("lodBaseDistance\x00"))
 	shadowMatricesLoc := gl.GetUniformLocation(program, gl.Str("shadowMatrices\x00"))
 	diffuseLoc := gl.GetUniformLocation(program, gl.Str("diffuse\x00"))
 	lightPosLoc := gl.GetUniformLocation(program, gl.Str("lightPos\x00"))
@@ -181,6 +187,9 @@ func NewPointLightShadowShader() (*PointLightShadowShader, error) {
 		shader:           shader{program},
 		Model:            uniforms.NewMatrix4(program, modelLoc),
 		IsInstanced:      uniforms.NewInt(program, isInstancedLoc),
+		LodMorphEnabled:  uniforms.NewInt(program, lodMorphEnabledLoc),
+		LodCenter:        uniforms.NewVector3(program, lodCenterLoc),
+		LodBaseDistance:  uniforms.NewFloat(program, lodBaseDistanceLoc),
 		ShadowMatrices:   uniforms.NewMatrix4Array(program, shadowMatricesLoc),
 		Diffuse:          uniforms.NewSampler2D(program, diffuseLoc),
 		LightPos:         uniforms.NewVector3Array(program, lightPosLoc),
@@ -0,0 +1,185 @@
+package shaders
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/brandonnelson3/GoRender/gfx/uniforms"
+
+	"github.com/go-gl/gl/v4.5-core/gl"
+)
+
+const (
+	prefilterShaderOriginalVertexSourceFile = `prefiltershader.vert`
+	prefilterShaderVertSrc                  = `
+#version 450
+
+uniform mat4 projection;
+uniform mat4 view;
+
+in vec3 vert;
+
+out vec3 position;
+
+void main() {
+	gl_Position = projection * view * vec4(vert, 1);
+	position = vert;
+}` + "\x00"
+	prefilterShaderOriginalFragmentSourceFile = `prefiltershader.frag`
+	prefilterShaderFragSrc                    = `
+#version 450
+
+uniform samplerCube environmentMap;
+uniform float roughness;
+uniform float environmentMapSize;
+
+in vec3 position;
+
+out vec4 outputColor;
+
+#define PI 3.141592
+#define SAMPLE_COUNT 256u
+
+// Van der Corput radical inverse, used to build a Hammersley point set.
+float radicalInverse(uint bits) {
+	bits = (bits << 16u) | (bits >> 16u);
+	bits = ((bits & 0x55555555u) << 1u) | ((bits & 0xAAAAAAAAu) >> 1u);
+	bits = ((bits & 0x33333333u) << 2u) | ((bits & 0xCCCCCCCCu) >> 2u);
+	bits = ((bits & 0x0F0F0F0Fu) << 4u) | ((bits & 0xF0F0F0F0u) >> 4u);
+	bits = ((bits & 0x00FF00FFu) << 8u) | ((bits & 0xFF00FF00u) >> 8u);
+	return float(bits) * 2.3283064365386963e-10;
+}
+
+vec3 importanceSampleGGX(vec2 xi, vec3 n, float a) {
+	float phi = 2.0 * PI * xi.x;
+	float cosTheta = sqrt((1.0 - xi.y) / (1.0 + (a*a - 1.0) * xi.y));
+	float sinTheta = sqrt(1.0 - cosTheta*cosTheta);
+	vec3 h = vec3(cos(phi) * sinTheta, sin(phi) * sinTheta, cosTheta);
+
+	vec3 up = abs(n.z) < 0.999 ? vec3(0.0, 0.0, 1.0) : vec3(1.0, 0.0, 0.0);
+	vec3 tangent = normalize(cross(up, n));
+	vec3 bitangent = cross(n, tangent);
+	return normalize(tangent * h.x + bitangent * h.y + n * h.z);
+}
+
+float distributionGGX(float NdH, float a) {
+	float aa = a * a;
+	float d = NdH * NdH * (aa - 1.0) + 1.0;
+	return aa / (PI * d * d);
+}
+
+void main() {
+	// Split-sum approximation: assume view == normal == reflection direction.
+	vec3 n = normalize(position);
+	float a = roughness * roughness;
+
+	if (roughness == 0.0) {
+		outputColor = vec4(textureLod(environmentMap, n, 0.0).rgb, 1.0);
+		return;
+	}
+
+	vec3 color = vec3(0.0);
+	float totalWeight = 0.0;
+	for (uint i = 0u; i < SAMPLE_COUNT; i++) {
+		vec2 xi = vec2(float(i) / float(SAMPLE_COUNT), radicalInverse(i));
+		vec3 h = importanceSampleGGX(xi, n, a);
+		vec3 l = normalize(2.0 * dot(n, h) * h - n);
+		float NdL = dot(n, l);
+		if (NdL > 0.0) {
+			// Sample from a mip proportional to the sample's solid angle to avoid fireflies.
+			float NdH = max(dot(n, h), 0.0);
+			float pdf = distributionGGX(NdH, a) * 0.25 + 0.0001;
+			float saSample = 1.0 / (float(SAMPLE_COUNT) * pdf);
+			float saTexel = 4.0 * PI / (6.0 * environmentMapSize * environmentMapSize);
+			float mip = 0.5 * log2(saSample / saTexel);
+
+			color += textureLod(environmentMap, l, max(mip, 0.0)).rgb * NdL;
+			totalWeight += NdL;
+		}
+	}
+
+	outputColor = vec4(color / max(totalWeight, 0.0001), 1.0);
+}
+` + "\x00"
+)
+
+// PrefilterShader is a Shader which convolves an environment cubemap with a GGX lobe
+// to build one mip of a prefiltered specular cubemap.
+type PrefilterShader struct {
+	shader
+
+	Projection, View *uniforms.Matrix4
+
+	EnvironmentMap     *uniforms.SamplerCube
+	Roughness          *uniforms.Float
+	EnvironmentMapSize *uniforms.Float
+}
+
+// NewPrefilterShader instantiates and initializes a shader object.
+func NewPrefilterShader() (*PrefilterShader, error) {
+	program := gl.CreateProgram()
+
+	// VertexShader
+	vertexShader := gl.CreateShader(gl.VERTEX_SHADER)
+	vertexSrc, freeVertexSrc := gl.Strs(prefilterShaderVertSrc)
+	gl.ShaderSource(vertexShader, 1, vertexSrc, nil)
+	freeVertexSrc()
+	gl.CompileShader(vertexShader)
+	var status int32
+	gl.GetShaderiv(vertexShader, gl.COMPILE_STATUS, &status)
+	if status == gl.FALSE {
+		var logLength int32
+		gl.GetShaderiv(vertexShader, gl.INFO_LOG_LENGTH, &logLength)
+		log := strings.Repeat("\x00", int(logLength+1))
+		gl.GetShaderInfoLog(vertexShader, logLength, nil, gl.Str(log))
+		return nil, fmt.Errorf("failed to compile %v: %v", prefilterShaderOriginalVertexSourceFile, log)
+	}
+	gl.AttachShader(program, vertexShader)
+
+	// FragmentShader
+	fragmentShader := gl.CreateShader(gl.FRAGMENT_SHADER)
+	fragmentSrc, freeFragmentSrc := gl.Strs(prefilterShaderFragSrc)
+	gl.ShaderSource(fragmentShader, 1, fragmentSrc, nil)
+	freeFragmentSrc()
+	gl.CompileShader(fragmentShader)
+	gl.GetShaderiv(fragmentShader, gl.COMPILE_STATUS, &status)
+	if status == gl.FALSE {
+		var logLength int32
+		gl.GetShaderiv(fragmentShader, gl.INFO_LOG_LENGTH, &logLength)
+		log := strings.Repeat("\x00", int(logLength+1))
+		gl.GetShaderInfoLog(fragmentShader, logLength, nil, gl.Str(log))
+		return nil, fmt.Errorf("failed to compile %v: %v", prefilterShaderOriginalFragmentSourceFile, log)
+	}
+	gl.AttachShader(program, fragmentShader)
+
+	// Linking
+	gl.LinkProgram(program)
+	gl.GetProgramiv(program, gl.LINK_STATUS, &status)
+	if status == gl.FALSE {
+		var logLength int32
+		gl.GetProgramiv(program, gl.INFO_LOG_LENGTH, &logLength)
+		log := strings.Repeat("\x00", int(logLength+1))
+		gl.GetProgramInfoLog(program, logLength, nil, gl.Str(log))
+		return nil, fmt.Errorf("failed to link %v: %v", prefilterShaderOriginalVertexSourceFile, log)
+	}
+
+	projectionLoc := gl.GetUniformLocation(program, gl.Str("projection\x00"))
+	viewLoc := gl.GetUniformLocation(program, gl.Str("view\x00"))
+	environmentMapLoc := gl.GetUniformLocation(program, gl.Str("environmentMap\x00"))
+	roughnessLoc := gl.GetUniformLocation(program, gl.Str("roughness\x00"))
+	environmentMapSizeLoc := gl.GetUniformLocation(program, gl.Str("environmentMapSize\x00"))
+
+	gl.DeleteShader(vertexShader)
+	gl.DeleteShader(fragmentShader)
+
+	gl.BindFragDataLocation(program, 0, gl.Str("outputColor\x00"))
+
+	return &PrefilterShader{
+		shader:             shader{program},
+		Projection:         uniforms.NewMatrix4(program, projectionLoc),
+		View:               uniforms.NewMatrix4(program, viewLoc),
+		EnvironmentMap:     uniforms.NewSamplerCube(program, environmentMapLoc),
+		Roughness:          uniforms.NewFloat(program, roughnessLoc),
+		EnvironmentMapSize: uniforms.NewFloat(program, environmentMapSizeLoc),
+	}, nil
+}
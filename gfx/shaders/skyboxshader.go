@@ -0,0 +1,124 @@
+package shaders
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/brandonnelson3/GoRender/gfx/uniforms"
+
+	"github.com/go-gl/gl/v4.5-core/gl"
+)
+
+const (
+	skyboxShaderOriginalVertexSourceFile = `skyboxshader.vert`
+	skyboxShaderVertSrc                  = `
+#version 450
+
+uniform mat4 projection;
+uniform mat4 view;
+
+in vec3 vert;
+
+out vec3 position;
+
+void main() {
+	gl_Position = projection * view * vec4(vert, 1);
+	position = vert;
+}` + "\x00"
+	skyboxShaderOriginalFragmentSourceFile = `skyboxshader.frag`
+	skyboxShaderFragSrc                    = `
+#version 450
+
+uniform samplerCube skybox;
+uniform mat4 rotation;
+uniform float intensity;
+
+in vec3 position;
+
+out vec4 outputColor;
+
+void main() {
+	vec3 dir = (rotation * vec4(normalize(position), 0.0)).xyz;
+	outputColor = vec4(texture(skybox, dir).rgb * intensity, 1.0);
+}
+` + "\x00"
+)
+
+// SkyboxShader is a Shader which draws a cubemap behind the scene.
+type SkyboxShader struct {
+	shader
+
+	Projection, View, Rotation *uniforms.Matrix4
+
+	Skybox    *uniforms.SamplerCube
+	Intensity *uniforms.Float
+}
+
+// NewSkyboxShader instantiates and initializes a shader object.
+func NewSkyboxShader() (*SkyboxShader, error) {
+	program := gl.CreateProgram()
+
+	// VertexShader
+	vertexShader := gl.CreateShader(gl.VERTEX_SHADER)
+	vertexSrc, freeVertexSrc := gl.Strs(skyboxShaderVertSrc)
+	gl.ShaderSource(vertexShader, 1, vertexSrc, nil)
+	freeVertexSrc()
+	gl.CompileShader(vertexShader)
+	var status int32
+	gl.GetShaderiv(vertexShader, gl.COMPILE_STATUS, &status)
+	if status == gl.FALSE {
+		var logLength int32
+		gl.GetShaderiv(vertexShader, gl.INFO_LOG_LENGTH, &logLength)
+		log := strings.Repeat("\x00", int(logLength+1))
+		gl.GetShaderInfoLog(vertexShader, logLength, nil, gl.Str(log))
+		return nil, fmt.Errorf("failed to compile %v: %v", skyboxShaderOriginalVertexSourceFile, log)
+	}
+	gl.AttachShader(program, vertexShader)
+
+	// FragmentShader
+	fragmentShader := gl.CreateShader(gl.FRAGMENT_SHADER)
+	fragmentSrc, freeFragmentSrc := gl.Strs(skyboxShaderFragSrc)
+	gl.ShaderSource(fragmentShader, 1, fragmentSrc, nil)
+	freeFragmentSrc()
+	gl.CompileShader(fragmentShader)
+	gl.GetShaderiv(fragmentShader, gl.COMPILE_STATUS, &status)
+	if status == gl.FALSE {
+		var logLength int32
+		gl.GetShaderiv(fragmentShader, gl.INFO_LOG_LENGTH, &logLength)
+		log := strings.Repeat("\x00", int(logLength+1))
+		gl.GetShaderInfoLog(fragmentShader, logLength, nil, gl.Str(log))
+		return nil, fmt.Errorf("failed to compile %v: %v", skyboxShaderOriginalFragmentSourceFile, log)
+	}
+	gl.AttachShader(program, fragmentShader)
+
+	// Linking
+	gl.LinkProgram(program)
+	gl.GetProgramiv(program, gl.LINK_STATUS, &status)
+	if status == gl.FALSE {
+		var logLength int32
+		gl.GetProgramiv(program, gl.INFO_LOG_LENGTH, &logLength)
+		log := strings.Repeat("\x00", int(logLength+1))
+		gl.GetProgramInfoLog(program, logLength, nil, gl.Str(log))
+		return nil, fmt.Errorf("failed to link %v: %v", skyboxShaderOriginalVertexSourceFile, log)
+	}
+
+	projectionLoc := gl.GetUniformLocation(program, gl.Str("projection\x00"))
+	viewLoc := gl.GetUniformLocation(program, gl.Str("view\x00"))
+	rotationLoc := gl.GetUniformLocation(program, gl.Str("rotation\x00"))
+	skyboxLoc := gl.GetUniformLocation(program, gl.Str("skybox\x00"))
+	intensityLoc := gl.GetUniformLocation(program, gl.Str("intensity\x00"))
+
+	gl.DeleteShader(vertexShader)
+	gl.DeleteShader(fragmentShader)
+
+	gl.BindFragDataLocation(program, 0, gl.Str("outputColor\x00"))
+
+	return &SkyboxShader{
+		shader:     shader{program},
+		Projection: uniforms.NewMatrix4(program, projectionLoc),
+		View:       uniforms.NewMatrix4(program, viewLoc),
+		Rotation:   uniforms.NewMatrix4(program, rotationLoc),
+		Skybox:     uniforms.NewSamplerCube(program, skyboxLoc),
+		Intensity:  uniforms.NewFloat(program, intensityLoc),
+	}, nil
+}
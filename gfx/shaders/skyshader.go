@@ -43,6 +43,10 @@ layout(std430, binding = 2) readonly buffer DirectionalLightBuffer {
 	DirectionalLight data;
 } directionalLightBuffer;
 
+uniform float cloudCoverage;
+uniform float cloudDensity;
+uniform vec2 cloudOffset;
+
 in vec3 position;
 
 out vec4 outputColor;
@@ -50,6 +54,9 @@ out vec4 outputColor;
 #define PI 3.141592
 #define iSteps 16
 #define jSteps 8
+#define CLOUD_ALTITUDE 2000.0
+#define CLOUD_SCALE 0.00025
+#define CLOUD_SHADOW_STEPS 4
 
 vec2 rsi(vec3 r0, vec3 rd, float sr) {
     // ray-sphere intersection that assumes
@@ -155,6 +162,64 @@ vec3 atmosphere(vec3 r, vec3 r0, vec3 pSun, float iSun, float rPlanet, float rAt
     return iSun * (pRlh * kRlh * totalRlh + pMie * kMie * totalMie);
 }
 
+float hash(vec2 p) {
+	p = fract(p * vec2(123.34, 456.21));
+	p += dot(p, p + 45.32);
+	return fract(p.x * p.y);
+}
+
+float valueNoise(vec2 p) {
+	vec2 i = floor(p);
+	vec2 f = fract(p);
+	vec2 u = f * f * (3.0 - 2.0 * f);
+	return mix(mix(hash(i), hash(i + vec2(1, 0)), u.x), mix(hash(i + vec2(0, 1)), hash(i + vec2(1, 1)), u.x), u.y);
+}
+
+float fbm(vec2 p) {
+	float value = 0.0;
+	float amplitude = 0.5;
+	for (int i = 0; i < 5; i++) {
+		value += amplitude * valueNoise(p);
+		p = p * 2.03 + vec2(17.1, 9.2);
+		amplitude *= 0.5;
+	}
+	return value;
+}
+
+float cloudDensityAt(vec2 p) {
+	float threshold = 1.0 - cloudCoverage;
+	return smoothstep(threshold, threshold + 0.35, fbm(p)) * cloudDensity;
+}
+
+// Returns the color and opacity of the cloud layer seen along r, lit by the sun at pSun
+// and by the sky color behind it.
+vec4 clouds(vec3 r, vec3 pSun, vec3 sunColor, vec3 skyColor) {
+	if (cloudCoverage <= 0.0 || r.y <= 0.01) {
+		return vec4(0.0);
+	}
+
+	vec2 p = (r.xz * (CLOUD_ALTITUDE / r.y) - cloudOffset) * CLOUD_SCALE;
+	float density = cloudDensityAt(p);
+	if (density <= 0.0) {
+		return vec4(0.0);
+	}
+
+	// March toward the sun across the layer to estimate how much the cloud shadows itself.
+	vec2 toSun = normalize(pSun.xz + vec2(0.0001)) * 0.05;
+	float sunDensity = 0.0;
+	for (int i = 1; i <= CLOUD_SHADOW_STEPS; i++) {
+		sunDensity += cloudDensityAt(p + toSun * float(i));
+	}
+	float sunTransmittance = exp(-sunDensity * 1.5);
+
+	// Thin cloud edges facing the sun light up with a silver lining.
+	float silverLining = 2.0 * pow(max(dot(r, pSun), 0.0), 8.0) * (1.0 - density);
+	vec3 lit = sunColor * sunTransmittance * (0.6 + silverLining) + skyColor * 0.5;
+
+	float alpha = (1.0 - exp(-density * 4.0)) * smoothstep(0.01, 0.15, r.y);
+	return vec4(lit, alpha);
+}
+
 void main() {	
 	DirectionalLight directionalLight = directionalLightBuffer.data;
 	
@@ -175,6 +240,12 @@ void main() {
     // Apply exposure.
     color = 1.0 - exp(-1.0 * color);
 
+	// Sunlight reaching the clouds fades out as the sun sets.
+	vec3 pSun = normalize(directionalLight.direction * -1);
+	vec3 sunColor = directionalLight.color * directionalLight.brightness * clamp(pSun.y * 4.0 + 0.2, 0.0, 1.0);
+	vec4 cloud = clouds(normalize(position), pSun, sunColor, color);
+	color = mix(color, cloud.rgb, cloud.a);
+
     outputColor = vec4(color, 1);
 }
 ` + "\x00"
@@ -186,6 +257,9 @@ type SkyShader struct {
 
 	Projection, View *uniforms.Matrix4
 
+	CloudCoverage, CloudDensity *uniforms.Float
+	CloudOffset                 *uniforms.Vector2
+
 	DirectionalLightBuffer *buffers.Binding
 }
 
@@ -239,6 +313,9 @@ func NewSkyShader() (*SkyShader, error) {
 
 	projectionLoc := gl.GetUniformLocation(program, gl.Str("projection\x00"))
 	viewLoc := gl.GetUniformLocation(program, gl.Str("view\x00"))
+	cloudCoverageLoc := gl.GetUniformLocation(program, gl.Str("cloudCoverage\x00"))
+	cloudDensityLoc := gl.GetUniformLocation(program, gl.Str("cloudDensity\x00"))
+	cloudOffsetLoc := gl.GetUniformLocation(program, gl.Str("cloudOffset\x00"))
 
 	gl.DeleteShader(vertexShader)
 	gl.DeleteShader(fragmentShader)
@@ -249,6 +326,9 @@ func NewSkyShader() (*SkyShader, error) {
 		shader:     shader{program},
 		Projection: uniforms.NewMatrix4(program, projectionLoc),
 		View:       uniforms.NewMatrix4(program, viewLoc),
+		CloudCoverage: uniforms.NewFloat(program, cloudCoverageLoc),
+		CloudDensity:  uniforms.NewFloat(program, cloudDensityLoc),
+		CloudOffset:   uniforms.NewVector2(program, cloudOffsetLoc),
 		DirectionalLightBuffer: buffers.NewBinding(2),
 	}, nil
 }
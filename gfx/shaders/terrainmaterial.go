@@ -0,0 +1,72 @@
+package shaders
+
+// terrainMaterialSrc is the fragment shader code that colors terrain from up to four texture layers. Each
+// layer covers a range of heights and slopes, or a painted splat map gives the weight of each layer in its
+// red, green, blue and alpha channels. Brushes paint layer weights over either. Layers are projected along
+// all three axes so steep faces aren't stretched.
+const terrainMaterialSrc = `
+uniform int terrainMaterialEnabled;
+uniform sampler2DArray terrainLayers;
+// Component i of these holds the range of heights and slopes covered by layer i. Slope is 0 for flat ground and 1 for a vertical face.
+uniform vec4 terrainHeightMin;
+uniform vec4 terrainHeightMax;
+uniform vec4 terrainSlopeMin;
+uniform vec4 terrainSlopeMax;
+uniform float terrainHeightBlend;
+uniform float terrainSlopeBlend;
+uniform float terrainTextureScale;
+uniform int terrainSplatMapEnabled;
+uniform sampler2D terrainSplatMap;
+// terrainSplatMapRect is the world space x, z of the splat map's corner, followed by its width and depth.
+uniform vec4 terrainSplatMapRect;
+in vec4 terrainPaint_out;
+
+float terrainRangeWeight(float x, float lo, float hi, float blend) {
+	return smoothstep(lo - blend, lo + blend, x) * (1.0 - smoothstep(hi - blend, hi + blend, x));
+}
+
+vec4 getTerrainWeights(vec3 p, vec3 n) {
+	vec4 w = vec4(0.0);
+	vec2 splatUV = (p.xz - terrainSplatMapRect.xy) / terrainSplatMapRect.zw;
+	if (terrainSplatMapEnabled != 0 && all(greaterThanEqual(splatUV, vec2(0.0))) && all(lessThanEqual(splatUV, vec2(1.0)))) {
+		w = texture(terrainSplatMap, splatUV);
+	} else {
+		float slope = 1.0 - n.y;
+		for (int i = 0; i < 4; i++) {
+			w[i] = terrainRangeWeight(p.y, terrainHeightMin[i], terrainHeightMax[i], terrainHeightBlend) *
+				terrainRangeWeight(slope, terrainSlopeMin[i], terrainSlopeMax[i], terrainSlopeBlend);
+		}
+	}
+	float total = w.x + w.y + w.z + w.w;
+	// Nothing covers this point, so fall back to the first layer.
+	w = (total < 0.0001) ? vec4(1.0, 0.0, 0.0, 0.0) : w / total;
+	// Painted weights replace their share of the others.
+	float painted = min(terrainPaint_out.x + terrainPaint_out.y + terrainPaint_out.z + terrainPaint_out.w, 1.0);
+	return terrainPaint_out + w * (1.0 - painted);
+}
+
+vec4 sampleTerrainLayer(int layer, vec3 p, vec3 blend) {
+	vec3 uv = p * terrainTextureScale;
+	vec4 top = texture(terrainLayers, vec3(uv.xz, layer));
+	// Flat ground only needs the top down projection.
+	if (blend.y > 0.99) {
+		return top;
+	}
+	return top * blend.y +
+		texture(terrainLayers, vec3(uv.zy, layer)) * blend.x +
+		texture(terrainLayers, vec3(uv.xy, layer)) * blend.z;
+}
+
+vec4 getTerrainColor(vec3 p, vec3 n) {
+	vec4 w = getTerrainWeights(p, n);
+	vec3 blend = pow(abs(n), vec3(4.0));
+	blend /= blend.x + blend.y + blend.z;
+	vec4 color = vec4(0.0);
+	for (int i = 0; i < 4; i++) {
+		if (w[i] > 0.001) {
+			color += sampleTerrainLayer(i, p, blend) * w[i];
+		}
+	}
+	return color;
+}
+`
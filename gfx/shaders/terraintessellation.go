@@ -0,0 +1,315 @@
+package shaders
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/brandonnelson3/GoRender/gfx/uniforms"
+	"github.com/go-gl/gl/v4.5-core/gl"
+)
+
+const (
+	terrainTessOriginalVertexSourceFile  = `terraintess.vert`
+	terrainTessOriginalControlSourceFile = `terraintess.tesc`
+	terrainTessOriginalEvalSourceFile    = `terraintess.tese`
+	terrainTessDepthOriginalEvalFile     = `terraintessdepth.tese`
+
+	// terrainTessVertSrc passes terrain vertices through to be tessellated.
+	terrainTessVertSrc = `
+#version 450
+
+layout(location = 0) in vec3 vert;
+layout(location = 2) in vec2 uv;
+layout(location = 8) in vec4 terrainPaint;
+
+out vec3 vert_tc;
+out vec2 uv_tc;
+out vec4 terrainPaint_tc;
+
+void main() {
+	vert_tc = vert;
+	uv_tc = uv;
+	terrainPaint_tc = terrainPaint;
+}` + "\x00"
+
+	// terrainTessControlSrc subdivides every edge of a triangle into pieces about tessEdgeLength pixels long
+	// on screen. The level of an edge only depends on its ends, so triangles sharing it agree and no cracks open.
+	terrainTessControlSrc = `
+#version 450
+
+layout(vertices = 3) out;
+
+uniform mat4 model;
+// The level is chosen from the camera the terrain is seen from in every pass, so shadows match what is drawn.
+uniform vec3 tessCameraPosition;
+// tessProjectionScale is the size in pixels of one world unit at a distance of one world unit.
+uniform float tessProjectionScale;
+uniform float tessEdgeLength;
+uniform float tessMaxLevel;
+
+in vec3 vert_tc[];
+in vec2 uv_tc[];
+in vec4 terrainPaint_tc[];
+
+out vec3 vert_te[];
+out vec2 uv_te[];
+out vec4 terrainPaint_te[];
+
+float edgeLevel(vec3 a, vec3 b) {
+	vec3 wa = (model * vec4(a, 1)).xyz;
+	vec3 wb = (model * vec4(b, 1)).xyz;
+	float d = max(distance((wa + wb) * 0.5, tessCameraPosition), 0.001);
+	float pixels = distance(wa, wb) * tessProjectionScale / d;
+	return clamp(pixels / tessEdgeLength, 1.0, tessMaxLevel);
+}
+
+void main() {
+	vert_te[gl_InvocationID] = vert_tc[gl_InvocationID];
+	uv_te[gl_InvocationID] = uv_tc[gl_InvocationID];
+	terrainPaint_te[gl_InvocationID] = terrainPaint_tc[gl_InvocationID];
+	if (gl_InvocationID == 0) {
+		// Outer level i is the edge opposite vertex i.
+		gl_TessLevelOuter[0] = edgeLevel(vert_tc[1], vert_tc[2]);
+		gl_TessLevelOuter[1] = edgeLevel(vert_tc[2], vert_tc[0]);
+		gl_TessLevelOuter[2] = edgeLevel(vert_tc[0], vert_tc[1]);
+		gl_TessLevelInner[0] = max(max(gl_TessLevelOuter[0], gl_TessLevelOuter[1]), gl_TessLevelOuter[2]);
+	}
+}` + "\x00"
+
+	// terrainTessEvalSrc is the evaluation shader code shared by the color and depth passes, which displaces
+	// every generated vertex by the cell's height map and the tiled detail map.
+	terrainTessEvalSrc = `
+layout(triangles, equal_spacing, ccw) in;
+
+uniform mat4 model;
+uniform mat4 projection;
+uniform mat4 view;
+// terrainHeightMap holds terrainHeightMapResolution samples of the height source per world unit over the cell,
+// with one extra sample on every side.
+uniform sampler2D terrainHeightMap;
+uniform float terrainHeightMapResolution;
+uniform float terrainHeightMapSize;
+uniform int terrainDetailEnabled;
+uniform sampler2D terrainDetailMap;
+uniform float terrainDetailScale;
+uniform float terrainDetailAmplitude;
+
+in vec3 vert_te[];
+in vec2 uv_te[];
+in vec4 terrainPaint_te[];
+
+// Both passes must place every vertex identically, or the depth pre-pass wouldn't match.
+invariant gl_Position;
+
+// terrainHeight returns the height at the cell space point p, where the cell's first vertex is at (1, 1).
+float terrainHeight(vec2 p) {
+	vec2 uv = ((p - 1.0) * terrainHeightMapResolution + 1.5) / terrainHeightMapSize;
+	float h = textureLod(terrainHeightMap, uv, 0).r;
+	if (terrainDetailEnabled != 0) {
+		vec2 world = (model * vec4(p.x, 0, p.y, 1)).xz;
+		h += (textureLod(terrainDetailMap, world * terrainDetailScale, 0).r * 2.0 - 1.0) * terrainDetailAmplitude;
+	}
+	return h;
+}
+
+vec3 terrainNormal(vec2 p) {
+	float e = 1.0 / terrainHeightMapResolution;
+	float dx = terrainHeight(p - vec2(e, 0)) - terrainHeight(p + vec2(e, 0));
+	float dz = terrainHeight(p - vec2(0, e)) - terrainHeight(p + vec2(0, e));
+	return normalize(vec3(dx, 2.0 * e, dz));
+}
+
+vec3 tessVertex() {
+	vec3 b = gl_TessCoord;
+	vec3 v = vert_te[0] * b.x + vert_te[1] * b.y + vert_te[2] * b.z;
+	return vec3(v.x, terrainHeight(v.xz), v.z);
+}
+`
+
+	terrainTessEvalColorSrc = `
+#version 450
+
+const int NUMBER_OF_CASCADES = 5;
+` + terrainTessEvalSrc + `
+uniform mat4 lightViewProjs[NUMBER_OF_CASCADES];
+uniform vec4 clipPlane;
+
+out vec4 position;
+out vec3 worldPosition;
+out vec3 norm_out;
+out vec2 uv_out;
+out vec4 terrainPaint_out;
+out vec4 lightPositions[NUMBER_OF_CASCADES];
+
+void main() {
+	vec3 b = gl_TessCoord;
+	vec3 v = tessVertex();
+	vec4 world = model * vec4(v, 1);
+	gl_Position = projection * view * world;
+	position = gl_Position;
+	worldPosition = world.xyz;
+	gl_ClipDistance[0] = dot(world, clipPlane);
+	// Cells are only ever translated, so normals don't need transforming.
+	norm_out = terrainNormal(v.xz);
+	uv_out = uv_te[0] * b.x + uv_te[1] * b.y + uv_te[2] * b.z;
+	terrainPaint_out = terrainPaint_te[0] * b.x + terrainPaint_te[1] * b.y + terrainPaint_te[2] * b.z;
+	for (int i = 0; i < NUMBER_OF_CASCADES; i++) {
+		lightPositions[i] = lightViewProjs[i] * world;
+	}
+}` + "\x00"
+
+	terrainTessEvalDepthSrc = `
+#version 450
+` + terrainTessEvalSrc + `
+out vec2 uv_out;
+
+void main() {
+	vec3 b = gl_TessCoord;
+	gl_Position = projection * view * model * vec4(tessVertex(), 1);
+	uv_out = uv_te[0] * b.x + uv_te[1] * b.y + uv_te[2] * b.z;
+}` + "\x00"
+)
+
+// TerrainTessellation holds the uniforms of the terrain tessellation stages.
+type TerrainTessellation struct {
+	TessCameraPosition                                *uniforms.Vector3
+	TessProjectionScale, TessEdgeLength, TessMaxLevel *uniforms.Float
+
+	TerrainHeightMap                                 *uniforms.Sampler2D
+	TerrainHeightMapResolution, TerrainHeightMapSize *uniforms.Float
+	TerrainDetailEnabled                             *uniforms.Int
+	TerrainDetailMap                                 *uniforms.Sampler2D
+	TerrainDetailScale, TerrainDetailAmplitude       *uniforms.Float
+}
+
+// TessellatedColorShader draws terrain subdivided on the GPU with the color shader's fragment stage. Every
+// uniform set on the color shader is set on it too, so it draws with the state of the pass. Uniforms set on it
+// directly last until the color shader sets them again.
+type TessellatedColorShader struct {
+	*ColorShader
+	TerrainTessellation
+}
+
+// TessellatedDepthShader draws terrain subdivided on the GPU into depth maps. Every uniform set on the depth
+// shader is set on it too, so it draws with the state of the pass. Uniforms set on it directly last until the
+// depth shader sets them again.
+type TessellatedDepthShader struct {
+	*DepthShader
+	TerrainTessellation
+}
+
+// tessellationStage is the source of one stage of a tessellated program.
+type tessellationStage struct {
+	kind     uint32
+	src      string
+	origFile string
+}
+
+// linkTessellated compiles and links stages into a program.
+func linkTessellated(stages []tessellationStage) (uint32, error) {
+	program := gl.CreateProgram()
+	var compiled []uint32
+	for _, stage := range stages {
+		stageShader := gl.CreateShader(stage.kind)
+		src, freeSrc := gl.Strs(stage.src)
+		gl.ShaderSource(stageShader, 1, src, nil)
+		freeSrc()
+		gl.CompileShader(stageShader)
+		var status int32
+		gl.GetShaderiv(stageShader, gl.COMPILE_STATUS, &status)
+		if status == gl.FALSE {
+			var logLength int32
+			gl.GetShaderiv(stageShader, gl.INFO_LOG_LENGTH, &logLength)
+			log := strings.Repeat("\x00", int(logLength+1))
+			gl.GetShaderInfoLog(stageShader, logLength, nil, gl.Str(log))
+			return 0, fmt.Errorf("failed to compile %v: %v", stage.origFile, log)
+		}
+		gl.AttachShader(program, stageShader)
+		compiled = append(compiled, stageShader)
+	}
+
+	gl.LinkProgram(program)
+	var status int32
+	gl.GetProgramiv(program, gl.LINK_STATUS, &status)
+	if status == gl.FALSE {
+		var logLength int32
+		gl.GetProgramiv(program, gl.INFO_LOG_LENGTH, &logLength)
+		log := strings.Repeat("\x00", int(logLength+1))
+		gl.GetProgramInfoLog(program, logLength, nil, gl.Str(log))
+		return 0, fmt.Errorf("failed to link %v: %v", terrainTessOriginalEvalSourceFile, log)
+	}
+	for _, stageShader := range compiled {
+		gl.DeleteShader(stageShader)
+	}
+	return program, nil
+}
+
+// newTerrainTessellation looks up the uniforms of the terrain tessellation stages in program.
+func newTerrainTessellation(program uint32) TerrainTessellation {
+	tessCameraPositionLoc := gl.GetUniformLocation(program, gl.Str("tessCameraPosition\x00"))
+	tessProjectionScaleLoc := gl.GetUniformLocation(program, gl.Str("tessProjectionScale\x00"))
+	tessEdgeLengthLoc := gl.GetUniformLocation(program, gl.Str("tessEdgeLength\x00"))
+	tessMaxLevelLoc := gl.GetUniformLocation(program, gl.Str("tessMaxLevel\x00"))
+	terrainHeightMapLoc := gl.GetUniformLocation(program, gl.Str("terrainHeightMap\x00"))
+	terrainHeightMapResolutionLoc := gl.GetUniformLocation(program, gl.Str("terrainHeightMapResolution\x00"))
+	terrainHeightMapSizeLoc := gl.GetUniformLocation(program, gl.Str("terrainHeightMapSize\x00"))
+	terrainDetailEnabledLoc := gl.GetUniformLocation(program, gl.Str("terrainDetailEnabled\x00"))
+	terrainDetailMapLoc := gl.GetUniformLocation(program, gl.Str("terrainDetailMap\x00"))
+	terrainDetailScaleLoc := gl.GetUniformLocation(program, gl.Str("terrainDetailScale\x00"))
+	terrainDetailAmplitudeLoc := gl.GetUniformLocation(program, gl.Str("terrainDetailAmplitude\x00"))
+
+	return TerrainTessellation{
+		TessCameraPosition:         uniforms.NewVector3(program, tessCameraPositionLoc),
+		TessProjectionScale:        uniforms.NewFloat(program, tessProjectionScaleLoc),
+		TessEdgeLength:             uniforms.NewFloat(program, tessEdgeLengthLoc),
+		TessMaxLevel:               uniforms.NewFloat(program, tessMaxLevelLoc),
+		TerrainHeightMap:           uniforms.NewSampler2D(program, terrainHeightMapLoc),
+		TerrainHeightMapResolution: uniforms.NewFloat(program, terrainHeightMapResolutionLoc),
+		TerrainHeightMapSize:       uniforms.NewFloat(program, terrainHeightMapSizeLoc),
+		TerrainDetailEnabled:       uniforms.NewInt(program, terrainDetailEnabledLoc),
+		TerrainDetailMap:           uniforms.NewSampler2D(program, terrainDetailMapLoc),
+		TerrainDetailScale:         uniforms.NewFloat(program, terrainDetailScaleLoc),
+		TerrainDetailAmplitude:     uniforms.NewFloat(program, terrainDetailAmplitudeLoc),
+	}
+}
+
+// newTessellatedColorShader builds the tessellated twin of colorShader.
+func newTessellatedColorShader(colorShader *ColorShader) (*TessellatedColorShader, error) {
+	program, err := linkTessellated([]tessellationStage{
+		{gl.VERTEX_SHADER, terrainTessVertSrc, terrainTessOriginalVertexSourceFile},
+		{gl.TESS_CONTROL_SHADER, terrainTessControlSrc, terrainTessOriginalControlSourceFile},
+		{gl.TESS_EVALUATION_SHADER, terrainTessEvalColorSrc, terrainTessOriginalEvalSourceFile},
+		{gl.FRAGMENT_SHADER, colorShaderFragSrc, colorShaderOriginalFragmentSourceFile},
+	})
+	if err != nil {
+		return nil, err
+	}
+	gl.BindFragDataLocation(program, 0, gl.Str("outputColor\x00"))
+
+	s := &TessellatedColorShader{
+		ColorShader:         newColorShaderUniforms(program),
+		TerrainTessellation: newTerrainTessellation(program),
+	}
+	uniforms.Mirror(colorShader, s.ColorShader)
+	return s, nil
+}
+
+// newTessellatedDepthShader builds the tessellated twin of depthShader.
+func newTessellatedDepthShader(depthShader *DepthShader) (*TessellatedDepthShader, error) {
+	program, err := linkTessellated([]tessellationStage{
+		{gl.VERTEX_SHADER, terrainTessVertSrc, terrainTessOriginalVertexSourceFile},
+		{gl.TESS_CONTROL_SHADER, terrainTessControlSrc, terrainTessOriginalControlSourceFile},
+		{gl.TESS_EVALUATION_SHADER, terrainTessEvalDepthSrc, terrainTessDepthOriginalEvalFile},
+		{gl.FRAGMENT_SHADER, depthShaderFragSrc, depthShaderOriginalFragmentSourceFile},
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	s := &TessellatedDepthShader{
+		DepthShader:         newDepthShaderUniforms(program),
+		TerrainTessellation: newTerrainTessellation(program),
+	}
+	uniforms.Mirror(depthShader, s.DepthShader)
+	return s, nil
+}
@@ -0,0 +1,125 @@
+package shaders
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/brandonnelson3/GoRender/gfx/uniforms"
+
+	"github.com/go-gl/gl/v4.5-core/gl"
+)
+
+const (
+	volumetricIntegrationShaderOriginalComputeSourceFile = `volumetricintegrationshader.comp`
+	volumetricIntegrationShaderComputeSrc                = `
+#version 450
+
+layout(rgba16f) uniform readonly image3D scatteringVolume;
+layout(rgba16f) uniform writeonly image3D integratedVolume;
+
+uniform mat4 inverseProjection;
+uniform float volumetricNear;
+uniform float volumetricFar;
+
+float sliceDepth(float w) {
+	return volumetricNear * pow(volumetricFar / volumetricNear, w);
+}
+
+// Marches every froxel column front to back. Each output froxel holds the light scattered toward
+// the camera up to the far side of that slice in rgb, and the transmittance to that point in a.
+layout(local_size_x = 8, local_size_y = 8, local_size_z = 1) in;
+void main() {
+	ivec2 column = ivec2(gl_GlobalInvocationID.xy);
+	ivec3 size = imageSize(scatteringVolume);
+	if (any(greaterThanEqual(column, size.xy))) {
+		return;
+	}
+
+	// Slices are spaced by view depth, scale them to distance along this column's ray.
+	vec2 ndc = (vec2(column) + 0.5) / vec2(size.xy) * 2.0 - 1.0;
+	vec4 viewRay = inverseProjection * vec4(ndc, 1.0, 1.0);
+	viewRay.xyz /= viewRay.w;
+	float rayScale = length(viewRay.xyz / -viewRay.z);
+
+	vec3 scattered = vec3(0.0);
+	float transmittance = 1.0;
+	float previousDepth = 0.0;
+	for (int z = 0; z < size.z; z++) {
+		float depth = sliceDepth(float(z + 1) / float(size.z));
+		float thickness = (depth - previousDepth) * rayScale;
+		previousDepth = depth;
+
+		vec4 froxel = imageLoad(scatteringVolume, ivec3(column, z));
+		float extinction = max(froxel.a, 0.000001);
+		float sliceTransmittance = exp(-extinction * thickness);
+
+		// Integrate the in-scattering analytically across the slice so thick slices don't gain energy.
+		vec3 sliceScattering = (froxel.rgb - froxel.rgb * sliceTransmittance) / extinction;
+		scattered += transmittance * sliceScattering;
+		transmittance *= sliceTransmittance;
+
+		imageStore(integratedVolume, ivec3(column, z), vec4(scattered, transmittance));
+	}
+}` + "\x00"
+)
+
+// VolumetricIntegrationShader is a compute shader which accumulates a froxel scattering volume
+// along each view ray into the volume sampled when shading geometry.
+type VolumetricIntegrationShader struct {
+	shader
+
+	ScatteringVolume, IntegratedVolume *uniforms.Image3D
+
+	InverseProjection             *uniforms.Matrix4
+	VolumetricNear, VolumetricFar *uniforms.Float
+}
+
+// NewVolumetricIntegrationShader instantiates and initializes a VolumetricIntegrationShader object.
+func NewVolumetricIntegrationShader() (*VolumetricIntegrationShader, error) {
+	program := gl.CreateProgram()
+
+	// ComputeShader
+	computeShader := gl.CreateShader(gl.COMPUTE_SHADER)
+	computeSrc, freeComputeSrc := gl.Strs(volumetricIntegrationShaderComputeSrc)
+	gl.ShaderSource(computeShader, 1, computeSrc, nil)
+	freeComputeSrc()
+	gl.CompileShader(computeShader)
+	var status int32
+	gl.GetShaderiv(computeShader, gl.COMPILE_STATUS, &status)
+	if status == gl.FALSE {
+		var logLength int32
+		gl.GetShaderiv(computeShader, gl.INFO_LOG_LENGTH, &logLength)
+		log := strings.Repeat("\x00", int(logLength+1))
+		gl.GetShaderInfoLog(computeShader, logLength, nil, gl.Str(log))
+		return nil, fmt.Errorf("failed to compile %v: %v", volumetricIntegrationShaderOriginalComputeSourceFile, log)
+	}
+	gl.AttachShader(program, computeShader)
+
+	// Linking
+	gl.LinkProgram(program)
+	gl.GetProgramiv(program, gl.LINK_STATUS, &status)
+	if status == gl.FALSE {
+		var logLength int32
+		gl.GetProgramiv(program, gl.INFO_LOG_LENGTH, &logLength)
+		log := strings.Repeat("\x00", int(logLength+1))
+		gl.GetProgramInfoLog(program, logLength, nil, gl.Str(log))
+		return nil, fmt.Errorf("failed to link %v: %v", volumetricIntegrationShaderOriginalComputeSourceFile, log)
+	}
+
+	scatteringVolumeLoc := gl.GetUniformLocation(program, gl.Str("scatteringVolume\x00"))
+	integratedVolumeLoc := gl.GetUniformLocation(program, gl.Str("integratedVolume\x00"))
+	inverseProjectionLoc := gl.GetUniformLocation(program, gl.Str("inverseProjection\x00"))
+	volumetricNearLoc := gl.GetUniformLocation(program, gl.Str("volumetricNear\x00"))
+	volumetricFarLoc := gl.GetUniformLocation(program, gl.Str("volumetricFar\x00"))
+
+	gl.DeleteShader(computeShader)
+
+	return &VolumetricIntegrationShader{
+		shader:            shader{program},
+		ScatteringVolume:  uniforms.NewImage3D(program, scatteringVolumeLoc),
+		IntegratedVolume:  uniforms.NewImage3D(program, integratedVolumeLoc),
+		InverseProjection: uniforms.NewMatrix4(program, inverseProjectionLoc),
+		VolumetricNear:    uniforms.NewFloat(program, volumetricNearLoc),
+		VolumetricFar:     uniforms.NewFloat(program, volumetricFarLoc),
+	}, nil
+}
@@ -0,0 +1,260 @@
+package shaders
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/brandonnelson3/GoRender/gfx/buffers"
+	"github.com/brandonnelson3/GoRender/gfx/uniforms"
+
+	"github.com/go-gl/gl/v4.5-core/gl"
+)
+
+const (
+	volumetricScatteringShaderOriginalComputeSourceFile = `volumetricscatteringshader.comp`
+	volumetricScatteringShaderComputeSrc                = `
+#version 450
+
+const int NUMBER_OF_CASCADES = 5;
+const int MAX_POINT_SHADOW_LIGHTS = 4;
+
+struct DirectionalLight {
+	vec3 color;
+	float brightness;
+	vec3 direction;
+};
+
+layout(std430, binding = 2) readonly buffer DirectionalLightBuffer {
+	DirectionalLight data;
+} directionalLightBuffer;
+
+layout(rgba16f) uniform writeonly image3D scatteringVolume;
+
+uniform mat4 inverseView;
+uniform mat4 inverseProjection;
+uniform float volumetricNear;
+uniform float volumetricFar;
+
+// Height fog
+uniform float fogDensity;
+uniform float fogHeightFalloff;
+uniform float fogBaseHeight;
+uniform float fogAnisotropy;
+uniform vec3 skyAmbient;
+uniform float skyLightIntensity;
+
+// Directional light shadows
+uniform mat4 lightViewProjs[NUMBER_OF_CASCADES];
+uniform float cascadeDepthLimits[NUMBER_OF_CASCADES + 1];
+uniform vec3 firstPersonPosition;
+uniform vec3 firstPersonForward;
+uniform sampler2DShadow shadowMap1;
+uniform sampler2DShadow shadowMap2;
+uniform sampler2DShadow shadowMap3;
+uniform sampler2DShadow shadowMap4;
+uniform sampler2DShadow shadowMap5;
+
+// Point light shadows
+uniform samplerCubeArrayShadow pointShadowMaps;
+uniform int numPointShadowLights;
+uniform vec3 pointShadowLightPositions[MAX_POINT_SHADOW_LIGHTS];
+uniform vec3 pointShadowLightColors[MAX_POINT_SHADOW_LIGHTS];
+uniform float pointShadowLightRadii[MAX_POINT_SHADOW_LIGHTS];
+uniform float pointShadowFarPlane;
+
+#define PI 3.141592
+
+// Slices are distributed exponentially so that resolution is concentrated near the camera.
+float sliceDepth(float w) {
+	return volumetricNear * pow(volumetricFar / volumetricNear, w);
+}
+
+float henyeyGreenstein(float cosTheta, float g) {
+	float gg = g * g;
+	return (1.0 - gg) / (4.0 * PI * pow(1.0 + gg - 2.0 * g * cosTheta, 1.5));
+}
+
+float sampleCascade(int index, vec3 coords) {
+	if (index == 0) return texture(shadowMap1, coords);
+	if (index == 1) return texture(shadowMap2, coords);
+	if (index == 2) return texture(shadowMap3, coords);
+	if (index == 3) return texture(shadowMap4, coords);
+	return texture(shadowMap5, coords);
+}
+
+// Returns 0.0 when worldPos is shadowed from the directional light and 1.0 when it is lit.
+float getDirectionalShadow(vec3 worldPos) {
+	float depthTest = dot(worldPos - firstPersonPosition, firstPersonForward);
+	for (int i = 0; i < NUMBER_OF_CASCADES; i++) {
+		vec3 coords = (lightViewProjs[i] * vec4(worldPos, 1.0)).xyz * 0.5 + 0.5;
+		if (clamp(coords.xy, 0.0, 1.0) == coords.xy && depthTest < cascadeDepthLimits[i + 1]) {
+			return sampleCascade(i, coords);
+		}
+	}
+	return 1.0;
+}
+
+layout(local_size_x = 8, local_size_y = 8, local_size_z = 1) in;
+void main() {
+	ivec3 froxel = ivec3(gl_GlobalInvocationID);
+	ivec3 size = imageSize(scatteringVolume);
+	if (any(greaterThanEqual(froxel, size))) {
+		return;
+	}
+
+	// Reconstruct the world position at the center of this froxel.
+	vec2 ndc = (vec2(froxel.xy) + 0.5) / vec2(size.xy) * 2.0 - 1.0;
+	vec4 viewRay = inverseProjection * vec4(ndc, 1.0, 1.0);
+	viewRay.xyz /= viewRay.w;
+	float depth = sliceDepth((float(froxel.z) + 0.5) / float(size.z));
+	vec3 viewPos = viewRay.xyz / -viewRay.z * depth;
+	vec3 worldPos = (inverseView * vec4(viewPos, 1.0)).xyz;
+	vec3 rayDir = normalize(worldPos - inverseView[3].xyz);
+
+	float density = fogDensity * exp(-fogHeightFalloff * (worldPos.y - fogBaseHeight));
+
+	DirectionalLight light = directionalLightBuffer.data;
+	float phase = henyeyGreenstein(dot(rayDir, -light.direction), fogAnisotropy);
+	vec3 inScattering = light.color * light.brightness * phase * getDirectionalShadow(worldPos);
+
+	// Sky light arrives from every direction, so an isotropic phase function integrates to its average.
+	inScattering += skyAmbient * skyLightIntensity;
+
+	for (int s = 0; s < numPointShadowLights && s < MAX_POINT_SHADOW_LIGHTS; s++) {
+		vec3 toLight = pointShadowLightPositions[s] - worldPos;
+		float dist = length(toLight);
+		float attenuation = max(1.0 - dist / pointShadowLightRadii[s], 0.0);
+		if (attenuation <= 0.0) {
+			continue;
+		}
+		float shadow = texture(pointShadowMaps, vec4(-toLight, s), (dist - 0.05) / pointShadowFarPlane);
+		float pointPhase = henyeyGreenstein(dot(rayDir, toLight / dist), fogAnisotropy);
+		inScattering += pointShadowLightColors[s] * attenuation * pointPhase * shadow;
+	}
+
+	imageStore(scatteringVolume, froxel, vec4(inScattering * density, density));
+}` + "\x00"
+)
+
+// VolumetricScatteringShader is a compute shader which fills a froxel volume with the light
+// scattered toward the camera, and the fog extinction, at the center of every froxel.
+type VolumetricScatteringShader struct {
+	shader
+
+	ScatteringVolume *uniforms.Image3D
+
+	InverseView, InverseProjection *uniforms.Matrix4
+	VolumetricNear, VolumetricFar  *uniforms.Float
+
+	FogDensity, FogHeightFalloff, FogBaseHeight, FogAnisotropy *uniforms.Float
+	SkyAmbient                                                 *uniforms.Vector3
+	SkyLightIntensity                                          *uniforms.Float
+
+	LightViewProjs                          *uniforms.Matrix4Array
+	CascadeDepthLimits                      *uniforms.FloatArray
+	FirstPersonPosition, FirstPersonForward *uniforms.Vector3
+
+	ShadowMap1, ShadowMap2, ShadowMap3, ShadowMap4, ShadowMap5 *uniforms.Sampler2D
+
+	PointShadowMaps           *uniforms.SamplerCubeArrayTexture
+	NumPointShadowLights      *uniforms.Int
+	PointShadowLightPositions *uniforms.Vector3Array
+	PointShadowLightColors    *uniforms.Vector3Array
+	PointShadowLightRadii     *uniforms.FloatArray
+	PointShadowFarPlane       *uniforms.Float
+
+	DirectionalLightBuffer *buffers.Binding
+}
+
+// NewVolumetricScatteringShader instantiates and initializes a VolumetricScatteringShader object.
+func NewVolumetricScatteringShader() (*VolumetricScatteringShader, error) {
+	program := gl.CreateProgram()
+
+	// ComputeShader
+	computeShader := gl.CreateShader(gl.COMPUTE_SHADER)
+	computeSrc, freeComputeSrc := gl.Strs(volumetricScatteringShaderComputeSrc)
+	gl.ShaderSource(computeShader, 1, computeSrc, nil)
+	freeComputeSrc()
+	gl.CompileShader(computeShader)
+	var status int32
+	gl.GetShaderiv(computeShader, gl.COMPILE_STATUS, &status)
+	if status == gl.FALSE {
+		var logLength int32
+		gl.GetShaderiv(computeShader, gl.INFO_LOG_LENGTH, &logLength)
+		log := strings.Repeat("\x00", int(logLength+1))
+		gl.GetShaderInfoLog(computeShader, logLength, nil, gl.Str(log))
+		return nil, fmt.Errorf("failed to compile %v: %v", volumetricScatteringShaderOriginalComputeSourceFile, log)
+	}
+	gl.AttachShader(program, computeShader)
+
+	// Linking
+	gl.LinkProgram(program)
+	gl.GetProgramiv(program, gl.LINK_STATUS, &status)
+	if status == gl.FALSE {
+		var logLength int32
+		gl.GetProgramiv(program, gl.INFO_LOG_LENGTH, &logLength)
+		log := strings.Repeat("\x00", int(logLength+1))
+		gl.GetProgramInfoLog(program, logLength, nil, gl.Str(log))
+		return nil, fmt.Errorf("failed to link %v: %v", volumetricScatteringShaderOriginalComputeSourceFile, log)
+	}
+
+	scatteringVolumeLoc := gl.GetUniformLocation(program, gl.Str("scatteringVolume\x00"))
+	inverseViewLoc := gl.GetUniformLocation(program, gl.Str("inverseView\x00"))
+	inverseProjectionLoc := gl.GetUniformLocation(program, gl.Str("inverseProjection\x00"))
+	volumetricNearLoc := gl.GetUniformLocation(program, gl.Str("volumetricNear\x00"))
+	volumetricFarLoc := gl.GetUniformLocation(program, gl.Str("volumetricFar\x00"))
+	fogDensityLoc := gl.GetUniformLocation(program, gl.Str("fogDensity\x00"))
+	fogHeightFalloffLoc := gl.GetUniformLocation(program, gl.Str("fogHeightFalloff\x00"))
+	fogBaseHeightLoc := gl.GetUniformLocation(program, gl.Str("fogBaseHeight\x00"))
+	fogAnisotropyLoc := gl.GetUniformLocation(program, gl.Str("fogAnisotropy\x00"))
+	skyAmbientLoc := gl.GetUniformLocation(program, gl.Str("skyAmbient\x00"))
+	skyLightIntensityLoc := gl.GetUniformLocation(program, gl.Str("skyLightIntensity\x00"))
+	lightViewProjsLoc := gl.GetUniformLocation(program, gl.Str("lightViewProjs\x00"))
+	cascadeDepthLimitsLoc := gl.GetUniformLocation(program, gl.Str("cascadeDepthLimits\x00"))
+	firstPersonPositionLoc := gl.GetUniformLocation(program, gl.Str("firstPersonPosition\x00"))
+	firstPersonForwardLoc := gl.GetUniformLocation(program, gl.Str("firstPersonForward\x00"))
+	shadowMap1Loc := gl.GetUniformLocation(program, gl.Str("shadowMap1\x00"))
+	shadowMap2Loc := gl.GetUniformLocation(program, gl.Str("shadowMap2\x00"))
+	shadowMap3Loc := gl.GetUniformLocation(program, gl.Str("shadowMap3\x00"))
+	shadowMap4Loc := gl.GetUniformLocation(program, gl.Str("shadowMap4\x00"))
+	shadowMap5Loc := gl.GetUniformLocation(program, gl.Str("shadowMap5\x00"))
+	pointShadowMapsLoc := gl.GetUniformLocation(program, gl.Str("pointShadowMaps\x00"))
+	numPointShadowLightsLoc := gl.GetUniformLocation(program, gl.Str("numPointShadowLights\x00"))
+	pointShadowLightPositionsLoc := gl.GetUniformLocation(program, gl.Str("pointShadowLightPositions\x00"))
+	pointShadowLightColorsLoc := gl.GetUniformLocation(program, gl.Str("pointShadowLightColors\x00"))
+	pointShadowLightRadiiLoc := gl.GetUniformLocation(program, gl.Str("pointShadowLightRadii\x00"))
+	pointShadowFarPlaneLoc := gl.GetUniformLocation(program, gl.Str("pointShadowFarPlane\x00"))
+
+	gl.DeleteShader(computeShader)
+
+	return &VolumetricScatteringShader{
+		shader:                    shader{program},
+		ScatteringVolume:          uniforms.NewImage3D(program, scatteringVolumeLoc),
+		InverseView:               uniforms.NewMatrix4(program, inverseViewLoc),
+		InverseProjection:         uniforms.NewMatrix4(program, inverseProjectionLoc),
+		VolumetricNear:            uniforms.NewFloat(program, volumetricNearLoc),
+		VolumetricFar:             uniforms.NewFloat(program, volumetricFarLoc),
+		FogDensity:                uniforms.NewFloat(program, fogDensityLoc),
+		FogHeightFalloff:          uniforms.NewFloat(program, fogHeightFalloffLoc),
+		FogBaseHeight:             uniforms.NewFloat(program, fogBaseHeightLoc),
+		FogAnisotropy:             uniforms.NewFloat(program, fogAnisotropyLoc),
+		SkyAmbient:                uniforms.NewVector3(program, skyAmbientLoc),
+		SkyLightIntensity:         uniforms.NewFloat(program, skyLightIntensityLoc),
+		LightViewProjs:            uniforms.NewMatrix4Array(program, lightViewProjsLoc),
+		CascadeDepthLimits:        uniforms.NewFloatArray(program, cascadeDepthLimitsLoc),
+		FirstPersonPosition:       uniforms.NewVector3(program, firstPersonPositionLoc),
+		FirstPersonForward:        uniforms.NewVector3(program, firstPersonForwardLoc),
+		ShadowMap1:                uniforms.NewSampler2D(program, shadowMap1Loc),
+		ShadowMap2:                uniforms.NewSampler2D(program, shadowMap2Loc),
+		ShadowMap3:                uniforms.NewSampler2D(program, shadowMap3Loc),
+		ShadowMap4:                uniforms.NewSampler2D(program, shadowMap4Loc),
+		ShadowMap5:                uniforms.NewSampler2D(program, shadowMap5Loc),
+		PointShadowMaps:           uniforms.NewSamplerCubeArrayTexture(program, pointShadowMapsLoc),
+		NumPointShadowLights:      uniforms.NewInt(program, numPointShadowLightsLoc),
+		PointShadowLightPositions: uniforms.NewVector3Array(program, pointShadowLightPositionsLoc),
+		PointShadowLightColors:    uniforms.NewVector3Array(program, pointShadowLightColorsLoc),
+		PointShadowLightRadii:     uniforms.NewFloatArray(program, pointShadowLightRadiiLoc),
+		PointShadowFarPlane:       uniforms.NewFloat(program, pointShadowFarPlaneLoc),
+		DirectionalLightBuffer:    buffers.NewBinding(2),
+	}, nil
+}
@@ -0,0 +1,300 @@
+package shaders
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/brandonnelson3/GoRender/gfx/buffers"
+	"github.com/brandonnelson3/GoRender/gfx/uniforms"
+
+	"github.com/go-gl/gl/v4.5-core/gl"
+)
+
+const (
+	waterShaderOriginalVertexSourceFile = `watershader.vert`
+	waterShaderVertSrc                  = `
+#version 450
+
+uniform mat4 projection;
+uniform mat4 view;
+uniform mat4 model;
+
+layout(location = 0) in vec3 vert;
+
+out vec3 worldPosition;
+
+void main() {
+	worldPosition = vec3(model * vec4(vert, 1));
+	gl_Position = projection * view * vec4(worldPosition, 1);
+}` + "\x00"
+	waterShaderOriginalFragmentSourceFile = `watershader.frag`
+	waterShaderFragSrc                    = `
+#version 450
+
+struct DirectionalLight {
+	vec3 color;
+	float brightness;
+	vec3 direction;
+};
+
+layout(std430, binding = 2) readonly buffer DirectionalLightBuffer {
+	DirectionalLight data;
+} directionalLightBuffer;
+
+uniform mat4 view;
+uniform sampler2D reflectionMap;
+uniform sampler2D refractionMap;
+uniform sampler2D depthMap;
+uniform sampler2D normalMap;
+uniform samplerCube environmentMap;
+uniform vec2 screenSize;
+uniform float zNear;
+uniform float zFar;
+uniform vec3 cameraPosition;
+uniform float time;
+uniform float waveScale;
+uniform float waveSpeed;
+uniform float waveStrength;
+uniform vec3 shallowColor;
+uniform vec3 deepColor;
+uniform float shorelineDepth;
+
+// Height fog, matching the color shader.
+uniform sampler3D volumetricFog;
+uniform float volumetricNear;
+uniform float volumetricFar;
+uniform float fogDensity;
+uniform float fogHeightFalloff;
+uniform float fogBaseHeight;
+uniform float fogAnisotropy;
+uniform vec3 skyAmbient;
+
+in vec3 worldPosition;
+
+out vec4 outputColor;
+
+#define PI 3.141592
+
+float linearizeDepth(float depth) {
+	float z = depth * 2.0 - 1.0;
+	return (2.0 * zNear * zFar) / (zFar + zNear - z * (zFar - zNear));
+}
+
+float henyeyGreenstein(float cosTheta, float g) {
+	float gg = g * g;
+	return (1.0 - gg) / (4.0 * PI * pow(1.0 + gg - 2.0 * g * cosTheta, 1.5));
+}
+
+float getHeightFogOpticalDepth(vec3 start, vec3 dir, float dist) {
+	float density = fogDensity * exp(-fogHeightFalloff * (start.y - fogBaseHeight));
+	float falloff = fogHeightFalloff * dir.y;
+	if (abs(falloff) < 0.0001) {
+		return density * dist;
+	}
+	return density * (1.0 - exp(-falloff * dist)) / falloff;
+}
+
+vec3 applyFog(vec3 color, DirectionalLight light) {
+	if (fogDensity <= 0.0) {
+		return color;
+	}
+
+	vec3 toFragment = worldPosition - cameraPosition;
+	float dist = length(toFragment);
+	vec3 dir = toFragment / dist;
+	float viewDepth = -(view * vec4(worldPosition, 1.0)).z;
+
+	if (viewDepth > volumetricFar) {
+		float start = dist * volumetricFar / viewDepth;
+		float transmittance = exp(-getHeightFogOpticalDepth(cameraPosition + dir * start, dir, dist - start));
+		float phase = henyeyGreenstein(dot(dir, -light.direction), fogAnisotropy);
+		vec3 inScattering = light.color * light.brightness * phase + skyAmbient;
+		color = mix(inScattering, color, transmittance);
+	}
+
+	float slices = float(textureSize(volumetricFog, 0).z);
+	float w = log(max(viewDepth, volumetricNear) / volumetricNear) / log(volumetricFar / volumetricNear);
+	vec4 fog = texture(volumetricFog, vec3(gl_FragCoord.xy / screenSize, clamp(w - 0.5 / slices, 0.0, 1.0)));
+	return color * fog.a + fog.rgb;
+}
+
+void main() {
+	DirectionalLight light = directionalLightBuffer.data;
+	vec2 screenUV = gl_FragCoord.xy / screenSize;
+	vec3 viewDir = normalize(cameraPosition - worldPosition);
+
+	// Two normal map layers scrolling in different directions give the surface its motion.
+	vec2 uv = worldPosition.xz * waveScale;
+	vec3 n1 = texture(normalMap, uv + vec2(time * waveSpeed, time * waveSpeed * 0.4)).rgb * 2.0 - 1.0;
+	vec3 n2 = texture(normalMap, uv * 1.7 - vec2(time * waveSpeed * 0.6, -time * waveSpeed * 0.8)).rgb * 2.0 - 1.0;
+	vec3 tangentNormal = n1 + n2;
+	vec3 n = normalize(vec3(tangentNormal.x * waveStrength, tangentNormal.z, tangentNormal.y * waveStrength));
+
+	// Thickness of the water along the view ray, from the depth pre-pass which doesn't contain the water.
+	float thickness = max(linearizeDepth(texture(depthMap, screenUV).r) - linearizeDepth(gl_FragCoord.z), 0.0);
+	float depthFactor = clamp(thickness / shorelineDepth, 0.0, 1.0);
+
+	// Distort less in the shallows so the shoreline doesn't pick up geometry from above the water.
+	vec2 distortion = n.xz * 0.03 * depthFactor;
+
+	// Anything the reflection pass didn't draw is sky, looked up from the environment instead.
+	vec4 reflection = texture(reflectionMap, screenUV + distortion);
+	vec3 skyReflection = textureLod(environmentMap, reflect(-viewDir, n), 0.0).rgb;
+	vec3 reflectionColor = mix(skyReflection, reflection.rgb, reflection.a);
+
+	vec3 refraction = texture(refractionMap, screenUV + distortion).rgb * shallowColor;
+	vec3 refractionColor = mix(refraction, deepColor * (0.2 + 0.8 * light.brightness), depthFactor);
+
+	float fresnel = 0.02 + 0.98 * pow(1.0 - max(dot(n, viewDir), 0.0), 5.0);
+	vec3 color = mix(refractionColor, reflectionColor, fresnel);
+
+	vec3 halfway = normalize(viewDir - light.direction);
+	color += light.color * light.brightness * pow(max(dot(n, halfway), 0.0), 256.0) * 2.0;
+
+	// Lighten the water where it laps against the shore.
+	float shoreline = 1.0 - smoothstep(0.0, 0.3, thickness);
+	color = mix(color, vec3(0.9) * (0.2 + 0.8 * light.brightness), shoreline * 0.5);
+
+	outputColor = vec4(applyFog(color, light), 1.0);
+}
+` + "\x00"
+)
+
+// WaterShader is a Shader which draws a water surface from its reflection and refraction textures.
+type WaterShader struct {
+	shader
+
+	Projection, View, Model *uniforms.Matrix4
+
+	ReflectionMap, RefractionMap, DepthMap, NormalMap *uniforms.Sampler2D
+	EnvironmentMap                                    *uniforms.SamplerCube
+
+	ScreenSize     *uniforms.Vector2
+	ZNear, ZFar    *uniforms.Float
+	CameraPosition *uniforms.Vector3
+
+	Time, WaveScale, WaveSpeed, WaveStrength *uniforms.Float
+	ShallowColor, DeepColor                  *uniforms.Vector3
+	ShorelineDepth                           *uniforms.Float
+
+	VolumetricFog                                              *uniforms.Sampler3D
+	VolumetricNear, VolumetricFar                              *uniforms.Float
+	FogDensity, FogHeightFalloff, FogBaseHeight, FogAnisotropy *uniforms.Float
+	SkyAmbient                                                 *uniforms.Vector3
+
+	DirectionalLightBuffer *buffers.Binding
+}
+
+// NewWaterShader instantiates and initializes a shader object.
+func NewWaterShader() (*WaterShader, error) {
+	program := gl.CreateProgram()
+
+	// VertexShader
+	vertexShader := gl.CreateShader(gl.VERTEX_SHADER)
+	vertexSrc, freeVertexSrc := gl.Strs(waterShaderVertSrc)
+	gl.ShaderSource(vertexShader, 1, vertexSrc, nil)
+	freeVertexSrc()
+	gl.CompileShader(vertexShader)
+	var status int32
+	gl.GetShaderiv(vertexShader, gl.COMPILE_STATUS, &status)
+	if status == gl.FALSE {
+		var logLength int32
+		gl.GetShaderiv(vertexShader, gl.INFO_LOG_LENGTH, &logLength)
+		log := strings.Repeat("\x00", int(logLength+1))
+		gl.GetShaderInfoLog(vertexShader, logLength, nil, gl.Str(log))
+		return nil, fmt.Errorf("failed to compile %v: %v", waterShaderOriginalVertexSourceFile, log)
+	}
+	gl.AttachShader(program, vertexShader)
+
+	// FragmentShader
+	fragmentShader := gl.CreateShader(gl.FRAGMENT_SHADER)
+	fragmentSrc, freeFragmentSrc := gl.Strs(waterShaderFragSrc)
+	gl.ShaderSource(fragmentShader, 1, fragmentSrc, nil)
+	freeFragmentSrc()
+	gl.CompileShader(fragmentShader)
+	gl.GetShaderiv(fragmentShader, gl.COMPILE_STATUS, &status)
+	if status == gl.FALSE {
+		var logLength int32
+		gl.GetShaderiv(fragmentShader, gl.INFO_LOG_LENGTH, &logLength)
+		log := strings.Repeat("\x00", int(logLength+1))
+		gl.GetShaderInfoLog(fragmentShader, logLength, nil, gl.Str(log))
+		return nil, fmt.Errorf("failed to compile %v: %v", waterShaderOriginalFragmentSourceFile, log)
+	}
+	gl.AttachShader(program, fragmentShader)
+
+	// Linking
+	gl.LinkProgram(program)
+	gl.GetProgramiv(program, gl.LINK_STATUS, &status)
+	if status == gl.FALSE {
+		var logLength int32
+		gl.GetProgramiv(program, gl.INFO_LOG_LENGTH, &logLength)
+		log := strings.Repeat("\x00", int(logLength+1))
+		gl.GetProgramInfoLog(program, logLength, nil, gl.Str(log))
+		return nil, fmt.Errorf("failed to link %v: %v", waterShaderOriginalVertexSourceFile, log)
+	}
+
+	projectionLoc := gl.GetUniformLocation(program, gl.Str("projection\x00"))
+	viewLoc := gl.GetUniformLocation(program, gl.Str("view\x00"))
+	modelLoc := gl.GetUniformLocation(program, gl.Str("model\x00"))
+	reflectionMapLoc := gl.GetUniformLocation(program, gl.Str("reflectionMap\x00"))
+	refractionMapLoc := gl.GetUniformLocation(program, gl.Str("refractionMap\x00"))
+	depthMapLoc := gl.GetUniformLocation(program, gl.Str("depthMap\x00"))
+	normalMapLoc := gl.GetUniformLocation(program, gl.Str("normalMap\x00"))
+	environmentMapLoc := gl.GetUniformLocation(program, gl.Str("environmentMap\x00"))
+	screenSizeLoc := gl.GetUniformLocation(program, gl.Str("screenSize\x00"))
+	zNearLoc := gl.GetUniformLocation(program, gl.Str("zNear\x00"))
+	zFarLoc := gl.GetUniformLocation(program, gl.Str("zFar\x00"))
+	cameraPositionLoc := gl.GetUniformLocation(program, gl.Str("cameraPosition\x00"))
+	timeLoc := gl.GetUniformLocation(program, gl.Str("time\x00"))
+	waveScaleLoc := gl.GetUniformLocation(program, gl.Str("waveScale\x00"))
+	waveSpeedLoc := gl.GetUniformLocation(program, gl.Str("waveSpeed\x00"))
+	waveStrengthLoc := gl.GetUniformLocation(program, gl.Str("waveStrength\x00"))
+	shallowColorLoc := gl.GetUniformLocation(program, gl.Str("shallowColor\x00"))
+	deepColorLoc := gl.GetUniformLocation(program, gl.Str("deepColor\x00"))
+	shorelineDepthLoc := gl.GetUniformLocation(program, gl.Str("shorelineDepth\x00"))
+	volumetricFogLoc := gl.GetUniformLocation(program, gl.Str("volumetricFog\x00"))
+	volumetricNearLoc := gl.GetUniformLocation(program, gl.Str("volumetricNear\x00"))
+	volumetricFarLoc := gl.GetUniformLocation(program, gl.Str("volumetricFar\x00"))
+	fogDensityLoc := gl.GetUniformLocation(program, gl.Str("fogDensity\x00"))
+	fogHeightFalloffLoc := gl.GetUniformLocation(program, gl.Str("fogHeightFalloff\x00"))
+	fogBaseHeightLoc := gl.GetUniformLocation(program, gl.Str("fogBaseHeight\x00"))
+	fogAnisotropyLoc := gl.GetUniformLocation(program, gl.Str("fogAnisotropy\x00"))
+	skyAmbientLoc := gl.GetUniformLocation(program, gl.Str("skyAmbient\x00"))
+
+	gl.DeleteShader(vertexShader)
+	gl.DeleteShader(fragmentShader)
+
+	gl.BindFragDataLocation(program, 0, gl.Str("outputColor\x00"))
+
+	return &WaterShader{
+		shader:                 shader{program},
+		Projection:             uniforms.NewMatrix4(program, projectionLoc),
+		View:                   uniforms.NewMatrix4(program, viewLoc),
+		Model:                  uniforms.NewMatrix4(program, modelLoc),
+		ReflectionMap:          uniforms.NewSampler2D(program, reflectionMapLoc),
+		RefractionMap:          uniforms.NewSampler2D(program, refractionMapLoc),
+		DepthMap:               uniforms.NewSampler2D(program, depthMapLoc),
+		NormalMap:              uniforms.NewSampler2D(program, normalMapLoc),
+		EnvironmentMap:         uniforms.NewSamplerCube(program, environmentMapLoc),
+		ScreenSize:             uniforms.NewVector2(program, screenSizeLoc),
+		ZNear:                  uniforms.NewFloat(program, zNearLoc),
+		ZFar:                   uniforms.NewFloat(program, zFarLoc),
+		CameraPosition:         uniforms.NewVector3(program, cameraPositionLoc),
+		Time:                   uniforms.NewFloat(program, timeLoc),
+		WaveScale:              uniforms.NewFloat(program, waveScaleLoc),
+		WaveSpeed:              uniforms.NewFloat(program, waveSpeedLoc),
+		WaveStrength:           uniforms.NewFloat(program, waveStrengthLoc),
+		ShallowColor:           uniforms.NewVector3(program, shallowColorLoc),
+		DeepColor:              uniforms.NewVector3(program, deepColorLoc),
+		ShorelineDepth:         uniforms.NewFloat(program, shorelineDepthLoc),
+		VolumetricFog:          uniforms.NewSampler3D(program, volumetricFogLoc),
+		VolumetricNear:         uniforms.NewFloat(program, volumetricNearLoc),
+		VolumetricFar:          uniforms.NewFloat(program, volumetricFarLoc),
+		FogDensity:             uniforms.NewFloat(program, fogDensityLoc),
+		FogHeightFalloff:       uniforms.NewFloat(program, fogHeightFalloffLoc),
+		FogBaseHeight:          uniforms.NewFloat(program, fogBaseHeightLoc),
+		FogAnisotropy:          uniforms.NewFloat(program, fogAnisotropyLoc),
+		SkyAmbient:             uniforms.NewVector3(program, skyAmbientLoc),
+		DirectionalLightBuffer: buffers.NewBinding(2),
+	}, nil
+}
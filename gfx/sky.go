@@ -6,13 +6,43 @@ import (
 	"github.com/go-gl/mathgl/mgl32"
 )
 
-type Sky struct {
+// Sky is the background drawn behind the scene, which also provides the image based lighting for it.
+type Sky interface {
+	// Render renders the sky. This should always be rendered before any other part of the scene.
+	Render()
+	// Update refreshes the sky for the current camera orientation, and recaptures the
+	// image based lighting derived from the sky if it has changed.
+	Update()
+	// SetTime sets the time in seconds that the sky is animated to.
+	SetTime(seconds float64)
+
+	// getEnvironment returns the image based lighting captured from this sky.
+	getEnvironment() *environment
+	// skyLightScale returns the factor the captured lighting is scaled by when lighting the scene.
+	skyLightScale() float32
+}
+
+// Atmosphere is a Sky which renders procedural Rayleigh/Mie atmospheric scattering lit by the directional light.
+type Atmosphere struct {
 	vao, vbo uint32
 
 	skyShader *shaders.SkyShader
+
+	clouds cloudLayer
+
+	environment *environment
+	// lightVersion and capturedClouds are the directional light version and cloud settings
+	// the environment was last captured for.
+	lightVersion   uint64
+	capturedClouds CloudSettings
+	captured       bool
 }
 
-func NewSky() (*Sky, error) {
+// cloudRecaptureThreshold is how far cloud coverage or density must drift before the image based lighting is recaptured.
+const cloudRecaptureThreshold = 0.02
+
+// NewAtmosphere instantiates the procedural atmosphere sky.
+func NewAtmosphere() (*Atmosphere, error) {
 	skyShader, err := shaders.NewSkyShader()
 	if err != nil {
 		return nil, err
@@ -29,28 +59,102 @@ func NewSky() (*Sky, error) {
 
 	gl.BindVertexArray(0)
 
-	return &Sky{
-		vao:       vao,
-		vbo:       vbo,
-		skyShader: skyShader,
+	env, err := newEnvironment(skyShader.Program())
+	if err != nil {
+		return nil, err
+	}
+
+	return &Atmosphere{
+		vao:         vao,
+		vbo:         vbo,
+		skyShader:   skyShader,
+		environment: env,
 	}, nil
 }
 
 // Render renders the sky. This should always be rendered before any other part of the scene.
-func (sky *Sky) Render() {
+func (sky *Atmosphere) Render() {
 	gl.Disable(gl.DEPTH_TEST)
 	sky.skyShader.Use()
-	view := mgl32.LookAtV(mgl32.Vec3{}, ActiveCamera.GetForward(), mgl32.Vec3{0, 1, 0})
+	view := mgl32.LookAtV(mgl32.Vec3{}, ActiveCamera.GetForward(), ActiveCamera.GetUp())
 	sky.skyShader.View.Set(view)
-	sky.skyShader.Projection.Set(Window.GetProjection())
+	sky.skyShader.Projection.Set(ActiveCamera.GetProjection())
 	sky.skyShader.DirectionalLightBuffer.Set(GetDirectionalLightBuffer())
+	sky.setCloudUniforms()
 	gl.BindVertexArray(sky.vao)
 	gl.DrawArrays(gl.TRIANGLES, 0, 2*3)
 	gl.BindVertexArray(0)
 	gl.Enable(gl.DEPTH_TEST)
 }
 
-func (sky *Sky) Update() {
+// Update refreshes the sky quad for the current camera orientation, and recaptures the
+// image based lighting derived from the sky if the directional light or clouds have changed.
+func (sky *Atmosphere) Update() {
+	cloudsChanged := sky.clouds.settings.coverageDiffers(sky.capturedClouds, cloudRecaptureThreshold)
+	if !sky.captured || sky.lightVersion != directionalLightVersion || cloudsChanged {
+		sky.lightVersion = directionalLightVersion
+		sky.capturedClouds = sky.clouds.settings
+		sky.captured = true
+
+		sky.skyShader.Use()
+		sky.skyShader.Projection.Set(environmentCaptureProjection)
+		sky.skyShader.DirectionalLightBuffer.Set(GetDirectionalLightBuffer())
+		sky.setCloudUniforms()
+		sky.environment.capture(sky.skyShader.View.Set)
+	}
+
+	updateSkyQuad(sky.vao, sky.vbo)
+}
+
+// SetTime advances the clouds, and any cloud tween in progress, to the given time in seconds.
+func (sky *Atmosphere) SetTime(seconds float64) {
+	sky.clouds.setTime(seconds)
+}
+
+// SetClouds replaces the cloud layer settings immediately.
+func (sky *Atmosphere) SetClouds(settings CloudSettings) {
+	sky.clouds.set(settings)
+}
+
+// TweenClouds eases the cloud layer settings to target over duration seconds of sky time.
+func (sky *Atmosphere) TweenClouds(target CloudSettings, duration float64) {
+	sky.clouds.tween(target, duration)
+}
+
+// Clouds returns the current cloud layer settings, part way through any tween in progress.
+func (sky *Atmosphere) Clouds() CloudSettings {
+	return sky.clouds.settings
+}
+
+func (sky *Atmosphere) setCloudUniforms() {
+	sky.skyShader.CloudCoverage.Set(sky.clouds.settings.Coverage)
+	sky.skyShader.CloudDensity.Set(sky.clouds.settings.Density)
+	sky.skyShader.CloudOffset.Set(sky.clouds.offset)
+}
+
+func (sky *Atmosphere) getEnvironment() *environment {
+	return sky.environment
+}
+
+// skyLightScale follows the sun's brightness so that unlit (indoor) scenes stay dark.
+func (sky *Atmosphere) skyLightScale() float32 {
+	return directionalLight.Brightness
+}
+
+// updateSkyQuad uploads a quad covering the viewport for the active camera's orientation into vbo.
+func updateSkyQuad(vao, vbo uint32) {
+	view := mgl32.LookAtV(mgl32.Vec3{}, ActiveCamera.GetForward(), ActiveCamera.GetUp())
+	vertices := skyQuadVertices(view, ActiveCamera.GetProjection())
+
+	gl.BindVertexArray(vao)
+	gl.BindBuffer(gl.ARRAY_BUFFER, vbo)
+	gl.BufferData(gl.ARRAY_BUFFER, len(vertices)*3*4, gl.Ptr(vertices), gl.DYNAMIC_DRAW)
+	gl.BindVertexArray(0)
+}
+
+// skyQuadVertices returns the world space vertices of a quad that covers the entire viewport
+// for the given origin-centered view and projection.
+func skyQuadVertices(view, projection mgl32.Mat4) []mgl32.Vec3 {
 	vertices := []mgl32.Vec3{
 		{-1, 1, .1},
 		{1, -1, .1},
@@ -60,14 +164,9 @@ func (sky *Sky) Update() {
 		{-1, 1, .1},
 	}
 
-	view := mgl32.LookAtV(mgl32.Vec3{}, ActiveCamera.GetForward(), mgl32.Vec3{0, 1, 0})
-	transform := Window.GetProjection().Mul4(view).Transpose().Inv()
+	transform := projection.Mul4(view).Transpose().Inv()
 	for i, v := range vertices {
 		vertices[i] = transformTransposed(v, transform)
 	}
-
-	gl.BindVertexArray(sky.vao)
-	gl.BindBuffer(gl.ARRAY_BUFFER, sky.vbo)
-	gl.BufferData(gl.ARRAY_BUFFER, len(vertices)*3*4, gl.Ptr(vertices), gl.DYNAMIC_DRAW)
-	gl.BindVertexArray(0)
+	return vertices
 }
@@ -0,0 +1,192 @@
+package gfx
+
+import (
+	"fmt"
+	"math"
+
+	"github.com/brandonnelson3/GoRender/gfx/shaders"
+	"github.com/go-gl/gl/v4.5-core/gl"
+	"github.com/go-gl/mathgl/mgl32"
+)
+
+// maxSkyboxFaceSize limits the resolution of the cubemap faces built from an equirectangular image.
+const maxSkyboxFaceSize = 1024
+
+// Skybox is a Sky which draws a cubemap, such as a studio HDRI, behind the scene.
+type Skybox struct {
+	vao, vbo uint32
+
+	skyboxShader *shaders.SkyboxShader
+
+	cubemap uint32
+
+	rotation, intensity float32
+
+	environment *environment
+	// dirty is set whenever the environment needs to be recaptured.
+	dirty bool
+}
+
+// NewSkybox instantiates a Skybox from a single equirectangular Radiance .hdr or .png image.
+func NewSkybox(file string) (*Skybox, error) {
+	img, err := loadHDRImage(file)
+	if err != nil {
+		return nil, err
+	}
+	if img.width != 2*img.height {
+		return nil, fmt.Errorf("equirectangular image %v must be twice as wide as it is tall, got %dx%d", file, img.width, img.height)
+	}
+
+	size := img.width / 4
+	if size > maxSkyboxFaceSize {
+		size = maxSkyboxFaceSize
+	}
+	return newSkybox(newSkyboxCubemap(equirectangularToCubemap(img, size), size))
+}
+
+// NewCubemapSkybox instantiates a Skybox from 6 square .hdr or .png face images, in +X, -X, +Y, -Y, +Z, -Z order.
+func NewCubemapSkybox(files [6]string) (*Skybox, error) {
+	var faces [6][]float32
+	size := 0
+	for face, file := range files {
+		img, err := loadHDRImage(file)
+		if err != nil {
+			return nil, err
+		}
+		if face == 0 {
+			size = img.width
+		}
+		if img.width != size || img.height != size {
+			return nil, fmt.Errorf("cubemap face %v must be %dx%d, got %dx%d", file, size, size, img.width, img.height)
+		}
+		faces[face] = img.pix
+	}
+	return newSkybox(newSkyboxCubemap(faces, size))
+}
+
+func newSkybox(cubemap uint32) (*Skybox, error) {
+	skyboxShader, err := shaders.NewSkyboxShader()
+	if err != nil {
+		return nil, err
+	}
+
+	var vao uint32
+	gl.GenVertexArrays(1, &vao)
+	gl.BindVertexArray(vao)
+	var vbo uint32
+	gl.GenBuffers(1, &vbo)
+	gl.BindBuffer(gl.ARRAY_BUFFER, vbo)
+
+	BindSkyVertexAttributes(skyboxShader.Program())
+
+	gl.BindVertexArray(0)
+
+	env, err := newEnvironment(skyboxShader.Program())
+	if err != nil {
+		return nil, err
+	}
+
+	return &Skybox{
+		vao:          vao,
+		vbo:          vbo,
+		skyboxShader: skyboxShader,
+		cubemap:      cubemap,
+		intensity:    1,
+		environment:  env,
+		dirty:        true,
+	}, nil
+}
+
+// SetRotation rotates the skybox around the vertical axis by the given angle in radians.
+func (sky *Skybox) SetRotation(radians float32) {
+	sky.rotation = radians
+	sky.dirty = true
+}
+
+// SetIntensity scales the brightness of the skybox, and of the light it casts on the scene.
+func (sky *Skybox) SetIntensity(intensity float32) {
+	sky.intensity = intensity
+	sky.dirty = true
+}
+
+// use binds the skybox shader with its cubemap, rotation and intensity.
+func (sky *Skybox) use(projection mgl32.Mat4) {
+	sky.skyboxShader.Use()
+	sky.skyboxShader.Projection.Set(projection)
+	sky.skyboxShader.Rotation.Set(mgl32.HomogRotate3DY(-sky.rotation))
+	sky.skyboxShader.Intensity.Set(sky.intensity)
+	sky.skyboxShader.Skybox.Set(gl.TEXTURE0, 0, sky.cubemap)
+}
+
+// Render renders the sky. This should always be rendered before any other part of the scene.
+func (sky *Skybox) Render() {
+	gl.Disable(gl.DEPTH_TEST)
+	sky.use(ActiveCamera.GetProjection())
+	sky.skyboxShader.View.Set(mgl32.LookAtV(mgl32.Vec3{}, ActiveCamera.GetForward(), ActiveCamera.GetUp()))
+	gl.BindVertexArray(sky.vao)
+	gl.DrawArrays(gl.TRIANGLES, 0, 2*3)
+	gl.BindVertexArray(0)
+	gl.Enable(gl.DEPTH_TEST)
+}
+
+// Update refreshes the sky quad for the current camera orientation, and recaptures the
+// image based lighting derived from the sky if its rotation or intensity has changed.
+func (sky *Skybox) Update() {
+	if sky.dirty {
+		sky.dirty = false
+		sky.use(environmentCaptureProjection)
+		sky.environment.capture(sky.skyboxShader.View.Set)
+	}
+
+	updateSkyQuad(sky.vao, sky.vbo)
+}
+
+// SetTime does nothing, a skybox is static.
+func (sky *Skybox) SetTime(seconds float64) {}
+
+func (sky *Skybox) getEnvironment() *environment {
+	return sky.environment
+}
+
+// skyLightScale is constant, the skybox's intensity is already part of the captured lighting.
+func (sky *Skybox) skyLightScale() float32 {
+	return 1
+}
+
+// equirectangularToCubemap resamples an equirectangular image into 6 RGB cubemap faces of the given size.
+// The center of the image faces -Z, and the top row is straight up.
+func equirectangularToCubemap(img *hdrImage, size int) [6][]float32 {
+	var faces [6][]float32
+	for face := range faces {
+		faces[face] = make([]float32, size*size*3)
+		for y := 0; y < size; y++ {
+			for x := 0; x < size; x++ {
+				d := cubemapTexelDirection(face, x, y, size)
+				u := 0.5 + float32(math.Atan2(float64(d.X()), float64(-d.Z())))/(2*math.Pi)
+				v := float32(math.Acos(float64(mgl32.Clamp(d.Y(), -1, 1)))) / math.Pi
+				c := img.sampleBilinear(u, v)
+				i := (y*size + x) * 3
+				copy(faces[face][i:i+3], c[:])
+			}
+		}
+	}
+	return faces
+}
+
+// newSkyboxCubemap uploads 6 RGB float faces of the given size into a mipmapped cubemap.
+func newSkyboxCubemap(faces [6][]float32, size int) uint32 {
+	var texture uint32
+	gl.GenTextures(1, &texture)
+	gl.BindTexture(gl.TEXTURE_CUBE_MAP, texture)
+	for face := range faces {
+		gl.TexImage2D(gl.TEXTURE_CUBE_MAP_POSITIVE_X+uint32(face), 0, gl.RGB16F, int32(size), int32(size), 0, gl.RGB, gl.FLOAT, gl.Ptr(faces[face]))
+	}
+	gl.GenerateMipmap(gl.TEXTURE_CUBE_MAP)
+	gl.TexParameteri(gl.TEXTURE_CUBE_MAP, gl.TEXTURE_MIN_FILTER, gl.LINEAR_MIPMAP_LINEAR)
+	gl.TexParameteri(gl.TEXTURE_CUBE_MAP, gl.TEXTURE_MAG_FILTER, gl.LINEAR)
+	gl.TexParameteri(gl.TEXTURE_CUBE_MAP, gl.TEXTURE_WRAP_S, gl.CLAMP_TO_EDGE)
+	gl.TexParameteri(gl.TEXTURE_CUBE_MAP, gl.TEXTURE_WRAP_T, gl.CLAMP_TO_EDGE)
+	gl.TexParameteri(gl.TEXTURE_CUBE_MAP, gl.TEXTURE_WRAP_R, gl.CLAMP_TO_EDGE)
+	gl.BindTexture(gl.TEXTURE_CUBE_MAP, 0)
+	return texture
+}
@@ -0,0 +1,89 @@
+package gfx
+
+import (
+	"math"
+
+	"github.com/go-gl/mathgl/mgl32"
+)
+
+// numSHCoefficients is the number of coefficients in a 3-band (L2) spherical harmonic expansion.
+const numSHCoefficients = 9
+
+// cubemapTexelDirection returns the world space direction through the center of texel (x, y)
+// of the given cubemap face, following the OpenGL face orientation conventions.
+// Face order matches gl.TEXTURE_CUBE_MAP_POSITIVE_X + face.
+func cubemapTexelDirection(face, x, y, size int) mgl32.Vec3 {
+	s := 2*(float32(x)+0.5)/float32(size) - 1
+	t := 2*(float32(y)+0.5)/float32(size) - 1
+	var d mgl32.Vec3
+	switch face {
+	case 0:
+		d = mgl32.Vec3{1, -t, -s}
+	case 1:
+		d = mgl32.Vec3{-1, -t, s}
+	case 2:
+		d = mgl32.Vec3{s, 1, t}
+	case 3:
+		d = mgl32.Vec3{s, -1, -t}
+	case 4:
+		d = mgl32.Vec3{s, -t, 1}
+	default:
+		d = mgl32.Vec3{-s, -t, -1}
+	}
+	return d.Normalize()
+}
+
+// cubemapTexelSolidAngle returns the approximate solid angle subtended by texel (x, y) of a cubemap face.
+func cubemapTexelSolidAngle(x, y, size int) float32 {
+	s := 2*(float64(x)+0.5)/float64(size) - 1
+	t := 2*(float64(y)+0.5)/float64(size) - 1
+	texelArea := 4.0 / float64(size*size)
+	return float32(texelArea / math.Pow(1+s*s+t*t, 1.5))
+}
+
+// shBasis evaluates the 9 real spherical harmonic basis functions for the unit direction d.
+func shBasis(d mgl32.Vec3) [numSHCoefficients]float32 {
+	x, y, z := d.X(), d.Y(), d.Z()
+	return [numSHCoefficients]float32{
+		0.282095,
+		0.488603 * y,
+		0.488603 * z,
+		0.488603 * x,
+		1.092548 * x * y,
+		1.092548 * y * z,
+		0.315392 * (3*z*z - 1),
+		1.092548 * x * z,
+		0.546274 * (x*x - y*y),
+	}
+}
+
+// projectIrradianceSH projects the radiance stored in 6 RGB float cubemap faces of the given size onto
+// spherical harmonics and convolves the result with a cosine lobe. The returned coefficients evaluate
+// (via shBasis) directly to the diffuse radiance reflected by a white Lambertian surface with that normal.
+func projectIrradianceSH(faces [6][]float32, size int) [numSHCoefficients]mgl32.Vec3 {
+	var sh [numSHCoefficients]mgl32.Vec3
+	totalWeight := float32(0)
+	for face := range faces {
+		for y := 0; y < size; y++ {
+			for x := 0; x < size; x++ {
+				i := (y*size + x) * 3
+				radiance := mgl32.Vec3{faces[face][i], faces[face][i+1], faces[face][i+2]}
+				weight := cubemapTexelSolidAngle(x, y, size)
+				basis := shBasis(cubemapTexelDirection(face, x, y, size))
+				for c := range sh {
+					sh[c] = sh[c].Add(radiance.Mul(basis[c] * weight))
+				}
+				totalWeight += weight
+			}
+		}
+	}
+
+	// Renormalize so the texel solid angles sum to exactly 4π, then apply the cosine lobe
+	// convolution (π, 2π/3, π/4 per band) and divide by π for Lambertian reflectance.
+	normalization := float32(4*math.Pi) / totalWeight
+	bandScale := [numSHCoefficients]float32{1, 2.0 / 3.0, 2.0 / 3.0, 2.0 / 3.0, 0.25, 0.25, 0.25, 0.25, 0.25}
+	for c := range sh {
+		sh[c] = sh[c].Mul(normalization * bandScale[c])
+	}
+	return sh
+}
@@ -0,0 +1,63 @@
+package gfx
+
+import (
+	"math"
+	"testing"
+
+	"github.com/go-gl/mathgl/mgl32"
+	"github.com/stretchr/testify/assert"
+)
+
+// uniformCubemap returns the faces of a cubemap of the given size with radiance everywhere.
+func uniformCubemap(size int, radiance mgl32.Vec3) [6][]float32 {
+	var faces [6][]float32
+	for face := range faces {
+		faces[face] = make([]float32, size*size*3)
+		for i := 0; i < len(faces[face]); i += 3 {
+			copy(faces[face][i:], radiance[:])
+		}
+	}
+	return faces
+}
+
+// evaluateSH returns the value of the coefficients in direction d.
+func evaluateSH(sh [numSHCoefficients]mgl32.Vec3, d mgl32.Vec3) mgl32.Vec3 {
+	var v mgl32.Vec3
+	basis := shBasis(d)
+	for c := range sh {
+		v = v.Add(sh[c].Mul(basis[c]))
+	}
+	return v
+}
+
+func TestProjectIrradianceSHUniform(t *testing.T) {
+	radiance := mgl32.Vec3{.5, 1, 2}
+	sh := projectIrradianceSH(uniformCubemap(8, radiance), 8)
+
+	// A surface facing any way sees the whole hemisphere above it, so its irradiance is π·L, and it reflects L.
+	for _, d := range []mgl32.Vec3{{1, 0, 0}, {-1, 0, 0}, {0, 1, 0}, {0, -1, 0}, {0, 0, 1}, {0, 0, -1}, {1, 2, -3}} {
+		irradiance := evaluateSH(sh, d.Normalize()).Mul(math.Pi)
+		assert.InDelta(t, 0, irradiance.Sub(radiance.Mul(math.Pi)).Len(), 1e-4, "%v", d)
+	}
+	// Only the constant band is left.
+	for c := 1; c < numSHCoefficients; c++ {
+		assert.InDelta(t, 0, sh[c].Len(), 1e-5, "coefficient %d", c)
+	}
+}
+
+func TestProjectIrradianceSHDirectional(t *testing.T) {
+	// Light only from the +X face lights surfaces facing it more than those facing away.
+	const size = 8
+	faces := uniformCubemap(size, mgl32.Vec3{})
+	for i := range faces[0] {
+		faces[0][i] = 1
+	}
+	sh := projectIrradianceSH(faces, size)
+
+	toward := evaluateSH(sh, mgl32.Vec3{1, 0, 0})
+	away := evaluateSH(sh, mgl32.Vec3{-1, 0, 0})
+	side := evaluateSH(sh, mgl32.Vec3{0, 1, 0})
+	assert.Greater(t, toward.X(), side.X())
+	assert.Greater(t, side.X(), away.X())
+	assert.InDelta(t, evaluateSH(sh, mgl32.Vec3{0, 0, 1}).X(), side.X(), 1e-4, "the light is symmetric about the X axis")
+}
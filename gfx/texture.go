@@ -1,10 +1,14 @@
 package gfx
 
 import (
+	"errors"
 	"fmt"
 	"image"
+	"image/color"
 	"image/draw"
 	_ "image/png" // Required for image/png to work..
+	"io/fs"
+	"log"
 	"strings"
 
 	"github.com/brandonnelson3/GoRender/loader"
@@ -21,22 +25,100 @@ func LoadTexture(file string) (uint32, error) {
 	return 0, fmt.Errorf("Attempted to load texture from unsupported file type: %v", file)
 }
 
-// fromPng builds a texture from the provided Png file.
-func fromPng(file string) (uint32, error) {
+// LoadTextureArray loads the provided files into the layers of a texture array, in order. Every file must be a png of the same size.
+// A file which doesn't exist is replaced by a layer of the matching color in fallbacks, if it has one.
+func LoadTextureArray(files []string, fallbacks []color.RGBA) (uint32, error) {
+	if len(files) == 0 {
+		return 0, fmt.Errorf("Attempted to load texture array without any files")
+	}
+	layers := make([]*image.RGBA, len(files))
+	var loaded *image.RGBA
+	for i, file := range files {
+		if !strings.HasSuffix(file, pngExt) {
+			return 0, fmt.Errorf("Attempted to load texture from unsupported file type: %v", file)
+		}
+		rgba, err := decodePng(file)
+		if errors.Is(err, fs.ErrNotExist) && i < len(fallbacks) {
+			log.Printf("warning: %v, using a solid color layer instead", err)
+			continue
+		}
+		if err != nil {
+			return 0, err
+		}
+		if loaded != nil && rgba.Rect.Size() != loaded.Rect.Size() {
+			return 0, fmt.Errorf("texture array layer %v is %v, expected %v", file, rgba.Rect.Size(), loaded.Rect.Size())
+		}
+		layers[i], loaded = rgba, rgba
+	}
+	// Solid layers match the size of the loaded ones.
+	bounds := image.Rect(0, 0, 1, 1)
+	if loaded != nil {
+		bounds = loaded.Rect
+	}
+	for i, rgba := range layers {
+		if rgba == nil {
+			layers[i] = image.NewRGBA(bounds)
+			draw.Draw(layers[i], bounds, &image.Uniform{fallbacks[i]}, image.Point{}, draw.Src)
+		}
+	}
+
+	size := layers[0].Rect.Size()
+	var texture uint32
+	gl.GenTextures(1, &texture)
+	gl.ActiveTexture(gl.TEXTURE0)
+	gl.BindTexture(gl.TEXTURE_2D_ARRAY, texture)
+	gl.TexParameteri(gl.TEXTURE_2D_ARRAY, gl.TEXTURE_MIN_FILTER, gl.LINEAR_MIPMAP_LINEAR)
+	gl.TexParameteri(gl.TEXTURE_2D_ARRAY, gl.TEXTURE_MAG_FILTER, gl.LINEAR)
+	gl.TexParameteri(gl.TEXTURE_2D_ARRAY, gl.TEXTURE_WRAP_S, gl.REPEAT)
+	gl.TexParameteri(gl.TEXTURE_2D_ARRAY, gl.TEXTURE_WRAP_T, gl.REPEAT)
+	gl.TexImage3D(gl.TEXTURE_2D_ARRAY, 0, gl.RGBA, int32(size.X), int32(size.Y), int32(len(layers)), 0, gl.RGBA, gl.UNSIGNED_BYTE, nil)
+	for i, rgba := range layers {
+		gl.TexSubImage3D(gl.TEXTURE_2D_ARRAY, 0, 0, 0, int32(i), int32(size.X), int32(size.Y), 1, gl.RGBA, gl.UNSIGNED_BYTE, gl.Ptr(rgba.Pix))
+	}
+	gl.GenerateMipmap(gl.TEXTURE_2D_ARRAY)
+	gl.BindTexture(gl.TEXTURE_2D_ARRAY, 0)
+
+	return texture, nil
+}
+
+// NewSolidTexture creates a 1x1 texture of the provided color.
+func NewSolidTexture(c color.RGBA) uint32 {
+	pix := []uint8{c.R, c.G, c.B, c.A}
+	var texture uint32
+	gl.GenTextures(1, &texture)
+	gl.ActiveTexture(gl.TEXTURE0)
+	gl.BindTexture(gl.TEXTURE_2D, texture)
+	gl.TexParameteri(gl.TEXTURE_2D, gl.TEXTURE_MIN_FILTER, gl.NEAREST)
+	gl.TexParameteri(gl.TEXTURE_2D, gl.TEXTURE_MAG_FILTER, gl.NEAREST)
+	gl.TexImage2D(gl.TEXTURE_2D, 0, gl.RGBA, 1, 1, 0, gl.RGBA, gl.UNSIGNED_BYTE, gl.Ptr(pix))
+	return texture
+}
+
+// decodePng decodes the provided Png file into RGBA pixels.
+func decodePng(file string) (*image.RGBA, error) {
 	r, err := loader.Load(file)
 	if err != nil {
-		return 0, err
+		return nil, err
 	}
 	img, _, err := image.Decode(r)
 	if err != nil {
-		return 0, err
+		return nil, err
 	}
 
 	rgba := image.NewRGBA(img.Bounds())
 	if rgba.Stride != rgba.Rect.Size().X*4 {
-		return 0, fmt.Errorf("unsupported stride")
+		return nil, fmt.Errorf("unsupported stride")
 	}
 	draw.Draw(rgba, rgba.Bounds(), img, image.Point{0, 0}, draw.Src)
+	return rgba, nil
+}
+
+// fromPng builds a texture from the provided Png file.
+func fromPng(file string) (uint32, error) {
+	rgba, err := decodePng(file)
+	if err != nil {
+		return 0, err
+	}
 
 	var texture uint32
 	gl.GenTextures(1, &texture)
@@ -0,0 +1,49 @@
+package uniforms
+
+import (
+	"reflect"
+)
+
+// location is where a uniform is in a program.
+type location struct {
+	program uint32
+	uniform int32
+}
+
+// binding is the location a uniform is set at, and the locations of the same uniform in the programs mirroring it.
+type binding struct {
+	program uint32
+	uniform int32
+	mirrors []location
+}
+
+// mirrored is every uniform, whose binding can be mirrored to another program.
+type mirrored interface {
+	bound() *binding
+}
+
+func (b *binding) bound() *binding {
+	return b
+}
+
+// Mirror makes every uniform in src, a pointer to a struct of uniforms, also set the matching uniform of dst,
+// a pointer to a struct of the same type looked up in another program. Programs which share most of their
+// uniforms stay in step this way, without reading any back.
+func Mirror(src, dst any) {
+	s, d := reflect.ValueOf(src).Elem(), reflect.ValueOf(dst).Elem()
+	for i := range s.NumField() {
+		if !s.Type().Field(i).IsExported() || s.Field(i).Kind() != reflect.Pointer || s.Field(i).IsNil() {
+			continue
+		}
+		from, ok := s.Field(i).Interface().(mirrored)
+		if !ok || d.Field(i).IsNil() {
+			continue
+		}
+		to := d.Field(i).Interface().(mirrored).bound()
+		// Uniforms the other program doesn't use have no location to set.
+		if to.uniform < 0 {
+			continue
+		}
+		from.bound().mirrors = append(from.bound().mirrors, location{to.program, to.uniform})
+	}
+}
@@ -6,16 +6,18 @@ import (
 
 // Float is a wrapper around a program/uniform for binding.
 type Float struct {
-	program uint32
-	uniform int32
+	binding
 }
 
 // NewFloat instantiates a Float for the provided program and uniform location.
 func NewFloat(p uint32, u int32) *Float {
-	return &Float{p, u}
+	return &Float{binding{program: p, uniform: u}}
 }
 
 // Set sets this Float to the provided data, and updates the uniform data.
 func (m *Float) Set(f float32) {
 	gl.ProgramUniform1f(m.program, m.uniform, f)
+	for _, o := range m.mirrors {
+		gl.ProgramUniform1f(o.program, o.uniform, f)
+	}
 }
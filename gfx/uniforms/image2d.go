@@ -0,0 +1,26 @@
+package uniforms
+
+import (
+	"github.com/go-gl/gl/v4.5-core/gl"
+)
+
+// Image2D binds a level of a 2D texture to an image unit for load/store access from a shader.
+// The GLSL uniform is declared as: layout(r32f) uniform image2D name;
+type Image2D struct {
+	binding
+}
+
+// NewImage2D instantiates an Image2D for the provided program and uniform location.
+func NewImage2D(p uint32, u int32) *Image2D {
+	return &Image2D{binding{program: p, uniform: u}}
+}
+
+// Set binds the given level of the texture to the given image unit with the provided access
+// (e.g. gl.WRITE_ONLY) and format (e.g. gl.R32F), and sets the uniform to that unit.
+func (m *Image2D) Set(unit uint32, textureID uint32, level int32, access uint32, format uint32) {
+	gl.BindImageTexture(unit, textureID, level, false, 0, access, format)
+	gl.ProgramUniform1i(m.program, m.uniform, int32(unit))
+	for _, o := range m.mirrors {
+		gl.ProgramUniform1i(o.program, o.uniform, int32(unit))
+	}
+}
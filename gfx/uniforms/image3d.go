@@ -0,0 +1,26 @@
+package uniforms
+
+import (
+	"github.com/go-gl/gl/v4.5-core/gl"
+)
+
+// Image3D binds a 3D texture to an image unit for load/store access from a shader.
+// The GLSL uniform is declared as: layout(rgba16f) uniform image3D name;
+type Image3D struct {
+	binding
+}
+
+// NewImage3D instantiates an Image3D for the provided program and uniform location.
+func NewImage3D(p uint32, u int32) *Image3D {
+	return &Image3D{binding{program: p, uniform: u}}
+}
+
+// Set binds every layer of level 0 of the texture to the given image unit with the provided
+// access (e.g. gl.WRITE_ONLY) and format (e.g. gl.RGBA16F), and sets the uniform to that unit.
+func (m *Image3D) Set(unit uint32, textureID uint32, access uint32, format uint32) {
+	gl.BindImageTexture(unit, textureID, 0, true, 0, access, format)
+	gl.ProgramUniform1i(m.program, m.uniform, int32(unit))
+	for _, o := range m.mirrors {
+		gl.ProgramUniform1i(o.program, o.uniform, int32(unit))
+	}
+}
@@ -6,16 +6,18 @@ import (
 
 // Int is a wrapper around a program/uniform for binding.
 type Int struct {
-	program uint32
-	uniform int32
+	binding
 }
 
 // NewInt instantiates a Int for the provided program and uniform location.
 func NewInt(p uint32, u int32) *Int {
-	return &Int{p, u}
+	return &Int{binding{program: p, uniform: u}}
 }
 
 // Set sets this Int to the provided data, and updates the uniform data.
 func (m *Int) Set(i int32) {
 	gl.ProgramUniform1i(m.program, m.uniform, i)
+	for _, o := range m.mirrors {
+		gl.ProgramUniform1i(o.program, o.uniform, i)
+	}
 }
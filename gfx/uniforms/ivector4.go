@@ -9,16 +9,18 @@ type IVec4 [4]int32
 
 // IVector4 is a wrapper around a program/uniform for binding.
 type IVector4 struct {
-	program uint32
-	uniform int32
+	binding
 }
 
 // NewIVector4 instantiates a IVector4 for the provided program and uniform location.
 func NewIVector4(p uint32, u int32) *IVector4 {
-	return &IVector4{p, u}
+	return &IVector4{binding{program: p, uniform: u}}
 }
 
 // Set sets this Vector4 to the provided data, and updates the uniform data.
 func (m *IVector4) Set(nv IVec4) {
 	gl.ProgramUniform4iv(m.program, m.uniform, 1, &nv[0])
+	for _, o := range m.mirrors {
+		gl.ProgramUniform4iv(o.program, o.uniform, 1, &nv[0])
+	}
 }
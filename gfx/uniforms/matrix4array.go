@@ -6,16 +6,18 @@ import (
 
 // Matrix4Array is a wrapper around an array of mgl32.Mat4, and a program/uniform for binding.
 type Matrix4Array struct {
-	program uint32
-	uniform int32
+	binding
 }
 
 // NewMatrix4Array instantiates a matrix array for the provided program and uniform location.
 func NewMatrix4Array(p uint32, u int32) *Matrix4Array {
-	return &Matrix4Array{p, u}
+	return &Matrix4Array{binding{program: p, uniform: u}}
 }
 
 // Set sets this Matrix4 to the provided data, and updates the uniform data.
 func (m *Matrix4Array) Set(first *float32, numberOfMatrices int32) {
 	gl.ProgramUniformMatrix4fv(m.program, m.uniform, numberOfMatrices, false, first)
+	for _, o := range m.mirrors {
+		gl.ProgramUniformMatrix4fv(o.program, o.uniform, numberOfMatrices, false, first)
+	}
 }
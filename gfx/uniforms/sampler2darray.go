@@ -0,0 +1,25 @@
+package uniforms
+
+import (
+	"github.com/go-gl/gl/v4.5-core/gl"
+)
+
+// Sampler2DArray is a wrapper around a int32 which is the sampler texture id, and a program/uniform for binding.
+type Sampler2DArray struct {
+	binding
+}
+
+// NewSampler2DArray instantiates a sampler2darray for the provided program, and uniform location.
+func NewSampler2DArray(p uint32, u int32) *Sampler2DArray {
+	return &Sampler2DArray{binding{program: p, uniform: u}}
+}
+
+// Set sets this Sampler2DArray to the provided id, and updates the uniform data.
+func (m *Sampler2DArray) Set(texture int, slot int32, samplerID uint32) {
+	gl.ActiveTexture(uint32(texture))
+	gl.ProgramUniform1i(m.program, m.uniform, slot)
+	for _, o := range m.mirrors {
+		gl.ProgramUniform1i(o.program, o.uniform, slot)
+	}
+	gl.BindTexture(gl.TEXTURE_2D_ARRAY, samplerID)
+}
@@ -0,0 +1,25 @@
+package uniforms
+
+import (
+	"github.com/go-gl/gl/v4.5-core/gl"
+)
+
+// Sampler3D is a wrapper around a int32 which is the sampler texture id, and a program/uniform for binding.
+type Sampler3D struct {
+	binding
+}
+
+// NewSampler3D instantiates a sampler3d for the provided program, and uniform location.
+func NewSampler3D(p uint32, u int32) *Sampler3D {
+	return &Sampler3D{binding{program: p, uniform: u}}
+}
+
+// Set sets this Sampler3D to the provided id, and updates the uniform data.
+func (m *Sampler3D) Set(texture int, slot int32, samplerID uint32) {
+	gl.ActiveTexture(uint32(texture))
+	gl.ProgramUniform1i(m.program, m.uniform, slot)
+	for _, o := range m.mirrors {
+		gl.ProgramUniform1i(o.program, o.uniform, slot)
+	}
+	gl.BindTexture(gl.TEXTURE_3D, samplerID)
+}
@@ -6,18 +6,20 @@ import (
 
 // SamplerCube is a wrapper around a cubemap texture uniform.
 type SamplerCube struct {
-	program uint32
-	uniform int32
+	binding
 }
 
 // NewSamplerCube instantiates a SamplerCube for the provided program and uniform location.
 func NewSamplerCube(p uint32, u int32) *SamplerCube {
-	return &SamplerCube{p, u}
+	return &SamplerCube{binding{program: p, uniform: u}}
 }
 
 // Set binds a cubemap texture to the given texture unit/slot.
 func (m *SamplerCube) Set(texture int, slot int32, samplerID uint32) {
 	gl.ActiveTexture(uint32(texture))
 	gl.ProgramUniform1i(m.program, m.uniform, slot)
+	for _, o := range m.mirrors {
+		gl.ProgramUniform1i(o.program, o.uniform, slot)
+	}
 	gl.BindTexture(gl.TEXTURE_CUBE_MAP, samplerID)
 }
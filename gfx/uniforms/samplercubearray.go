@@ -7,13 +7,12 @@ import (
 // SamplerCubeArray binds an array of cubemap textures to consecutive texture units.
 // The GLSL uniform is declared as: uniform samplerCube name[N];
 type SamplerCubeArray struct {
-	program uint32
-	uniform int32
+	binding
 }
 
 // NewSamplerCubeArray instantiates a SamplerCubeArray for the provided program and uniform location.
 func NewSamplerCubeArray(p uint32, u int32) *SamplerCubeArray {
-	return &SamplerCubeArray{p, u}
+	return &SamplerCubeArray{binding{program: p, uniform: u}}
 }
 
 // Set binds each cubemap in samplerIDs to successive texture units starting at
@@ -28,5 +27,8 @@ func (m *SamplerCubeArray) Set(baseTexture int, baseSlot int32, samplerIDs []uin
 	}
 	if len(slots) > 0 {
 		gl.ProgramUniform1iv(m.program, m.uniform, int32(len(slots)), &slots[0])
+		for _, o := range m.mirrors {
+			gl.ProgramUniform1iv(o.program, o.uniform, int32(len(slots)), &slots[0])
+		}
 	}
 }
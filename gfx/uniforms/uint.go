@@ -6,16 +6,18 @@ import (
 
 // UInt is a wrapper around a program/uniform for binding.
 type UInt struct {
-	program uint32
-	uniform int32
+	binding
 }
 
 // NewUInt instantiates a UInt for the provided program and uniform location.
 func NewUInt(p uint32, u int32) *UInt {
-	return &UInt{p, u}
+	return &UInt{binding{program: p, uniform: u}}
 }
 
 // Set sets this UInt to the provided data, and updates the uniform data.
 func (m *UInt) Set(i uint32) {
 	gl.ProgramUniform1ui(m.program, m.uniform, i)
+	for _, o := range m.mirrors {
+		gl.ProgramUniform1ui(o.program, o.uniform, i)
+	}
 }
@@ -9,16 +9,18 @@ type UIVec3 [3]uint32
 
 // UIVector3 is a wrapper around a program/uniform for binding.
 type UIVector3 struct {
-	program uint32
-	uniform int32
+	binding
 }
 
 // NewUIVector3 instantiates a UIVector3 for the provided program and uniform location.
 func NewUIVector3(p uint32, u int32) *UIVector3 {
-	return &UIVector3{p, u}
+	return &UIVector3{binding{program: p, uniform: u}}
 }
 
 // Set sets this UIVector3 to the provided data, and updates the uniform data.
 func (m *UIVector3) Set(nv UIVec3) {
 	gl.ProgramUniform3uiv(m.program, m.uniform, 1, &nv[0])
+	for _, o := range m.mirrors {
+		gl.ProgramUniform3uiv(o.program, o.uniform, 1, &nv[0])
+	}
 }
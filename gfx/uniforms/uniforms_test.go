@@ -20,6 +20,20 @@ func TestNewFloatArray(t *testing.T) {
 	assert.Equal(t, int32(2), u.uniform)
 }
 
+func TestNewImage2D(t *testing.T) {
+	u := NewImage2D(1, 2)
+	assert.NotNil(t, u)
+	assert.Equal(t, uint32(1), u.program)
+	assert.Equal(t, int32(2), u.uniform)
+}
+
+func TestNewImage3D(t *testing.T) {
+	u := NewImage3D(1, 2)
+	assert.NotNil(t, u)
+	assert.Equal(t, uint32(1), u.program)
+	assert.Equal(t, int32(2), u.uniform)
+}
+
 func TestNewInt(t *testing.T) {
 	u := NewInt(1, 2)
 	assert.NotNil(t, u)
@@ -69,6 +83,20 @@ func TestNewSampler2D(t *testing.T) {
 	assert.Equal(t, int32(2), u.uniform)
 }
 
+func TestNewSampler2DArray(t *testing.T) {
+	u := NewSampler2DArray(1, 2)
+	assert.NotNil(t, u)
+	assert.Equal(t, uint32(1), u.program)
+	assert.Equal(t, int32(2), u.uniform)
+}
+
+func TestNewSampler3D(t *testing.T) {
+	u := NewSampler3D(1, 2)
+	assert.NotNil(t, u)
+	assert.Equal(t, uint32(1), u.program)
+	assert.Equal(t, int32(2), u.uniform)
+}
+
 func TestNewUInt(t *testing.T) {
 	u := NewUInt(1, 2)
 	assert.NotNil(t, u)
@@ -117,3 +145,21 @@ func TestNewVector4(t *testing.T) {
 	assert.Equal(t, uint32(1), u.program)
 	assert.Equal(t, int32(2), u.uniform)
 }
+
+func TestMirror(t *testing.T) {
+	type shader struct {
+		A       *Float
+		B       *Matrix4
+		Unused  *Int
+		Missing *Vector3
+		Other   int
+	}
+	src := &shader{A: NewFloat(1, 2), B: NewMatrix4(1, 3), Unused: NewInt(1, 4), Missing: NewVector3(1, 5)}
+	dst := &shader{A: NewFloat(6, 7), B: NewMatrix4(6, 8), Unused: NewInt(6, -1)}
+	Mirror(src, dst)
+	assert.Equal(t, []location{{6, 7}}, src.A.mirrors)
+	assert.Equal(t, []location{{6, 8}}, src.B.mirrors)
+	assert.Empty(t, src.Unused.mirrors)
+	assert.Empty(t, src.Missing.mirrors)
+	assert.Empty(t, dst.A.mirrors)
+}
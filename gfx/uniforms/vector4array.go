@@ -0,0 +1,24 @@
+package uniforms
+
+import (
+	"github.com/go-gl/gl/v4.5-core/gl"
+)
+
+// Vector4Array is a wrapper around an array of vec4 uniforms, and a program/uniform for binding.
+type Vector4Array struct {
+	binding
+}
+
+// NewVector4Array instantiates a Vector4Array for the provided program and uniform location.
+func NewVector4Array(p uint32, u int32) *Vector4Array {
+	return &Vector4Array{binding{program: p, uniform: u}}
+}
+
+// Set sets this Vector4Array uniform to the provided slice, and updates the uniform data.
+// first must point to the first float32 of the contiguous data (e.g. &vec4s[0][0]).
+func (m *Vector4Array) Set(first *float32, count int32) {
+	gl.ProgramUniform4fv(m.program, m.uniform, count, first)
+	for _, o := range m.mirrors {
+		gl.ProgramUniform4fv(o.program, o.uniform, count, first)
+	}
+}
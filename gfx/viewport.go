@@ -0,0 +1,146 @@
+package gfx
+
+import (
+	"unsafe"
+
+	"github.com/brandonnelson3/GoRender/benchmark"
+
+	"github.com/go-gl/gl/v4.5-core/gl"
+)
+
+// Viewport is a rectangle of the window the scene is rendered into from a camera. Each viewport has its own
+// depth pre-pass and light culling tiles, and is rendered into a texture of its own before being drawn.
+type Viewport struct {
+	Camera *Camera
+	// X, Y, Width and Height are the rectangle of the window the viewport covers, in pixels from its bottom left.
+	X, Y, Width, Height int32
+	// Offscreen leaves the rendered image in the viewport's texture instead of drawing it to the window.
+	Offscreen bool
+
+	// target, depthMap and visibleLightIndices are allocated at the size the viewport was last rendered at.
+	target                *OffscreenFBO
+	depthMapFBO, depthMap uint32
+	visibleLightIndices   uint32
+	occlusion             hiZ
+}
+
+// NewViewport returns a viewport rendering camera into the given rectangle of the window.
+func NewViewport(camera *Camera, x, y, width, height int32) *Viewport {
+	return &Viewport{Camera: camera, X: x, Y: y, Width: width, Height: height}
+}
+
+// SplitScreen returns viewports rendering cameras side by side across the window.
+func SplitScreen(cameras ...*Camera) []*Viewport {
+	viewports := make([]*Viewport, len(cameras))
+	width := int32(Window.Width) / int32(len(cameras))
+	for i, c := range cameras {
+		viewports[i] = NewViewport(c, int32(i)*width, 0, width, int32(Window.Height))
+	}
+	return viewports
+}
+
+// Texture returns the texture the viewport was last rendered into, or zero if it hasn't been rendered.
+func (v *Viewport) Texture() uint32 {
+	if v.target == nil {
+		return 0
+	}
+	return v.target.colorTex
+}
+
+// allocate creates the viewport's render target and buffers at its current size, if they aren't already.
+func (v *Viewport) allocate() error {
+	if v.target != nil && v.target.Width == v.Width && v.target.Height == v.Height {
+		return nil
+	}
+	v.Delete()
+
+	target, err := NewOffscreenFBO(v.Width, v.Height)
+	if err != nil {
+		return err
+	}
+	v.target = target
+
+	gl.GenFramebuffers(1, &v.depthMapFBO)
+	gl.GenTextures(1, &v.depthMap)
+	gl.BindTexture(gl.TEXTURE_2D, v.depthMap)
+	gl.TexImage2D(gl.TEXTURE_2D, 0, gl.DEPTH_COMPONENT, v.Width, v.Height, 0, gl.DEPTH_COMPONENT, gl.FLOAT, nil)
+	gl.TexParameteri(gl.TEXTURE_2D, gl.TEXTURE_MIN_FILTER, gl.NEAREST)
+	gl.TexParameteri(gl.TEXTURE_2D, gl.TEXTURE_MAG_FILTER, gl.NEAREST)
+	gl.TexParameteri(gl.TEXTURE_2D, gl.TEXTURE_WRAP_S, gl.CLAMP_TO_BORDER)
+	gl.TexParameteri(gl.TEXTURE_2D, gl.TEXTURE_WRAP_T, gl.CLAMP_TO_BORDER)
+	borderColor := []float32{1.0, 1.0, 1.0, 1.0}
+	gl.TexParameterfv(gl.TEXTURE_2D, gl.TEXTURE_BORDER_COLOR, &borderColor[0])
+	gl.BindTexture(gl.TEXTURE_2D, 0)
+	gl.BindFramebuffer(gl.FRAMEBUFFER, v.depthMapFBO)
+	gl.FramebufferTexture2D(gl.FRAMEBUFFER, gl.DEPTH_ATTACHMENT, gl.TEXTURE_2D, v.depthMap, 0)
+	gl.DrawBuffer(gl.NONE)
+	gl.ReadBuffer(gl.NONE)
+	gl.BindFramebuffer(gl.FRAMEBUFFER, 0)
+
+	tiles := int((v.Width + tileSize - 1) / tileSize * ((v.Height + tileSize - 1) / tileSize))
+	gl.GenBuffers(1, &v.visibleLightIndices)
+	gl.BindBuffer(gl.SHADER_STORAGE_BUFFER, v.visibleLightIndices)
+	gl.BufferData(gl.SHADER_STORAGE_BUFFER, tiles*int(unsafe.Sizeof(VisibleIndex{}))*MaximumPointLights, nil, gl.STATIC_DRAW)
+	gl.BindBuffer(gl.SHADER_STORAGE_BUFFER, 0)
+	return nil
+}
+
+// Delete frees the GL resources of the viewport. It is allocated again if it is rendered afterwards.
+func (v *Viewport) Delete() {
+	if v.target == nil {
+		return
+	}
+	v.target.Delete()
+	v.occlusion.Delete()
+	gl.DeleteFramebuffers(1, &v.depthMapFBO)
+	gl.DeleteTextures(1, &v.depthMap)
+	gl.DeleteBuffers(1, &v.visibleLightIndices)
+	v.target = nil
+}
+
+// RenderViewports renders the scene into each of viewports from its camera, sharing the culling camera's
+// shadows between them, and draws those which aren't offscreen to the window.
+func (renderer *r) RenderViewports(sky Sky, renderables []Renderable, viewports []*Viewport) error {
+	views := make([]*view, len(viewports))
+	for i, vp := range viewports {
+		if err := vp.allocate(); err != nil {
+			return err
+		}
+		vp.Camera.viewAspect = float32(vp.Width) / float32(vp.Height)
+		views[i] = &view{
+			camera:              vp.Camera,
+			width:               vp.Width,
+			height:              vp.Height,
+			framebuffer:         vp.target.fbo,
+			depthMapFBO:         vp.depthMapFBO,
+			depthMap:            vp.depthMap,
+			visibleLightIndices: vp.visibleLightIndices,
+			occlusion:           &vp.occlusion,
+			counted:             i == 0,
+		}
+	}
+
+	waters := renderer.prepareScene(renderables, views)
+	renderer.renderCascades()
+	for _, v := range views {
+		renderer.renderView(v, sky, waters)
+	}
+	countOcclusion(renderer.occlusionTested, renderer.occlusionCulled)
+
+	benchmark.Start("Render: Viewports")
+	gl.BindFramebuffer(gl.DRAW_FRAMEBUFFER, renderer.TargetFramebuffer)
+	gl.Viewport(0, 0, int32(Window.Width), int32(Window.Height))
+	gl.Clear(gl.COLOR_BUFFER_BIT | gl.DEPTH_BUFFER_BIT)
+	for _, vp := range viewports {
+		if vp.Offscreen {
+			continue
+		}
+		gl.BindFramebuffer(gl.READ_FRAMEBUFFER, vp.target.fbo)
+		gl.BlitFramebuffer(0, 0, vp.Width, vp.Height, vp.X, vp.Y, vp.X+vp.Width, vp.Y+vp.Height, gl.COLOR_BUFFER_BIT, gl.NEAREST)
+	}
+	gl.BindFramebuffer(gl.FRAMEBUFFER, renderer.TargetFramebuffer)
+	benchmark.End("Render: Viewports")
+
+	renderer.renderOverlays()
+	return nil
+}
@@ -0,0 +1,319 @@
+package gfx
+
+import (
+	"math"
+
+	"github.com/brandonnelson3/GoRender/gfx/shaders"
+
+	"github.com/go-gl/gl/v4.5-core/gl"
+	"github.com/go-gl/mathgl/mgl32"
+)
+
+const (
+	// waterNormalMapSize is the resolution of the tiling normal map scrolled across the water.
+	waterNormalMapSize = 256
+	// waterTargetDivisor is how much smaller than the window the reflection and refraction textures are.
+	waterTargetDivisor = 2
+	// waterClipBias pushes the clip plane slightly past the surface to hide seams at the waterline.
+	waterClipBias = 0.1
+)
+
+// waterNormalWaves are the integer frequencies, slopes and phases of the waves summed into the
+// water normal map. Integer frequencies keep the map tileable.
+var waterNormalWaves = []struct {
+	kx, ky       float64
+	slope, phase float64
+}{
+	{1, 2, 0.25, 0},
+	{3, -1, 0.2, 1.3},
+	{-2, 5, 0.15, 2.1},
+	{7, 3, 0.1, 0.4},
+	{-5, -9, 0.08, 4.2},
+	{11, -6, 0.06, 3.3},
+}
+
+// Water is a Renderable flat water surface at SeaLevel, which follows the active camera. It reflects
+// and refracts the rest of the scene through textures rendered by the renderer before the main pass.
+type Water struct {
+	// SeaLevel is the height of the water surface.
+	SeaLevel float32
+	// Extent is the distance from the camera to the edge of the water surface.
+	Extent float32
+	// ShallowColor tints the refracted scene, DeepColor is what deep water fades toward.
+	ShallowColor, DeepColor mgl32.Vec3
+	// ShorelineDepth is the thickness of water, along the view ray, at which it becomes fully DeepColor.
+	ShorelineDepth float32
+	// WaveScale is the normal map repeats per unit, WaveSpeed how fast it scrolls and WaveStrength how bumpy it is.
+	WaveScale, WaveSpeed, WaveStrength float32
+
+	vao, vbo uint32
+
+	waterShader *shaders.WaterShader
+
+	normalMap uint32
+
+	reflection, refraction waterTarget
+
+	time float32
+}
+
+// waterTarget is a color texture with a depth buffer that part of the scene is rendered into for the water.
+type waterTarget struct {
+	fbo, color, depth uint32
+	width, height     int32
+}
+
+// NewWater instantiates a water surface at the given sea level.
+func NewWater(seaLevel float32) (*Water, error) {
+	waterShader, err := shaders.NewWaterShader()
+	if err != nil {
+		return nil, err
+	}
+
+	// A quad spanning [-1, 1] in x and z, scaled and moved under the camera by the model matrix.
+	vertices := []mgl32.Vec3{
+		{-1, 0, -1},
+		{-1, 0, 1},
+		{1, 0, 1},
+		{-1, 0, -1},
+		{1, 0, 1},
+		{1, 0, -1},
+	}
+
+	var vao uint32
+	gl.GenVertexArrays(1, &vao)
+	gl.BindVertexArray(vao)
+	var vbo uint32
+	gl.GenBuffers(1, &vbo)
+	gl.BindBuffer(gl.ARRAY_BUFFER, vbo)
+	gl.BufferData(gl.ARRAY_BUFFER, len(vertices)*3*4, gl.Ptr(vertices), gl.STATIC_DRAW)
+	gl.EnableVertexAttribArray(0)
+	gl.VertexAttribPointer(0, 3, gl.FLOAT, false, 3*4, gl.PtrOffset(0))
+	gl.BindVertexArray(0)
+
+	var normalMap uint32
+	gl.GenTextures(1, &normalMap)
+	gl.BindTexture(gl.TEXTURE_2D, normalMap)
+	gl.TexImage2D(gl.TEXTURE_2D, 0, gl.RGBA8, waterNormalMapSize, waterNormalMapSize, 0, gl.RGBA, gl.UNSIGNED_BYTE, gl.Ptr(waterNormalMapPixels(waterNormalMapSize)))
+	gl.GenerateMipmap(gl.TEXTURE_2D)
+	gl.TexParameteri(gl.TEXTURE_2D, gl.TEXTURE_MIN_FILTER, gl.LINEAR_MIPMAP_LINEAR)
+	gl.TexParameteri(gl.TEXTURE_2D, gl.TEXTURE_MAG_FILTER, gl.LINEAR)
+	gl.TexParameteri(gl.TEXTURE_2D, gl.TEXTURE_WRAP_S, gl.REPEAT)
+	gl.TexParameteri(gl.TEXTURE_2D, gl.TEXTURE_WRAP_T, gl.REPEAT)
+	gl.BindTexture(gl.TEXTURE_2D, 0)
+
+	return &Water{
+		SeaLevel:       seaLevel,
+		Extent:         2000,
+		ShallowColor:   mgl32.Vec3{0.75, 0.9, 0.9},
+		DeepColor:      mgl32.Vec3{0.02, 0.08, 0.12},
+		ShorelineDepth: 6,
+		WaveScale:      0.05,
+		WaveSpeed:      0.02,
+		WaveStrength:   0.4,
+		vao:            vao,
+		vbo:            vbo,
+		waterShader:    waterShader,
+		normalMap:      normalMap,
+	}, nil
+}
+
+// SetTime sets the time in seconds that the waves are animated to.
+func (w *Water) SetTime(seconds float64) {
+	w.time = float32(seconds)
+}
+
+// center returns the point on the water surface below the active camera.
+func (w *Water) center() mgl32.Vec3 {
+	p := ActiveCamera.GetPosition()
+	return mgl32.Vec3{p.X(), w.SeaLevel, p.Z()}
+}
+
+// reflectionView returns the active camera's view mirrored about the water surface.
+func (w *Water) reflectionView() mgl32.Mat4 {
+	mirror := mgl32.Translate3D(0, w.SeaLevel, 0).Mul4(mgl32.Scale3D(1, -1, 1)).Mul4(mgl32.Translate3D(0, -w.SeaLevel, 0))
+	return ActiveCamera.GetView().Mul4(mirror)
+}
+
+// Render draws the water surface with the reflection and refraction textures, which must have
+// been rendered this frame. The color shader is left in use afterwards.
+func (w *Water) Render(colorShader *shaders.ColorShader, frustum *Frustum) {
+	if frustum != nil {
+		min, max := w.GetBounds()
+		if !frustum.IsBoxIn(min, max) {
+			return
+		}
+	}
+
+	c := w.center()
+	ws := w.waterShader
+	ws.Use()
+	ws.Projection.Set(ActiveCamera.GetProjection())
+	ws.View.Set(ActiveCamera.GetView())
+	ws.Model.Set(mgl32.Translate3D(c.X(), c.Y(), c.Z()).Mul4(mgl32.Scale3D(w.Extent, 1, w.Extent)))
+	ws.ReflectionMap.Set(gl.TEXTURE11, 11, w.reflection.color)
+	ws.RefractionMap.Set(gl.TEXTURE12, 12, w.refraction.color)
+	ws.DepthMap.Set(gl.TEXTURE5, 5, Renderer.view.depthMap)
+	ws.NormalMap.Set(gl.TEXTURE13, 13, w.normalMap)
+	width, height := viewSize()
+	ws.ScreenSize.Set(mgl32.Vec2{float32(width), float32(height)})
+	ws.ZNear.Set(ActiveCamera.projection.Near)
+	ws.ZFar.Set(ActiveCamera.projection.Far)
+	ws.CameraPosition.Set(ActiveCamera.GetPosition())
+	ws.Time.Set(w.time)
+	ws.WaveScale.Set(w.WaveScale)
+	ws.WaveSpeed.Set(w.WaveSpeed)
+	ws.WaveStrength.Set(w.WaveStrength)
+	ws.ShallowColor.Set(w.ShallowColor)
+	ws.DeepColor.Set(w.DeepColor)
+	ws.ShorelineDepth.Set(w.ShorelineDepth)
+	ws.DirectionalLightBuffer.Set(GetDirectionalLightBuffer())
+
+	gl.Disable(gl.CULL_FACE)
+	gl.BindVertexArray(w.vao)
+	gl.DrawArrays(gl.TRIANGLES, 0, 2*3)
+	gl.BindVertexArray(0)
+	gl.Enable(gl.CULL_FACE)
+
+	colorShader.Use()
+}
+
+// RenderDepth does nothing, water neither casts shadows nor hides the ground from the depth pre-pass.
+func (w *Water) RenderDepth(depthShader *shaders.DepthShader, frustum *Frustum) {}
+
+// RenderPointLightDepth does nothing, water doesn't cast shadows.
+func (w *Water) RenderPointLightDepth(shader *shaders.PointLightShadowShader, frustum *Frustum) {}
+
+// GetBounds returns a thin box around the water surface.
+func (w *Water) GetBounds() (mgl32.Vec3, mgl32.Vec3) {
+	c := w.center()
+	return mgl32.Vec3{c.X() - w.Extent, w.SeaLevel - waterClipBias, c.Z() - w.Extent},
+		mgl32.Vec3{c.X() + w.Extent, w.SeaLevel + waterClipBias, c.Z() + w.Extent}
+}
+
+// bindSky binds the sky's environment and the fog the water surface is seen through.
+func (w *Water) bindSky(sky Sky) {
+	ws := w.waterShader
+	ws.Use()
+	ws.EnvironmentMap.Set(gl.TEXTURE9, 9, sky.getEnvironment().prefilteredMap)
+	ws.VolumetricFog.Set(gl.TEXTURE10, 10, integratedVolume)
+	ws.VolumetricNear.Set(Fog.VolumetricNear)
+	ws.VolumetricFar.Set(Fog.VolumetricFar)
+	ws.FogDensity.Set(Fog.Density)
+	ws.FogHeightFalloff.Set(Fog.HeightFalloff)
+	ws.FogBaseHeight.Set(Fog.BaseHeight)
+	ws.FogAnisotropy.Set(Fog.Anisotropy)
+	ws.SkyAmbient.Set(sky.getEnvironment().irradianceSH[0].Mul(0.282095 * SkyLightIntensity * sky.skyLightScale()))
+}
+
+// resize reallocates the target's textures if the requested size differs from its current one.
+func (t *waterTarget) resize(width, height int32) {
+	if t.fbo != 0 && t.width == width && t.height == height {
+		return
+	}
+	if t.fbo == 0 {
+		gl.GenFramebuffers(1, &t.fbo)
+		gl.GenTextures(1, &t.color)
+		gl.GenRenderbuffers(1, &t.depth)
+	}
+	t.width, t.height = width, height
+
+	gl.BindTexture(gl.TEXTURE_2D, t.color)
+	gl.TexImage2D(gl.TEXTURE_2D, 0, gl.RGBA16F, width, height, 0, gl.RGBA, gl.FLOAT, nil)
+	gl.TexParameteri(gl.TEXTURE_2D, gl.TEXTURE_MIN_FILTER, gl.LINEAR)
+	gl.TexParameteri(gl.TEXTURE_2D, gl.TEXTURE_MAG_FILTER, gl.LINEAR)
+	gl.TexParameteri(gl.TEXTURE_2D, gl.TEXTURE_WRAP_S, gl.CLAMP_TO_EDGE)
+	gl.TexParameteri(gl.TEXTURE_2D, gl.TEXTURE_WRAP_T, gl.CLAMP_TO_EDGE)
+	gl.BindTexture(gl.TEXTURE_2D, 0)
+
+	gl.BindRenderbuffer(gl.RENDERBUFFER, t.depth)
+	gl.RenderbufferStorage(gl.RENDERBUFFER, gl.DEPTH_COMPONENT24, width, height)
+	gl.BindRenderbuffer(gl.RENDERBUFFER, 0)
+
+	gl.BindFramebuffer(gl.FRAMEBUFFER, t.fbo)
+	gl.FramebufferTexture2D(gl.FRAMEBUFFER, gl.COLOR_ATTACHMENT0, gl.TEXTURE_2D, t.color, 0)
+	gl.FramebufferRenderbuffer(gl.FRAMEBUFFER, gl.DEPTH_ATTACHMENT, gl.RENDERBUFFER, t.depth)
+	gl.BindFramebuffer(gl.FRAMEBUFFER, 0)
+}
+
+// waterNormalMapPixels returns size*size RGBA pixels of a tileable tangent space normal map,
+// built from a sum of sine waves.
+func waterNormalMapPixels(size int) []uint8 {
+	pix := make([]uint8, size*size*4)
+	for y := 0; y < size; y++ {
+		for x := 0; x < size; x++ {
+			u, v := float64(x)/float64(size), float64(y)/float64(size)
+			var dx, dy float64
+			for _, wave := range waterNormalWaves {
+				k := math.Hypot(wave.kx, wave.ky)
+				c := math.Cos(2*math.Pi*(wave.kx*u+wave.ky*v) + wave.phase)
+				dx += wave.slope * c * wave.kx / k
+				dy += wave.slope * c * wave.ky / k
+			}
+			n := mgl32.Vec3{float32(-dx), float32(-dy), 1}.Normalize()
+			i := (y*size + x) * 4
+			pix[i] = uint8((n.X()*0.5 + 0.5) * 255)
+			pix[i+1] = uint8((n.Y()*0.5 + 0.5) * 255)
+			pix[i+2] = uint8((n.Z()*0.5 + 0.5) * 255)
+			pix[i+3] = 255
+		}
+	}
+	return pix
+}
+
+// renderWaterTextures renders the scene mirrored about the water surface into the water's reflection
+// texture, and the scene below the surface into its refraction texture. Each pass draws what the scene tree
+// holds within its own frustum, as the mirrored view sees things the main view doesn't. The color shader must
+// be bound again with bindColorShader before it is used for another pass.
+func (renderer *r) renderWaterTextures(water *Water, sky Sky, numShadowLights int) {
+	viewWidth, viewHeight := viewSize()
+	width, height := int32(viewWidth)/waterTargetDivisor, int32(viewHeight)/waterTargetDivisor
+	water.reflection.resize(width, height)
+	water.refraction.resize(width, height)
+	water.bindSky(sky)
+
+	cs := renderer.colorShader
+	renderer.bindColorShader(sky, numShadowLights)
+	cs.ScreenSize.Set(mgl32.Vec2{float32(width), float32(height)})
+	gl.Viewport(0, 0, width, height)
+	gl.Enable(gl.CLIP_DISTANCE0)
+
+	// Reflection: the light tiles don't line up with a mirrored view, so only the directional
+	// light and shadowed point lights reach it. Anything left transparent is filled in from the sky.
+	gl.BindFramebuffer(gl.FRAMEBUFFER, water.reflection.fbo)
+	gl.ClearColor(0, 0, 0, 0)
+	gl.Clear(gl.COLOR_BUFFER_BIT | gl.DEPTH_BUFFER_BIT)
+	reflectionView := water.reflectionView()
+	cameraPosition := ActiveCamera.GetPosition()
+	cs.View.Set(reflectionView)
+	cs.CameraPosition.Set(mgl32.Vec3{cameraPosition.X(), 2*water.SeaLevel - cameraPosition.Y(), cameraPosition.Z()})
+	cs.ClipPlane.Set(mgl32.Vec4{0, 1, 0, -water.SeaLevel + waterClipBias})
+	cs.TiledLightsEnabled.Set(0)
+	cs.VolumetricFogEnabled.Set(0)
+	reflectionFrustum := NewFrustumFromMatrix(ActiveCamera.GetProjection().Mul4(reflectionView))
+	renderables := renderer.scene.QueryFrustum(reflectionFrustum, nil)
+	gl.FrontFace(gl.CW)
+	for _, renderable := range renderables {
+		renderable.Render(cs, reflectionFrustum)
+	}
+	gl.FrontFace(gl.CCW)
+
+	// Refraction: fog is applied to the water surface itself, not to what is seen through it.
+	gl.BindFramebuffer(gl.FRAMEBUFFER, water.refraction.fbo)
+	gl.ClearColor(0, 0, 0, 1)
+	gl.Clear(gl.COLOR_BUFFER_BIT | gl.DEPTH_BUFFER_BIT)
+	cs.View.Set(ActiveCamera.GetView())
+	cs.CameraPosition.Set(cameraPosition)
+	cs.ClipPlane.Set(mgl32.Vec4{0, -1, 0, water.SeaLevel + waterClipBias})
+	cs.TiledLightsEnabled.Set(1)
+	cs.TileCoordScale.Set(waterTargetDivisor)
+	cs.FogDensity.Set(0)
+	refractionFrustum := NewFrustumFromMatrix(ActiveCamera.GetProjection().Mul4(ActiveCamera.GetView()))
+	renderables = renderer.scene.QueryFrustum(refractionFrustum, renderables[:0])
+	for _, renderable := range renderables {
+		renderable.Render(cs, refractionFrustum)
+	}
+
+	gl.Disable(gl.CLIP_DISTANCE0)
+	gl.BindFramebuffer(gl.FRAMEBUFFER, 0)
+}
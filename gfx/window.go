@@ -1,6 +1,7 @@
 package gfx
 
 import (
+	"github.com/brandonnelson3/GoRender/input"
 	"github.com/brandonnelson3/GoRender/messagebus"
 
 	"github.com/go-gl/glfw/v3.1/glfw"
@@ -34,14 +35,14 @@ func CreateWindow(title string, width, height uint32, near, far, fov float32) {
 	}
 	Window = w{width, height, near, far, fov, window}
 
-	messagebus.RegisterType("key", handleEscape)
+	messagebus.RegisterType("action", handleQuit)
 }
 
-func handleEscape(m *messagebus.Message) {
-	pressedKeys := m.Data1.([]glfw.Key)
+func handleQuit(m *messagebus.Message) {
+	heldActions := m.Data1.([]input.Action)
 
-	for _, key := range pressedKeys {
-		if key == glfw.KeyEscape {
+	for _, action := range heldActions {
+		if action == input.Quit {
 			Window.SetShouldClose(true)
 		}
 	}
@@ -61,9 +62,9 @@ func (window *w) Resize(width, height int32) {
 	ResizePointLightBuffers()
 }
 
-// GetProjection returns the projection matrix.
-func (window *w) GetProjection() mgl32.Mat4 {
-	return mgl32.Perspective(mgl32.DegToRad(window.fieldOfViewDegrees), float32(window.Width)/float32(window.Height), window.nearPlane, window.farPlane)
+// DefaultProjection returns the projection the window was created with, which cameras project with by default.
+func (window *w) DefaultProjection() Projection {
+	return Projection{FieldOfView: window.fieldOfViewDegrees, Near: window.nearPlane, Far: window.farPlane}
 }
 
 func getPortionOfRange(near, far, nearPortion, farPortion float32) (float32, float32) {
@@ -71,11 +72,6 @@ func getPortionOfRange(near, far, nearPortion, farPortion float32) (float32, flo
 	return near + delta*nearPortion, near + delta*farPortion
 }
 
-// GetShadowCascadePerspectiveProjection returns the i-th cascade's frustum specific perspective projection matrix.
-func (window *w) GetShadowCascadePerspectiveProjection(i int) mgl32.Mat4 {
-	return mgl32.Perspective(mgl32.DegToRad(window.fieldOfViewDegrees), float32(window.Width)/float32(window.Height), ShadowSplits[i], ShadowSplits[i+1])
-}
-
 // GetNearFar returns a mgl32.Vec2 consisting of the near and far planes for the given i-th cascade.
 func (window *w) GetNearFar(i int) mgl32.Vec2 {
 	n, f := getPortionOfRange(window.nearPlane, window.farPlane, ShadowSplits[i], ShadowSplits[i+1])
@@ -0,0 +1,395 @@
+package input
+
+import (
+	"fmt"
+	"log"
+	"strings"
+	"sync"
+
+	"github.com/brandonnelson3/GoRender/messagebus"
+
+	"github.com/go-gl/glfw/v3.1/glfw"
+)
+
+// Action is something the user does, such as moving forward or toggling the HUD, which is bound to inputs.
+// Every frame an "action" message is sent with the held actions as Data1 and those pressed that frame as
+// Data2, like the "key" message for keys.
+type Action string
+
+const (
+	MoveForward Action = "MoveForward"
+	MoveBack    Action = "MoveBack"
+	MoveRight   Action = "MoveRight"
+	MoveLeft    Action = "MoveLeft"
+	MoveUp      Action = "MoveUp"
+	MoveDown    Action = "MoveDown"
+	RollRight   Action = "RollRight"
+	RollLeft    Action = "RollLeft"
+	Sprint      Action = "Sprint"
+	LookRight   Action = "LookRight"
+	LookLeft    Action = "LookLeft"
+	LookUp      Action = "LookUp"
+	LookDown    Action = "LookDown"
+
+	NextCamera        Action = "NextCamera"
+	NextController    Action = "NextController"
+	ToggleSplitScreen Action = "ToggleSplitScreen"
+	Select            Action = "Select"
+	RecordKeyframe    Action = "RecordKeyframe"
+	AddPointLight     Action = "AddPointLight"
+	RotateSunLeft     Action = "RotateSunLeft"
+	RotateSunRight    Action = "RotateSunRight"
+	Screenshot        Action = "Screenshot"
+	Quit              Action = "Quit"
+
+	ToggleFPS       Action = "ToggleFPS"
+	ToggleHUD       Action = "ToggleHUD"
+	ToggleOcclusion Action = "ToggleOcclusion"
+	ShowPip         Action = "ShowPip"
+	HidePip         Action = "HidePip"
+
+	ToggleFrustum               Action = "ToggleFrustum"
+	ToggleCascade1              Action = "ToggleCascade1"
+	ToggleCascade2              Action = "ToggleCascade2"
+	ToggleCascade3              Action = "ToggleCascade3"
+	ToggleCascadeCenters        Action = "ToggleCascadeCenters"
+	ToggleCascade1ShadowFrustum Action = "ToggleCascade1ShadowFrustum"
+	ToggleCascade2ShadowFrustum Action = "ToggleCascade2ShadowFrustum"
+	ToggleCascade3ShadowFrustum Action = "ToggleCascade3ShadowFrustum"
+	ToggleShadowFrustumEyes     Action = "ToggleShadowFrustumEyes"
+)
+
+// RenderModes is the number of render modes the renderer can be switched between.
+const RenderModes = 25
+
+// RenderMode returns the action which switches the renderer to mode, from 0.
+func RenderMode(mode int) Action {
+	return Action(fmt.Sprintf("RenderMode%d", mode))
+}
+
+// PipCascade returns the action which shows shadow cascade i, from 0, in the picture in picture.
+func PipCascade(i int) Action {
+	return Action(fmt.Sprintf("PipCascade%d", i+1))
+}
+
+// axisDeadZone is how far a gamepad axis can be pushed before it counts, as it rarely rests exactly at 0.
+const axisDeadZone = .2
+
+var (
+	// actions holds every action, in the order they are sent.
+	actions []Action
+
+	mu sync.Mutex
+	// bindings holds the inputs each action is bound to.
+	bindings map[Action][]Binding
+	// capturing is the action the next input pressed is bound to, if it isn't empty, and captured is called
+	// once it is.
+	capturing Action
+	captured  func(Binding, error)
+
+	// values holds how far each action was pushed during the last update, and held and pressed the actions
+	// held and pressed.
+	values  = map[Action]float32{}
+	held    = map[Action]bool{}
+	pressed = map[Action]bool{}
+)
+
+func init() {
+	bindings = DefaultBindings()
+	for _, d := range defaultBindings() {
+		actions = append(actions, d.action)
+	}
+	messagebus.RegisterType("bind", handleBind)
+}
+
+// actionBindings is the inputs an action is bound to.
+type actionBindings struct {
+	action   Action
+	bindings []Binding
+}
+
+// defaultBindings returns every action with the inputs it is bound to by default. The gamepad is laid out as
+// glfw reports an Xbox controller: the left stick is axes 0 and 1, the right stick axes 2 and 3, and A, B, X
+// and Y are buttons 0 to 3.
+func defaultBindings() []actionBindings {
+	d := []actionBindings{
+		{MoveForward, []Binding{Key(glfw.KeyW), PadAxis(1, true)}},
+		{MoveBack, []Binding{Key(glfw.KeyS), PadAxis(1, false)}},
+		{MoveRight, []Binding{Key(glfw.KeyD), PadAxis(0, false)}},
+		{MoveLeft, []Binding{Key(glfw.KeyA), PadAxis(0, true)}},
+		{MoveUp, []Binding{Key(glfw.KeySpace), PadButton(0)}},
+		{MoveDown, []Binding{Key(glfw.KeyLeftControl), PadButton(1)}},
+		{RollRight, []Binding{Key(glfw.KeyE), PadButton(5)}},
+		{RollLeft, []Binding{Key(glfw.KeyQ), PadButton(4)}},
+		{Sprint, []Binding{Key(glfw.KeyLeftShift), PadButton(8)}},
+		{LookRight, []Binding{PadAxis(2, false)}},
+		{LookLeft, []Binding{PadAxis(2, true)}},
+		{LookUp, []Binding{PadAxis(3, true)}},
+		{LookDown, []Binding{PadAxis(3, false)}},
+
+		{NextCamera, []Binding{Key(glfw.KeyC), PadButton(2)}},
+		{NextController, []Binding{Key(glfw.KeyV), PadButton(3)}},
+		{ToggleSplitScreen, []Binding{Key(glfw.KeyKPSubtract)}},
+		{Select, []Binding{MouseButton(glfw.MouseButtonLeft)}},
+		{RecordKeyframe, []Binding{Key(glfw.KeyK)}},
+		{AddPointLight, []Binding{Key(glfw.KeyL)}},
+		{RotateSunLeft, []Binding{Key(glfw.KeyPageUp)}},
+		{RotateSunRight, []Binding{Key(glfw.KeyPageDown)}},
+		{Screenshot, []Binding{Key(glfw.KeyPrintScreen), PadButton(6)}},
+		{Quit, []Binding{Key(glfw.KeyEscape)}},
+
+		{ToggleFPS, []Binding{Key(glfw.KeyF)}},
+		{ToggleHUD, []Binding{Key(glfw.KeyH)}},
+		{ToggleOcclusion, []Binding{Key(glfw.KeyO)}},
+		{ShowPip, []Binding{Key(glfw.KeyHome)}},
+		{HidePip, []Binding{Key(glfw.KeyEnd)}},
+
+		{ToggleFrustum, []Binding{Key(glfw.KeyKP0)}},
+		{ToggleCascade1, []Binding{Key(glfw.KeyKP1)}},
+		{ToggleCascade2, []Binding{Key(glfw.KeyKP2)}},
+		{ToggleCascade3, []Binding{Key(glfw.KeyKP3)}},
+		{ToggleCascadeCenters, []Binding{Key(glfw.KeyKPDecimal)}},
+		{ToggleCascade1ShadowFrustum, []Binding{Key(glfw.KeyKP4)}},
+		{ToggleCascade2ShadowFrustum, []Binding{Key(glfw.KeyKP5)}},
+		{ToggleCascade3ShadowFrustum, []Binding{Key(glfw.KeyKP6)}},
+		{ToggleShadowFrustumEyes, []Binding{Key(glfw.KeyKPAdd)}},
+	}
+	for i, k := range []glfw.Key{glfw.KeyKP7, glfw.KeyKP8, glfw.KeyKP9, glfw.KeyKPDivide, glfw.KeyKPMultiply} {
+		d = append(d, actionBindings{PipCascade(i), []Binding{Key(k)}})
+	}
+	for mode := 0; mode < RenderModes; mode++ {
+		d = append(d, actionBindings{RenderMode(mode), []Binding{Key(glfw.KeyF1 + glfw.Key(mode))}})
+	}
+	return d
+}
+
+// DefaultBindings returns the inputs every action is bound to by default.
+func DefaultBindings() map[Action][]Binding {
+	b := map[Action][]Binding{}
+	for _, d := range defaultBindings() {
+		b[d.action] = d.bindings
+	}
+	return b
+}
+
+// Actions returns every action.
+func Actions() []Action {
+	return append([]Action(nil), actions...)
+}
+
+// isAction returns whether action is one of the actions. mu must be held.
+func isAction(action Action) bool {
+	_, ok := bindings[action]
+	return ok
+}
+
+// Bindings returns the inputs action is bound to.
+func Bindings(action Action) []Binding {
+	mu.Lock()
+	defer mu.Unlock()
+	return append([]Binding(nil), bindings[action]...)
+}
+
+// Bind binds action to inputs in place of what it was bound to. Nothing is rebound if any of the inputs is
+// bound to another action, and the Conflict is returned.
+func Bind(action Action, inputs ...Binding) error {
+	mu.Lock()
+	defer mu.Unlock()
+	return bind(action, inputs)
+}
+
+func bind(action Action, inputs []Binding) error {
+	if !isAction(action) {
+		return fmt.Errorf("unknown action %q", action)
+	}
+	rebound := make(map[Action][]Binding, len(bindings))
+	for a, bs := range bindings {
+		rebound[a] = bs
+	}
+	rebound[action] = inputs
+	for _, c := range FindConflicts(rebound) {
+		if containsAction(c.Actions, action) {
+			return c
+		}
+	}
+	bindings = rebound
+	return nil
+}
+
+// CaptureBinding binds action to the next input pressed, in place of what it was bound to, then calls done
+// with the input or the error binding it. The input doesn't trigger any action as it is captured.
+func CaptureBinding(action Action, done func(Binding, error)) {
+	mu.Lock()
+	defer mu.Unlock()
+	capturing, captured = action, done
+}
+
+// handleBind rebinds Data1, the name of an action, to Data2, a comma separated list of inputs, or to the next
+// input pressed if Data2 is empty.
+func handleBind(m *messagebus.Message) {
+	action, inputs := Action(fmt.Sprint(m.Data1)), fmt.Sprint(m.Data2)
+	if inputs == "" {
+		CaptureBinding(action, func(b Binding, err error) {
+			if err != nil {
+				log.Printf("Failed to bind %v: %v", action, err)
+				return
+			}
+			log.Printf("Bound %v to %v", action, b)
+		})
+		return
+	}
+	var bs []Binding
+	for _, s := range strings.Split(inputs, ",") {
+		b, err := ParseBinding(s)
+		if err != nil {
+			log.Printf("Failed to bind %v: %v", action, err)
+			return
+		}
+		bs = append(bs, b)
+	}
+	if err := Bind(action, bs...); err != nil {
+		log.Printf("Failed to bind %v: %v", action, err)
+	}
+}
+
+// Value returns how far action was pushed during the last update, from 0 to 1. Only gamepad axes push it
+// part of the way.
+func Value(action Action) float32 {
+	mu.Lock()
+	defer mu.Unlock()
+	return values[action]
+}
+
+// Held returns whether action was held, even part of the way, during the last update.
+func Held(action Action) bool {
+	mu.Lock()
+	defer mu.Unlock()
+	return held[action]
+}
+
+// Pressed returns whether action was pressed during the last update.
+func Pressed(action Action) bool {
+	mu.Lock()
+	defer mu.Unlock()
+	return pressed[action]
+}
+
+// state is the state of every input during one update.
+type state struct {
+	keys, keysPressed                 [keyRange]bool
+	mouseButtons, mouseButtonsPressed [glfw.MouseButtonLast + 1]bool
+	// padButtons and padAxes are the gamepad's buttons and axes, and previous its state the update before.
+	padButtons []bool
+	padAxes    []float32
+	previous   *state
+}
+
+// value returns how far b is pushed in s, from 0 to 1.
+func (s *state) value(b Binding) float32 {
+	switch b.Device {
+	case Keyboard:
+		if b.Code >= 0 && b.Code < len(s.keys) && s.keys[b.Code] {
+			return 1
+		}
+	case Mouse:
+		if b.Code >= 0 && b.Code < len(s.mouseButtons) && s.mouseButtons[b.Code] {
+			return 1
+		}
+	case GamepadButton:
+		if b.Code < len(s.padButtons) && s.padButtons[b.Code] {
+			return 1
+		}
+	case GamepadAxis:
+		if b.Code >= len(s.padAxes) {
+			return 0
+		}
+		v := s.padAxes[b.Code]
+		if b.Negative {
+			v = -v
+		}
+		// The dead zone is taken out so the value still reaches 1.
+		return max(0, min(1, (v-axisDeadZone)/(1-axisDeadZone)))
+	}
+	return 0
+}
+
+// pressed returns whether b was pressed in s. Keys and mouse buttons pressed and released between updates
+// still count.
+func (s *state) pressed(b Binding) bool {
+	switch b.Device {
+	case Keyboard:
+		return b.Code >= 0 && b.Code < len(s.keysPressed) && s.keysPressed[b.Code]
+	case Mouse:
+		return b.Code >= 0 && b.Code < len(s.mouseButtonsPressed) && s.mouseButtonsPressed[b.Code]
+	}
+	return s.value(b) >= .5 && (s.previous == nil || s.previous.value(b) < .5)
+}
+
+// firstPressed returns an input pressed in s, for capturing a binding.
+func (s *state) firstPressed() (Binding, bool) {
+	for k := range s.keysPressed {
+		if s.keysPressed[k] {
+			return Key(glfw.Key(k)), true
+		}
+	}
+	for button := range s.mouseButtonsPressed {
+		if s.mouseButtonsPressed[button] {
+			return MouseButton(glfw.MouseButton(button)), true
+		}
+	}
+	for i := range s.padButtons {
+		if b := PadButton(i); s.pressed(b) {
+			return b, true
+		}
+	}
+	for i := range s.padAxes {
+		for _, b := range []Binding{PadAxis(i, false), PadAxis(i, true)} {
+			if s.pressed(b) {
+				return b, true
+			}
+		}
+	}
+	return Binding{}, false
+}
+
+// updateActions works out the actions held and pressed in s, and returns them in order. An input pressed
+// while a binding is being captured is bound instead, and nothing is returned.
+func updateActions(s *state) ([]Action, []Action) {
+	mu.Lock()
+	clear(values)
+	clear(held)
+	clear(pressed)
+
+	if capturing != "" {
+		b, ok := s.firstPressed()
+		if !ok {
+			mu.Unlock()
+			return nil, nil
+		}
+		err := bind(capturing, []Binding{b})
+		done := captured
+		capturing, captured = "", nil
+		mu.Unlock()
+		if done != nil {
+			done(b, err)
+		}
+		return nil, nil
+	}
+	defer mu.Unlock()
+
+	var heldActions, pressedActions []Action
+	for _, action := range actions {
+		for _, b := range bindings[action] {
+			values[action] = max(values[action], s.value(b))
+			pressed[action] = pressed[action] || s.pressed(b)
+		}
+		held[action] = values[action] > 0
+		if held[action] {
+			heldActions = append(heldActions, action)
+		}
+		if pressed[action] {
+			pressedActions = append(pressedActions, action)
+		}
+	}
+	return heldActions, pressedActions
+}
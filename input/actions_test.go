@@ -0,0 +1,141 @@
+package input
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/go-gl/glfw/v3.1/glfw"
+	"github.com/stretchr/testify/assert"
+)
+
+// resetBindings restores the default bindings once the test ends.
+func resetBindings(t *testing.T) {
+	t.Cleanup(func() {
+		mu.Lock()
+		defer mu.Unlock()
+		bindings = DefaultBindings()
+		capturing, captured = "", nil
+	})
+}
+
+func TestBindingStrings(t *testing.T) {
+	for _, b := range []Binding{
+		Key(glfw.KeyW), Key(glfw.KeyLeftControl), Key(glfw.KeyF12), Key(glfw.KeyKP0), Key(glfw.KeyKPSubtract),
+		MouseButton(glfw.MouseButtonLeft), MouseButton(glfw.MouseButton5),
+		PadButton(3), PadAxis(1, true), PadAxis(2, false),
+	} {
+		parsed, err := ParseBinding(b.String())
+		if assert.NoError(t, err, "%v", b) {
+			assert.Equal(t, b, parsed)
+		}
+	}
+	assert.Equal(t, "Mouse Left", MouseButton(glfw.MouseButtonLeft).String())
+	assert.Equal(t, "Pad Axis 1-", PadAxis(1, true).String())
+
+	for _, s := range []string{"", "Nope", "Mouse Sideways", "Mouse 0", "Pad Button x", "Pad Axis 1", "Pad Axis 1*"} {
+		_, err := ParseBinding(s)
+		assert.Error(t, err, "%q", s)
+	}
+}
+
+func TestDefaultBindingsDontConflict(t *testing.T) {
+	assert.Empty(t, FindConflicts(DefaultBindings()))
+	assert.Len(t, Actions(), len(DefaultBindings()))
+}
+
+func TestBindRejectsConflicts(t *testing.T) {
+	resetBindings(t)
+
+	err := Bind(ToggleHUD, Key(glfw.KeyW))
+	if assert.Error(t, err) {
+		assert.Equal(t, Conflict{Key(glfw.KeyW), []Action{MoveForward, ToggleHUD}}, err)
+	}
+	assert.Equal(t, []Binding{Key(glfw.KeyH)}, Bindings(ToggleHUD))
+
+	assert.NoError(t, Bind(ToggleHUD, Key(glfw.KeyJ), PadButton(7)))
+	assert.Equal(t, []Binding{Key(glfw.KeyJ), PadButton(7)}, Bindings(ToggleHUD))
+	assert.Error(t, Bind("NotAnAction", Key(glfw.KeyU)))
+}
+
+func TestLoadAndSaveBindings(t *testing.T) {
+	resetBindings(t)
+	dir := t.TempDir()
+
+	file := filepath.Join(dir, "bindings.json")
+	assert.NoError(t, os.WriteFile(file, []byte(`{"ToggleHUD": ["J", "Pad Button 7"], "Select": ["Mouse Right"]}`), 0644))
+	assert.NoError(t, LoadBindings(file))
+	assert.Equal(t, []Binding{Key(glfw.KeyJ), PadButton(7)}, Bindings(ToggleHUD))
+	assert.Equal(t, []Binding{MouseButton(glfw.MouseButtonRight)}, Bindings(Select))
+	// Actions the file doesn't list keep their bindings.
+	assert.Equal(t, []Binding{Key(glfw.KeyW), PadAxis(1, true)}, Bindings(MoveForward))
+
+	saved := filepath.Join(dir, "saved.json")
+	assert.NoError(t, SaveBindings(saved))
+	assert.NoError(t, Bind(ToggleHUD, Key(glfw.KeyH)))
+	assert.NoError(t, LoadBindings(saved))
+	assert.Equal(t, []Binding{Key(glfw.KeyJ), PadButton(7)}, Bindings(ToggleHUD))
+
+	conflicting := filepath.Join(dir, "conflicting.json")
+	assert.NoError(t, os.WriteFile(conflicting, []byte(`{"ToggleHUD": ["W"]}`), 0644))
+	assert.Error(t, LoadBindings(conflicting))
+	unknown := filepath.Join(dir, "unknown.json")
+	assert.NoError(t, os.WriteFile(unknown, []byte(`{"Jump": ["Space"]}`), 0644))
+	assert.Error(t, LoadBindings(unknown))
+	assert.Equal(t, []Binding{Key(glfw.KeyJ), PadButton(7)}, Bindings(ToggleHUD))
+}
+
+func TestUpdateActions(t *testing.T) {
+	resetBindings(t)
+
+	s := &state{padAxes: []float32{0, -.6, .1, 0}, padButtons: []bool{true}}
+	s.keys[glfw.KeyD] = true
+	s.keysPressed[glfw.KeyH] = true
+	s.mouseButtonsPressed[glfw.MouseButtonLeft] = true
+	heldActions, pressedActions := updateActions(s)
+
+	assert.Equal(t, []Action{MoveForward, MoveRight, MoveUp}, heldActions)
+	assert.Equal(t, []Action{MoveForward, MoveUp, Select, ToggleHUD}, pressedActions)
+	assert.Equal(t, float32(1), Value(MoveRight))
+	// The stick is pushed half way past its dead zone, and the other one not out of it.
+	assert.InDelta(t, .5, Value(MoveForward), 1e-6)
+	assert.Zero(t, Value(LookRight))
+	assert.True(t, Held(MoveUp))
+	assert.True(t, Pressed(ToggleHUD))
+	assert.False(t, Held(ToggleHUD))
+
+	// Gamepad inputs held since the update before aren't pressed again.
+	next := &state{padAxes: []float32{0, -.6, .1, 0}, padButtons: []bool{true}, previous: s}
+	heldActions, pressedActions = updateActions(next)
+	assert.Equal(t, []Action{MoveForward, MoveUp}, heldActions)
+	assert.Empty(t, pressedActions)
+}
+
+func TestCaptureBinding(t *testing.T) {
+	resetBindings(t)
+
+	var got Binding
+	var gotErr error
+	CaptureBinding(ToggleHUD, func(b Binding, err error) { got, gotErr = b, err })
+	heldActions, pressedActions := updateActions(&state{})
+	assert.Empty(t, heldActions)
+	assert.Empty(t, pressedActions)
+
+	s := &state{}
+	s.keysPressed[glfw.KeyJ] = true
+	heldActions, pressedActions = updateActions(s)
+	assert.Empty(t, heldActions)
+	assert.Empty(t, pressedActions, "the captured input triggers nothing")
+	assert.NoError(t, gotErr)
+	assert.Equal(t, Key(glfw.KeyJ), got)
+	assert.Equal(t, []Binding{Key(glfw.KeyJ)}, Bindings(ToggleHUD))
+
+	// Capturing an input bound to another action leaves both as they were.
+	CaptureBinding(ToggleHUD, func(b Binding, err error) { got, gotErr = b, err })
+	s = &state{}
+	s.keysPressed[glfw.KeyW] = true
+	updateActions(s)
+	assert.Error(t, gotErr)
+	assert.Equal(t, []Binding{Key(glfw.KeyJ)}, Bindings(ToggleHUD))
+	assert.Equal(t, []Binding{Key(glfw.KeyW), PadAxis(1, true)}, Bindings(MoveForward))
+}
@@ -0,0 +1,266 @@
+package input
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/go-gl/glfw/v3.1/glfw"
+)
+
+// Device is the kind of input a Binding is to.
+type Device int
+
+const (
+	Keyboard Device = iota
+	Mouse
+	GamepadButton
+	// GamepadAxis bindings are to one direction of an axis, and go from 0 at its centre to 1 at its end.
+	GamepadAxis
+)
+
+// Binding is an input an action is bound to. It is written as the key's name, such as "W" or "LeftControl",
+// "Mouse Left", "Pad Button 0", or a direction of an axis such as "Pad Axis 1-".
+type Binding struct {
+	Device Device
+	// Code is the key, mouse button, or index of the gamepad button or axis.
+	Code int
+	// Negative selects the negative direction of a gamepad axis.
+	Negative bool
+}
+
+// Key returns the binding to key.
+func Key(key glfw.Key) Binding {
+	return Binding{Device: Keyboard, Code: int(key)}
+}
+
+// MouseButton returns the binding to button.
+func MouseButton(button glfw.MouseButton) Binding {
+	return Binding{Device: Mouse, Code: int(button)}
+}
+
+// PadButton returns the binding to the gamepad's button i.
+func PadButton(i int) Binding {
+	return Binding{Device: GamepadButton, Code: i}
+}
+
+// PadAxis returns the binding to the gamepad's axis i pushed in the negative direction if negative, or the
+// positive one otherwise.
+func PadAxis(i int, negative bool) Binding {
+	return Binding{Device: GamepadAxis, Code: i, Negative: negative}
+}
+
+var (
+	// keyNames holds the name of every key, and keysByName the reverse.
+	keyNames   = map[glfw.Key]string{}
+	keysByName = map[string]glfw.Key{}
+
+	mouseButtonNames = map[glfw.MouseButton]string{
+		glfw.MouseButtonLeft:   "Left",
+		glfw.MouseButtonRight:  "Right",
+		glfw.MouseButtonMiddle: "Middle",
+	}
+)
+
+func init() {
+	for k := glfw.KeyA; k <= glfw.KeyZ; k++ {
+		keyNames[k] = string(rune('A' + k - glfw.KeyA))
+	}
+	for k := glfw.Key0; k <= glfw.Key9; k++ {
+		keyNames[k] = string(rune('0' + k - glfw.Key0))
+	}
+	for k := glfw.KeyF1; k <= glfw.KeyF25; k++ {
+		keyNames[k] = fmt.Sprintf("F%d", k-glfw.KeyF1+1)
+	}
+	for k := glfw.KeyKP0; k <= glfw.KeyKP9; k++ {
+		keyNames[k] = fmt.Sprintf("KP%d", k-glfw.KeyKP0)
+	}
+	for k, name := range map[glfw.Key]string{
+		glfw.KeySpace: "Space", glfw.KeyApostrophe: "Apostrophe", glfw.KeyComma: "Comma", glfw.KeyMinus: "Minus",
+		glfw.KeyPeriod: "Period", glfw.KeySlash: "Slash", glfw.KeySemicolon: "Semicolon", glfw.KeyEqual: "Equal",
+		glfw.KeyLeftBracket: "LeftBracket", glfw.KeyBackslash: "Backslash", glfw.KeyRightBracket: "RightBracket",
+		glfw.KeyGraveAccent: "GraveAccent", glfw.KeyWorld1: "World1", glfw.KeyWorld2: "World2",
+		glfw.KeyEscape: "Escape", glfw.KeyEnter: "Enter", glfw.KeyTab: "Tab", glfw.KeyBackspace: "Backspace",
+		glfw.KeyInsert: "Insert", glfw.KeyDelete: "Delete", glfw.KeyRight: "Right", glfw.KeyLeft: "Left",
+		glfw.KeyDown: "Down", glfw.KeyUp: "Up", glfw.KeyPageUp: "PageUp", glfw.KeyPageDown: "PageDown",
+		glfw.KeyHome: "Home", glfw.KeyEnd: "End", glfw.KeyCapsLock: "CapsLock", glfw.KeyScrollLock: "ScrollLock",
+		glfw.KeyNumLock: "NumLock", glfw.KeyPrintScreen: "PrintScreen", glfw.KeyPause: "Pause",
+		glfw.KeyKPDecimal: "KPDecimal", glfw.KeyKPDivide: "KPDivide", glfw.KeyKPMultiply: "KPMultiply",
+		glfw.KeyKPSubtract: "KPSubtract", glfw.KeyKPAdd: "KPAdd", glfw.KeyKPEnter: "KPEnter",
+		glfw.KeyKPEqual: "KPEqual", glfw.KeyLeftShift: "LeftShift", glfw.KeyLeftControl: "LeftControl",
+		glfw.KeyLeftAlt: "LeftAlt", glfw.KeyLeftSuper: "LeftSuper", glfw.KeyRightShift: "RightShift",
+		glfw.KeyRightControl: "RightControl", glfw.KeyRightAlt: "RightAlt", glfw.KeyRightSuper: "RightSuper",
+		glfw.KeyMenu: "Menu",
+	} {
+		keyNames[k] = name
+	}
+	for k, name := range keyNames {
+		keysByName[name] = k
+	}
+}
+
+// String returns the binding as it is written in bindings files.
+func (b Binding) String() string {
+	switch b.Device {
+	case Keyboard:
+		if name, ok := keyNames[glfw.Key(b.Code)]; ok {
+			return name
+		}
+		return fmt.Sprintf("Key %d", b.Code)
+	case Mouse:
+		if name, ok := mouseButtonNames[glfw.MouseButton(b.Code)]; ok {
+			return "Mouse " + name
+		}
+		return fmt.Sprintf("Mouse %d", b.Code+1)
+	case GamepadButton:
+		return fmt.Sprintf("Pad Button %d", b.Code)
+	case GamepadAxis:
+		if b.Negative {
+			return fmt.Sprintf("Pad Axis %d-", b.Code)
+		}
+		return fmt.Sprintf("Pad Axis %d+", b.Code)
+	}
+	return fmt.Sprintf("unknown device %d", b.Device)
+}
+
+// ParseBinding returns the binding written as s.
+func ParseBinding(s string) (Binding, error) {
+	s = strings.TrimSpace(s)
+	if k, ok := keysByName[s]; ok {
+		return Key(k), nil
+	}
+	fields := strings.Fields(s)
+	switch {
+	case len(fields) == 2 && fields[0] == "Key":
+		if code, err := strconv.Atoi(fields[1]); err == nil && code >= int(glfw.KeySpace) && code < int(keyRange) {
+			return Key(glfw.Key(code)), nil
+		}
+	case len(fields) == 2 && fields[0] == "Mouse":
+		for button, name := range mouseButtonNames {
+			if name == fields[1] {
+				return MouseButton(button), nil
+			}
+		}
+		// Mouse buttons are numbered from 1, as glfw names them.
+		if n, err := strconv.Atoi(fields[1]); err == nil && n >= 1 && n <= int(glfw.MouseButtonLast)+1 {
+			return MouseButton(glfw.MouseButton(n - 1)), nil
+		}
+	case len(fields) == 3 && fields[0] == "Pad" && fields[1] == "Button":
+		if i, err := strconv.Atoi(fields[2]); err == nil && i >= 0 {
+			return PadButton(i), nil
+		}
+	case len(fields) == 3 && fields[0] == "Pad" && fields[1] == "Axis":
+		axis, direction := fields[2][:len(fields[2])-1], fields[2][len(fields[2])-1]
+		if i, err := strconv.Atoi(axis); err == nil && i >= 0 && (direction == '+' || direction == '-') {
+			return PadAxis(i, direction == '-'), nil
+		}
+	}
+	return Binding{}, fmt.Errorf("unknown input %q", s)
+}
+
+// MarshalText writes the binding as its String.
+func (b Binding) MarshalText() ([]byte, error) {
+	return []byte(b.String()), nil
+}
+
+// UnmarshalText parses the binding with ParseBinding.
+func (b *Binding) UnmarshalText(text []byte) error {
+	parsed, err := ParseBinding(string(text))
+	if err != nil {
+		return err
+	}
+	*b = parsed
+	return nil
+}
+
+// Conflict is an input bound to more than one action.
+type Conflict struct {
+	Binding Binding
+	Actions []Action
+}
+
+func (c Conflict) Error() string {
+	names := make([]string, len(c.Actions))
+	for i, a := range c.Actions {
+		names[i] = string(a)
+	}
+	return fmt.Sprintf("%v is bound to %v", c.Binding, strings.Join(names, " and "))
+}
+
+// FindConflicts returns every input bound to more than one action in bindings, ordered by input.
+func FindConflicts(bindings map[Action][]Binding) []Conflict {
+	actions := map[Binding][]Action{}
+	for action, bs := range bindings {
+		for _, b := range bs {
+			if !containsAction(actions[b], action) {
+				actions[b] = append(actions[b], action)
+			}
+		}
+	}
+	var conflicts []Conflict
+	for b, as := range actions {
+		if len(as) > 1 {
+			sort.Slice(as, func(i, j int) bool { return as[i] < as[j] })
+			conflicts = append(conflicts, Conflict{b, as})
+		}
+	}
+	sort.Slice(conflicts, func(i, j int) bool {
+		return conflicts[i].Binding.String() < conflicts[j].Binding.String()
+	})
+	return conflicts
+}
+
+func containsAction(actions []Action, action Action) bool {
+	for _, a := range actions {
+		if a == action {
+			return true
+		}
+	}
+	return false
+}
+
+// LoadBindings rebinds the actions listed in the JSON file, which maps each action's name to a list of inputs,
+// leaving the rest as they are. Nothing is rebound if the file names an unknown action or would leave an input
+// bound to more than one action.
+func LoadBindings(file string) error {
+	data, err := os.ReadFile(file)
+	if err != nil {
+		return err
+	}
+	var loaded map[Action][]Binding
+	if err := json.Unmarshal(data, &loaded); err != nil {
+		return fmt.Errorf("failed to parse bindings %v: %v", file, err)
+	}
+
+	mu.Lock()
+	defer mu.Unlock()
+	merged := make(map[Action][]Binding, len(bindings))
+	for action, bs := range bindings {
+		merged[action] = bs
+	}
+	for action, bs := range loaded {
+		if !isAction(action) {
+			return fmt.Errorf("failed to load bindings %v: unknown action %q", file, action)
+		}
+		merged[action] = bs
+	}
+	if conflicts := FindConflicts(merged); len(conflicts) > 0 {
+		return fmt.Errorf("failed to load bindings %v: %v", file, conflicts[0])
+	}
+	bindings = merged
+	return nil
+}
+
+// SaveBindings writes every action's bindings to the JSON file, in the form LoadBindings reads.
+func SaveBindings(file string) error {
+	mu.Lock()
+	data, err := json.MarshalIndent(bindings, "", "\t")
+	mu.Unlock()
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(file, data, 0644)
+}
@@ -1,8 +1,6 @@
 package input
 
 import (
-	"fmt"
-
 	"github.com/brandonnelson3/GoRender/messagebus"
 
 	"github.com/go-gl/glfw/v3.1/glfw"
@@ -15,6 +13,12 @@ const (
 var (
 	down          [keyRange]bool
 	downThisFrame [keyRange]bool
+
+	mouseDown          [glfw.MouseButtonLast + 1]bool
+	mouseDownThisFrame [glfw.MouseButtonLast + 1]bool
+
+	// last is the state of every input during the last update.
+	last *state
 )
 
 // MouseInput is message data which is sent for every mouse cursor position callback.
@@ -22,7 +26,18 @@ type MouseInput struct {
 	X, Y float64
 }
 
-// Update calls all of the currently pressed keys.
+// MouseButtonInput is message data which is sent whenever a mouse button is pressed or released.
+type MouseButtonInput struct {
+	Button  glfw.MouseButton
+	Pressed bool
+}
+
+// ScrollInput is message data which is sent whenever the mouse wheel is turned.
+type ScrollInput struct {
+	X, Y float64
+}
+
+// Update calls all of the currently pressed keys, then the actions they and the other inputs are bound to.
 func Update() {
 	pressedKeys := make([]glfw.Key, 0, 10)
 	pressedKeysThisFrame := make([]glfw.Key, 0, 10)
@@ -40,6 +55,26 @@ func Update() {
 	if len(pressedKeys) > 0 {
 		messagebus.SendSync(&messagebus.Message{Type: "key", Data1: pressedKeys, Data2: pressedKeysThisFrame})
 	}
+
+	s := &state{keys: down, mouseButtons: mouseDown, mouseButtonsPressed: mouseDownThisFrame, previous: last}
+	for _, key := range pressedKeysThisFrame {
+		s.keysPressed[key] = true
+	}
+	mouseDownThisFrame = [glfw.MouseButtonLast + 1]bool{}
+	// The first gamepad connected is used.
+	if glfw.JoystickPresent(glfw.Joystick1) {
+		s.padAxes = glfw.GetJoystickAxes(glfw.Joystick1)
+		for _, b := range glfw.GetJoystickButtons(glfw.Joystick1) {
+			s.padButtons = append(s.padButtons, b == byte(glfw.Press))
+		}
+	}
+
+	heldActions, pressedActions := updateActions(s)
+	// Only the update before is needed to tell when gamepad inputs are pressed.
+	s.previous, last = nil, s
+	if len(heldActions) > 0 || len(pressedActions) > 0 {
+		messagebus.SendSync(&messagebus.Message{Type: "action", Data1: heldActions, Data2: pressedActions})
+	}
 }
 
 // KeyCallBack is the function bound to handle key events from OpenGL.
@@ -55,10 +90,24 @@ func KeyCallBack(w *glfw.Window, key glfw.Key, scancode int, action glfw.Action,
 
 // MouseButtonCallback is the function bound to handle mouse button events from OpenGL.
 func MouseButtonCallback(w *glfw.Window, button glfw.MouseButton, action glfw.Action, mod glfw.ModifierKey) {
-	fmt.Printf("Got mouse press: %v\n", button)
+	if action == glfw.Press {
+		mouseDown[button] = true
+		mouseDownThisFrame[button] = true
+	}
+	if action == glfw.Release {
+		mouseDown[button] = false
+	}
+	if action == glfw.Press || action == glfw.Release {
+		messagebus.SendSync(&messagebus.Message{Type: "mousebutton", Data1: MouseButtonInput{button, action == glfw.Press}})
+	}
 }
 
 // CursorPosCallback is the function bound to handle mouse movement events from OpenGL.
 func CursorPosCallback(w *glfw.Window, x, y float64) {
 	messagebus.SendSync(&messagebus.Message{Type: "mouse", Data1: MouseInput{x, y}})
 }
+
+// ScrollCallback is the function bound to handle mouse wheel events from OpenGL.
+func ScrollCallback(w *glfw.Window, xoff, yoff float64) {
+	messagebus.SendSync(&messagebus.Message{Type: "scroll", Data1: ScrollInput{xoff, yoff}})
+}
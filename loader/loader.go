@@ -16,7 +16,7 @@ func Load(path string) (io.Reader, error) {
 	}
 	file, err := os.Open(path)
 	if err != nil {
-		return nil, fmt.Errorf("file \"%s\" not found on disk: %v", path, err)
+		return nil, fmt.Errorf("file \"%s\" not found on disk: %w", path, err)
 	}
 	defer file.Close()
 	b, err := io.ReadAll(file)
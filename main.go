@@ -2,6 +2,7 @@ package main
 
 import (
 	"flag"
+	"fmt"
 	"image/png"
 	"log"
 	"os"
@@ -12,6 +13,7 @@ import (
 	"github.com/brandonnelson3/GoRender/console"
 	"github.com/brandonnelson3/GoRender/gfx"
 	"github.com/brandonnelson3/GoRender/input"
+	"github.com/brandonnelson3/GoRender/messagebus"
 	"github.com/brandonnelson3/GoRender/rendertest"
 	"github.com/brandonnelson3/GoRender/terrain"
 
@@ -27,6 +29,7 @@ const (
 	windowFOV    = 45.0
 	windowNear   = .1
 	windowFar    = 10000
+	seaLevel     = 18
 )
 
 var (
@@ -34,6 +37,17 @@ var (
 	renderScene    = flag.String("scene", "", "if set, only render this scene name (used with -rendertest)")
 	renderOut      = flag.String("out", filepath.Join("rendertest", "testdata", "actual"), "output directory for render-test PNGs")
 	benchmarkMode  = flag.Bool("benchmark", false, "run for 5 seconds and report performance metrics then exit")
+	benchmarkPath  = flag.String("path", "", "if set, -benchmark flies along the camera path in this file at a steady speed instead of holding a pose, and reports its slowest stretches")
+	pathSegments   = flag.Int("pathsegments", 10, "number of stretches -benchmark -path reports the camera path's frame times over")
+	skyboxFile     = flag.String("skybox", "", "if set, use this equirectangular .hdr or .png image as the sky instead of the procedural atmosphere")
+	terrainSeed    = flag.Int64("seed", 0, "seed the terrain and the objects scattered over it are generated from")
+	tessellate     = flag.Bool("tessellate", false, "subdivide the terrain on the GPU for finer detail up close")
+	recordPath     = flag.String("recordpath", "", "if set, record the camera's path and save it to this file on exit; K adds a keyframe")
+	recordInterval = flag.Float64("recordinterval", 0, "seconds between poses recorded with -recordpath, or 0 to only record keyframes added with K")
+	playPath       = flag.String("playpath", "", "if set, fly the first person camera along the path in this file")
+	pathStep       = flag.Float64("pathstep", 0, "if set, advance the cameras by this many seconds each frame instead of the frame's length")
+	pathFrames     = flag.String("pathframes", "", "if set, write each frame of -playpath to a PNG in this directory and exit once the path ends")
+	bindingsFile   = flag.String("bindings", "", "if set, load the keys, mouse buttons and gamepad inputs actions are bound to from this JSON file, writing the defaults to it if it doesn't exist")
 )
 
 func init() {
@@ -44,6 +58,16 @@ func init() {
 func main() {
 	flag.Parse()
 
+	if *bindingsFile != "" {
+		if _, err := os.Stat(*bindingsFile); os.IsNotExist(err) {
+			if err := input.SaveBindings(*bindingsFile); err != nil {
+				log.Fatalln("failed to save bindings:", err)
+			}
+		} else if err := input.LoadBindings(*bindingsFile); err != nil {
+			log.Fatalln("failed to load bindings:", err)
+		}
+	}
+
 	if err := glfw.Init(); err != nil {
 		log.Fatalln("failed to initialize glfw:", err)
 	}
@@ -59,6 +83,7 @@ func main() {
 	gfx.Window.SetKeyCallback(input.KeyCallBack)
 	gfx.Window.SetMouseButtonCallback(input.MouseButtonCallback)
 	gfx.Window.SetCursorPosCallback(input.CursorPosCallback)
+	gfx.Window.SetScrollCallback(input.ScrollCallback)
 	gfx.Window.MakeContextCurrent()
 	gfx.Window.RecenterCursor()
 
@@ -82,7 +107,16 @@ func main() {
 	objRenderable := obj.GetChunkedRenderable()
 	objRenderable.Scale = mgl32.Scale3D(2, 2, 2)
 
-	sky, err := gfx.NewSky()
+	var sky gfx.Sky
+	if *skyboxFile != "" {
+		sky, err = gfx.NewSkybox(*skyboxFile)
+	} else {
+		var atmosphere *gfx.Atmosphere
+		if atmosphere, err = gfx.NewAtmosphere(); err == nil {
+			atmosphere.SetClouds(gfx.CloudSettings{Coverage: 0.45, Density: 0.8, WindSpeed: 20, WindDirection: 0.6})
+		}
+		sky = atmosphere
+	}
 	if err != nil {
 		panic(err)
 	}
@@ -91,7 +125,18 @@ func main() {
 		log.Println("warning: could not load camera model:", err)
 	}
 
-	terr := terrain.NewTerrain()
+	terr, err := terrain.NewTerrain(terrain.DefaultHeightSource(*terrainSeed))
+	if err != nil {
+		log.Fatalln("failed to create terrain:", err)
+	}
+	defer terr.Close()
+	gfx.SetCameraGround(terr.HeightAt)
+	if *tessellate {
+		tess := terrain.DefaultTessellation()
+		if err := terr.SetTessellation(&tess); err != nil {
+			log.Fatalln("failed to tessellate terrain:", err)
+		}
+	}
 
 	if *benchmarkMode {
 		benchmark.RecordMode = false // Don't record frames during warmup
@@ -99,37 +144,90 @@ func main() {
 		gfx.FirstPerson.SetPose(mgl32.Vec3{53.28, 52.97, -28.90}, 4.15, -0.43)
 		log.Println("Warming up benchmark for 1 second...")
 	}
+	if *benchmarkMode && *benchmarkPath != "" {
+		*playPath = *benchmarkPath
+		if *pathStep == 0 {
+			// Every run renders the same frames along the path, however long they take.
+			*pathStep = 1.0 / 60
+		}
+	}
+
+	var recorder *gfx.PathRecorder
+	if *recordPath != "" {
+		recorder = gfx.NewPathRecorder(*recordInterval)
+		messagebus.RegisterType("action", func(m *messagebus.Message) {
+			for _, action := range m.Data2.([]input.Action) {
+				if action == input.RecordKeyframe {
+					recorder.AddKeyframe(gfx.ActiveCamera.GetPose())
+				}
+			}
+		})
+	}
+	var player *gfx.PathController
+	if *playPath != "" {
+		path, err := gfx.LoadCameraPath(*playPath)
+		if err != nil {
+			log.Fatalln("failed to load camera path:", err)
+		}
+		player = gfx.NewPathController(path)
+		player.Loop = *pathFrames == "" && !*benchmarkMode
+		if *benchmarkMode {
+			path.ConstantSpeed = true
+		}
+		gfx.FirstPerson.SetController(gfx.FirstPerson.AddController(player))
+		if *pathFrames != "" {
+			if err := os.MkdirAll(*pathFrames, 0755); err != nil {
+				log.Fatalln("failed to create frame directory:", err)
+			}
+		}
+	}
 
 	renderables := []gfx.Renderable{terr}
 	updateables := []gfx.Updateable{terr}
 
-	treeRenderable := objRenderable.Copy()
-	var instanceTransforms []mgl32.Mat4
-	for x := 0; x <= 4; x++ {
-		for z := 0; z <= 4; z++ {
-			height := terr.GetHeight(float32(x*8+5), float32(z*8+5))
-			pos := mgl32.Vec3{float32(x*8 + 5), height, float32(z*8 + 5)}
-			m := mgl32.Translate3D(pos.X(), pos.Y(), pos.Z()).Mul4(treeRenderable.Scale.Mul4(treeRenderable.Rotation))
-			instanceTransforms = append(instanceTransforms, m)
-		}
+	// Terrain valleys below sea level fill in as lakes.
+	water, err := gfx.NewWater(seaLevel)
+	if err != nil {
+		panic(err)
 	}
-	treeRenderable.InstanceTransforms = instanceTransforms
-	renderables = append(renderables, treeRenderable)
+	renderables = append(renderables, water)
+
+	// Forests are scattered over the terrain as it streams in, thinned out into clumps by a noise mask.
+	terr.AddScatter(terrain.ScatterRule{
+		Template:  objRenderable.Copy(),
+		Spacing:   16,
+		MinHeight: seaLevel + 1,
+		MaxHeight: 38,
+		MaxSlope:  .3,
+		Mask:      terrain.NewFBM(terrain.NoiseSettings{Seed: *terrainSeed + 1, Octaves: 2, Frequency: .004, Lacunarity: 2, Gain: .5}),
+		MinScale:  .8,
+		MaxScale:  1.2,
+		Seed:      *terrainSeed + 1,
+	})
 
 	startTime := glfw.GetTime()
 	benchmarkStarted := false
 	const warmupDuration = 1.0
 	const benchmarkDuration = 5.0
 
+	frame := 0
 	for !gfx.Window.ShouldClose() {
 		if *benchmarkMode {
 			elapsed := glfw.GetTime() - startTime
 			if !benchmarkStarted && elapsed >= warmupDuration {
 				benchmark.RecordMode = true
 				benchmarkStarted = true
-				log.Println("Warmup complete. Starting benchmark collection (5 seconds)...")
+				if player != nil {
+					log.Printf("Warmup complete. Starting benchmark collection (%.1f second path)...", player.Path.Duration())
+				} else {
+					log.Println("Warmup complete. Starting benchmark collection (5 seconds)...")
+				}
 			}
-			if elapsed >= warmupDuration+benchmarkDuration {
+			if player != nil {
+				if player.Done() {
+					break
+				}
+			} else if elapsed >= warmupDuration+benchmarkDuration {
 				break
 			}
 		}
@@ -142,18 +240,44 @@ func main() {
 		benchmark.End("Input Update")
 
 		benchmark.Start("Sky Update")
+		sky.SetTime(glfw.GetTime() - startTime)
 		sky.Update()
+		water.SetTime(glfw.GetTime() - startTime)
 		benchmark.End("Sky Update")
 
 		benchmark.Start("Camera Update")
-		gfx.FirstPerson.Update(GetPreviousFrameLength())
-		gfx.ThirdPerson.Update(GetPreviousFrameLength())
+		step := GetPreviousFrameLength()
+		if *pathStep > 0 {
+			// A fixed step flies the path the same way however long frames take to render.
+			step = *pathStep
+		}
+		if *benchmarkMode && !benchmarkStarted {
+			// The camera waits at the start of the path until the warmup is over.
+			step = 0
+		}
+		gfx.UpdateCameras(step)
+		if player != nil && benchmarkStarted {
+			benchmark.SetPosition(player.GetTime())
+		}
+		if recorder != nil {
+			recorder.Update(gfx.ActiveCamera.GetPose(), step)
+		}
 		benchmark.End("Camera Update")
 
 		benchmark.Start("Render")
 		gfx.Renderer.Render(sky, renderables)
 		benchmark.End("Render")
 
+		if player != nil && *pathFrames != "" {
+			if err := gfx.SaveFrame(filepath.Join(*pathFrames, fmt.Sprintf("%05d.png", frame))); err != nil {
+				log.Fatalln("failed to save frame:", err)
+			}
+			if player.Done() {
+				break
+			}
+		}
+		frame++
+
 		benchmark.Start("Renderer Update")
 		gfx.Renderer.Update(updateables)
 		benchmark.End("Renderer Update")
@@ -174,6 +298,14 @@ func main() {
 
 	if *benchmarkMode {
 		benchmark.WriteSummary()
+		if player != nil {
+			benchmark.WritePathSummary(*pathSegments)
+		}
+	}
+	if recorder != nil {
+		if err := recorder.Path().Save(*recordPath); err != nil {
+			log.Println("failed to save camera path:", err)
+		}
 	}
 }
 
@@ -196,9 +328,9 @@ func runRenderTests() {
 	gfx.InitDirectionalLights()
 	gfx.InitPip()
 
-	sky, err := gfx.NewSky()
+	atmosphere, err := gfx.NewAtmosphere()
 	if err != nil {
-		log.Fatalf("NewSky: %v", err)
+		log.Fatalf("NewAtmosphere: %v", err)
 	}
 
 	if err := os.MkdirAll(*renderOut, 0755); err != nil {
@@ -225,8 +357,15 @@ func runRenderTests() {
 		// Setup also returns the scene's renderables.
 		renderables := scene.Setup()
 
-		// Advance the camera one tick so shadow matrices are computed.
-		gfx.FirstPerson.Update(0)
+		var sky gfx.Sky = atmosphere
+		if scene.Sky != nil {
+			if sky, err = scene.Sky(); err != nil {
+				log.Fatalf("Sky for %s: %v", scene.Name, err)
+			}
+		}
+
+		// Advance the cameras one tick so shadow matrices are computed.
+		gfx.UpdateCameras(0)
 
 		// Update the sky vertices for the current camera orientation.
 		sky.Update()
@@ -253,6 +392,9 @@ func runRenderTests() {
 		}
 		f.Close()
 		fbo.Delete()
+		if scene.Cleanup != nil {
+			scene.Cleanup(renderables)
+		}
 
 		log.Printf("wrote: %s", outPath)
 	}
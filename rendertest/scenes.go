@@ -10,6 +10,7 @@ import (
 	"math"
 
 	"github.com/brandonnelson3/GoRender/gfx"
+	"github.com/brandonnelson3/GoRender/terrain"
 	"github.com/go-gl/mathgl/mgl32"
 )
 
@@ -23,6 +24,12 @@ type Scene struct {
 	// It must configure all global gfx state (camera, lights, etc.) deterministically
 	// and return the list of renderables to include in the frame.
 	Setup func() []gfx.Renderable
+	// Sky optionally builds the sky for this scene, such as a studio HDRI skybox.
+	// When nil the procedural atmosphere is used.
+	Sky func() (gfx.Sky, error)
+	// Cleanup optionally releases what Setup created, such as terrain generating in the
+	// background. It is called with Setup's renderables once the frame has been captured.
+	Cleanup func(renderables []gfx.Renderable)
 }
 
 // All is the canonical list of render-test scenes.
@@ -60,6 +67,32 @@ var All = []Scene{
 			return nil
 		},
 	},
+	{
+		Name:   "sky_clouds",
+		Width:  1920,
+		Height: 1080,
+		Setup: func() []gfx.Renderable {
+			// Camera looking up into the clouds with the afternoon sun behind it.
+			gfx.FirstPerson.SetPose(mgl32.Vec3{0, 5, 0}, 0, 0.4)
+			gfx.ActiveCamera = gfx.FirstPerson
+
+			gfx.ResetDirectionalLight(mgl32.Vec3{1, 0.95, 0.9}, 1.0, mgl32.Vec3{-1, -1, 0.3}.Normalize())
+			gfx.ResetPointLights()
+
+			return nil
+		},
+		Sky: func() (gfx.Sky, error) {
+			sky, err := gfx.NewAtmosphere()
+			if err != nil {
+				return nil, err
+			}
+			// Halfway through a tween, so both the wind and the tween are covered by the golden.
+			sky.SetClouds(gfx.CloudSettings{Coverage: 0.3, Density: 0.6, WindSpeed: 40, WindDirection: 0})
+			sky.TweenClouds(gfx.CloudSettings{Coverage: 0.6, Density: 0.9, WindSpeed: 40, WindDirection: 1}, 10)
+			sky.SetTime(5)
+			return sky, nil
+		},
+	},
 	{
 		Name:   "corner_room",
 		Width:  1920,
@@ -102,8 +135,20 @@ var All = []Scene{
 		Height: 1080,
 		Setup:  setupFloatingCrate,
 	},
+	{
+		Name:   "terrain_hills",
+		Width:  1920,
+		Height: 1080,
+		Setup:  setupTerrainHills,
+		Cleanup: func(renderables []gfx.Renderable) {
+			renderables[0].(*terrain.Terrain).Close()
+		},
+	},
 }
 
+// terrainSeed is the seed of the terrain in the terrain scenes. Changing it changes their goldens.
+const terrainSeed = 7
+
 // setupCornerRoom builds a small interior corner:
 //   - Sand-textured floor
 //   - Brick-textured left wall  (XZ plane, running along X)
@@ -637,3 +682,31 @@ func setupFloatingCrate() []gfx.Renderable {
 
 	return renderables
 }
+
+// setupTerrainHills creates a scene looking out over the default terrain from above, lit by an
+// afternoon sun. Every cell in range is generated before the frame is captured, so the whole
+// terrain is in the golden.
+func setupTerrainHills() []gfx.Renderable {
+	gfx.SetRenderMode(0)
+
+	pos := mgl32.Vec3{64, 70, 64}
+	gfx.FirstPerson.SetPose(
+		pos,
+		float32(math.Pi/4), // looking toward +X,-Z
+		-0.3,               // down over the hills
+	)
+	gfx.FirstPerson.SetFrustumRendering(false)
+	gfx.ActiveCamera = gfx.FirstPerson
+
+	gfx.ResetDirectionalLight(mgl32.Vec3{1, 0.95, 0.85}, 1.0, mgl32.Vec3{-1, -1, 0.5}.Normalize())
+	gfx.ResetPointLights()
+
+	terr, err := terrain.NewTerrain(terrain.DefaultHeightSource(terrainSeed))
+	if err != nil {
+		log.Fatalf("rendertest: creating terrain: %v", err)
+	}
+	if err := terr.GenerateAround(pos); err != nil {
+		log.Fatalf("rendertest: generating terrain: %v", err)
+	}
+	return []gfx.Renderable{terr}
+}
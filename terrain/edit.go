@@ -0,0 +1,321 @@
+package terrain
+
+import (
+	"bufio"
+	"encoding/binary"
+	"fmt"
+	"math"
+	"os"
+	"sync"
+
+	"github.com/go-gl/mathgl/mgl32"
+)
+
+const (
+	// editsMagic and editsVersion identify a file written by SaveEdits.
+	editsMagic   = uint32(0x45545247) // "GRTE"
+	editsVersion = uint32(1)
+
+	// editPainted flags an edit cell saved with paint weights.
+	editPainted = uint32(1)
+)
+
+// BrushMode selects what a Brush does to the terrain.
+type BrushMode int
+
+const (
+	// BrushRaise raises the terrain by the brush's strength.
+	BrushRaise BrushMode = iota
+	// BrushLower lowers the terrain by the brush's strength.
+	BrushLower
+	// BrushSmooth moves the terrain toward the average of its neighbours.
+	BrushSmooth
+	// BrushFlatten moves the terrain toward the height of the brush's center.
+	BrushFlatten
+	// BrushPaint paints the brush's material layer over the terrain.
+	BrushPaint
+)
+
+// Brush is an edit applied to the terrain around a point.
+type Brush struct {
+	Mode BrushMode
+	// Radius is the distance from the center the brush reaches.
+	Radius float32
+	// Falloff is the fraction of the radius, from the edge inward, over which the brush fades out.
+	Falloff float32
+	// Strength is how far a raise or lower moves the terrain, or the fraction of the way a smooth, flatten or
+	// paint goes toward its target, at the center of the brush.
+	Strength float32
+	// Layer is the material layer painted by BrushPaint.
+	Layer int
+}
+
+// weight returns how strongly the brush applies at distance d from its center.
+func (b Brush) weight(d float32) float32 {
+	if d >= b.Radius {
+		return 0
+	}
+	inner := b.Radius * (1 - b.Falloff)
+	if d <= inner {
+		return 1
+	}
+	f := 1 - (d-inner)/(b.Radius-inner)
+	return f * f * (3 - 2*f)
+}
+
+// editCell holds the edits of the world grid points of one cell, indexed x*cellsize+z from its corner.
+type editCell struct {
+	height [cellsize * cellsize]float32
+	// paint holds the painted layer weights, and is nil until the cell is painted.
+	paint []mgl32.Vec4
+}
+
+// editLayer holds the edits made to the terrain on top of its source.
+type editLayer struct {
+	mu    sync.RWMutex
+	cells map[cellId]*editCell
+}
+
+// floorDiv returns a divided by b, rounded down.
+func floorDiv(a, b int32) int32 {
+	q := a / b
+	if a%b != 0 && (a < 0) != (b < 0) {
+		q--
+	}
+	return q
+}
+
+// editIndex returns the edit cell holding the world grid point (x, z), and the point's index within it.
+func editIndex(x, z int32) (cellId, int) {
+	id := cellId{floorDiv(x, cellsize), floorDiv(z, cellsize)}
+	return id, int((x-id.x*cellsize)*cellsize + z - id.z*cellsize)
+}
+
+// height returns the height edit at the world grid point (x, z). Must be called with e.mu held.
+func (e *editLayer) height(x, z int32) float32 {
+	id, i := editIndex(x, z)
+	if c, ok := e.cells[id]; ok {
+		return c.height[i]
+	}
+	return 0
+}
+
+// paint returns the painted layer weights at the world grid point (x, z).
+func (e *editLayer) paint(x, z int32) mgl32.Vec4 {
+	e.mu.RLock()
+	defer e.mu.RUnlock()
+	id, i := editIndex(x, z)
+	if c, ok := e.cells[id]; ok && c.paint != nil {
+		return c.paint[i]
+	}
+	return mgl32.Vec4{}
+}
+
+// cell returns the edit cell id, creating it if needed. Must be called with e.mu held for writing.
+func (e *editLayer) cell(id cellId) *editCell {
+	if e.cells == nil {
+		e.cells = make(map[cellId]*editCell)
+	}
+	c, ok := e.cells[id]
+	if !ok {
+		c = &editCell{}
+		e.cells[id] = c
+	}
+	return c
+}
+
+// heightEdit returns the height edit at (x, z), interpolated between the grid points around it.
+func (e *editLayer) heightEdit(x, z float32) float32 {
+	e.mu.RLock()
+	defer e.mu.RUnlock()
+	if len(e.cells) == 0 {
+		return 0
+	}
+	x0, z0 := int32(math.Floor(float64(x))), int32(math.Floor(float64(z)))
+	fx, fz := x-float32(x0), z-float32(z0)
+	h := e.height(x0, z0) * (1 - fx) * (1 - fz)
+	if fx > 0 {
+		h += e.height(x0+1, z0) * fx * (1 - fz)
+	}
+	if fz > 0 {
+		h += e.height(x0, z0+1) * (1 - fx) * fz
+	}
+	if fx > 0 && fz > 0 {
+		h += e.height(x0+1, z0+1) * fx * fz
+	}
+	return h
+}
+
+// ApplyBrush applies b to the terrain around center, and regenerates the cells it changed. Cells keep being
+// drawn as they were until they have been regenerated.
+func (t *Terrain) ApplyBrush(b Brush, center mgl32.Vec3) error {
+	if b.Radius <= 0 {
+		return fmt.Errorf("terrain brush radius must be greater than zero, got %v", b.Radius)
+	}
+	if b.Falloff < 0 || b.Falloff > 1 {
+		return fmt.Errorf("terrain brush falloff must be within [0, 1], got %v", b.Falloff)
+	}
+	if b.Mode == BrushPaint && (b.Layer < 0 || b.Layer >= MaxLayers) {
+		return fmt.Errorf("terrain brush layer %d is not within [0, %d)", b.Layer, MaxLayers)
+	}
+	minX, maxX := int32(math.Ceil(float64(center.X()-b.Radius))), int32(math.Floor(float64(center.X()+b.Radius)))
+	minZ, maxZ := int32(math.Ceil(float64(center.Z()-b.Radius))), int32(math.Floor(float64(center.Z()+b.Radius)))
+	if minX > maxX || minZ > maxZ {
+		return nil
+	}
+
+	// Heights are read before the edits are locked, since reading them locks the edits too. Smoothing needs
+	// one more point on every side.
+	w, d := maxX-minX+3, maxZ-minZ+3
+	heights := make([]float32, w*d)
+	for x := range w {
+		for z := range d {
+			heights[x*d+z] = t.vertexHeight(minX-1+x, minZ-1+z)
+		}
+	}
+	heightAt := func(x, z int32) float32 {
+		return heights[(x-minX+1)*d+z-minZ+1]
+	}
+
+	t.edits.mu.Lock()
+	for x := minX; x <= maxX; x++ {
+		for z := minZ; z <= maxZ; z++ {
+			dist := mgl32.Vec2{float32(x) - center.X(), float32(z) - center.Z()}.Len()
+			weight := b.weight(dist)
+			if weight == 0 {
+				continue
+			}
+			id, i := editIndex(x, z)
+			c := t.edits.cell(id)
+			amount := min(b.Strength*weight, 1)
+			h := heightAt(x, z)
+			switch b.Mode {
+			case BrushRaise:
+				c.height[i] += b.Strength * weight
+			case BrushLower:
+				c.height[i] -= b.Strength * weight
+			case BrushSmooth:
+				var sum float32
+				for dx := int32(-1); dx <= 1; dx++ {
+					for dz := int32(-1); dz <= 1; dz++ {
+						sum += heightAt(x+dx, z+dz)
+					}
+				}
+				c.height[i] += (sum/9 - h) * amount
+			case BrushFlatten:
+				c.height[i] += (center.Y() - h) * amount
+			case BrushPaint:
+				if c.paint == nil {
+					c.paint = make([]mgl32.Vec4, cellsize*cellsize)
+				}
+				var target mgl32.Vec4
+				target[b.Layer] = 1
+				c.paint[i] = c.paint[i].Mul(1 - amount).Add(target.Mul(amount))
+			}
+		}
+	}
+	t.edits.mu.Unlock()
+
+	// A cell's vertices and normals use the cellsizep1p2 grid points from its corner to two past its far edge.
+	var ids []cellId
+	for x := floorDiv(minX-cellsizep1p2+cellsize, cellsize); x <= floorDiv(maxX, cellsize); x++ {
+		for z := floorDiv(minZ-cellsizep1p2+cellsize, cellsize); z <= floorDiv(maxZ, cellsize); z++ {
+			ids = append(ids, cellId{x, z})
+		}
+	}
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.regenerate(ids)
+	return nil
+}
+
+// SaveEdits writes every edit made to the terrain to file.
+func (t *Terrain) SaveEdits(file string) error {
+	f, err := os.Create(file)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	w := bufio.NewWriter(f)
+
+	t.edits.mu.RLock()
+	defer t.edits.mu.RUnlock()
+	write := func(data any) {
+		if err == nil {
+			err = binary.Write(w, binary.LittleEndian, data)
+		}
+	}
+	write([]uint32{editsMagic, editsVersion, uint32(len(t.edits.cells))})
+	for id, c := range t.edits.cells {
+		flags := uint32(0)
+		if c.paint != nil {
+			flags |= editPainted
+		}
+		write([]int32{id.x, id.z})
+		write(flags)
+		write(c.height[:])
+		if c.paint != nil {
+			write(c.paint)
+		}
+	}
+	if err != nil {
+		return err
+	}
+	if err := w.Flush(); err != nil {
+		return err
+	}
+	return f.Close()
+}
+
+// LoadEdits replaces the edits made to the terrain with those SaveEdits wrote to file, and regenerates every cell.
+func (t *Terrain) LoadEdits(file string) error {
+	f, err := os.Open(file)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	r := bufio.NewReader(f)
+
+	var header [3]uint32
+	if err := binary.Read(r, binary.LittleEndian, &header); err != nil {
+		return err
+	}
+	if header[0] != editsMagic {
+		return fmt.Errorf("%v is not a terrain edits file", file)
+	}
+	if header[1] != editsVersion {
+		return fmt.Errorf("%v has unsupported terrain edits version %d", file, header[1])
+	}
+	// The map grows as cells are read, so a corrupt count fails at the end of the file instead of allocating.
+	cells := make(map[cellId]*editCell)
+	for range header[2] {
+		var id [2]int32
+		var flags uint32
+		c := &editCell{}
+		if err := binary.Read(r, binary.LittleEndian, &id); err != nil {
+			return err
+		}
+		if err := binary.Read(r, binary.LittleEndian, &flags); err != nil {
+			return err
+		}
+		if err := binary.Read(r, binary.LittleEndian, &c.height); err != nil {
+			return err
+		}
+		if flags&editPainted != 0 {
+			c.paint = make([]mgl32.Vec4, cellsize*cellsize)
+			if err := binary.Read(r, binary.LittleEndian, c.paint); err != nil {
+				return err
+			}
+		}
+		cells[cellId{id[0], id[1]}] = c
+	}
+
+	t.edits.mu.Lock()
+	t.edits.cells = cells
+	t.edits.mu.Unlock()
+
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.regenerateAll()
+	return nil
+}
@@ -0,0 +1,195 @@
+package terrain
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"sort"
+	"sync"
+	"testing"
+
+	"github.com/go-gl/mathgl/mgl32"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestBrushWeight(t *testing.T) {
+	hard := Brush{Radius: 4}
+	assert.Equal(t, float32(1), hard.weight(0))
+	assert.Equal(t, float32(1), hard.weight(3.9))
+	assert.Equal(t, float32(0), hard.weight(4))
+
+	// With half the radius fading out, the weight is full inside it, and half way down the fade.
+	soft := Brush{Radius: 4, Falloff: .5}
+	assert.Equal(t, float32(1), soft.weight(2))
+	assert.InDelta(t, .5, soft.weight(3), 1e-6)
+	assert.Greater(t, soft.weight(2.5), soft.weight(3.5))
+	assert.Equal(t, float32(0), soft.weight(5))
+
+	full := Brush{Radius: 4, Falloff: 1}
+	assert.Equal(t, float32(1), full.weight(0))
+	assert.InDelta(t, .5, full.weight(2), 1e-6)
+}
+
+func TestApplyBrushRaiseLower(t *testing.T) {
+	terrain := newTestTerrain(Constant(10))
+	raise := Brush{Mode: BrushRaise, Radius: 3, Strength: 2}
+	assert.NoError(t, terrain.ApplyBrush(raise, mgl32.Vec3{0, 10, 0}))
+	assert.InDelta(t, 12, terrain.GetHeight(0, 0), 1e-6)
+	assert.InDelta(t, 12, terrain.GetHeight(2, -2), 1e-6)
+	assert.InDelta(t, 10, terrain.GetHeight(3, 0), 1e-6)
+	// Between grid points, the edit is interpolated.
+	assert.InDelta(t, 11, terrain.GetHeight(2.5, 2), 1e-6)
+
+	lower := raise
+	lower.Mode = BrushLower
+	assert.NoError(t, terrain.ApplyBrush(lower, mgl32.Vec3{0, 10, 0}))
+	assert.NoError(t, terrain.ApplyBrush(lower, mgl32.Vec3{0, 10, 0}))
+	assert.InDelta(t, 8, terrain.GetHeight(0, 0), 1e-6)
+	assert.InDelta(t, 10, terrain.GetHeight(3, 0), 1e-6)
+}
+
+func TestApplyBrushSmooth(t *testing.T) {
+	// A spike of 9 at one point smooths to the average of the 3x3 points around it.
+	terrain := newTestTerrain(Constant(10))
+	assert.NoError(t, terrain.ApplyBrush(Brush{Mode: BrushRaise, Radius: .5, Strength: 9}, mgl32.Vec3{}))
+	assert.InDelta(t, 19, terrain.GetHeight(0, 0), 1e-6)
+	assert.InDelta(t, 10, terrain.GetHeight(1, 0), 1e-6)
+
+	assert.NoError(t, terrain.ApplyBrush(Brush{Mode: BrushSmooth, Radius: .5, Strength: .5}, mgl32.Vec3{}))
+	assert.InDelta(t, 15, terrain.GetHeight(0, 0), 1e-5)
+	assert.NoError(t, terrain.ApplyBrush(Brush{Mode: BrushSmooth, Radius: .5, Strength: 1}, mgl32.Vec3{}))
+	assert.InDelta(t, 10+5.0/9, terrain.GetHeight(0, 0), 1e-5)
+}
+
+func TestApplyBrushFlatten(t *testing.T) {
+	terrain := newTestTerrain(HeightFunc(func(x, z float64) float64 {
+		return x
+	}))
+	assert.NoError(t, terrain.ApplyBrush(Brush{Mode: BrushFlatten, Radius: 3, Strength: .5}, mgl32.Vec3{0, 4, 0}))
+	for x := float32(-2); x <= 2; x++ {
+		assert.InDelta(t, (x+4)/2, terrain.GetHeight(x, 1), 1e-5, "at %v", x)
+	}
+	assert.NoError(t, terrain.ApplyBrush(Brush{Mode: BrushFlatten, Radius: 3, Strength: 1}, mgl32.Vec3{0, 4, 0}))
+	for x := float32(-2); x <= 2; x++ {
+		assert.InDelta(t, 4, terrain.GetHeight(x, 1), 1e-5, "at %v", x)
+	}
+	assert.InDelta(t, 3, terrain.GetHeight(3, 0), 1e-5)
+}
+
+func TestApplyBrushPaint(t *testing.T) {
+	terrain := newTestTerrain(Constant(0))
+	assert.Equal(t, mgl32.Vec4{}, terrain.edits.paint(0, 0))
+
+	assert.NoError(t, terrain.ApplyBrush(Brush{Mode: BrushPaint, Radius: 2, Strength: 1, Layer: 2}, mgl32.Vec3{}))
+	assert.Equal(t, mgl32.Vec4{0, 0, 1, 0}, terrain.edits.paint(0, 0))
+	assert.NoError(t, terrain.ApplyBrush(Brush{Mode: BrushPaint, Radius: 2, Strength: .25, Layer: 1}, mgl32.Vec3{}))
+	assert.True(t, terrain.edits.paint(1, -1).ApproxEqual(mgl32.Vec4{0, .25, .75, 0}))
+	assert.Equal(t, mgl32.Vec4{}, terrain.edits.paint(2, 0))
+	// Painting leaves the height alone.
+	assert.Equal(t, float32(0), terrain.GetHeight(0, 0))
+}
+
+func TestApplyBrushRejectsInvalidBrushes(t *testing.T) {
+	terrain := newTestTerrain(Constant(0))
+	for _, b := range []Brush{
+		{Mode: BrushRaise, Radius: 0},
+		{Mode: BrushRaise, Radius: 1, Falloff: -.5},
+		{Mode: BrushRaise, Radius: 1, Falloff: 1.5},
+		{Mode: BrushPaint, Radius: 1, Layer: MaxLayers},
+		{Mode: BrushPaint, Radius: 1, Layer: -1},
+	} {
+		assert.Error(t, terrain.ApplyBrush(b, mgl32.Vec3{}), "%+v", b)
+	}
+	assert.Empty(t, terrain.edits.cells)
+}
+
+// requestedCells applies b at center to a terrain with every cell near the origin waiting to be regenerated,
+// and returns the cells it requested, in order.
+func requestedCells(t *testing.T, b Brush, center mgl32.Vec3) []cellId {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	terrain := &Terrain{
+		source:  Constant(0),
+		ctx:     ctx,
+		pending: make(map[cellId]*cellRequest),
+		failed:  make(map[cellId]error),
+	}
+	terrain.wake = sync.NewCond(&terrain.mu)
+	for x := int32(-3); x <= 3; x++ {
+		for z := int32(-3); z <= 3; z++ {
+			terrain.failed[cellId{x, z}] = errors.New("not generated")
+		}
+	}
+	assert.NoError(t, terrain.ApplyBrush(b, center))
+
+	var ids []cellId
+	for id := range terrain.pending {
+		ids = append(ids, id)
+	}
+	sort.Slice(ids, func(i, j int) bool {
+		return ids[i].x < ids[j].x || ids[i].x == ids[j].x && ids[i].z < ids[j].z
+	})
+	return ids
+}
+
+func TestApplyBrushRegeneratesTheCellsItChanged(t *testing.T) {
+	// Cell n is generated from the grid points n*cellsize to n*cellsize+cellsize+2, so the brush's points -2 to 2
+	// change cells -1 and 0 along both axes.
+	b := Brush{Mode: BrushRaise, Radius: 2, Strength: 1}
+	assert.Equal(t, []cellId{{-1, -1}, {-1, 0}, {0, -1}, {0, 0}}, requestedCells(t, b, mgl32.Vec3{}))
+
+	// Points 131 and 132 are past the last point of cell 0, at 130.
+	b.Radius = 1
+	x := float32(cellsizep1p2) + .5
+	assert.Equal(t, []cellId{{1, -1}, {1, 0}}, requestedCells(t, b, mgl32.Vec3{x, 0, 0}))
+	// Point 130 is the last point of cell 0, and the first of cell 1 is 128.
+	assert.Equal(t, []cellId{{0, -1}, {0, 0}, {1, -1}, {1, 0}}, requestedCells(t, b, mgl32.Vec3{x - 1, 0, 0}))
+
+	// A brush between grid points changes nothing.
+	b.Radius = .25
+	assert.Empty(t, requestedCells(t, b, mgl32.Vec3{.5, 0, .5}))
+}
+
+func TestSaveLoadEdits(t *testing.T) {
+	// The brushes cross the corner of four cells, and only some of them are painted.
+	terrain := newTestTerrain(Constant(0))
+	assert.NoError(t, terrain.ApplyBrush(Brush{Mode: BrushRaise, Radius: 5, Falloff: .5, Strength: 3}, mgl32.Vec3{}))
+	assert.NoError(t, terrain.ApplyBrush(Brush{Mode: BrushPaint, Radius: 2, Strength: .5, Layer: 3}, mgl32.Vec3{2, 0, 2}))
+	file := filepath.Join(t.TempDir(), "edits")
+	assert.NoError(t, terrain.SaveEdits(file))
+
+	loaded := newTestTerrain(Constant(0))
+	assert.NoError(t, loaded.ApplyBrush(Brush{Mode: BrushLower, Radius: 2, Strength: 1}, mgl32.Vec3{500, 0, 500}))
+	assert.NoError(t, loaded.LoadEdits(file))
+	assert.Equal(t, terrain.edits.cells, loaded.edits.cells)
+	assert.Equal(t, float32(0), loaded.GetHeight(500, 500), "loading replaces the edits")
+	assert.Equal(t, terrain.GetHeight(1.5, -.5), loaded.GetHeight(1.5, -.5))
+	assert.Equal(t, mgl32.Vec4{0, 0, 0, .5}, loaded.edits.paint(2, 2))
+}
+
+func TestLoadEditsRejectsOtherFiles(t *testing.T) {
+	dir := t.TempDir()
+	terrain := newTestTerrain(Constant(0))
+	assert.NoError(t, terrain.ApplyBrush(Brush{Mode: BrushRaise, Radius: 2, Strength: 1}, mgl32.Vec3{}))
+	good := filepath.Join(dir, "good")
+	assert.NoError(t, terrain.SaveEdits(good))
+	data, err := os.ReadFile(good)
+	assert.NoError(t, err)
+
+	corrupt := map[string][]byte{
+		"empty":     nil,
+		"magic":     append([]byte{'x'}, data[1:]...),
+		"version":   append(append(append([]byte{}, data[:4]...), 9, 0, 0, 0), data[8:]...),
+		"truncated": data[:len(data)-1],
+		// A header claiming billions of cells fails when the file runs out, without allocating them first.
+		"count": append(append(append([]byte{}, data[:8]...), 0xff, 0xff, 0xff, 0xff), data[12:]...),
+	}
+	for name, contents := range corrupt {
+		file := filepath.Join(dir, name)
+		assert.NoError(t, os.WriteFile(file, contents, 0644))
+		loaded := newTestTerrain(Constant(0))
+		assert.Error(t, loaded.LoadEdits(file), name)
+		assert.Empty(t, loaded.edits.cells, name)
+	}
+}
@@ -0,0 +1,112 @@
+package terrain
+
+import (
+	"encoding/binary"
+	"fmt"
+	"image"
+	"image/color"
+	_ "image/png" // Register the png decoder for image.Decode.
+	"io"
+	"math"
+	"strings"
+
+	"github.com/brandonnelson3/GoRender/loader"
+)
+
+const (
+	pngExt = ".png"
+	rawExt = ".raw"
+	r16Ext = ".r16"
+)
+
+// Heightmap is a HeightSource which bilinearly interpolates a grid of height samples. Positions
+// outside the grid take the height of the nearest edge.
+type Heightmap struct {
+	width, height int
+	// samples are in [0, 1], row by row along z.
+	samples []float64
+
+	// Spacing is the world distance between neighbouring samples.
+	Spacing float64
+	// HeightScale is the height of a full scale sample.
+	HeightScale float64
+	// OriginX and OriginZ are the world position of the first sample.
+	OriginX, OriginZ float64
+}
+
+// NewHeightmap instantiates a Heightmap from width*height 16-bit samples, stored row by row along z.
+func NewHeightmap(width, height int, samples []uint16, spacing, heightScale float64) (*Heightmap, error) {
+	if width < 2 || height < 2 || len(samples) != width*height {
+		return nil, fmt.Errorf("heightmap needs at least 2x2 samples and exactly width*height of them, got %d for %dx%d", len(samples), width, height)
+	}
+	h := &Heightmap{
+		width:       width,
+		height:      height,
+		samples:     make([]float64, len(samples)),
+		Spacing:     spacing,
+		HeightScale: heightScale,
+	}
+	for i, s := range samples {
+		h.samples[i] = float64(s) / math.MaxUint16
+	}
+	return h, nil
+}
+
+// LoadHeightmap loads a heightmap from a grayscale .png, ideally 16-bit, or from a square .raw or
+// .r16 file of little endian 16-bit samples.
+func LoadHeightmap(file string, spacing, heightScale float64) (*Heightmap, error) {
+	r, err := loader.Load(file)
+	if err != nil {
+		return nil, err
+	}
+	switch {
+	case strings.HasSuffix(file, pngExt):
+		img, _, err := image.Decode(r)
+		if err != nil {
+			return nil, err
+		}
+		bounds := img.Bounds()
+		width, height := bounds.Dx(), bounds.Dy()
+		samples := make([]uint16, 0, width*height)
+		for y := bounds.Min.Y; y < bounds.Max.Y; y++ {
+			for x := bounds.Min.X; x < bounds.Max.X; x++ {
+				samples = append(samples, color.Gray16Model.Convert(img.At(x, y)).(color.Gray16).Y)
+			}
+		}
+		return NewHeightmap(width, height, samples, spacing, heightScale)
+	case strings.HasSuffix(file, rawExt), strings.HasSuffix(file, r16Ext):
+		b, err := io.ReadAll(r)
+		if err != nil {
+			return nil, err
+		}
+		size := int(math.Sqrt(float64(len(b) / 2)))
+		if len(b)%2 != 0 || size*size*2 != len(b) {
+			return nil, fmt.Errorf("raw heightmap %v must be a square of 16-bit samples, got %d bytes", file, len(b))
+		}
+		samples := make([]uint16, size*size)
+		for i := range samples {
+			samples[i] = binary.LittleEndian.Uint16(b[i*2:])
+		}
+		return NewHeightmap(size, size, samples, spacing, heightScale)
+	}
+	return nil, fmt.Errorf("Attempted to load heightmap from unsupported file type: %v", file)
+}
+
+func (h *Heightmap) at(x, z int) float64 {
+	x = max(0, min(h.width-1, x))
+	z = max(0, min(h.height-1, z))
+	return h.samples[z*h.width+x]
+}
+
+// Height returns the interpolated height at (x, z).
+func (h *Heightmap) Height(x, z float64) float64 {
+	fx := (x - h.OriginX) / h.Spacing
+	fz := (z - h.OriginZ) / h.Spacing
+	x0, z0 := math.Floor(fx), math.Floor(fz)
+	tx, tz := fx-x0, fz-z0
+	ix, iz := int(x0), int(z0)
+
+	top := h.at(ix, iz)*(1-tx) + h.at(ix+1, iz)*tx
+	bottom := h.at(ix, iz+1)*(1-tx) + h.at(ix+1, iz+1)*tx
+	return (top*(1-tz) + bottom*tz) * h.HeightScale
+}
@@ -0,0 +1,64 @@
+package terrain
+
+import "math"
+
+// HeightSource is a heightfield that terrain is generated from.
+type HeightSource interface {
+	// Height returns the height of the terrain at the world position (x, z).
+	Height(x, z float64) float64
+}
+
+// HeightFunc adapts an ordinary function to a HeightSource.
+type HeightFunc func(x, z float64) float64
+
+// Height returns f(x, z).
+func (f HeightFunc) Height(x, z float64) float64 {
+	return f(x, z)
+}
+
+// Constant is a HeightSource which is the same height everywhere.
+type Constant float64
+
+// Height returns c.
+func (c Constant) Height(x, z float64) float64 {
+	return float64(c)
+}
+
+// Add returns a HeightSource which is the sum of all of sources.
+func Add(sources ...HeightSource) HeightSource {
+	return HeightFunc(func(x, z float64) float64 {
+		sum := 0.0
+		for _, s := range sources {
+			sum += s.Height(x, z)
+		}
+		return sum
+	})
+}
+
+// Multiply returns a HeightSource which is the product of all of sources.
+func Multiply(sources ...HeightSource) HeightSource {
+	return HeightFunc(func(x, z float64) float64 {
+		product := 1.0
+		for _, s := range sources {
+			product *= s.Height(x, z)
+		}
+		return product
+	})
+}
+
+// Clamp returns a HeightSource which limits source to the range [min, max].
+func Clamp(source HeightSource, min, max float64) HeightSource {
+	return HeightFunc(func(x, z float64) float64 {
+		return math.Max(min, math.Min(max, source.Height(x, z)))
+	})
+}
+
+// Terrace returns a HeightSource which flattens source into steps of the given height. A sharpness
+// of 1 leaves source unchanged, larger values give flatter steps and steeper cliffs between them.
+func Terrace(source HeightSource, step, sharpness float64) HeightSource {
+	return HeightFunc(func(x, z float64) float64 {
+		t := source.Height(x, z) / step
+		k := math.Floor(t)
+		return (k + math.Pow(t-k, sharpness)) * step
+	})
+}
@@ -0,0 +1,206 @@
+package terrain
+
+import (
+	"math"
+	"math/bits"
+
+	"github.com/go-gl/gl/v4.5-core/gl"
+	"github.com/go-gl/mathgl/mgl32"
+)
+
+const (
+	// lodLevels is the number of levels of detail a cell can be drawn at. Level n draws every 2^n-th
+	// vertex, so the coarsest level is a cellsize/16 grid.
+	lodLevels = 5
+	// lodBaseDistance is the distance from the camera within which cells are drawn at full detail.
+	// Every doubling of the distance beyond it drops one level.
+	lodBaseDistance = 256
+)
+
+// stitchMask holds a bit per cell edge whose neighbour is one level coarser, those edges skip
+// every other vertex so they line up with the neighbour without cracks.
+type stitchMask uint8
+
+const (
+	stitchNegX stitchMask = 1 << iota
+	stitchPosX
+	stitchNegZ
+	stitchPosZ
+)
+
+// lodMeshKey identifies one of the index buffers shared by every cell.
+type lodMeshKey struct {
+	level  int
+	stitch stitchMask
+}
+
+// lodMesh is an index buffer drawing a cell's vertices at one level of detail.
+type lodMesh struct {
+	ebo   uint32
+	count int32
+}
+
+// lodLevel returns the level of detail for the cell id, from the horizontal distance between center
+// and the nearest point of the cell.
+func lodLevel(id cellId, center mgl32.Vec3) int {
+	minX, minZ := float64(id.x*cellsize), float64(id.z*cellsize)
+	dx := max(minX-float64(center.X()), 0, float64(center.X())-(minX+float64(cellsize)))
+	dz := max(minZ-float64(center.Z()), 0, float64(center.Z())-(minZ+float64(cellsize)))
+	d := math.Hypot(dx, dz)
+	if d <= lodBaseDistance {
+		return 0
+	}
+	return min(int(math.Log2(d/lodBaseDistance)), lodLevels-1)
+}
+
+// quadFlipped reports whether the quad at (qx, qz) of a grid is split along its other diagonal.
+// Alternating the diagonals keeps the triangulation symmetric.
+func quadFlipped(qx, qz uint32) bool {
+	return qx%2 != qz%2
+}
+
+// lodIndices returns the triangle indices drawing a cell at the given level, with the edges in stitch
+// collapsed onto the next coarser level.
+func lodIndices(level int, stitch stitchMask) []uint32 {
+	step := uint32(1) << level
+	odd := func(c uint32) bool {
+		return c/step%2 == 1
+	}
+	// Vertices on a stitched edge that the coarser neighbour doesn't have are moved onto the
+	// previous vertex along the edge, which turns their triangles into a fan over the coarse edge.
+	// Like calculateIndice, u runs along world z and v along world x.
+	snap := func(u, v uint32) uint32 {
+		switch {
+		case v == 0 && stitch&stitchNegX != 0 && odd(u), v == uint32(cellsize) && stitch&stitchPosX != 0 && odd(u):
+			u -= step
+		case u == 0 && stitch&stitchNegZ != 0 && odd(v), u == uint32(cellsize) && stitch&stitchPosZ != 0 && odd(v):
+			v -= step
+		}
+		return calculateIndice(u, v)
+	}
+
+	quads := uint32(cellsize) / step
+	indices := make([]uint32, 0, quads*quads*6)
+	for qv := range quads {
+		for qu := range quads {
+			u, v := qu*step, qv*step
+			i1 := snap(u, v)
+			i2 := snap(u+step, v)
+			i3 := snap(u, v+step)
+			i4 := snap(u+step, v+step)
+			flipped := quadFlipped(qu, qv)
+			// In a corner with both edges stitched, a diagonal between two moved vertices would
+			// run straight past the vertex inside the corner, so split the quad the other way.
+			if !flipped && i2 != calculateIndice(u+step, v) && i3 != calculateIndice(u, v+step) ||
+				flipped && i1 != calculateIndice(u, v) && i4 != calculateIndice(u+step, v+step) {
+				flipped = !flipped
+			}
+			var triangles [6]uint32
+			if !flipped {
+				// 1-----2
+				// |   / |
+				// | /   |
+				// 3-----4
+				triangles = [6]uint32{i3, i1, i2, i2, i4, i3}
+			} else {
+				// 1-----2
+				// | \   |
+				// |   \ |
+				// 3-----4
+				triangles = [6]uint32{i1, i2, i4, i4, i3, i1}
+			}
+			for t := 0; t < 6; t += 3 {
+				a, b, c := triangles[t], triangles[t+1], triangles[t+2]
+				if a != b && b != c && c != a {
+					indices = append(indices, a, b, c)
+				}
+			}
+		}
+	}
+	return indices
+}
+
+// dropLevel returns the last level of detail that includes the vertex at (x, z) of a cell.
+func dropLevel(x, z uint32) int {
+	return min(bits.TrailingZeros32(x), bits.TrailingZeros32(z), lodLevels-1)
+}
+
+// lodMorphTargets returns, for every vertex of a cell, the height it has once it is removed by the next
+// coarser level of detail and the last level that includes it. height returns the height of the vertex at (x, z).
+func lodMorphTargets(height func(x, z uint32) float32) []mgl32.Vec2 {
+	morph := make([]mgl32.Vec2, 0, cellsizep1*cellsizep1)
+	for x := uint32(0); x <= uint32(cellsize); x++ {
+		for z := uint32(0); z <= uint32(cellsize); z++ {
+			level := dropLevel(x, z)
+			target := height(x, z)
+			if level < lodLevels-1 {
+				// On the coarser grid this vertex is halfway along an edge, or the middle of a quad's diagonal.
+				coarse := uint32(2) << level
+				h := coarse / 2
+				switch {
+				case x%coarse == 0:
+					target = (height(x, z-h) + height(x, z+h)) / 2
+				case z%coarse == 0:
+					target = (height(x-h, z) + height(x+h, z)) / 2
+				case !quadFlipped((x-h)/coarse, (z-h)/coarse):
+					target = (height(x+h, z-h) + height(x-h, z+h)) / 2
+				default:
+					target = (height(x-h, z-h) + height(x+h, z+h)) / 2
+				}
+			}
+			morph = append(morph, mgl32.Vec2{target, float32(level)})
+		}
+	}
+	return morph
+}
+
+// getLodMesh returns the shared index buffer for the given level and stitching, creating it on first use.
+func (t *Terrain) getLodMesh(level int, stitch stitchMask) lodMesh {
+	key := lodMeshKey{level, stitch}
+	if mesh, ok := t.lodMeshes[key]; ok {
+		return mesh
+	}
+	indices := lodIndices(level, stitch)
+	mesh := lodMesh{count: int32(len(indices))}
+	// Element buffer bindings are part of the vertex array state, so make sure none is bound.
+	gl.BindVertexArray(0)
+	gl.GenBuffers(1, &mesh.ebo)
+	gl.BindBuffer(gl.ELEMENT_ARRAY_BUFFER, mesh.ebo)
+	gl.BufferData(gl.ELEMENT_ARRAY_BUFFER, len(indices)*4, gl.Ptr(indices), gl.STATIC_DRAW)
+	gl.BindBuffer(gl.ELEMENT_ARRAY_BUFFER, 0)
+	t.lodMeshes[key] = mesh
+	return mesh
+}
+
+// releaseLodMeshes deletes every shared index buffer.
+func (t *Terrain) releaseLodMeshes() {
+	for key, mesh := range t.lodMeshes {
+		gl.DeleteBuffers(1, &mesh.ebo)
+		delete(t.lodMeshes, key)
+	}
+}
+
+// selectLevels picks the level of detail of every cell for the given center, and stitches the
+// edges of cells next to a coarser neighbour. Must be called with t.mu held.
+func (t *Terrain) selectLevels(center mgl32.Vec3) {
+	for _, c := range t.data {
+		c.lod = lodLevel(c.id, center)
+	}
+	for _, c := range t.data {
+		c.stitch = 0
+		neighbours := []struct {
+			id   cellId
+			edge stitchMask
+		}{
+			{cellId{c.id.x - 1, c.id.z}, stitchNegX},
+			{cellId{c.id.x + 1, c.id.z}, stitchPosX},
+			{cellId{c.id.x, c.id.z - 1}, stitchNegZ},
+			{cellId{c.id.x, c.id.z + 1}, stitchPosZ},
+		}
+		for _, n := range neighbours {
+			if neighbour, ok := t.data[n.id]; ok && neighbour.lod > c.lod {
+				c.stitch |= n.edge
+			}
+		}
+	}
+}
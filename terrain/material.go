@@ -0,0 +1,138 @@
+package terrain
+
+import (
+	"fmt"
+	"image/color"
+
+	"github.com/brandonnelson3/GoRender/gfx"
+	"github.com/brandonnelson3/GoRender/gfx/shaders"
+	"github.com/go-gl/gl/v4.5-core/gl"
+	"github.com/go-gl/mathgl/mgl32"
+)
+
+const (
+	// MaxLayers is the number of texture layers a Material can blend.
+	MaxLayers = 4
+
+	// unbounded is a height beyond any terrain, for layer ranges that are open at one end.
+	unbounded = 1e6
+)
+
+// Layer is one texture of a terrain Material, and the heights and slopes it covers. Slope is 0 for
+// flat ground and 1 for a vertical face.
+type Layer struct {
+	Texture string
+	// Color is drawn instead of the texture if its file doesn't exist.
+	Color                color.RGBA
+	MinHeight, MaxHeight float32
+	MinSlope, MaxSlope   float32
+}
+
+// Material describes how the terrain is textured. Each point is covered by the layers whose height and slope
+// ranges contain it, unless it is inside the splat map, whose red, green, blue and alpha channels give the
+// weight of layers 0 to 3 instead.
+type Material struct {
+	// Layers holds up to MaxLayers layers. Every layer texture must be the same size.
+	Layers []Layer
+	// HeightBlend and SlopeBlend are how far either side of the edge of a range two layers are blended
+	// over, and must be greater than zero.
+	HeightBlend, SlopeBlend float32
+	// TextureSize is the size in world units one repeat of a layer's texture covers.
+	TextureSize float32
+
+	// SplatMap is an optional png painting the layers over the world space rectangle from SplatMapOrigin
+	// (x, z) to SplatMapOrigin+SplatMapSize.
+	SplatMap       string
+	SplatMapOrigin mgl32.Vec2
+	SplatMapSize   mgl32.Vec2
+}
+
+// DefaultMaterial returns a material with sand by the water, grass above it, rock on steep faces and snow on the peaks.
+func DefaultMaterial() Material {
+	return Material{
+		Layers: []Layer{
+			// Slope ranges that should include flat ground or vertical faces reach past 0 and 1, so they
+			// aren't faded out by the blend at their ends.
+			{Texture: "assets/sand.png", Color: color.RGBA{194, 178, 128, 255}, MinHeight: -unbounded, MaxHeight: 21, MinSlope: -1, MaxSlope: 2},
+			{Texture: "assets/grass.png", Color: color.RGBA{86, 125, 70, 255}, MinHeight: 21, MaxHeight: 38, MinSlope: -1, MaxSlope: .35},
+			{Texture: "assets/rock.png", Color: color.RGBA{112, 106, 100, 255}, MinHeight: 21, MaxHeight: unbounded, MinSlope: .35, MaxSlope: 2},
+			{Texture: "assets/snow.png", Color: color.RGBA{238, 242, 248, 255}, MinHeight: 38, MaxHeight: unbounded, MinSlope: -1, MaxSlope: .35},
+		},
+		HeightBlend: 1.5,
+		SlopeBlend:  .05,
+		TextureSize: 8,
+	}
+}
+
+// material is a Material with its textures loaded.
+type material struct {
+	Material
+
+	layers, splatMap uint32
+	// heightMin, heightMax, slopeMin and slopeMax hold the ranges of every layer, one per component.
+	heightMin, heightMax, slopeMin, slopeMax mgl32.Vec4
+}
+
+// loadMaterial loads the textures of m.
+func loadMaterial(m Material) (*material, error) {
+	if len(m.Layers) == 0 || len(m.Layers) > MaxLayers {
+		return nil, fmt.Errorf("terrain material has %d layers, expected 1 to %d", len(m.Layers), MaxLayers)
+	}
+	if m.HeightBlend <= 0 || m.SlopeBlend <= 0 {
+		return nil, fmt.Errorf("terrain material blends must be greater than zero, got %v and %v", m.HeightBlend, m.SlopeBlend)
+	}
+	files := make([]string, len(m.Layers))
+	colors := make([]color.RGBA, len(m.Layers))
+	for i, l := range m.Layers {
+		files[i], colors[i] = l.Texture, l.Color
+	}
+	layers, err := gfx.LoadTextureArray(files, colors)
+	if err != nil {
+		return nil, err
+	}
+	loaded := &material{Material: m, layers: layers}
+
+	if m.SplatMap != "" {
+		loaded.splatMap, err = gfx.LoadTexture(m.SplatMap)
+		if err != nil {
+			return nil, err
+		}
+		// The splat map is sampled inside its rectangle only, and at full resolution.
+		gl.BindTexture(gl.TEXTURE_2D, loaded.splatMap)
+		gl.TexParameteri(gl.TEXTURE_2D, gl.TEXTURE_WRAP_S, gl.CLAMP_TO_EDGE)
+		gl.TexParameteri(gl.TEXTURE_2D, gl.TEXTURE_WRAP_T, gl.CLAMP_TO_EDGE)
+		gl.TexParameterf(gl.TEXTURE_2D, gl.TEXTURE_MIN_LOD, 0)
+		gl.BindTexture(gl.TEXTURE_2D, 0)
+	}
+
+	// Unused layers get an empty range so they never contribute.
+	for i := range MaxLayers {
+		l := Layer{MinHeight: unbounded, MaxHeight: -unbounded, MinSlope: 3, MaxSlope: -2}
+		if i < len(m.Layers) {
+			l = m.Layers[i]
+		}
+		loaded.heightMin[i], loaded.heightMax[i] = l.MinHeight, l.MaxHeight
+		loaded.slopeMin[i], loaded.slopeMax[i] = l.MinSlope, l.MaxSlope
+	}
+	return loaded, nil
+}
+
+// bind sets the color shader up to draw with this material.
+func (m *material) bind(colorShader *shaders.ColorShader) {
+	colorShader.TerrainMaterialEnabled.Set(1)
+	colorShader.TerrainLayers.Set(gl.TEXTURE14, 14, m.layers)
+	colorShader.TerrainHeightMin.Set(m.heightMin)
+	colorShader.TerrainHeightMax.Set(m.heightMax)
+	colorShader.TerrainSlopeMin.Set(m.slopeMin)
+	colorShader.TerrainSlopeMax.Set(m.slopeMax)
+	colorShader.TerrainHeightBlend.Set(m.HeightBlend)
+	colorShader.TerrainSlopeBlend.Set(m.SlopeBlend)
+	colorShader.TerrainTextureScale.Set(1 / m.TextureSize)
+	if m.splatMap != 0 {
+		colorShader.TerrainSplatMapEnabled.Set(1)
+		colorShader.TerrainSplatMap.Set(gl.TEXTURE15, 15, m.splatMap)
+		colorShader.TerrainSplatMapRect.Set(mgl32.Vec4{m.SplatMapOrigin.X(), m.SplatMapOrigin.Y(), m.SplatMapSize.X(), m.SplatMapSize.Y()})
+	} else {
+		colorShader.TerrainSplatMapEnabled.Set(0)
+	}
+}
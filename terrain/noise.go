@@ -0,0 +1,108 @@
+package terrain
+
+import (
+	"math"
+
+	perlin "github.com/aquilax/go-perlin"
+)
+
+// NoiseSettings configure a multi-octave noise HeightSource.
+type NoiseSettings struct {
+	// Seed selects the random gradients of the noise.
+	Seed int64
+	// Octaves is the number of layers of noise summed together.
+	Octaves int
+	// Frequency is the frequency of the first octave, in cycles per world unit.
+	Frequency float64
+	// Lacunarity is how much the frequency grows each octave.
+	Lacunarity float64
+	// Gain is how much the amplitude shrinks each octave.
+	Gain float64
+}
+
+// octaves calls f with the position and amplitude of each octave of s.
+func (s NoiseSettings) octaves(x, z float64, f func(x, z, amplitude float64)) {
+	x, z = x*s.Frequency, z*s.Frequency
+	amplitude := 1.0
+	for i := 0; i < s.Octaves; i++ {
+		f(x, z, amplitude)
+		x, z = x*s.Lacunarity, z*s.Lacunarity
+		amplitude *= s.Gain
+	}
+}
+
+// newGradientNoise returns a single octave of Perlin noise for seed.
+func newGradientNoise(seed int64) *perlin.Perlin {
+	return perlin.NewPerlin(1, 1, 1, seed)
+}
+
+// FBM is a HeightSource of fractional Brownian motion: octaves of Perlin noise summed together.
+// Each octave is roughly in [-1, 1] before it is scaled by its amplitude.
+type FBM struct {
+	settings NoiseSettings
+	noise    *perlin.Perlin
+}
+
+// NewFBM instantiates fractional Brownian motion noise.
+func NewFBM(settings NoiseSettings) *FBM {
+	return &FBM{settings, newGradientNoise(settings.Seed)}
+}
+
+// Height returns the noise at (x, z).
+func (f *FBM) Height(x, z float64) float64 {
+	sum := 0.0
+	f.settings.octaves(x, z, func(x, z, amplitude float64) {
+		sum += f.noise.Noise2D(x, z) * amplitude
+	})
+	return sum
+}
+
+// Ridged is a HeightSource of ridged noise, where each octave is folded about zero to form sharp
+// crests. Each octave is in [0, 1] before it is scaled by its amplitude.
+type Ridged struct {
+	settings NoiseSettings
+	noise    *perlin.Perlin
+}
+
+// NewRidged instantiates ridged noise.
+func NewRidged(settings NoiseSettings) *Ridged {
+	return &Ridged{settings, newGradientNoise(settings.Seed)}
+}
+
+// Height returns the noise at (x, z).
+func (r *Ridged) Height(x, z float64) float64 {
+	sum := 0.0
+	r.settings.octaves(x, z, func(x, z, amplitude float64) {
+		ridge := 1 - math.Abs(r.noise.Noise2D(x, z))
+		sum += ridge * ridge * amplitude
+	})
+	return sum
+}
+
+// DomainWarp is a HeightSource which samples another source at positions displaced by noise,
+// which bends its features into more natural, flowing shapes.
+type DomainWarp struct {
+	source       HeightSource
+	warpX, warpZ *FBM
+	strength     float64
+}
+
+// NewDomainWarp warps source by up to strength world units, with the displacement along each axis
+// given by fractional Brownian motion of the given settings.
+func NewDomainWarp(source HeightSource, warp NoiseSettings, strength float64) *DomainWarp {
+	warpZ := warp
+	warpZ.Seed++
+	return &DomainWarp{source, NewFBM(warp), NewFBM(warpZ), strength}
+}
+
+// Height returns the height of the source at the displaced position.
+func (d *DomainWarp) Height(x, z float64) float64 {
+	return d.source.Height(x+d.warpX.Height(x, z)*d.strength, z+d.warpZ.Height(x, z)*d.strength)
+}
+
+// DefaultHeightSource returns the rolling dunes the terrain has always had, 3 octaves of noise
+// scaled to heights between 0 and 50.
+func DefaultHeightSource(seed int64) HeightSource {
+	dunes := NewFBM(NoiseSettings{Seed: seed, Octaves: 3, Frequency: 0.01, Lacunarity: 2, Gain: 0.5})
+	return Add(Multiply(dunes, Constant(25)), Constant(25))
+}
@@ -0,0 +1,53 @@
+package terrain
+
+import (
+	"github.com/go-gl/mathgl/mgl32"
+)
+
+// calculateNormal returns the normal of the triangle pos1, pos2, pos3, scaled by twice its area.
+func calculateNormal(pos1, pos2, pos3 mgl32.Vec3) mgl32.Vec3 {
+	a := pos2.Sub(pos1)
+	b := pos3.Sub(pos1)
+	return a.Cross(b)
+}
+
+// gridNormal returns the normal of the vertex at (x, z) of a grid with unit spacing, averaged over the
+// triangles sharing the vertex. point returns the position of the vertex at (x, z), and the vertex must
+// have a neighbour on every side.
+func gridNormal(point func(x, z int32) mgl32.Vec3, x, z int32) mgl32.Vec3 {
+	v := point(x, z)
+	u := point(x, z+1)
+	d := point(x, z-1)
+	l := point(x-1, z)
+	r := point(x+1, z)
+	if x&1 == z&1 {
+		//   / | \
+		// / 1 | 2 \
+		// ----V----
+		// \ 3 | 4 /
+		//   \ | /
+		n1 := calculateNormal(l, u, v)
+		n2 := calculateNormal(u, r, v)
+		n3 := calculateNormal(r, d, v)
+		n4 := calculateNormal(d, l, v)
+		return n1.Add(n2).Add(n3).Add(n4).Normalize()
+	}
+	// \ 1 | 2 /
+	// 8 \ | / 3
+	// ----V----
+	// 7 / | \ 4
+	// / 6 | 5 \
+	ul := point(x-1, z+1)
+	ur := point(x+1, z+1)
+	dl := point(x-1, z-1)
+	dr := point(x+1, z-1)
+	n1 := calculateNormal(ul, u, v)
+	n2 := calculateNormal(u, ur, v)
+	n3 := calculateNormal(ur, r, v)
+	n4 := calculateNormal(r, dr, v)
+	n5 := calculateNormal(dr, d, v)
+	n6 := calculateNormal(d, dl, v)
+	n7 := calculateNormal(dl, l, v)
+	n8 := calculateNormal(l, ul, v)
+	return n1.Add(n2).Add(n3).Add(n4).Add(n5).Add(n6).Add(n7).Add(n8).Normalize()
+}
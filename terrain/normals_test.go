@@ -0,0 +1,46 @@
+package terrain
+
+import (
+	"testing"
+
+	"github.com/go-gl/mathgl/mgl32"
+	"github.com/stretchr/testify/assert"
+)
+
+// plane returns a point function for the plane y = a*x + b*z.
+func plane(a, b float32) func(x, z int32) mgl32.Vec3 {
+	return func(x, z int32) mgl32.Vec3 {
+		return mgl32.Vec3{float32(x), a*float32(x) + b*float32(z), float32(z)}
+	}
+}
+
+func TestGridNormalFlat(t *testing.T) {
+	for _, v := range [][2]int32{{2, 2}, {2, 3}, {3, 2}, {3, 3}} {
+		n := gridNormal(plane(0, 0), v[0], v[1])
+		assert.True(t, n.ApproxEqual(mgl32.Vec3{0, 1, 0}), "vertex %v: %v", v, n)
+	}
+}
+
+func TestGridNormalSlope(t *testing.T) {
+	for _, slope := range [][2]float32{{1, 0}, {0, 1}, {-.5, 2}, {3, -1}} {
+		want := mgl32.Vec3{-slope[0], 1, -slope[1]}.Normalize()
+		for _, v := range [][2]int32{{2, 2}, {2, 3}, {3, 2}, {3, 3}} {
+			n := gridNormal(plane(slope[0], slope[1]), v[0], v[1])
+			assert.True(t, n.ApproxEqualThreshold(want, 1e-5), "slope %v vertex %v: got %v, want %v", slope, v, n, want)
+		}
+	}
+}
+
+func TestGridNormalPeak(t *testing.T) {
+	// Both kinds of vertex on top of a symmetric peak point straight up.
+	for _, v := range [][2]int32{{3, 3}, {2, 3}} {
+		peak := func(x, z int32) mgl32.Vec3 {
+			if x == v[0] && z == v[1] {
+				return mgl32.Vec3{float32(x), 1, float32(z)}
+			}
+			return mgl32.Vec3{float32(x), 0, float32(z)}
+		}
+		n := gridNormal(peak, v[0], v[1])
+		assert.True(t, n.ApproxEqual(mgl32.Vec3{0, 1, 0}), "vertex %v: %v", v, n)
+	}
+}
@@ -0,0 +1,149 @@
+package terrain
+
+import (
+	"math"
+
+	"github.com/brandonnelson3/GoRender/gfx"
+	"github.com/go-gl/mathgl/mgl32"
+)
+
+// RaycastHit describes where a ray hit the terrain.
+type RaycastHit struct {
+	// Point is where the ray hit, Distance how far along the ray it is, and Normal the surface normal there.
+	Point    mgl32.Vec3
+	Normal   mgl32.Vec3
+	Distance float32
+	// CellX and CellZ identify the cell that was hit.
+	CellX, CellZ int32
+}
+
+// vertexHeight returns the height of the terrain vertex at the world grid point (x, z).
+func (t *Terrain) vertexHeight(x, z int32) float32 {
+	return t.GetHeight(float32(x), float32(z))
+}
+
+// vertex returns the position of the terrain vertex at the world grid point (x, z).
+func (t *Terrain) vertex(x, z int32) mgl32.Vec3 {
+	return mgl32.Vec3{float32(x), t.vertexHeight(x, z), float32(z)}
+}
+
+// worldQuadFlipped reports whether the quad at the world grid point (x, z) is split along its other diagonal,
+// like quadFlipped. World and cell parity agree since cells are an even size.
+func worldQuadFlipped(x, z int32) bool {
+	return quadFlipped(uint32(z&1), uint32(x&1))
+}
+
+// quadTriangle returns the corners, as offsets from (x0, z0), of the rendered triangle of the quad at (x0, z0)
+// containing the point (x0+fx, z0+fz), and the barycentric weight of each corner for that point.
+func quadTriangle(x0, z0 int32, fx, fz float32) ([3][2]int32, [3]float32) {
+	if !worldQuadFlipped(x0, z0) {
+		// Diagonal from (0, 1) to (1, 0).
+		if fx+fz <= 1 {
+			return [3][2]int32{{0, 0}, {1, 0}, {0, 1}}, [3]float32{1 - fx - fz, fx, fz}
+		}
+		return [3][2]int32{{1, 1}, {0, 1}, {1, 0}}, [3]float32{fx + fz - 1, 1 - fx, 1 - fz}
+	}
+	// Diagonal from (0, 0) to (1, 1).
+	if fx >= fz {
+		return [3][2]int32{{0, 0}, {1, 0}, {1, 1}}, [3]float32{1 - fx, fx - fz, fz}
+	}
+	return [3][2]int32{{0, 0}, {1, 1}, {0, 1}}, [3]float32{1 - fz, fx, fz - fx}
+}
+
+// HeightAt returns the height of the rendered terrain at (x, z), interpolated across the triangle containing it.
+// This matches the terrain drawn at full detail exactly, unlike GetHeight which samples the source.
+func (t *Terrain) HeightAt(x, z float32) float32 {
+	x0, z0 := int32(math.Floor(float64(x))), int32(math.Floor(float64(z)))
+	corners, weights := quadTriangle(x0, z0, x-float32(x0), z-float32(z0))
+	var h float32
+	for i, c := range corners {
+		h += weights[i] * t.vertexHeight(x0+c[0], z0+c[1])
+	}
+	return h
+}
+
+// NormalAt returns the normal of the rendered terrain at (x, z), interpolated across the triangle containing it
+// from the same vertex normals the terrain is shaded with.
+func (t *Terrain) NormalAt(x, z float32) mgl32.Vec3 {
+	x0, z0 := int32(math.Floor(float64(x))), int32(math.Floor(float64(z)))
+	corners, weights := quadTriangle(x0, z0, x-float32(x0), z-float32(z0))
+	var n mgl32.Vec3
+	for i, c := range corners {
+		n = n.Add(gridNormal(t.vertex, x0+c[0], z0+c[1]).Mul(weights[i]))
+	}
+	return n.Normalize()
+}
+
+// Raycast intersects the ray from origin along dir with the terrain drawn at full detail, and returns the nearest
+// hit within maxDist, which must be finite. It reports false if the ray doesn't hit the terrain within maxDist.
+func (t *Terrain) Raycast(origin, dir mgl32.Vec3, maxDist float32) (RaycastHit, bool) {
+	if dir.Len() == 0 {
+		return RaycastHit{}, false
+	}
+	dir = dir.Normalize()
+
+	// Walk the quads under the ray in order, as in a 2D DDA over the grid.
+	x, z := int32(math.Floor(float64(origin.X()))), int32(math.Floor(float64(origin.Z())))
+	stepX, nextX, deltaX := ddaAxis(origin.X(), dir.X())
+	stepZ, nextZ, deltaZ := ddaAxis(origin.Z(), dir.Z())
+	for {
+		// The ray is over this quad from entry to min(nextX, nextZ).
+		if hit, ok := t.raycastQuad(x, z, origin, dir, maxDist); ok {
+			return hit, true
+		}
+		if math.Min(nextX, nextZ) > float64(maxDist) {
+			return RaycastHit{}, false
+		}
+		if nextX < nextZ {
+			x += stepX
+			nextX += deltaX
+		} else {
+			z += stepZ
+			nextZ += deltaZ
+		}
+	}
+}
+
+// ddaAxis returns the direction to step along one axis of the grid, the distance along the ray to the first grid
+// line on that axis, and the distance between grid lines, for a ray starting at o with direction component d.
+func ddaAxis(o, d float32) (int32, float64, float64) {
+	if d == 0 {
+		return 0, math.Inf(1), math.Inf(1)
+	}
+	delta := math.Abs(1 / float64(d))
+	f := float64(o) - math.Floor(float64(o))
+	if d > 0 {
+		return 1, (1 - f) * delta, delta
+	}
+	return -1, f * delta, delta
+}
+
+// raycastQuad intersects the ray with both triangles of the quad at (x, z), returning the nearer hit.
+func (t *Terrain) raycastQuad(x, z int32, origin, dir mgl32.Vec3, maxDist float32) (RaycastHit, bool) {
+	p00, p10 := t.vertex(x, z), t.vertex(x+1, z)
+	p01, p11 := t.vertex(x, z+1), t.vertex(x+1, z+1)
+	var triangles [2][3]mgl32.Vec3
+	if !worldQuadFlipped(x, z) {
+		triangles = [2][3]mgl32.Vec3{{p00, p10, p01}, {p11, p01, p10}}
+	} else {
+		triangles = [2][3]mgl32.Vec3{{p00, p10, p11}, {p00, p11, p01}}
+	}
+
+	best := float32(math.MaxFloat32)
+	for _, tri := range triangles {
+		if d, ok := gfx.IntersectTriangle(origin, dir, tri[0], tri[1], tri[2]); ok && d <= maxDist && d < best {
+			best = d
+		}
+	}
+	if best == math.MaxFloat32 {
+		return RaycastHit{}, false
+	}
+	p := origin.Add(dir.Mul(best))
+	return RaycastHit{
+		Point:    p,
+		Normal:   t.NormalAt(p.X(), p.Z()),
+		Distance: best,
+		CellX:    int32(math.Floor(float64(p.X()) / float64(cellsize))),
+		CellZ:    int32(math.Floor(float64(p.Z()) / float64(cellsize))),
+	}, true
+}
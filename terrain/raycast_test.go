@@ -0,0 +1,109 @@
+package terrain
+
+import (
+	"math"
+	"math/rand"
+	"testing"
+
+	"github.com/go-gl/mathgl/mgl32"
+	"github.com/stretchr/testify/assert"
+)
+
+func newTestTerrain(source HeightSource) *Terrain {
+	return &Terrain{source: source}
+}
+
+// meshHeight returns the height at the local point (x, z) of the cell id by searching the full detail index
+// buffer for the triangle containing it.
+func meshHeight(t *Terrain, id cellId, x, z float32) (float32, bool) {
+	// Vertices are laid out like GenerateCell, x major.
+	vertex := func(i uint32) mgl32.Vec3 {
+		lx, lz := int32(i/uint32(cellsizep1)), int32(i%uint32(cellsizep1))
+		return mgl32.Vec3{float32(lx), t.vertexHeight(id.x*cellsize+lx, id.z*cellsize+lz), float32(lz)}
+	}
+	indices := lodIndices(0, 0)
+	for i := 0; i < len(indices); i += 3 {
+		a, b, c := vertex(indices[i]), vertex(indices[i+1]), vertex(indices[i+2])
+		// Barycentric coordinates of (x, z) in the triangle's projection onto the ground.
+		det := (b.Z()-c.Z())*(a.X()-c.X()) + (c.X()-b.X())*(a.Z()-c.Z())
+		wa := ((b.Z()-c.Z())*(x-c.X()) + (c.X()-b.X())*(z-c.Z())) / det
+		wb := ((c.Z()-a.Z())*(x-c.X()) + (a.X()-c.X())*(z-c.Z())) / det
+		wc := 1 - wa - wb
+		if wa >= 0 && wb >= 0 && wc >= 0 {
+			return wa*a.Y() + wb*b.Y() + wc*c.Y(), true
+		}
+	}
+	return 0, false
+}
+
+func TestHeightAtVertices(t *testing.T) {
+	terrain := newTestTerrain(DefaultHeightSource(0))
+	for _, p := range [][2]int32{{0, 0}, {3, 7}, {-5, 12}, {-130, -257}} {
+		x, z := float32(p[0]), float32(p[1])
+		assert.InDelta(t, terrain.GetHeight(x, z), terrain.HeightAt(x, z), 1e-4, "at %v", p)
+	}
+}
+
+func TestHeightAtMatchesMesh(t *testing.T) {
+	terrain := newTestTerrain(DefaultHeightSource(0))
+	r := rand.New(rand.NewSource(1))
+	for _, id := range []cellId{{0, 0}, {-1, -2}} {
+		for range 50 {
+			x, z := r.Float32()*float32(cellsize), r.Float32()*float32(cellsize)
+			want, ok := meshHeight(terrain, id, x, z)
+			assert.True(t, ok)
+			got := terrain.HeightAt(float32(id.x*cellsize)+x, float32(id.z*cellsize)+z)
+			assert.InDelta(t, want, got, 1e-3, "cell %v at (%v, %v)", id, x, z)
+		}
+	}
+}
+
+func TestNormalAtPlane(t *testing.T) {
+	terrain := newTestTerrain(HeightFunc(func(x, z float64) float64 {
+		return .5*x - .25*z
+	}))
+	want := mgl32.Vec3{-.5, 1, .25}.Normalize()
+	for _, p := range [][2]float32{{.2, .7}, {-3.5, 10.1}, {100.9, -4.4}} {
+		assert.True(t, terrain.NormalAt(p[0], p[1]).ApproxEqualThreshold(want, 1e-4), "at %v", p)
+	}
+}
+
+func TestRaycastStraightDown(t *testing.T) {
+	terrain := newTestTerrain(DefaultHeightSource(0))
+	for _, p := range [][2]float32{{10.3, 20.7}, {-64.5, 3.25}, {0, 0}} {
+		hit, ok := terrain.Raycast(mgl32.Vec3{p[0], 200, p[1]}, mgl32.Vec3{0, -1, 0}, 500)
+		assert.True(t, ok, "at %v", p)
+		assert.InDelta(t, terrain.HeightAt(p[0], p[1]), hit.Point.Y(), 1e-3)
+		assert.InDelta(t, 200-hit.Point.Y(), hit.Distance, 1e-3)
+		assert.True(t, hit.Normal.ApproxEqualThreshold(terrain.NormalAt(p[0], p[1]), 1e-4))
+	}
+}
+
+func TestRaycastSlanted(t *testing.T) {
+	terrain := newTestTerrain(DefaultHeightSource(0))
+	r := rand.New(rand.NewSource(2))
+	for range 50 {
+		origin := mgl32.Vec3{r.Float32()*400 - 200, 80, r.Float32()*400 - 200}
+		angle := r.Float64() * 2 * math.Pi
+		dir := mgl32.Vec3{float32(math.Cos(angle)), -r.Float32()*.5 - .05, float32(math.Sin(angle))}
+		hit, ok := terrain.Raycast(origin, dir, 5000)
+		assert.True(t, ok)
+		assert.InDelta(t, terrain.HeightAt(hit.Point.X(), hit.Point.Z()), hit.Point.Y(), 1e-2)
+		assert.InDelta(t, 0, origin.Add(dir.Normalize().Mul(hit.Distance)).Sub(hit.Point).Len(), 1e-3)
+		assert.Equal(t, int32(math.Floor(float64(hit.Point.X())/float64(cellsize))), hit.CellX)
+
+		// Nothing along the ray before the hit is below the terrain.
+		for d := float32(0); d < hit.Distance-.5; d += .5 {
+			p := origin.Add(dir.Normalize().Mul(d))
+			assert.Greater(t, p.Y(), terrain.HeightAt(p.X(), p.Z())-1e-2)
+		}
+	}
+}
+
+func TestRaycastMiss(t *testing.T) {
+	terrain := newTestTerrain(DefaultHeightSource(0))
+	_, ok := terrain.Raycast(mgl32.Vec3{0, 200, 0}, mgl32.Vec3{1, 1, 0}, 1000)
+	assert.False(t, ok)
+	_, ok = terrain.Raycast(mgl32.Vec3{0, 200, 0}, mgl32.Vec3{0, -1, 0}, 10)
+	assert.False(t, ok)
+}
@@ -0,0 +1,164 @@
+package terrain
+
+import (
+	"container/heap"
+	"context"
+	"math"
+	"math/rand"
+
+	"github.com/brandonnelson3/GoRender/gfx"
+	"github.com/go-gl/mathgl/mgl32"
+)
+
+// poissonCandidates is the number of candidates tried around each point by poissonDisk before giving up on it.
+const poissonCandidates = 30
+
+// ScatterRule describes how instances of a renderable are scattered over the terrain. Instances are spread
+// by Poisson-disk sampling, then kept only where the terrain matches the rule.
+type ScatterRule struct {
+	// Template is the renderable drawn at every instance. Its Scale and Rotation are applied to each instance.
+	Template *gfx.VAORenderable
+	// Spacing is the minimum distance between two instances.
+	Spacing float32
+	// MinHeight, MaxHeight and MaxSlope limit where instances are placed. Slope is 0 for flat ground and 1
+	// for a vertical face.
+	MinHeight, MaxHeight float32
+	MaxSlope             float32
+	// Mask is optional. Its value at a point is the chance in [0, 1] of keeping an instance there.
+	Mask HeightSource
+	// MinScale and MaxScale are the range each instance is randomly scaled within.
+	MinScale, MaxScale float32
+	// Seed selects the random placement, scale and yaw of the instances.
+	Seed int64
+}
+
+// AddScatter scatters instances of rule over the terrain, in every cell as it streams in. Cells already
+// generated are regenerated to include them.
+func (t *Terrain) AddScatter(rule ScatterRule) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.scatter = append(t.scatter[:len(t.scatter):len(t.scatter)], rule)
+
+	for id, c := range t.data {
+		c.release()
+		delete(t.data, id)
+	}
+	t.uploads = nil
+	for id, req := range t.pending {
+		req.cancel()
+		if req.index >= 0 {
+			heap.Remove(&t.queue, req.index)
+		}
+		delete(t.pending, id)
+	}
+	clear(t.failed)
+}
+
+// cellRand returns the random source for placing rule's instances in the cell id.
+func cellRand(rule ScatterRule, id cellId) *rand.Rand {
+	return rand.New(rand.NewSource(rule.Seed*1000003 ^ int64(id.x)*73856093 ^ int64(id.z)*19349663))
+}
+
+// poissonDisk returns points within the square from (0, 0) to (size, size), no two closer than spacing, using
+// Bridson's algorithm.
+func poissonDisk(r *rand.Rand, size, spacing float32) []mgl32.Vec2 {
+	if size <= 0 || spacing <= 0 {
+		return nil
+	}
+	// Each background grid square is small enough to hold at most one point.
+	squareSize := spacing / math.Sqrt2
+	n := int(math.Ceil(float64(size / squareSize)))
+	grid := make([]int, n*n)
+	for i := range grid {
+		grid[i] = -1
+	}
+	square := func(p mgl32.Vec2) (int, int) {
+		return min(int(p.X()/squareSize), n-1), min(int(p.Y()/squareSize), n-1)
+	}
+
+	var points []mgl32.Vec2
+	add := func(p mgl32.Vec2) {
+		x, y := square(p)
+		grid[y*n+x] = len(points)
+		points = append(points, p)
+	}
+	fits := func(p mgl32.Vec2) bool {
+		if p.X() < 0 || p.Y() < 0 || p.X() >= size || p.Y() >= size {
+			return false
+		}
+		x, y := square(p)
+		for gy := max(y-2, 0); gy <= min(y+2, n-1); gy++ {
+			for gx := max(x-2, 0); gx <= min(x+2, n-1); gx++ {
+				if i := grid[gy*n+gx]; i >= 0 && points[i].Sub(p).Len() < spacing {
+					return false
+				}
+			}
+		}
+		return true
+	}
+
+	add(mgl32.Vec2{r.Float32() * size, r.Float32() * size})
+	active := []int{0}
+	for len(active) > 0 {
+		a := r.Intn(len(active))
+		center := points[active[a]]
+		found := false
+		for range poissonCandidates {
+			angle := r.Float64() * 2 * math.Pi
+			dist := spacing * (1 + r.Float32())
+			p := center.Add(mgl32.Vec2{float32(math.Cos(angle)), float32(math.Sin(angle))}.Mul(dist))
+			if fits(p) {
+				add(p)
+				active = append(active, len(points)-1)
+				found = true
+				break
+			}
+		}
+		if !found {
+			active[a] = active[len(active)-1]
+			active = active[:len(active)-1]
+		}
+	}
+	return points
+}
+
+// scatterCell returns an instanced copy of the template of every rule with instances within the cell id. It
+// returns ctx's error if ctx is cancelled first.
+func (t *Terrain) scatterCell(ctx context.Context, rules []ScatterRule, id cellId) ([]*gfx.VAORenderable, error) {
+	var renderables []*gfx.VAORenderable
+	for _, rule := range rules {
+		if err := ctx.Err(); err != nil {
+			return nil, err
+		}
+		r := cellRand(rule, id)
+		// Points are kept half the spacing away from the cell's edges, so instances in neighbouring cells
+		// are never closer than the spacing either.
+		margin := rule.Spacing / 2
+		origin := mgl32.Vec2{float32(id.x*cellsize) + margin, float32(id.z*cellsize) + margin}
+		var transforms []mgl32.Mat4
+		for _, p := range poissonDisk(r, float32(cellsize)-rule.Spacing, rule.Spacing) {
+			x, z := origin.X()+p.X(), origin.Y()+p.Y()
+			// Every point draws the same random numbers whether or not it is kept, so rejections don't shift the rest.
+			keep, yaw, scale := r.Float64(), r.Float64()*2*math.Pi, r.Float32()
+			if rule.Mask != nil && keep >= rule.Mask.Height(float64(x), float64(z)) {
+				continue
+			}
+			h := t.HeightAt(x, z)
+			if h < rule.MinHeight || h > rule.MaxHeight || 1-t.NormalAt(x, z).Y() > rule.MaxSlope {
+				continue
+			}
+			s := rule.MinScale + (rule.MaxScale-rule.MinScale)*scale
+			m := mgl32.Translate3D(x, h, z).
+				Mul4(mgl32.HomogRotate3DY(float32(yaw))).
+				Mul4(mgl32.Scale3D(s, s, s)).
+				Mul4(rule.Template.Scale.Mul4(rule.Template.Rotation))
+			transforms = append(transforms, m)
+		}
+		if len(transforms) > 0 {
+			instances := rule.Template.Copy()
+			instances.SetInstanceTransforms(transforms)
+			renderables = append(renderables, instances)
+		}
+	}
+	return renderables, nil
+}
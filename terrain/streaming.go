@@ -0,0 +1,307 @@
+package terrain
+
+import (
+	"container/heap"
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"runtime"
+	"sort"
+
+	"github.com/brandonnelson3/GoRender/gfx"
+	"github.com/brandonnelson3/GoRender/gfx/shaders"
+	"github.com/go-gl/mathgl/mgl32"
+)
+
+// uploadsPerFrame is the most generated cells uploaded to the GPU by a single Update, nearest first.
+const uploadsPerFrame = 4
+
+// streamWorkers returns the number of goroutines generating cells.
+func streamWorkers() int {
+	return max(runtime.NumCPU()/2, 1)
+}
+
+// cellRequest is a cell waiting for, or being generated by, a worker.
+type cellRequest struct {
+	id cellId
+	// distance is the squared distance from the camera to the center of the cell.
+	distance float32
+	ctx      context.Context
+	cancel   context.CancelFunc
+	// index is the position of the request in the queue, or -1 once a worker has taken it.
+	index int
+	// cell is the generated cell, once it is waiting to be uploaded.
+	cell *cell
+	// dirty is set when the cell is edited after a worker has started generating it, so it has to be
+	// generated again.
+	dirty bool
+}
+
+// requestQueue is a heap of cell requests, nearest to the camera first.
+type requestQueue []*cellRequest
+
+func (q requestQueue) Len() int           { return len(q) }
+func (q requestQueue) Less(i, j int) bool { return q[i].distance < q[j].distance }
+
+func (q requestQueue) Swap(i, j int) {
+	q[i], q[j] = q[j], q[i]
+	q[i].index = i
+	q[j].index = j
+}
+
+func (q *requestQueue) Push(x any) {
+	r := x.(*cellRequest)
+	r.index = len(*q)
+	*q = append(*q, r)
+}
+
+func (q *requestQueue) Pop() any {
+	old := *q
+	r := old[len(old)-1]
+	old[len(old)-1] = nil
+	r.index = -1
+	*q = old[:len(old)-1]
+	return r
+}
+
+// cellDistance returns the squared horizontal distance from pos to the center of the cell id.
+func cellDistance(id cellId, pos mgl32.Vec3) float32 {
+	dx := float32(id.x*cellsize) + halfCell.X() - pos.X()
+	dz := float32(id.z*cellsize) + halfCell.Z() - pos.Z()
+	return dx*dx + dz*dz
+}
+
+// centroidCell returns the cell the world is centered on when it is generated around pos.
+func centroidCell(pos mgl32.Vec3) cellId {
+	// Positions are shifted by half a cell from cell positions since cell positions are in the lower left corner.
+	pos = pos.Sub(halfCell)
+	return cellId{int32(pos.X()) / cellsize, int32(pos.Z()) / cellsize}
+}
+
+// worker generates requested cells, nearest first, until the terrain is closed.
+func (t *Terrain) worker() {
+	defer t.workers.Done()
+	for {
+		t.mu.Lock()
+		for len(t.queue) == 0 && t.ctx.Err() == nil {
+			t.wake.Wait()
+		}
+		if t.ctx.Err() != nil {
+			t.mu.Unlock()
+			return
+		}
+		req := heap.Pop(&t.queue).(*cellRequest)
+		rules := t.scatter
+		tess := t.tessellation
+		t.mu.Unlock()
+
+		c, err := t.GenerateCell(req.ctx, req.id)
+		if err == nil {
+			c.instances, err = t.scatterCell(req.ctx, rules, req.id)
+		}
+		if err == nil && tess != nil {
+			err = t.tessellateCell(req.ctx, c, tess)
+		}
+
+		t.mu.Lock()
+		// The request is only still current if it wasn't cancelled while the cell was generated. It stays
+		// pending until the cell is uploaded, unless generating it failed.
+		switch {
+		case req.ctx.Err() != nil:
+		case err != nil:
+			err = fmt.Errorf("failed to generate terrain cell %v: %w", req.id, err)
+			log.Println(err)
+			t.failed[req.id] = err
+			delete(t.pending, req.id)
+			req.cancel()
+		default:
+			req.cell = c
+			t.uploads = append(t.uploads, req)
+		}
+		t.ready.Broadcast()
+		t.mu.Unlock()
+	}
+}
+
+// request queues the cell id to be generated. Must be called with t.mu held.
+func (t *Terrain) request(id cellId) {
+	ctx, cancel := context.WithCancel(t.ctx)
+	req := &cellRequest{id: id, ctx: ctx, cancel: cancel}
+	t.pending[id] = req
+	heap.Push(&t.queue, req)
+	t.wake.Signal()
+}
+
+// regenerate generates the cells id again, for instance after they were edited. Cells keep being drawn as they
+// were until they have been regenerated. Must be called with t.mu held.
+func (t *Terrain) regenerate(ids []cellId) {
+	for _, id := range ids {
+		if _, ok := t.failed[id]; ok {
+			delete(t.failed, id)
+			t.request(id)
+			continue
+		}
+		if req, ok := t.pending[id]; ok {
+			// A queued request will see the edits, but one already being generated may not.
+			if req.index < 0 {
+				req.dirty = true
+			}
+			continue
+		}
+		if _, ok := t.data[id]; ok {
+			t.request(id)
+		}
+	}
+}
+
+// regenerateAll generates every cell again. Must be called with t.mu held.
+func (t *Terrain) regenerateAll() {
+	ids := make([]cellId, 0, len(t.data)+len(t.pending)+len(t.failed))
+	for id := range t.data {
+		ids = append(ids, id)
+	}
+	for id := range t.pending {
+		ids = append(ids, id)
+	}
+	for id := range t.failed {
+		ids = append(ids, id)
+	}
+	t.regenerate(ids)
+}
+
+// stream evicts cells that have left the world around centroid, cancels requests for them, and requests every
+// missing cell. Must be called with t.mu held.
+func (t *Terrain) stream(centroid cellId, pos mgl32.Vec3) {
+	for id, c := range t.data {
+		if !isCellInWorld(id, centroid) {
+			c.release()
+			delete(t.data, id)
+		}
+	}
+	for id, req := range t.pending {
+		if !isCellInWorld(id, centroid) {
+			req.cancel()
+			if req.index >= 0 {
+				heap.Remove(&t.queue, req.index)
+			}
+			delete(t.pending, id)
+		}
+	}
+	for id := range t.failed {
+		if !isCellInWorld(id, centroid) {
+			delete(t.failed, id)
+		}
+	}
+
+	for x := centroid.x - worldSizem1; x <= centroid.x+worldSize; x++ {
+		for z := centroid.z - worldSizem1; z <= centroid.z+worldSize; z++ {
+			id := cellId{x, z}
+			if _, ok := t.data[id]; ok {
+				continue
+			}
+			if _, ok := t.pending[id]; ok {
+				continue
+			}
+			if _, ok := t.failed[id]; ok {
+				continue
+			}
+			t.request(id)
+		}
+	}
+
+	// The camera has moved since the queue was ordered, so order it again.
+	for _, req := range t.queue {
+		req.distance = cellDistance(req.id, pos)
+	}
+	heap.Init(&t.queue)
+}
+
+// upload uploads the generated cells nearest to pos to the GPU, at most limit of them, replacing any earlier
+// version of them. Must be called with t.mu held.
+func (t *Terrain) upload(colorShader *shaders.ColorShader, pos mgl32.Vec3, limit int) {
+	// Cells whose request was cancelled before they were uploaded are dropped.
+	waiting := t.uploads[:0]
+	for _, req := range t.uploads {
+		if t.pending[req.id] == req {
+			waiting = append(waiting, req)
+		}
+	}
+	sort.Slice(waiting, func(i, j int) bool {
+		return cellDistance(waiting[i].id, pos) < cellDistance(waiting[j].id, pos)
+	})
+	n := min(len(waiting), limit)
+	for _, req := range waiting[:n] {
+		req.cell.upload(colorShader)
+		if old, ok := t.data[req.id]; ok {
+			old.release()
+		}
+		t.data[req.id] = req.cell
+		delete(t.pending, req.id)
+		req.cancel()
+		if req.dirty {
+			t.request(req.id)
+		}
+	}
+	t.uploads = append(waiting[:0], waiting[n:]...)
+}
+
+// GenerateAround generates and uploads every cell in range of position, blocking until they are all ready, so
+// a frame drawn from position includes the whole terrain. Cells already generated are kept, and cells which
+// failed to generate before are tried again. It returns an error if the terrain is closed first, or if any cell
+// fails to generate, once the rest are uploaded. Must be called from the thread owning the GL context.
+func (t *Terrain) GenerateAround(position mgl32.Vec3) error {
+	colorShader := gfx.Renderer.ColorShader()
+
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	clear(t.failed)
+	t.stream(centroidCell(position), position)
+	for {
+		if err := t.ctx.Err(); err != nil {
+			return err
+		}
+		t.upload(colorShader, position, len(t.uploads))
+		if len(t.pending) == 0 {
+			return t.failure(position)
+		}
+		t.ready.Wait()
+	}
+}
+
+// failure returns the errors of every cell which failed to generate, nearest to pos first, or nil if none did.
+// Must be called with t.mu held.
+func (t *Terrain) failure(pos mgl32.Vec3) error {
+	ids := make([]cellId, 0, len(t.failed))
+	for id := range t.failed {
+		ids = append(ids, id)
+	}
+	sort.Slice(ids, func(i, j int) bool {
+		return cellDistance(ids[i], pos) < cellDistance(ids[j], pos)
+	})
+	errs := make([]error, len(ids))
+	for i, id := range ids {
+		errs[i] = t.failed[id]
+	}
+	return errors.Join(errs...)
+}
+
+// Close stops generating cells, and deletes the GPU resources of the terrain. Must be called from the thread
+// owning the GL context.
+func (t *Terrain) Close() {
+	t.mu.Lock()
+	t.cancel()
+	t.wake.Broadcast()
+	t.ready.Broadcast()
+	t.mu.Unlock()
+	t.workers.Wait()
+
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	for id, c := range t.data {
+		c.release()
+		delete(t.data, id)
+	}
+	t.uploads = nil
+	t.releaseLodMeshes()
+}
@@ -1,15 +1,20 @@
 package terrain
 
 import (
+	"context"
+	"fmt"
+	"image/color"
+	"math"
 	"sync"
 	"time"
 
+	"github.com/brandonnelson3/GoRender/benchmark"
 	"github.com/brandonnelson3/GoRender/gfx"
 	"github.com/brandonnelson3/GoRender/gfx/shaders"
+	"github.com/brandonnelson3/GoRender/gfx/uniforms"
+	"github.com/brandonnelson3/GoRender/messagebus"
 	"github.com/go-gl/gl/v4.5-core/gl"
 	"github.com/go-gl/mathgl/mgl32"
-
-	perlin "github.com/aquilax/go-perlin"
 )
 
 const (
@@ -19,6 +24,11 @@ const (
 
 	worldSize   = 6
 	worldSizem1 = worldSize - 1
+
+	// lodMorphAttrib is the vertex attribute location of the per vertex lod morph targets.
+	lodMorphAttrib = 7
+	// terrainPaintAttrib is the vertex attribute location of the per vertex painted layer weights.
+	terrainPaintAttrib = 8
 )
 
 var (
@@ -34,16 +44,33 @@ func (lhs *cellId) Equal(rhs cellId) bool {
 }
 
 type cell struct {
-	id cellId
-
-	vao, vbo, veb uint32
-	numIndices    int32
-
-	verts   []gfx.Vertex
-	indices []uint32
+	id      cellId
+	terrain *Terrain
+
+	vao, vbo, mbo, pbo uint32
+
+	verts []gfx.Vertex
+	// morph holds the level of detail morph target of each vertex, see lodMorphTargets.
+	morph []mgl32.Vec2
+	// paint holds the painted layer weights of each vertex, and is nil if the cell isn't painted.
+	paint []mgl32.Vec4
+	// heights holds the height map the cell is displaced by when tessellated, see tessellateCell, and
+	// heightMap its texture.
+	heights   []float32
+	heightMap uint32
+	// instances holds the objects scattered over the cell, see ScatterRule.
+	instances []*gfx.VAORenderable
+
+	// minHeight and maxHeight are the lowest and highest vertex of the cell.
+	minHeight, maxHeight float32
+
+	// lod and stitch select the shared index buffer the cell is currently drawn with.
+	lod    int
+	stitch stitchMask
 }
 
-func (c *cell) Update(colorShader *shaders.ColorShader) {
+// upload creates the cell's GL buffers.
+func (c *cell) upload(colorShader *shaders.ColorShader) {
 	if c.vao == 0 {
 		gl.GenVertexArrays(1, &c.vao)
 		gl.BindVertexArray(c.vao)
@@ -51,80 +78,216 @@ func (c *cell) Update(colorShader *shaders.ColorShader) {
 		gl.BindBuffer(gl.ARRAY_BUFFER, c.vbo)
 		gl.BufferData(gl.ARRAY_BUFFER, len(c.verts)*8*4, gl.Ptr(c.verts), gl.STATIC_DRAW)
 		gfx.BindVertexAttributes(colorShader.Program())
-		gl.GenBuffers(1, &c.veb)
-		gl.BindBuffer(gl.ELEMENT_ARRAY_BUFFER, c.veb)
-		gl.BufferData(gl.ELEMENT_ARRAY_BUFFER, len(c.indices)*4, gl.Ptr(c.indices), gl.STATIC_DRAW)
+		gl.GenBuffers(1, &c.mbo)
+		gl.BindBuffer(gl.ARRAY_BUFFER, c.mbo)
+		gl.BufferData(gl.ARRAY_BUFFER, len(c.morph)*2*4, gl.Ptr(c.morph), gl.STATIC_DRAW)
+		gl.EnableVertexAttribArray(lodMorphAttrib)
+		gl.VertexAttribPointer(lodMorphAttrib, 2, gl.FLOAT, false, 2*4, gl.PtrOffset(0))
+		if c.paint != nil {
+			gl.GenBuffers(1, &c.pbo)
+			gl.BindBuffer(gl.ARRAY_BUFFER, c.pbo)
+			gl.BufferData(gl.ARRAY_BUFFER, len(c.paint)*4*4, gl.Ptr(c.paint), gl.STATIC_DRAW)
+			gl.EnableVertexAttribArray(terrainPaintAttrib)
+			gl.VertexAttribPointer(terrainPaintAttrib, 4, gl.FLOAT, false, 4*4, gl.PtrOffset(0))
+		}
 		gl.BindVertexArray(0)
+		if c.heights != nil {
+			c.uploadHeightMap()
+		}
 	}
 }
 
-func (c *cell) Render(colorShader *shaders.ColorShader) {
+// release deletes the cell's GL buffers.
+func (c *cell) release() {
 	if c.vao != 0 {
-		gl.BindVertexArray(c.vao)
-		colorShader.Model.Set(mgl32.Translate3D(float32(c.id.x*cellsize), 0, float32(c.id.z*cellsize)))
-		gl.DrawElements(gl.TRIANGLES, c.numIndices, gl.UNSIGNED_INT, nil)
-		gl.BindVertexArray(0)
+		gl.DeleteVertexArrays(1, &c.vao)
+		gl.DeleteBuffers(1, &c.vbo)
+		gl.DeleteBuffers(1, &c.mbo)
+		if c.pbo != 0 {
+			gl.DeleteBuffers(1, &c.pbo)
+		}
+		c.vao, c.vbo, c.mbo, c.pbo = 0, 0, 0, 0
+	}
+	if c.heightMap != 0 {
+		gl.DeleteTextures(1, &c.heightMap)
+		c.heightMap = 0
+	}
+	for _, r := range c.instances {
+		r.ReleaseInstances()
 	}
 }
 
-func (c *cell) RenderDepth(depthShader *shaders.DepthShader) {
-	if c.vao != 0 {
-		gl.BindVertexArray(c.vao)
-		depthShader.Model.Set(mgl32.Translate3D(float32(c.id.x*cellsize), 0, float32(c.id.z*cellsize)))
-		gl.DrawElements(gl.TRIANGLES, c.numIndices, gl.UNSIGNED_INT, nil)
-		gl.BindVertexArray(0)
+func (c *cell) model() mgl32.Mat4 {
+	return mgl32.Translate3D(float32(c.id.x*cellsize), 0, float32(c.id.z*cellsize))
+}
+
+// isVisible reports whether the cell has been uploaded and is inside frustum, if there is one.
+func (c *cell) isVisible(frustum *gfx.Frustum) bool {
+	if c.vao == 0 {
+		return false
+	}
+	if frustum != nil {
+		min, max := c.GetBounds()
+		return frustum.IsBoxIn(min, max)
 	}
+	return true
 }
 
-func (c *cell) RenderPointLightDepth(shader *shaders.PointLightShadowShader) {
-	if c.vao != 0 {
-		gl.BindVertexArray(c.vao)
-		shader.Model.Set(mgl32.Translate3D(float32(c.id.x*cellsize), 0, float32(c.id.z*cellsize)))
-		gl.DrawElements(gl.TRIANGLES, c.numIndices, gl.UNSIGNED_INT, nil)
-		gl.BindVertexArray(0)
+// draw draws the cell at its current level of detail as mode, either triangles or patches to tessellate, and
+// returns the number of triangles drawn before tessellation.
+func (c *cell) draw(model *uniforms.Matrix4, mode uint32) int {
+	mesh := c.terrain.getLodMesh(c.lod, c.stitch)
+	// Cells without paint read the default value of the paint attribute instead.
+	gl.VertexAttrib4f(terrainPaintAttrib, 0, 0, 0, 0)
+	gl.BindVertexArray(c.vao)
+	gl.BindBuffer(gl.ELEMENT_ARRAY_BUFFER, mesh.ebo)
+	model.Set(c.model())
+	if mode == gl.PATCHES {
+		gl.PatchParameteri(gl.PATCH_VERTICES, 3)
 	}
+	gl.DrawElements(mode, mesh.count, gl.UNSIGNED_INT, nil)
+	gl.BindVertexArray(0)
+	return int(mesh.count) / 3
+}
+
+func (c *cell) Render(colorShader *shaders.ColorShader, frustum *gfx.Frustum) {
+	if !c.isVisible(frustum) {
+		return
+	}
+	if c.tessellated() {
+		c.renderTessellated(colorShader)
+		return
+	}
+	c.terrain.material.bind(colorShader)
+	setLodMorph(colorShader.LodMorphEnabled, colorShader.LodCenter, colorShader.LodBaseDistance)
+	triangles := c.draw(colorShader.Model, gl.TRIANGLES)
+	if gfx.InMainColorPass() {
+		c.terrain.drawnTriangles += triangles
+	}
+	colorShader.LodMorphEnabled.Set(0)
+	colorShader.TerrainMaterialEnabled.Set(0)
+}
+
+func (c *cell) RenderDepth(depthShader *shaders.DepthShader, frustum *gfx.Frustum) {
+	if !c.isVisible(frustum) {
+		return
+	}
+	if c.tessellated() {
+		c.renderTessellatedDepth(depthShader)
+		return
+	}
+	depthShader.Diffuse.Set(gl.TEXTURE0, 0, c.terrain.opaque)
+	setLodMorph(depthShader.LodMorphEnabled, depthShader.LodCenter, depthShader.LodBaseDistance)
+	c.draw(depthShader.Model, gl.TRIANGLES)
+	depthShader.LodMorphEnabled.Set(0)
+}
+
+func (c *cell) RenderPointLightDepth(shader *shaders.PointLightShadowShader, frustum *gfx.Frustum) {
+	if !c.isVisible(frustum) {
+		return
+	}
+	shader.Diffuse.Set(gl.TEXTURE0, 0, c.terrain.opaque)
+	setLodMorph(shader.LodMorphEnabled, shader.LodCenter, shader.LodBaseDistance)
+	c.draw(shader.Model, gl.TRIANGLES)
+	shader.LodMorphEnabled.Set(0)
 }
 
 func (c *cell) GetBounds() (mgl32.Vec3, mgl32.Vec3) {
-	min := mgl32.Vec3{float32(c.id.x * cellsize), 0, float32(c.id.z * cellsize)}
-	max := mgl32.Vec3{float32((c.id.x + 1) * cellsize), 50, float32((c.id.z + 1) * cellsize)}
+	min := mgl32.Vec3{float32(c.id.x * cellsize), c.minHeight, float32(c.id.z * cellsize)}
+	max := mgl32.Vec3{float32((c.id.x + 1) * cellsize), c.maxHeight, float32((c.id.z + 1) * cellsize)}
 	return min, max
 }
 
+// IntersectRay intersects the ray with the terrain drawn at full detail over the cell, so the cell can be picked.
+func (c *cell) IntersectRay(origin, direction mgl32.Vec3, maxDistance float32) (float32, mgl32.Vec3, bool) {
+	hit, ok := c.terrain.Raycast(origin, direction, maxDistance)
+	if !ok || hit.CellX != c.id.x || hit.CellZ != c.id.z {
+		return 0, mgl32.Vec3{}, false
+	}
+	return hit.Distance, hit.Normal, true
+}
+
 type Terrain struct {
 	mu   sync.Mutex
 	data map[cellId]*cell
 
-	noise *perlin.Perlin
-
-	diffuse uint32
+	// ctx is cancelled by Close, which stops the workers.
+	ctx     context.Context
+	cancel  context.CancelFunc
+	workers sync.WaitGroup
+	// wake is signalled when cells are queued, or the terrain is closed.
+	wake *sync.Cond
+	// ready is broadcast when a worker finishes a cell, or the terrain is closed.
+	ready *sync.Cond
+	// queue holds the cells waiting for a worker, and pending every cell queued or being generated.
+	queue   requestQueue
+	pending map[cellId]*cellRequest
+	// failed holds why cells couldn't be generated. They aren't requested again until they are regenerated, or
+	// GenerateAround is called.
+	failed map[cellId]error
+	// uploads holds the generated cells waiting to be uploaded to the GPU.
+	uploads []*cellRequest
+
+	source HeightSource
+	// edits holds the changes made to the source by brushes.
+	edits editLayer
+	// scatter holds the rules objects are scattered over every cell by.
+	scatter []ScatterRule
+	// tessellation is nil unless the terrain is subdivided on the GPU.
+	tessellation *tessellation
+
+	material *material
+	// opaque is a plain white texture bound for the depth passes, which discard transparent texels.
+	opaque uint32
+
+	lodMeshes map[lodMeshKey]lodMesh
+	// drawnTriangles counts the triangles drawn by the main color pass since the last call to Parts, and
+	// visibleTriangles holds the count for the frame before it.
+	drawnTriangles   int
+	visibleTriangles int
 }
 
-func NewTerrain() *Terrain {
-	diffuseTexture, err := gfx.LoadTexture("assets/sand.png")
+// NewTerrain instantiates a terrain generated from source around the culling camera. Cells are generated
+// in the background until Close is called. It returns an error if the default material can't be loaded.
+func NewTerrain(source HeightSource) (*Terrain, error) {
+	material, err := loadMaterial(DefaultMaterial())
 	if err != nil {
-		panic(err)
+		return nil, err
 	}
 
+	ctx, cancel := context.WithCancel(context.Background())
 	t := &Terrain{
-		data:    make(map[cellId]*cell),
-		noise:   perlin.NewPerlin(2, 2, 3, int64(0)),
-		diffuse: diffuseTexture,
+		data:      make(map[cellId]*cell),
+		ctx:       ctx,
+		cancel:    cancel,
+		pending:   make(map[cellId]*cellRequest),
+		failed:    make(map[cellId]error),
+		source:    source,
+		material:  material,
+		opaque:    gfx.NewSolidTexture(color.RGBA{255, 255, 255, 255}),
+		lodMeshes: make(map[lodMeshKey]lodMesh),
 	}
+	t.wake = sync.NewCond(&t.mu)
+	t.ready = sync.NewCond(&t.mu)
 
-	for x := int32(-worldSize); x <= worldSize; x++ {
-		for z := int32(-worldSize); z <= worldSize; z++ {
-			go t.generate(x, z)
-		}
+	for range streamWorkers() {
+		t.workers.Add(1)
+		go t.worker()
 	}
 
-	return t
+	go t.updateConsoleOnTimer()
+
+	return t, nil
 }
 
-func calculateNormal(pos1, pos2, pos3 mgl32.Vec3) mgl32.Vec3 {
-	a := pos2.Sub(pos1)
-	b := pos3.Sub(pos1)
-	return a.Cross(b)
+// SetMaterial replaces the material the terrain is textured with.
+func (t *Terrain) SetMaterial(m Material) error {
+	material, err := loadMaterial(m)
+	if err != nil {
+		return err
+	}
+	t.material = material
+	return nil
 }
 
 func calculateIndice(x, z uint32) uint32 {
@@ -148,188 +311,182 @@ func isCellInWorld(cell, centroidCell cellId) bool {
 	return true
 }
 
-func (t *Terrain) GenerateCell(id cellId) *cell {
+// GenerateCell generates the cell id from the terrain's source. It returns ctx's error if ctx is cancelled first.
+func (t *Terrain) GenerateCell(ctx context.Context, id cellId) (*cell, error) {
 	var grid [cellsizep1p2][cellsizep1p2]mgl32.Vec3
 	for x := range cellsizep1p2 {
+		if err := ctx.Err(); err != nil {
+			return nil, err
+		}
 		for z := range cellsizep1p2 {
-			h := float32((t.noise.Noise2D(float64(id.x*cellsize+x)/100.0, float64(id.z*cellsize+z)/100.0)+1)/2.0) * 50
+			h := t.GetHeight(float32(id.x*cellsize+x), float32(id.z*cellsize+z))
 			grid[x][z] = mgl32.Vec3{float32(x), h, float32(z)}
 		}
 	}
 
+	point := func(x, z int32) mgl32.Vec3 {
+		return grid[x][z]
+	}
 	var verts []gfx.Vertex
+	var paint []mgl32.Vec4
+	painted := false
+	minHeight, maxHeight := float32(math.MaxFloat32), float32(-math.MaxFloat32)
 	for x := int32(1); x <= cellsizep1; x++ {
 		for z := int32(1); z <= cellsizep1; z++ {
-			n := mgl32.Vec3{}
 			v := grid[x][z]
-			u := grid[x][z+1]
-			d := grid[x][z-1]
-			l := grid[x-1][z]
-			r := grid[x+1][z]
-			if x%2 == 0 && z%2 == 0 || x%2 == 1 && z%2 == 1 {
-				//   / | \
-				// / 1 | 2 \
-				// ----V----
-				// \ 3 | 4 /
-				//   \ | /
-				n1 := calculateNormal(l, u, v)
-				n2 := calculateNormal(u, r, v)
-				n3 := calculateNormal(r, d, v)
-				n4 := calculateNormal(d, l, v)
-				n = n1.Add(n2).Add(n3).Add(n4)
-			} else {
-				// \ 1 | 2 /
-				// 8 \ | / 3
-				// ----V----
-				// 7 / | \ 4
-				// / 6 | 5 \
-				ul := grid[x-1][z+1]
-				ur := grid[x+1][z+1]
-				dl := grid[x-1][z-1]
-				dr := grid[x+1][z-1]
-				n1 := calculateNormal(ul, u, v)
-				n2 := calculateNormal(u, ur, v)
-				n3 := calculateNormal(ur, u, v)
-				n4 := calculateNormal(r, dr, v)
-				n5 := calculateNormal(dr, d, v)
-				n6 := calculateNormal(d, dl, v)
-				n7 := calculateNormal(dl, l, v)
-				n8 := calculateNormal(l, ul, v)
-				n = n1.Add(n2).Add(n3).Add(n4).Add(n5).Add(n6).Add(n7).Add(n8)
-			}
-			verts = append(verts, gfx.Vertex{Vert: grid[x][z], Norm: n.Normalize(), UV: mgl32.Vec2{float32(x) / 5.0, float32(z) / 5.0}})
-		}
-	}
-
-	var indices []uint32
-	for z := range uint32(cellsize) {
-		for x := range uint32(cellsize) {
-			i1 := calculateIndice(x, z)
-			i2 := calculateIndice(x+1, z)
-			i3 := calculateIndice(x, z+1)
-			i4 := calculateIndice(x+1, z+1)
-			if x%2 == 0 && z%2 == 0 || x%2 == 1 && z%2 == 1 {
-				// 1-----2
-				// |   / |
-				// | /   |
-				// 3-----4
-				indices = append(indices, i3, i1, i2, i2, i4, i3)
-			} else {
-				// 1-----2
-				// | \   |
-				// |   \ |
-				// 3-----4
-				indices = append(indices, i1, i2, i4, i4, i3, i1)
-			}
+			minHeight = min(minHeight, v.Y())
+			maxHeight = max(maxHeight, v.Y())
+			verts = append(verts, gfx.Vertex{Vert: v, Norm: gridNormal(point, x, z), UV: mgl32.Vec2{float32(x) / 5.0, float32(z) / 5.0}})
+			p := t.edits.paint(id.x*cellsize+x, id.z*cellsize+z)
+			painted = painted || p != mgl32.Vec4{}
+			paint = append(paint, p)
 		}
 	}
 
-	return &cell{
-		id:         id,
-		verts:      verts,
-		indices:    indices,
-		numIndices: int32(len(indices)),
+	if !painted {
+		paint = nil
 	}
-}
 
-func (t *Terrain) generate(x, z int32) {
-	lastCell := cellId{-1000000000, -1000000000}
-	for {
-		// No point in checking more often then every 100ms.
-		<-time.After(100 * time.Millisecond)
-
-		// Positions are shifted by half a cell from cell positions since cell positions are in the lower left corner.
-		pos := gfx.FirstPerson.GetPosition().Sub(halfCell)
-
-		// If this is the same cell as last iteration bail.
-		thisCell := cellId{int32(pos.X())/cellsize + x, int32(pos.Z())/cellsize + z}
-		if lastCell.Equal(thisCell) {
-			continue
-		}
-		lastCell = thisCell
-
-		// If this cell is already present in the world bail.
-		t.mu.Lock()
-		_, newOk := t.data[thisCell]
-		t.mu.Unlock()
-		if newOk {
-			continue
-		}
+	morph := lodMorphTargets(func(x, z uint32) float32 {
+		return grid[x+1][z+1].Y()
+	})
 
-		// This is a new cell not currently present in the world. Generate then insert.
-		c := t.GenerateCell(thisCell)
-		t.mu.Lock()
-		t.data[thisCell] = c
-		t.mu.Unlock()
-	}
+	return &cell{
+		id:        id,
+		terrain:   t,
+		verts:     verts,
+		morph:     morph,
+		paint:     paint,
+		minHeight: minHeight,
+		maxHeight: maxHeight,
+	}, nil
 }
 
+// GetHeight returns the height of the terrain's source at (x, z), with any edits made by brushes applied.
 func (t *Terrain) GetHeight(x, z float32) float32 {
-	return float32((t.noise.Noise2D(float64(x)/100.0, float64(z)/100.0)+1)/2.0) * 50
+	return float32(t.source.Height(float64(x), float64(z))) + t.edits.heightEdit(x, z)
 }
 
+// Update streams cells in and out around the culling camera, and uploads some of the generated cells to the GPU.
 func (t *Terrain) Update(colorShader *shaders.ColorShader) {
-	pos := gfx.FirstPerson.GetPosition()
-	pos = pos.Sub(halfCell)
-	centroidCell := cellId{int32(pos.X()) / cellsize, int32(pos.Z()) / cellsize}
+	pos := gfx.CullingCamera.GetPosition()
+
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.stream(centroidCell(pos), pos)
+	t.upload(colorShader, pos, uploadsPerFrame)
+}
 
+// Parts returns every cell of the terrain, so the renderer culls and shadows them individually. It also
+// picks the level of detail every cell is drawn at this frame.
+func (t *Terrain) Parts() []gfx.Renderable {
 	t.mu.Lock()
 	defer t.mu.Unlock()
+	t.selectLevels(lodCenter())
+	t.visibleTriangles = t.drawnTriangles
+	t.drawnTriangles = 0
+
+	parts := make([]gfx.Renderable, 0, len(t.data))
 	for _, c := range t.data {
-		if !isCellInWorld(c.id, centroidCell) {
-			delete(t.data, c.id)
-			continue
+		// Cells still waiting to be uploaded aren't drawn yet.
+		if c.vao != 0 {
+			parts = append(parts, c)
+			for _, r := range c.instances {
+				parts = append(parts, r)
+			}
 		}
-		c.Update(colorShader)
 	}
+	return parts
 }
 
 func (t *Terrain) Render(colorShader *shaders.ColorShader, frustum *gfx.Frustum) {
-	colorShader.Diffuse.Set(gl.TEXTURE0, 0, t.diffuse)
-
 	t.mu.Lock()
 	defer t.mu.Unlock()
+	t.selectLevels(lodCenter())
 	for _, c := range t.data {
-		if frustum != nil {
-			min, max := c.GetBounds()
-			if !frustum.IsBoxIn(min, max) {
-				continue
+		c.Render(colorShader, frustum)
+		if c.vao != 0 {
+			for _, r := range c.instances {
+				r.Render(colorShader, frustum)
 			}
 		}
-		c.Render(colorShader)
 	}
 }
 
 func (t *Terrain) RenderDepth(depthShader *shaders.DepthShader, frustum *gfx.Frustum) {
-	depthShader.Diffuse.Set(gl.TEXTURE0, 0, t.diffuse)
-
 	t.mu.Lock()
 	defer t.mu.Unlock()
+	t.selectLevels(lodCenter())
 	for _, c := range t.data {
-		if frustum != nil {
-			min, max := c.GetBounds()
-			if !frustum.IsBoxIn(min, max) {
-				continue
+		c.RenderDepth(depthShader, frustum)
+		if c.vao != 0 {
+			for _, r := range c.instances {
+				r.RenderDepth(depthShader, frustum)
 			}
 		}
-		c.RenderDepth(depthShader)
 	}
 }
 
 func (t *Terrain) RenderPointLightDepth(shader *shaders.PointLightShadowShader, frustum *gfx.Frustum) {
-	shader.Diffuse.Set(gl.TEXTURE0, 0, t.diffuse)
-
 	t.mu.Lock()
 	defer t.mu.Unlock()
-
+	t.selectLevels(lodCenter())
 	for _, c := range t.data {
-		c.RenderPointLightDepth(shader)
+		c.RenderPointLightDepth(shader, frustum)
+		if c.vao != 0 {
+			for _, r := range c.instances {
+				r.RenderPointLightDepth(shader, frustum)
+			}
+		}
 	}
 }
 
+// GetBounds returns the box around every cell currently in the world.
 func (t *Terrain) GetBounds() (mgl32.Vec3, mgl32.Vec3) {
-	// The terrain as a whole is too large to cull effectively.
-	// We handle culling per-cell in the renderer's loop if possible,
-	// but for the Renderable interface, we return a massive box.
-	return mgl32.Vec3{-1e9, -1e9, -1e9}, mgl32.Vec3{1e9, 1e9, 1e9}
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if len(t.data) == 0 {
+		return mgl32.Vec3{}, mgl32.Vec3{}
+	}
+	lo := mgl32.Vec3{math.MaxFloat32, math.MaxFloat32, math.MaxFloat32}
+	hi := mgl32.Vec3{-math.MaxFloat32, -math.MaxFloat32, -math.MaxFloat32}
+	for _, c := range t.data {
+		cellMin, cellMax := c.GetBounds()
+		for i := range 3 {
+			lo[i] = min(lo[i], cellMin[i])
+			hi[i] = max(hi[i], cellMax[i])
+		}
+	}
+	return lo, hi
+}
+
+// setLodMorph enables level of detail morphing around lodCenter on a shader's uniforms.
+func setLodMorph(enabled *uniforms.Int, center *uniforms.Vector3, baseDistance *uniforms.Float) {
+	enabled.Set(1)
+	center.Set(lodCenter())
+	baseDistance.Set(lodBaseDistance)
+}
+
+// lodCenter returns the point levels of detail are chosen around, which like streaming follows the culling camera.
+func lodCenter() mgl32.Vec3 {
+	return gfx.CullingCamera.GetPosition()
+}
+
+func (t *Terrain) updateConsoleOnTimer() {
+	ticker := time.NewTicker(time.Millisecond * 100)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-t.ctx.Done():
+			return
+		case <-ticker.C:
+		}
+		if benchmark.RecordMode {
+			continue
+		}
+		t.mu.Lock()
+		triangles := t.visibleTriangles
+		t.mu.Unlock()
+		messagebus.SendAsync(&messagebus.Message{Type: "console", Data1: "terrain_triangles", Data2: fmt.Sprintf("%d", triangles)})
+	}
 }
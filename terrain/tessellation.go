@@ -0,0 +1,170 @@
+package terrain
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/brandonnelson3/GoRender/gfx"
+	"github.com/brandonnelson3/GoRender/gfx/shaders"
+	"github.com/go-gl/gl/v4.5-core/gl"
+)
+
+const (
+	// tessHeightResolution is the number of height map samples per world unit of a tessellated cell.
+	tessHeightResolution = 2
+	// tessHeightMapSize is the number of samples along each side of a cell's height map, which has one extra
+	// sample on every side so normals can be taken at its edges.
+	tessHeightMapSize = cellsize*tessHeightResolution + 1 + 2
+
+	// maxTessLevel is the most pieces the GPU can subdivide an edge into.
+	maxTessLevel = 64
+)
+
+// Tessellation describes how the terrain is subdivided and displaced on the GPU. The terrain keeps its shape
+// from the height source between vertices, so the source's detail finer than the grid is drawn. Point light
+// shadows, HeightAt and Raycast use the terrain without tessellation.
+type Tessellation struct {
+	// EdgeLength is the length in pixels triangle edges are subdivided into, and MaxLevel the most pieces an
+	// edge is subdivided into, up to 64.
+	EdgeLength float32
+	MaxLevel   float32
+
+	// DetailMap is an optional grayscale png tiled over the terrain every DetailSize world units. It raises the
+	// terrain by up to DetailAmplitude where it is white, and lowers it as far where it is black.
+	DetailMap       string
+	DetailSize      float32
+	DetailAmplitude float32
+}
+
+// DefaultTessellation returns tessellation subdividing edges into pieces about 8 pixels long.
+func DefaultTessellation() Tessellation {
+	return Tessellation{EdgeLength: 8, MaxLevel: 16}
+}
+
+// tessellation is a Tessellation with its detail map loaded.
+type tessellation struct {
+	Tessellation
+
+	detailMap uint32
+}
+
+// SetTessellation subdivides the terrain on the GPU as described by tess, or stops subdividing it if tess is
+// nil. Cells are drawn as they were until they have been regenerated. Must be called from the thread owning
+// the GL context.
+func (t *Terrain) SetTessellation(tess *Tessellation) error {
+	var loaded *tessellation
+	if tess != nil {
+		if tess.EdgeLength <= 0 {
+			return fmt.Errorf("terrain tessellation edge length must be greater than zero, got %v", tess.EdgeLength)
+		}
+		if tess.MaxLevel < 1 || tess.MaxLevel > maxTessLevel {
+			return fmt.Errorf("terrain tessellation max level must be within [1, %d], got %v", maxTessLevel, tess.MaxLevel)
+		}
+		loaded = &tessellation{Tessellation: *tess}
+		if tess.DetailMap != "" {
+			if tess.DetailSize <= 0 {
+				return fmt.Errorf("terrain tessellation detail size must be greater than zero, got %v", tess.DetailSize)
+			}
+			var err error
+			if loaded.detailMap, err = gfx.LoadTexture(tess.DetailMap); err != nil {
+				return err
+			}
+		}
+	}
+
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if t.tessellation != nil && t.tessellation.detailMap != 0 {
+		gl.DeleteTextures(1, &t.tessellation.detailMap)
+	}
+	t.tessellation = loaded
+	t.regenerateAll()
+	return nil
+}
+
+// tessellateCell samples the height map c is displaced by on the GPU, and widens its bounds to fit it. It returns
+// ctx's error if ctx is cancelled first.
+func (t *Terrain) tessellateCell(ctx context.Context, c *cell, tess *tessellation) error {
+	heights := make([]float32, tessHeightMapSize*tessHeightMapSize)
+	// Sample 1 along each side is the cell's first vertex.
+	x0 := float32(c.id.x*cellsize+1) - 1.0/tessHeightResolution
+	z0 := float32(c.id.z*cellsize+1) - 1.0/tessHeightResolution
+	for row := range tessHeightMapSize {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		for col := range tessHeightMapSize {
+			h := t.GetHeight(x0+float32(col)/tessHeightResolution, z0+float32(row)/tessHeightResolution)
+			heights[row*tessHeightMapSize+col] = h
+			c.minHeight = min(c.minHeight, h)
+			c.maxHeight = max(c.maxHeight, h)
+		}
+	}
+	if tess.detailMap != 0 {
+		c.minHeight -= tess.DetailAmplitude
+		c.maxHeight += tess.DetailAmplitude
+	}
+	c.heights = heights
+	return nil
+}
+
+// tessellated reports whether the cell is drawn subdivided on the GPU.
+func (c *cell) tessellated() bool {
+	return c.terrain.tessellation != nil && c.heightMap != 0
+}
+
+// uploadHeightMap creates the texture of the cell's height map.
+func (c *cell) uploadHeightMap() {
+	gl.GenTextures(1, &c.heightMap)
+	gl.ActiveTexture(gl.TEXTURE0)
+	gl.BindTexture(gl.TEXTURE_2D, c.heightMap)
+	gl.TexParameteri(gl.TEXTURE_2D, gl.TEXTURE_MIN_FILTER, gl.LINEAR)
+	gl.TexParameteri(gl.TEXTURE_2D, gl.TEXTURE_MAG_FILTER, gl.LINEAR)
+	gl.TexParameteri(gl.TEXTURE_2D, gl.TEXTURE_WRAP_S, gl.CLAMP_TO_EDGE)
+	gl.TexParameteri(gl.TEXTURE_2D, gl.TEXTURE_WRAP_T, gl.CLAMP_TO_EDGE)
+	gl.TexImage2D(gl.TEXTURE_2D, 0, gl.R32F, tessHeightMapSize, tessHeightMapSize, 0, gl.RED, gl.FLOAT, gl.Ptr(c.heights))
+	gl.BindTexture(gl.TEXTURE_2D, 0)
+}
+
+// bindTessellation sets the tessellation uniforms u up to draw the cell.
+func (c *cell) bindTessellation(u *shaders.TerrainTessellation) {
+	tess := c.terrain.tessellation
+	u.TessCameraPosition.Set(lodCenter())
+	u.TessProjectionScale.Set(float32(gfx.Window.Height) / 2 * gfx.CullingCamera.GetProjection().At(1, 1))
+	u.TessEdgeLength.Set(tess.EdgeLength)
+	u.TessMaxLevel.Set(tess.MaxLevel)
+	u.TerrainHeightMap.Set(gl.TEXTURE16, 16, c.heightMap)
+	u.TerrainHeightMapResolution.Set(tessHeightResolution)
+	u.TerrainHeightMapSize.Set(float32(tessHeightMapSize))
+	if tess.detailMap != 0 {
+		u.TerrainDetailEnabled.Set(1)
+		u.TerrainDetailMap.Set(gl.TEXTURE17, 17, tess.detailMap)
+		u.TerrainDetailScale.Set(1 / tess.DetailSize)
+		u.TerrainDetailAmplitude.Set(tess.DetailAmplitude)
+	} else {
+		u.TerrainDetailEnabled.Set(0)
+	}
+}
+
+// renderTessellated draws the cell subdivided on the GPU with the tessellated twin of colorShader.
+func (c *cell) renderTessellated(colorShader *shaders.ColorShader) {
+	s := colorShader.Tessellated
+	s.Use()
+	c.terrain.material.bind(s.ColorShader)
+	c.bindTessellation(&s.TerrainTessellation)
+	triangles := c.draw(s.Model, gl.PATCHES)
+	if gfx.InMainColorPass() {
+		c.terrain.drawnTriangles += triangles
+	}
+	colorShader.Use()
+}
+
+// renderTessellatedDepth draws the cell subdivided on the GPU with the tessellated twin of depthShader.
+func (c *cell) renderTessellatedDepth(depthShader *shaders.DepthShader) {
+	s := depthShader.Tessellated
+	s.Use()
+	s.Diffuse.Set(gl.TEXTURE0, 0, c.terrain.opaque)
+	c.bindTessellation(&s.TerrainTessellation)
+	c.draw(s.Model, gl.PATCHES)
+	depthShader.Use()
+}